@@ -0,0 +1,35 @@
+package mssql
+
+import "context"
+
+type appNameContextKey struct{}
+
+// AppNameContext returns a copy of ctx that makes connections opened with
+// it log in with the given application name instead of the app name of
+// the connection string. The name is reported by APP_NAME() and in
+// sys.dm_exec_sessions.
+//
+// The application name is sent when logging in, so it cannot be changed
+// on an open connection. A pooled connection logged in with a different
+// name is closed when it is taken from the pool with ctx, and a new one is
+// opened in its place. A pool shared by many application names therefore
+// opens connections much more often; use a separate *sql.DB for each name
+// if there are only a few of them. Take a *sql.Conn with ctx to run several
+// statements with the same name:
+//
+//	conn, err := db.Conn(mssql.AppNameContext(ctx, "tenant-a"))
+//
+// A connection opened while the pool is at its MaxOpenConns limit may be
+// handed to a waiting query without being checked and keep its name.
+func AppNameContext(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, appNameContextKey{}, name)
+}
+
+// appNameFromContext returns the application name attached to ctx,
+// or def if there is none.
+func appNameFromContext(ctx context.Context, def string) string {
+	if name, ok := ctx.Value(appNameContextKey{}).(string); ok {
+		return name
+	}
+	return def
+}
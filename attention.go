@@ -0,0 +1,30 @@
+package mssql
+
+// Attention aborts the statement running on the connection, if any, the
+// query or the exec waiting for the server or the rows being read. As when
+// the context of the statement is done, an attention is sent to the server
+// and the response is read until the server acknowledges it, the statement
+// then returns context.Canceled and the connection remains usable.
+//
+// Unlike the other methods of Conn, Attention may be called from another
+// goroutine while the connection runs a statement, to implement a timeout
+// of one's own. Get the connection through sql.Conn.Raw beforehand:
+//
+//	err = conn.Raw(func(driverConn interface{}) error {
+//		mc = driverConn.(*mssql.Conn)
+//		return nil
+//	})
+//	timer := time.AfterFunc(limit, mc.Attention)
+//	_, err = conn.ExecContext(ctx, query)
+//	timer.Stop()
+//
+// It has no effect on batches run with ExecBatch or ExecRaw, which are
+// cancelled with their context.
+func (c *Conn) Attention() {
+	c.attnMu.Lock()
+	cancel := c.attention
+	c.attnMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
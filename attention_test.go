@@ -0,0 +1,136 @@
+package mssql
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func TestAttention(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	received := make(chan packetType, 3)
+	go func() {
+		defer server.Close()
+		buf := newTdsBuffer(1024, server)
+		replies := [][]byte{
+			// the attention is acknowledged, the next statement completes
+			{byte(tokenDone), doneAttn, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			{byte(tokenDone), doneCount, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0},
+		}
+		for {
+			packet, err := buf.BeginRead()
+			if err != nil {
+				return
+			}
+			if _, err = io.ReadAll(buf); err != nil {
+				return
+			}
+			received <- packet
+			if packet == packSQLBatch && len(replies) == 2 {
+				// the first statement runs until the attention
+				continue
+			}
+			buf.BeginPacket(packReply, false)
+			if _, err = buf.Write(replies[0]); err != nil || buf.FinishPacket() != nil {
+				return
+			}
+			replies = replies[1:]
+		}
+	}()
+
+	c := testConn(newConnector(msdsn.Config{}, nil), client)
+	// no statement was run yet
+	c.Attention()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := (&Stmt{c: c, query: "waitfor delay '00:00:20'"}).exec(context.Background(), nil)
+		errs <- err
+	}()
+	if packet := <-received; packet != packSQLBatch {
+		t.Fatalf("expected the batch, got the packet %d", packet)
+	}
+	c.Attention()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if packet := <-received; packet != packAttention {
+		t.Errorf("expected an attention, got the packet %d", packet)
+	}
+	if !c.connectionGood {
+		t.Fatal("expected the connection to stay usable after the attention is acknowledged")
+	}
+
+	res, err := (&Stmt{c: c, query: "select 1"}).exec(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Errorf("expected the next statement to affect 1 row, got %d", n)
+	}
+	// the statement is done
+	c.Attention()
+}
+
+func TestAttentionWaitfor(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var mc *Conn
+	err = conn.Raw(func(driverConn interface{}) error {
+		mc = driverConn.(*Conn)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	timer := time.AfterFunc(500*time.Millisecond, mc.Attention)
+	_, err = conn.ExecContext(ctx, "waitfor delay '00:00:20'")
+	timer.Stop()
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the statement to be aborted, it took %v", elapsed)
+	}
+
+	timer = time.AfterFunc(500*time.Millisecond, mc.Attention)
+	rows, err := conn.QueryContext(ctx, "select 1; waitfor delay '00:00:20'; select 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	for rows.NextResultSet() {
+		for rows.Next() {
+		}
+	}
+	timer.Stop()
+	if err = rows.Err(); err != context.Canceled {
+		t.Errorf("expected the rows to fail with context.Canceled, got %v", err)
+	}
+	rows.Close()
+
+	// the connection is reused
+	var v int
+	if err = conn.QueryRowContext(ctx, "select 3").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v != 3 {
+		t.Errorf("expected 3, got %d", v)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build windows
+// +build windows
+
+package mssql
+
+import (
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/integratedauth"
+	"github.com/microsoft/go-mssqldb/integratedauth/winsspi"
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func TestDefaultIntegratedAuthProvider(t *testing.T) {
+	config, err := msdsn.Parse("sqlserver://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := integratedauth.GetIntegratedAuthenticator(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.(*winsspi.Auth); !ok {
+		t.Errorf("expected SSPI to authenticate a connection without a user, got %T", a)
+	}
+}
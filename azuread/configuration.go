@@ -10,7 +10,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
@@ -54,6 +56,11 @@ type azureFedAuthConfig struct {
 	user                string
 	password            string
 	applicationClientID string
+
+	// tokens are refreshed this long before they expire
+	tokenRefreshSkew time.Duration
+	// tokens caches the tokens of the connector, nil when not connecting
+	tokens *tokenCache
 }
 
 // parse returns a config based on an msdsn-style connection string
@@ -78,6 +85,10 @@ func parse(dsn string) (*azureFedAuthConfig, error) {
 func (p *azureFedAuthConfig) validateParameters(params map[string]string) error {
 
 	fedAuthWorkflow := params["fedauth"]
+	if fedAuthWorkflow == "" {
+		// authentication is the keyword used by the other SQL Server drivers
+		fedAuthWorkflow = params["authentication"]
+	}
 	if fedAuthWorkflow == "" {
 		return nil
 	}
@@ -86,6 +97,14 @@ func (p *azureFedAuthConfig) validateParameters(params map[string]string) error
 
 	p.applicationClientID = params["applicationclientid"]
 
+	if skew, ok := params["token refresh skew"]; ok {
+		seconds, err := strconv.ParseUint(skew, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid token refresh skew '%v': %v", skew, err)
+		}
+		p.tokenRefreshSkew = time.Duration(seconds) * time.Second
+	}
+
 	switch {
 	case strings.EqualFold(fedAuthWorkflow, ActiveDirectoryPassword):
 		if p.applicationClientID == "" {
@@ -148,6 +167,19 @@ func (p *azureFedAuthConfig) validateParameters(params map[string]string) error
 	return nil
 }
 
+// managedIdentityOptions returns the options selecting the user-assigned
+// managed identity, or nil for the system-assigned identity. The resource id
+// takes precedence over the client id given as the user id.
+func (p *azureFedAuthConfig) managedIdentityOptions() *azidentity.ManagedIdentityCredentialOptions {
+	switch {
+	case p.resourceID != "":
+		return &azidentity.ManagedIdentityCredentialOptions{ID: azidentity.ResourceID(p.resourceID)}
+	case p.clientID != "":
+		return &azidentity.ManagedIdentityCredentialOptions{ID: azidentity.ClientID(p.clientID)}
+	}
+	return nil
+}
+
 func splitTenantAndClientID(user string) (string, string) {
 	// Split the user name into client id and tenant id at the @ symbol
 	at := strings.IndexRune(user, '@')
@@ -166,8 +198,9 @@ func splitAuthorityAndTenant(authorityURL string) (string, string) {
 }
 
 func (p *azureFedAuthConfig) provideActiveDirectoryToken(ctx context.Context, serverSPN, stsURL string) (string, error) {
-	var cred azcore.TokenCredential
-	var err error
+	if p.fedAuthWorkflow == ActiveDirectoryServicePrincipalAccessToken {
+		return p.password, nil
+	}
 	authority, tenant := splitAuthorityAndTenant(stsURL)
 	// client secret connection strings may override the server tenant
 	if p.tenantID != "" {
@@ -177,7 +210,25 @@ func (p *azureFedAuthConfig) provideActiveDirectoryToken(ctx context.Context, se
 	if !strings.HasSuffix(serverSPN, scopeDefaultSuffix) {
 		scope = serverSPN + scopeDefaultSuffix
 	}
+	newToken := func() (azcore.AccessToken, error) {
+		cred, err := p.newCredential(authority, tenant)
+		if err != nil {
+			return azcore.AccessToken{}, err
+		}
+		return cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{scope}})
+	}
+	if p.tokens != nil {
+		return p.tokens.getToken(authority+"/"+tenant+" "+scope, mssql.TokenRefreshRequired(ctx), newToken)
+	}
+	tk, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	return tk.Token, nil
+}
 
+// newCredential returns the credential of the workflow for the tenant.
+func (p *azureFedAuthConfig) newCredential(authority, tenant string) (cred azcore.TokenCredential, err error) {
 	switch p.fedAuthWorkflow {
 	case ActiveDirectoryServicePrincipal, ActiveDirectoryApplication:
 		switch {
@@ -195,18 +246,10 @@ func (p *azureFedAuthConfig) provideActiveDirectoryToken(ctx context.Context, se
 		default:
 			cred, err = azidentity.NewClientSecretCredential(tenant, p.clientID, p.clientSecret, nil)
 		}
-	case ActiveDirectoryServicePrincipalAccessToken:
-		return p.password, nil
 	case ActiveDirectoryPassword:
 		cred, err = azidentity.NewUsernamePasswordCredential(tenant, p.applicationClientID, p.user, p.password, nil)
 	case ActiveDirectoryMSI, ActiveDirectoryManagedIdentity:
-		if p.resourceID != "" {
-			cred, err = azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{ID: azidentity.ResourceID(p.resourceID)})
-		} else if p.clientID != "" {
-			cred, err = azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{ID: azidentity.ClientID(p.clientID)})
-		} else {
-			cred, err = azidentity.NewManagedIdentityCredential(nil)
-		}
+		cred, err = azidentity.NewManagedIdentityCredential(p.managedIdentityOptions())
 	case ActiveDirectoryInteractive:
 		c := cloud.Configuration{ActiveDirectoryAuthorityHost: authority}
 		config := azcore.ClientOptions{Cloud: c}
@@ -221,13 +264,5 @@ func (p *azureFedAuthConfig) provideActiveDirectoryToken(ctx context.Context, se
 		cred, err = azidentity.NewDefaultAzureCredential(nil)
 	}
 
-	if err != nil {
-		return "", err
-	}
-	opts := policy.TokenRequestOptions{Scopes: []string{scope}}
-	tk, err := cred.GetToken(ctx, opts)
-	if err != nil {
-		return "", err
-	}
-	return tk.Token, err
+	return cred, err
 }
@@ -12,7 +12,9 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	mssql "github.com/microsoft/go-mssqldb"
 	"github.com/microsoft/go-mssqldb/msdsn"
 )
@@ -99,6 +101,29 @@ func TestValidateParameters(t *testing.T) {
 				fedAuthWorkflow: ActiveDirectoryManagedIdentity,
 			},
 		},
+		{
+			name: "managed identity with client id and authentication keyword",
+			dsn:  "sqlserver://identity-client-id@someserver.database.windows.net?authentication=ActiveDirectoryManagedIdentity",
+			expected: &azureFedAuthConfig{
+				adalWorkflow:    mssql.FedAuthADALWorkflowMSI,
+				clientID:        "identity-client-id",
+				fedAuthWorkflow: ActiveDirectoryManagedIdentity,
+			},
+		},
+		{
+			name: "managed identity with token refresh skew",
+			dsn:  "server=someserver.database.windows.net;fedauth=ActiveDirectoryManagedIdentity;token refresh skew=120",
+			expected: &azureFedAuthConfig{
+				adalWorkflow:     mssql.FedAuthADALWorkflowMSI,
+				fedAuthWorkflow:  ActiveDirectoryManagedIdentity,
+				tokenRefreshSkew: 2 * time.Minute,
+			},
+		},
+		{
+			name:     "invalid token refresh skew",
+			dsn:      "server=someserver.database.windows.net;fedauth=ActiveDirectoryManagedIdentity;token refresh skew=soon",
+			expected: nil,
+		},
 		{
 			name: "managed identity with resource id",
 			dsn:  "server=someserver.database.windows.net;fedauth=ActiveDirectoryManagedIdentity;resource id=/subscriptions/{guid}/resourceGroups/{resource-group-name}/{resource-provider-namespace}/{resource-type}/{resource-name}",
@@ -144,6 +169,28 @@ func TestValidateParameters(t *testing.T) {
 	}
 }
 
+func TestManagedIdentityOptions(t *testing.T) {
+	resourceID := "/subscriptions/{guid}/resourceGroups/{resource-group-name}/providers/Microsoft.ManagedIdentity/userAssignedIdentities/{name}"
+	tests := []struct {
+		dsn      string
+		expected *azidentity.ManagedIdentityCredentialOptions
+	}{
+		{"server=someserver;authentication=ActiveDirectoryManagedIdentity", nil},
+		{"server=someserver;fedauth=ActiveDirectoryMSI;user id=identity-client-id", &azidentity.ManagedIdentityCredentialOptions{ID: azidentity.ClientID("identity-client-id")}},
+		{"server=someserver;authentication=ActiveDirectoryManagedIdentity;user id=identity-client-id", &azidentity.ManagedIdentityCredentialOptions{ID: azidentity.ClientID("identity-client-id")}},
+		{"server=someserver;fedauth=ActiveDirectoryManagedIdentity;user id=identity-client-id;resource id=" + resourceID, &azidentity.ManagedIdentityCredentialOptions{ID: azidentity.ResourceID(resourceID)}},
+	}
+	for _, tst := range tests {
+		config, err := parse(tst.dsn)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing '%s': %v", tst.dsn, err)
+		}
+		if opts := config.managedIdentityOptions(); !reflect.DeepEqual(opts, tst.expected) {
+			t.Errorf("Unexpected managed identity options for '%s'. Expected:%+v, Actual:%+v", tst.dsn, tst.expected, opts)
+		}
+	}
+}
+
 func TestProvideActiveDirectoryTokenValidations(t *testing.T) {
 	nonExistentCertPath := os.TempDir() + "non_existent_cert.pem"
 
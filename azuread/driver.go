@@ -47,6 +47,7 @@ func NewConnector(dsn string) (*mssql.Connector, error) {
 func newConnectorConfig(config *azureFedAuthConfig) (*mssql.Connector, error) {
 	switch config.fedAuthLibrary {
 	case mssql.FedAuthLibraryADAL:
+		config.tokens = newTokenCache(config.tokenRefreshSkew)
 		return mssql.NewActiveDirectoryTokenConnector(
 			config.mssqlConfig, config.adalWorkflow,
 			func(ctx context.Context, serverSPN, stsURL string) (string, error) {
@@ -0,0 +1,54 @@
+//go:build go1.18
+// +build go1.18
+
+package azuread
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// defaultTokenRefreshSkew is how long before its expiry a cached token is
+// refreshed when the connection string does not set the token refresh skew.
+const defaultTokenRefreshSkew = 5 * time.Minute
+
+// tokenCache keeps the access tokens obtained by a connector so that new
+// connections reuse them until they are about to expire.
+type tokenCache struct {
+	skew time.Duration
+	now  func() time.Time
+
+	mu     sync.Mutex
+	tokens map[string]azcore.AccessToken
+}
+
+func newTokenCache(skew time.Duration) *tokenCache {
+	if skew <= 0 {
+		skew = defaultTokenRefreshSkew
+	}
+	return &tokenCache{
+		skew:   skew,
+		now:    time.Now,
+		tokens: make(map[string]azcore.AccessToken),
+	}
+}
+
+// getToken returns the cached token of key, or obtains a new one with
+// newToken when there is none, when it expires within the skew or when
+// refresh is set because the server rejected the previous one as expired.
+func (c *tokenCache) getToken(key string, refresh bool, newToken func() (azcore.AccessToken, error)) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if tk, ok := c.tokens[key]; ok && !refresh && c.now().Add(c.skew).Before(tk.ExpiresOn) {
+		return tk.Token, nil
+	}
+	delete(c.tokens, key)
+	tk, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	c.tokens[key] = tk
+	return tk.Token, nil
+}
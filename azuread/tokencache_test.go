@@ -0,0 +1,60 @@
+//go:build go1.18
+// +build go1.18
+
+package azuread
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// expiringCredential hands out numbered tokens that expire after lifetime.
+type expiringCredential struct {
+	now      func() time.Time
+	lifetime time.Duration
+	calls    int
+}
+
+func (c *expiringCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.calls++
+	return azcore.AccessToken{Token: fmt.Sprintf("token%d", c.calls), ExpiresOn: c.now().Add(c.lifetime)}, nil
+}
+
+func TestTokenCacheRefresh(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newTokenCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	cred := &expiringCredential{now: cache.now, lifetime: time.Hour}
+	ctx := context.Background()
+	newToken := func() (azcore.AccessToken, error) {
+		return cred.GetToken(ctx, policy.TokenRequestOptions{})
+	}
+	check := func(refresh bool, expected string) {
+		t.Helper()
+		tk, err := cache.getToken("key", refresh, newToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tk != expected {
+			t.Errorf("Expected %s at %v, got %s", expected, now, tk)
+		}
+	}
+
+	check(false, "token1")
+	now = now.Add(58 * time.Minute)
+	check(false, "token1")
+	// within the skew of the expiry
+	now = now.Add(time.Minute + time.Second)
+	check(false, "token2")
+	check(false, "token2")
+	// the server rejected the token as expired
+	check(true, "token3")
+	if cred.calls != 3 {
+		t.Errorf("Expected 3 tokens to be requested, got %d", cred.calls)
+	}
+}
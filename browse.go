@@ -0,0 +1,152 @@
+package mssql
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// COLINFO status flags
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/aa8466c5-ca3d-48ca-a638-7c1becebe754
+const (
+	colInfoExpression    = 0x04
+	colInfoKey           = 0x08
+	colInfoHidden        = 0x10
+	colInfoDifferentName = 0x20
+)
+
+// ColumnSource describes where a result column of a browse mode query
+// comes from, see Rows.ColumnSource.
+type ColumnSource struct {
+	// Table is the name of the base table, with the parts the server sent
+	// such as "dbo.orders", empty for an expression.
+	Table string
+	// Column is the name of the column in the base table, which differs
+	// from the result column name when the column has an alias.
+	Column string
+	// Expression is set when the column is computed from an expression.
+	Expression bool
+	// Key is set when the column is part of the key of its table.
+	Key bool
+}
+
+// columnSources is sent on the token channel when the COLINFO token was
+// not read along with the column metadata.
+type columnSources []*ColumnSource
+
+// parseTabName reads the names of the base tables of a TABNAME token.
+func parseTabName(r *tdsBuffer, s *tdsSession) (tables []string) {
+	b := make([]byte, r.uint16())
+	r.ReadFull(b)
+	usVarChar := func() string {
+		if len(b) < 2 {
+			badStreamPanicf("Invalid TABNAME token")
+		}
+		n := 2 * int(binary.LittleEndian.Uint16(b))
+		if len(b) < 2+n {
+			badStreamPanicf("Invalid TABNAME token")
+		}
+		name, err := ucs22str(b[2 : 2+n])
+		if err != nil {
+			badStreamPanic(err)
+		}
+		b = b[2+n:]
+		return name
+	}
+	for len(b) > 0 {
+		if s.loginAck.TDSVersion != 0 && s.loginAck.TDSVersion < verTDS71rev1 {
+			tables = append(tables, usVarChar())
+			continue
+		}
+		parts := make([]string, b[0])
+		b = b[1:]
+		for i := range parts {
+			parts[i] = usVarChar()
+		}
+		tables = append(tables, strings.Join(parts, "."))
+	}
+	return
+}
+
+// parseColInfo reads a COLINFO token, it returns the source of each
+// column, nil for the columns the token does not describe.
+func parseColInfo(r *tdsBuffer, columns []columnStruct, tables []string) columnSources {
+	b := make([]byte, r.uint16())
+	r.ReadFull(b)
+	sources := make(columnSources, len(columns))
+	for len(b) > 0 {
+		if len(b) < 3 {
+			badStreamPanicf("Invalid COLINFO token")
+		}
+		colNum, tableNum, status := int(b[0]), int(b[1]), b[2]
+		b = b[3:]
+		src := &ColumnSource{
+			Expression: status&colInfoExpression != 0,
+			Key:        status&colInfoKey != 0,
+		}
+		if tableNum > 0 && tableNum <= len(tables) {
+			src.Table = tables[tableNum-1]
+		}
+		if status&colInfoDifferentName != 0 {
+			if len(b) < 1 || len(b) < 1+2*int(b[0]) {
+				badStreamPanicf("Invalid COLINFO token")
+			}
+			name, err := ucs22str(b[1 : 1+2*int(b[0])])
+			if err != nil {
+				badStreamPanic(err)
+			}
+			src.Column = name
+			b = b[1+2*int(b[0]):]
+		}
+		if colNum < 1 || colNum > len(columns) {
+			continue
+		}
+		if status&colInfoDifferentName == 0 && !src.Expression {
+			src.Column = columns[colNum-1].ColName
+		}
+		sources[colNum-1] = src
+	}
+	return sources
+}
+
+// setColumnSources attaches the sources read from a COLINFO token to the
+// columns.
+func setColumnSources(columns []columnStruct, sources columnSources) {
+	for i := range columns {
+		if i < len(sources) {
+			columns[i].source = sources[i]
+		}
+	}
+}
+
+// readBrowseTokens reads the TABNAME and COLINFO tokens which follow the
+// column metadata of a browse mode query when they are in the packet
+// already read, so the sources are known along with the columns.
+func readBrowseTokens(sess *tdsSession, columns []columnStruct, tables *[]string) {
+	for {
+		b, ok := sess.buf.peekByte()
+		if !ok {
+			return
+		}
+		switch token(b) {
+		case tokenTabName:
+			sess.buf.byte()
+			*tables = parseTabName(sess.buf, sess)
+		case tokenColInfo:
+			sess.buf.byte()
+			setColumnSources(columns, parseColInfo(sess.buf, columns, *tables))
+		default:
+			return
+		}
+	}
+}
+
+// ColumnSource returns the base table and column of a result column of a
+// query run in browse mode, with FOR BROWSE or after SET NO_BROWSETABLE ON.
+// ok is false for the other queries. The key columns the server adds to
+// the rows of such queries are not returned as result columns.
+func (r *Rows) ColumnSource(index int) (source ColumnSource, ok bool) {
+	if src := r.cols[index].source; src != nil {
+		return *src, true
+	}
+	return ColumnSource{}, false
+}
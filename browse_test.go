@@ -0,0 +1,159 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// browseTokens returns the TABNAME and COLINFO tokens of
+// select id, name as label, 1 + 1 from dbo.items for browse
+// with the hidden key column.
+func browseTokens() []byte {
+	tabName := []byte{2}
+	for _, part := range []string{"dbo", "items"} {
+		tabName = append(tabName, byte(len(part)), 0)
+		tabName = append(tabName, str2ucs2(part)...)
+	}
+	b := []byte{byte(tokenTabName), 0, 0}
+	binary.LittleEndian.PutUint16(b[1:], uint16(len(tabName)))
+	b = append(b, tabName...)
+
+	colInfo := []byte{1, 1, 0}
+	colInfo = append(colInfo, 2, 1, colInfoDifferentName, 4)
+	colInfo = append(colInfo, str2ucs2("name")...)
+	colInfo = append(colInfo, 3, 0, colInfoExpression, 4, 1, colInfoKey|colInfoHidden)
+	ci := []byte{byte(tokenColInfo), 0, 0}
+	binary.LittleEndian.PutUint16(ci[1:], uint16(len(colInfo)))
+	b = append(b, ci...)
+	return append(b, colInfo...)
+}
+
+func browseMetadata() []byte {
+	metadata := []byte{byte(tokenColMetadata), 4, 0}
+	metadata = append(metadata, cursorIntColumn("id", colFlagNullable)...)
+	metadata = append(metadata, cursorIntColumn("label", colFlagNullable)...)
+	metadata = append(metadata, cursorIntColumn("", colFlagNullable)...)
+	return append(metadata, cursorIntColumn("key", colFlagHidden)...)
+}
+
+func checkBrowseRows(t *testing.T, rows *Rows) {
+	t.Helper()
+	if cols := rows.Columns(); !reflect.DeepEqual(cols, []string{"id", "label", ""}) {
+		t.Errorf("expected the hidden key column not to be returned, got %v", cols)
+	}
+	dest := make([]driver.Value, 3)
+	if err := rows.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	want := []ColumnSource{
+		{Table: "dbo.items", Column: "id"},
+		{Table: "dbo.items", Column: "name"},
+		{Expression: true},
+	}
+	for i, w := range want {
+		if got, ok := rows.ColumnSource(i); !ok || got != w {
+			t.Errorf("column %d: expected %+v, got %+v (ok %v)", i, w, got, ok)
+		}
+	}
+	if !reflect.DeepEqual(dest, []driver.Value{int64(1), int64(2), int64(3)}) {
+		t.Errorf("unexpected row %v", dest)
+	}
+	if err := rows.Next(dest); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestBrowseColumnSource(t *testing.T) {
+	transport := &scriptedTransport{}
+	transport.reply(browseMetadata(), browseTokens(), cursorRow(1, 2, 3, 10), cursorDoneProc())
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	rows, err := (&Stmt{c: c, query: "select id, name as label, 1 + 1 from dbo.items for browse"}).queryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBrowseRows(t, rows.(*Rows))
+
+	// a query which is not in browse mode
+	transport.reply([]byte{byte(tokenColMetadata), 1, 0}, cursorIntColumn("n", colFlagNullable), cursorRow(1), cursorDoneProc())
+	rows, err = (&Stmt{c: c, query: "select 1 as n"}).queryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rows.(*Rows).ColumnSource(0); ok {
+		t.Error("expected no source outside of browse mode")
+	}
+}
+
+func TestBrowseColumnSourceNextPacket(t *testing.T) {
+	// the metadata fills the first packet, the server sends the browse
+	// tokens in the next one
+	transport := &scriptedTransport{}
+	for i, data := range [][]byte{browseMetadata(), bytes.Join([][]byte{browseTokens(), cursorRow(1, 2, 3, 10), cursorDoneProc()}, nil)} {
+		header := []byte{byte(packReply), byte(i), 0, 0, 0, 0, byte(i + 1), 0}
+		binary.BigEndian.PutUint16(header[2:], uint16(len(data)+len(header)))
+		transport.responses.Write(header)
+		transport.responses.Write(data)
+	}
+	rows, err := (&Stmt{c: testConn(newConnector(msdsn.Config{}, nil), transport), query: "select id, name as label, 1 + 1 from dbo.items for browse"}).queryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBrowseRows(t, rows.(*Rows))
+}
+
+func TestBrowseQuery(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, `create table #items (id int primary key, name nvarchar(10), rv rowversion);
+		insert into #items (id, name) values (1, N'one')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sources []ColumnSource
+	err = conn.Raw(func(driverConn interface{}) error {
+		s := &Stmt{c: driverConn.(*Conn), query: "select name as label, id + 1 as next from #items for browse"}
+		r, err := s.queryContext(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		if cols := r.Columns(); !reflect.DeepEqual(cols, []string{"label", "next"}) {
+			t.Errorf("expected the selected columns only, got %v", cols)
+		}
+		for i := range r.Columns() {
+			src, _ := r.(*Rows).ColumnSource(i)
+			sources = append(sources, src)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 || sources[0].Column != "name" || sources[0].Table == "" || !sources[1].Expression {
+		t.Errorf("unexpected sources %+v", sources)
+	}
+
+	// the hidden key columns do not change the columns scanned through database/sql
+	var label string
+	if err = conn.QueryRowContext(ctx, "select name from #items for browse").Scan(&label); err != nil {
+		t.Fatal(err)
+	}
+	if label != "one" {
+		t.Errorf("expected one, got %q", label)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"sync"
+	"sync/atomic"
 )
 
 type packetType uint8
@@ -18,6 +19,32 @@ type header struct {
 	Pad        uint8
 }
 
+// PacketHeader is the header of a TDS packet passed to Connector.PacketTracer.
+type PacketHeader struct {
+	Type     uint8
+	Status   uint8
+	Length   uint16
+	SPID     uint16
+	PacketID uint8
+}
+
+// Direction tells whether a traced packet is sent or received.
+type Direction uint8
+
+const (
+	// DirectionOutbound is a packet sent to the server.
+	DirectionOutbound Direction = iota
+	// DirectionInbound is a packet received from the server.
+	DirectionInbound
+)
+
+func (d Direction) String() string {
+	if d == DirectionInbound {
+		return "inbound"
+	}
+	return "outbound"
+}
+
 // bufpool provides buffers which are used for reading and writing in the tdsBuffer instances
 var bufpool = sync.Pool{
 	New: func() interface{} {
@@ -33,6 +60,10 @@ var bufpool = sync.Pool{
 // possible without locks. Currently attn signals are only sent during
 // reads, not writes.
 type tdsBuffer struct {
+	// stats is first to keep its counters 64-bit aligned for the atomic
+	// operations on 32-bit platforms.
+	stats connStats
+
 	transport io.ReadWriteCloser
 
 	packetSize int
@@ -57,6 +88,10 @@ type tdsBuffer struct {
 	// before the first use. It is executed after the first packet is
 	// written and then removed.
 	afterFirst func()
+
+	// tracer, when set, is called with the header of each packet
+	// written or read.
+	tracer func(header PacketHeader, direction Direction)
 }
 
 func newTdsBuffer(bufsize uint16, transport io.ReadWriteCloser) *tdsBuffer {
@@ -92,6 +127,15 @@ func (w *tdsBuffer) flush() (err error) {
 	if _, err = w.transport.Write(w.wbuf[:w.wpos]); err != nil {
 		return err
 	}
+	w.stats.sent(w.wpos)
+	if w.tracer != nil {
+		w.tracer(PacketHeader{
+			Type:     w.wbuf[0],
+			Status:   w.wbuf[1],
+			Length:   uint16(w.wpos),
+			PacketID: w.wPacketSeq,
+		}, DirectionOutbound)
+	}
 	// It is possible to create a whole new buffer after a flush.
 	// Useful for debugging. Normally reuse the buffer.
 	// w.wbuf = make([]byte, 1<<16)
@@ -150,6 +194,17 @@ func (w *tdsBuffer) BeginPacket(packetType packetType, resetSession bool) {
 
 func (w *tdsBuffer) FinishPacket() error {
 	w.wbuf[1] |= 1 // Mark this as the last packet in the message.
+	if err := w.flush(); err != nil {
+		return err
+	}
+	atomic.AddUint64(&w.stats.roundTrips, 1)
+	return nil
+}
+
+// AbortPacket ends the current message and marks it to be ignored by
+// the server, which discards a partially sent request.
+func (w *tdsBuffer) AbortPacket() error {
+	w.wbuf[1] |= 1 | 2 // Last packet in the message, the server ignores the message.
 	return w.flush()
 }
 
@@ -175,12 +230,22 @@ func (r *tdsBuffer) readNextPacket() error {
 	if headerSize > int(h.Size) {
 		return errors.New("invalid packet size, it is shorter than header size")
 	}
+	if r.tracer != nil {
+		r.tracer(PacketHeader{
+			Type:     uint8(h.PacketType),
+			Status:   h.Status,
+			Length:   h.Size,
+			SPID:     h.Spid,
+			PacketID: h.PacketNo,
+		}, DirectionInbound)
+	}
 	_, err = io.ReadFull(r.transport, r.rbuf[headerSize:h.Size])
 	//s := base64.StdEncoding.EncodeToString(r.rbuf[headerSize:h.Size])
 	//fmt.Print(s)
 	if err != nil {
 		return err
 	}
+	r.stats.received(int(h.Size))
 	r.rpos = headerSize
 	r.rsize = int(h.Size)
 	r.final = h.Status != 0
@@ -211,6 +276,15 @@ func (r *tdsBuffer) ReadByte() (res byte, err error) {
 	return res, nil
 }
 
+// peekByte returns the next byte of the packet already read, ok is false
+// at the end of the packet.
+func (r *tdsBuffer) peekByte() (b byte, ok bool) {
+	if r.rpos == r.rsize {
+		return 0, false
+	}
+	return r.rbuf[r.rpos], true
+}
+
 func (r *tdsBuffer) byte() byte {
 	b, err := r.ReadByte()
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"reflect"
 	"testing"
 	"unicode/utf16"
 )
@@ -392,6 +393,63 @@ func TestWrite(t *testing.T) {
 	}
 }
 
+func TestAbortPacket(t *testing.T) {
+	memBuf := bytes.NewBuffer([]byte{})
+	buf := newTdsBuffer(11, closableBuffer{memBuf})
+	buf.BeginPacket(7, false)
+	if _, err := buf.Write([]byte{3, 4, 5, 6}); err != nil {
+		t.Fatal("Write failed:", err.Error())
+	}
+	if err := buf.AbortPacket(); err != nil {
+		t.Fatal("AbortPacket failed:", err.Error())
+	}
+	expectedBuf := []byte{
+		7, 0, 0, 11, 0, 0, 1, 0, 3, 4, 5, // packet 1
+		7, 3, 0, 9, 0, 0, 2, 0, 6, // packet 2, end of message to be ignored
+	}
+	if !bytes.Equal(memBuf.Bytes(), expectedBuf) {
+		t.Fatalf("Written buffer has invalid content:\n got: %v\nwant: %v", memBuf.Bytes(), expectedBuf)
+	}
+}
+
+func TestPacketTracer(t *testing.T) {
+	memBuf := bytes.NewBuffer([]byte{})
+	buf := newTdsBuffer(11, closableBuffer{memBuf})
+	var traced []PacketHeader
+	var directions []Direction
+	buf.tracer = func(h PacketHeader, d Direction) {
+		traced = append(traced, h)
+		directions = append(directions, d)
+	}
+
+	buf.BeginPacket(2, false)
+	if _, err := buf.Write([]byte{3, 4, 5, 6}); err != nil {
+		t.Fatal("Write failed:", err.Error())
+	}
+	if err := buf.FinishPacket(); err != nil {
+		t.Fatal("FinishPacket failed:", err.Error())
+	}
+	// answer with a single packet from server spid 55
+	memBuf.Reset()
+	memBuf.Write([]byte{4, 1, 0, 9, 0, 55, 1, 0, 7})
+	if _, err := buf.BeginRead(); err != nil {
+		t.Fatal("BeginRead failed:", err.Error())
+	}
+
+	want := []PacketHeader{
+		{Type: 2, Status: 0, Length: 11, PacketID: 1},
+		{Type: 2, Status: 1, Length: 9, PacketID: 2},
+		{Type: 4, Status: 1, Length: 9, SPID: 55, PacketID: 1},
+	}
+	wantDirections := []Direction{DirectionOutbound, DirectionOutbound, DirectionInbound}
+	if !reflect.DeepEqual(traced, want) {
+		t.Errorf("expected headers %+v, got %+v", want, traced)
+	}
+	if !reflect.DeepEqual(directions, wantDirections) {
+		t.Errorf("expected directions %v, got %v", wantDirections, directions)
+	}
+}
+
 func TestWriteErrors(t *testing.T) {
 	// write should fail if underlying transport fails
 	buf := newTdsBuffer(uint16(headerSize)+1, failBuffer{})
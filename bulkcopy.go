@@ -5,9 +5,11 @@ import (
 	"context"
 	"database/sql/driver"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -17,47 +19,119 @@ import (
 )
 
 type Bulk struct {
-	// ctx is used only for AddRow and Done methods.
-	// This could be removed if AddRow and Done accepted
-	// a ctx field as well, which is available with the
-	// database/sql call.
+	// ctx is used only for AddRow and Done methods, the statements
+	// of CopyIn pass the context of each Exec instead.
 	ctx context.Context
 
 	cn          *Conn
 	metadata    []columnStruct
 	bulkColumns []columnStruct
+	// values holds the index in the rows added of the value of each bulk
+	// column, the values of the identity columns are skipped unless
+	// KeepIdentity is set
+	values      []int
 	columnsName []string
 	tablename   string
 	numRows     int
 
+	// batchRows counts the rows written since the last flush,
+	// flushedRows the rows reported by the server for previous flushes.
+	batchRows   int
+	flushedRows int64
+	// flushErr holds the error of a failed automatic flush until
+	// it can be returned by the next call to AddRow or Done.
+	flushErr error
+	// reportedRows is the row count last passed to the progress callback
+	reportedRows int
+
 	headerSent bool
 	Options    BulkOptions
 	Debug      bool
 }
 type BulkOptions struct {
-	CheckConstraints  bool
-	FireTriggers      bool
+	// CheckConstraints makes the server check the constraints of the table
+	// while loading the rows, they are not checked by default.
+	CheckConstraints bool
+	// FireTriggers makes the server run the insert triggers of the table.
+	FireTriggers bool
+	// KeepIdentity makes the server store the values given for the
+	// identity column instead of generating them. Without it the values
+	// of the identity column are not sent.
+	KeepIdentity      bool
 	KeepNulls         bool
 	KilobytesPerBatch int
 	RowsPerBatch      int
 	Order             []string
 	Tablock           bool
+	// Hints are added to the options of the INSERT BULK statement, for
+	// instance "TABLOCK" or "ORDER([id] ASC)". Hints other than the insert
+	// bulk options are rejected before the statement is sent, except
+	// IGNORE_CONSTRAINTS and IGNORE_TRIGGERS: constraints and triggers are
+	// ignored unless checked or fired, these hints are not sent and
+	// conflict with CHECK_CONSTRAINTS and FIRE_TRIGGERS.
+	Hints []string
+	// RowsBeforeFlush, when greater than zero, makes the bulk copy send
+	// the buffered rows to the server each time that many rows have been
+	// added. Column metadata is kept, so the caller does not need to
+	// prepare the bulk copy again after a flush.
+	RowsBeforeFlush int
+	// NoTransaction makes each batch of rows sent to the server durable on
+	// its own, so that the batches flushed before a failing one are kept
+	// and only the remaining rows need to be loaded again. The transaction
+	// opened by a batch when the session runs with IMPLICIT_TRANSACTIONS ON
+	// is committed after the batch. The bulk copy is refused when the
+	// connection is already in a transaction, whose rollback would discard
+	// the batches. Without it a failing batch is rolled back together with
+	// the previous batches of the transaction the bulk copy runs in.
+	NoTransaction bool
+	// ProgressCallback, when set, is called with the number of rows added
+	// so far each time ProgressInterval more rows have been added, and with
+	// the total once the last rows are sent by Done. It is called on the
+	// goroutine adding the rows, the one running Exec for a CopyIn
+	// statement, and delays the next row until it returns.
+	ProgressCallback func(rowsSent int64)
+	// ProgressInterval is the number of rows between two calls of
+	// ProgressCallback, defaultProgressInterval when not greater than zero.
+	ProgressInterval int
 }
 
+// defaultProgressInterval is the number of rows between two calls of the
+// progress callback when BulkOptions.ProgressInterval is not set.
+const defaultProgressInterval = 1000
+
 type DataValue interface{}
 
+// BulkCopyColumnCountError is returned by AddRow when the number of values
+// in a row does not match the number of columns of the bulk copy.
+type BulkCopyColumnCountError struct {
+	Expected int
+	Got      int
+}
+
+func (e BulkCopyColumnCountError) Error() string {
+	return fmt.Sprintf("row does not have the same number of columns than the destination table %d %d",
+		e.Got, e.Expected)
+}
+
 const (
 	sqlDateFormat     = "2006-01-02"
 	sqlDateTimeFormat = "2006-01-02 15:04:05.999999999Z07:00"
 	sqlTimeFormat     = "15:04:05.9999999"
 )
 
+// CreateBulk prepares the bulk copy of rows holding the values of columns
+// into table. The columns a row can not supply are omitted from the list:
+// computed columns are rejected, so are the sparse columns of a table with
+// a column set, which select * does not return, and the values of the
+// identity column are skipped unless KeepIdentity is set. The omitted
+// columns get their default value, or are computed.
 func (cn *Conn) CreateBulk(table string, columns []string) (_ *Bulk) {
 	b := Bulk{ctx: context.Background(), cn: cn, tablename: table, headerSent: false, columnsName: columns}
 	b.Debug = false
 	return &b
 }
 
+// CreateBulkContext is CreateBulk with the context of the bulk copy.
 func (cn *Conn) CreateBulkContext(ctx context.Context, table string, columns []string) (_ *Bulk) {
 	b := Bulk{ctx: ctx, cn: cn, tablename: table, headerSent: false, columnsName: columns}
 	b.Debug = false
@@ -65,32 +139,14 @@ func (cn *Conn) CreateBulkContext(ctx context.Context, table string, columns []s
 }
 
 func (b *Bulk) sendBulkCommand(ctx context.Context) (err error) {
-	//get table columns info
-	err = b.getMetadata(ctx)
-	if err != nil {
-		return err
+	if b.Options.NoTransaction && b.cn.sess.tranid != 0 {
+		return errors.New("mssql: bulk copy with NoTransaction can not run in a transaction")
 	}
-
-	//match the columns
-	for _, colname := range b.columnsName {
-		var bulkCol *columnStruct
-
-		for _, m := range b.metadata {
-			if m.ColName == colname {
-				bulkCol = &m
-				break
-			}
-		}
-		if bulkCol != nil {
-
-			if bulkCol.ti.TypeId == typeUdt {
-				//send udt as binary
-				bulkCol.ti.TypeId = typeBigVarBin
-			}
-			b.bulkColumns = append(b.bulkColumns, *bulkCol)
-			b.dlogf(ctx, "Adding column %s %s %#x", colname, bulkCol.ColName, bulkCol.ti.TypeId)
-		} else {
-			return fmt.Errorf("column %s does not exist in destination table %s", colname, b.tablename)
+	// the columns are only matched once, later batches reuse them
+	if len(b.bulkColumns) == 0 {
+		err = b.matchColumns(ctx)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -105,29 +161,9 @@ func (b *Bulk) sendBulkCommand(ctx context.Context) (err error) {
 		col_defs.WriteString("[" + col.ColName + "] " + makeDecl(col.ti))
 	}
 
-	//options
-	var with_opts []string
-
-	if b.Options.CheckConstraints {
-		with_opts = append(with_opts, "CHECK_CONSTRAINTS")
-	}
-	if b.Options.FireTriggers {
-		with_opts = append(with_opts, "FIRE_TRIGGERS")
-	}
-	if b.Options.KeepNulls {
-		with_opts = append(with_opts, "KEEP_NULLS")
-	}
-	if b.Options.KilobytesPerBatch > 0 {
-		with_opts = append(with_opts, fmt.Sprintf("KILOBYTES_PER_BATCH = %d", b.Options.KilobytesPerBatch))
-	}
-	if b.Options.RowsPerBatch > 0 {
-		with_opts = append(with_opts, fmt.Sprintf("ROWS_PER_BATCH = %d", b.Options.RowsPerBatch))
-	}
-	if len(b.Options.Order) > 0 {
-		with_opts = append(with_opts, fmt.Sprintf("ORDER(%s)", strings.Join(b.Options.Order, ",")))
-	}
-	if b.Options.Tablock {
-		with_opts = append(with_opts, "TABLOCK")
+	with_opts, err := b.Options.insertBulkOptions()
+	if err != nil {
+		return err
 	}
 	var with_part string
 	if len(with_opts) > 0 {
@@ -148,6 +184,7 @@ func (b *Bulk) sendBulkCommand(ctx context.Context) (err error) {
 	}
 
 	b.headerSent = true
+	b.cn.bulk = b
 
 	var buf = b.cn.sess.buf
 	buf.BeginPacket(packBulkLoadBCP, false)
@@ -159,21 +196,156 @@ func (b *Bulk) sendBulkCommand(ctx context.Context) (err error) {
 	return
 }
 
+// bulkIgnoreHints are the hints asking for the default of INSERT BULK
+// and the options they conflict with.
+var bulkIgnoreHints = map[string]string{
+	"IGNORE_CONSTRAINTS": "CHECK_CONSTRAINTS",
+	"IGNORE_TRIGGERS":    "FIRE_TRIGGERS",
+}
+
+var (
+	bulkHintRE      = regexp.MustCompile(`^(TABLOCK|CHECK_CONSTRAINTS|FIRE_TRIGGERS|KEEP_NULLS|KEEP_IDENTITY|(ROWS_PER_BATCH|KILOBYTES_PER_BATCH) *= *[0-9]+)$`)
+	bulkOrderItem   = `(\[[^\]]+\]|[A-Za-z_@#][A-Za-z0-9_@#$]*)( +(ASC|DESC))?`
+	bulkOrderHintRE = regexp.MustCompile(`(?i)^ORDER *\( *` + bulkOrderItem + `( *, *` + bulkOrderItem + `)* *\)$`)
+)
+
+// insertBulkOptions returns the options of the INSERT BULK statement.
+func (o BulkOptions) insertBulkOptions() ([]string, error) {
+	var with_opts []string
+
+	if o.CheckConstraints {
+		with_opts = append(with_opts, "CHECK_CONSTRAINTS")
+	}
+	if o.FireTriggers {
+		with_opts = append(with_opts, "FIRE_TRIGGERS")
+	}
+	if o.KeepNulls {
+		with_opts = append(with_opts, "KEEP_NULLS")
+	}
+	if o.KeepIdentity {
+		with_opts = append(with_opts, "KEEP_IDENTITY")
+	}
+	if o.KilobytesPerBatch > 0 {
+		with_opts = append(with_opts, fmt.Sprintf("KILOBYTES_PER_BATCH = %d", o.KilobytesPerBatch))
+	}
+	if o.RowsPerBatch > 0 {
+		with_opts = append(with_opts, fmt.Sprintf("ROWS_PER_BATCH = %d", o.RowsPerBatch))
+	}
+	if len(o.Order) > 0 {
+		with_opts = append(with_opts, fmt.Sprintf("ORDER(%s)", strings.Join(o.Order, ",")))
+	}
+	if o.Tablock {
+		with_opts = append(with_opts, "TABLOCK")
+	}
+	var ignored []string
+	for _, hint := range o.Hints {
+		hint = strings.TrimSpace(hint)
+		upper := strings.ToUpper(hint)
+		switch {
+		case bulkIgnoreHints[upper] != "":
+			ignored = append(ignored, upper)
+			continue
+		case bulkHintRE.MatchString(upper):
+			hint = upper
+		case bulkOrderHintRE.MatchString(hint):
+			if len(o.Order) > 0 {
+				return nil, fmt.Errorf("mssql: bulk copy hint %s conflicts with the Order option", hint)
+			}
+		default:
+			return nil, fmt.Errorf("mssql: unknown bulk copy hint %s", hint)
+		}
+		duplicate := false
+		for _, opt := range with_opts {
+			duplicate = duplicate || opt == hint
+		}
+		if !duplicate {
+			with_opts = append(with_opts, hint)
+		}
+	}
+	for _, hint := range ignored {
+		for _, opt := range with_opts {
+			if opt == bulkIgnoreHints[hint] {
+				return nil, fmt.Errorf("mssql: bulk copy hint %s conflicts with %s", hint, opt)
+			}
+		}
+	}
+	return with_opts, nil
+}
+
+func (b *Bulk) matchColumns(ctx context.Context) (err error) {
+	//get table columns info, unless BulkCopyFromRows already did
+	if b.metadata == nil {
+		err = b.getMetadata(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	//match the columns
+	for i, colname := range b.columnsName {
+		var bulkCol *columnStruct
+
+		for _, m := range b.metadata {
+			if m.ColName == colname {
+				bulkCol = &m
+				break
+			}
+		}
+		if bulkCol != nil {
+			if bulkCol.Flags&colFlagComputed != 0 {
+				return fmt.Errorf("column %s of destination table %s is computed and can not be loaded, omit it from the columns", colname, b.tablename)
+			}
+			if bulkCol.Flags&colFlagIdentity != 0 && !b.Options.KeepIdentity {
+				b.dlogf(ctx, "Skipping identity column %s", colname)
+				continue
+			}
+
+			if bulkCol.ti.TypeId == typeUdt {
+				//send udt as binary
+				bulkCol.ti.TypeId = typeBigVarBin
+			}
+			b.bulkColumns = append(b.bulkColumns, *bulkCol)
+			b.values = append(b.values, i)
+			b.dlogf(ctx, "Adding column %s %s %#x", colname, bulkCol.ColName, bulkCol.ti.TypeId)
+		} else {
+			for _, m := range b.metadata {
+				if m.Flags&colFlagSparseColumnSet != 0 {
+					// select * returns the column set instead of the sparse columns
+					return fmt.Errorf("column %s does not exist in destination table %s or is a sparse column of the column set %s, omit it from the columns", colname, b.tablename, m.ColName)
+				}
+			}
+			return fmt.Errorf("column %s does not exist in destination table %s", colname, b.tablename)
+		}
+	}
+	return nil
+}
+
 // AddRow immediately writes the row to the destination table.
 // The arguments are the row values in the order they were specified.
 func (b *Bulk) AddRow(row []interface{}) (err error) {
+	return b.addRow(b.ctx, row)
+}
+
+func (b *Bulk) addRow(ctx context.Context, row []interface{}) (err error) {
+	if b.flushErr != nil {
+		return b.flushErr
+	}
+	if err = ctx.Err(); err != nil {
+		if cerr := b.Cancel(); cerr != nil {
+			return cerr
+		}
+		return err
+	}
+	if len(row) != len(b.columnsName) {
+		return BulkCopyColumnCountError{Expected: len(b.columnsName), Got: len(row)}
+	}
 	if !b.headerSent {
-		err = b.sendBulkCommand(b.ctx)
+		err = b.sendBulkCommand(ctx)
 		if err != nil {
 			return
 		}
 	}
 
-	if len(row) != len(b.bulkColumns) {
-		return fmt.Errorf("row does not have the same number of columns than the destination table %d %d",
-			len(row), len(b.bulkColumns))
-	}
-
 	bytes, err := b.makeRowData(row)
 	if err != nil {
 		return
@@ -185,6 +357,20 @@ func (b *Bulk) AddRow(row []interface{}) (err error) {
 	}
 
 	b.numRows = b.numRows + 1
+	b.batchRows = b.batchRows + 1
+	if b.Options.ProgressCallback != nil && b.numRows%b.progressInterval() == 0 {
+		b.reportProgress()
+	}
+
+	if b.Options.RowsBeforeFlush > 0 && b.batchRows >= b.Options.RowsBeforeFlush {
+		rowcount, ferr := b.flush(ctx)
+		if ferr != nil {
+			// reported by the next AddRow or Done
+			b.flushErr = ferr
+			return
+		}
+		b.flushedRows += rowcount
+	}
 	return
 }
 
@@ -194,13 +380,14 @@ func (b *Bulk) makeRowData(row []interface{}) ([]byte, error) {
 
 	var logcol bytes.Buffer
 	for i, col := range b.bulkColumns {
+		val := row[b.values[i]]
 
 		if b.Debug {
-			logcol.WriteString(fmt.Sprintf(" col[%d]='%v' ", i, row[i]))
+			logcol.WriteString(fmt.Sprintf(" col[%d]='%v' ", i, val))
 		}
-		param, err := b.makeParam(row[i], col)
+		param, err := b.makeParam(val, col)
 		if err != nil {
-			return nil, fmt.Errorf("bulkcopy: %s", err.Error())
+			return nil, fmt.Errorf("bulkcopy: column %s: %s", col.ColName, err.Error())
 		}
 
 		if col.ti.Writer == nil {
@@ -218,11 +405,90 @@ func (b *Bulk) makeRowData(row []interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// Done sends the remaining rows and finishes the bulk copy. It returns the
+// number of rows reported as inserted by the server, including the rows of
+// previous flushes.
 func (b *Bulk) Done() (rowcount int64, err error) {
+	return b.done(b.ctx)
+}
+
+func (b *Bulk) done(ctx context.Context) (rowcount int64, err error) {
+	if b.flushErr != nil {
+		return b.flushedRows, b.flushErr
+	}
+	if err = ctx.Err(); err != nil {
+		if cerr := b.Cancel(); cerr != nil {
+			return b.flushedRows, cerr
+		}
+		return b.flushedRows, err
+	}
 	if !b.headerSent {
-		//no rows had been sent
-		return 0, nil
+		//no rows had been sent since the last flush
+		b.reportProgress()
+		return b.flushedRows, nil
 	}
+	rowcount, err = b.flush(ctx)
+	if err != nil {
+		return b.flushedRows, err
+	}
+	b.flushedRows += rowcount
+	b.reportProgress()
+	return b.flushedRows, nil
+}
+
+func (b *Bulk) progressInterval() int {
+	if b.Options.ProgressInterval > 0 {
+		return b.Options.ProgressInterval
+	}
+	return defaultProgressInterval
+}
+
+// reportProgress passes the number of rows added to the progress callback,
+// unless it was already reported.
+func (b *Bulk) reportProgress() {
+	if b.Options.ProgressCallback == nil || b.numRows == b.reportedRows {
+		return
+	}
+	b.reportedRows = b.numRows
+	b.Options.ProgressCallback(int64(b.numRows))
+}
+
+// Cancel aborts the bulk copy. The rows added since the last flush are
+// discarded by the server, rows of previous flushes are only rolled back
+// if the bulk copy runs in a transaction which is rolled back.
+// The connection remains usable. Closing the statement of CopyIn, or
+// rolling back its transaction, before the bulk copy is done cancels it.
+func (b *Bulk) Cancel() error {
+	if !b.headerSent {
+		return nil
+	}
+	b.headerSent = false
+	b.batchRows = 0
+	b.cn.bulk = nil
+
+	sess := b.cn.sess
+	if err := sess.buf.AbortPacket(); err != nil {
+		return b.cn.checkBadConn(b.ctx, err, false)
+	}
+	if err := sendAttention(sess.buf); err != nil {
+		return b.cn.checkBadConn(b.ctx, err, false)
+	}
+	// the server may answer the aborted request before it
+	// confirms the attention, read at most two responses
+	for i := 0; i < 2; i++ {
+		tokChan := make(chan tokenStruct, 5)
+		go processSingleResponse(context.Background(), sess, tokChan, outputs{})
+		if readCancelConfirmation(tokChan) {
+			return nil
+		}
+	}
+	b.cn.connectionGood = false
+	return ServerError{Error{Message: "did not get cancellation confirmation from the server"}}
+}
+
+// flush terminates the current batch of rows and waits for the server
+// to process it. The next AddRow starts a new batch.
+func (b *Bulk) flush(ctx context.Context) (rowcount int64, err error) {
 	var buf = b.cn.sess.buf
 	buf.WriteByte(byte(tokenDone))
 
@@ -236,11 +502,23 @@ func (b *Bulk) Done() (rowcount int64, err error) {
 	}
 
 	buf.FinishPacket()
+	b.headerSent = false
+	b.batchRows = 0
+	b.cn.bulk = nil
 
-	reader := startReading(b.cn.sess, b.ctx, outputs{})
+	reader := startReading(b.cn.sess, ctx, outputs{})
 	err = reader.iterateResponse()
 	if err != nil {
-		return 0, b.cn.checkBadConn(b.ctx, err, false)
+		return 0, b.cn.checkBadConn(ctx, err, false)
+	}
+	if b.Options.NoTransaction && b.cn.sess.tranid != 0 {
+		// commit the implicit transaction opened by the batch
+		if err = b.cn.sendCommitRequest(); err != nil {
+			return 0, b.cn.checkBadConn(ctx, err, true)
+		}
+		if err = b.cn.simpleProcessResp(ctx); err != nil {
+			return 0, err
+		}
 	}
 
 	return reader.rowCount, nil
@@ -262,7 +540,10 @@ func (b *Bulk) createColMetadata() []byte {
 		} else {
 			binary.Write(buf, binary.LittleEndian, uint16(col.UserType))
 		}
-		binary.Write(buf, binary.LittleEndian, uint16(col.Flags))
+		// only the flags describing the values sent, not those of the
+		// column in the table such as computed or sparse column set
+		flags := col.Flags & (colFlagNullable | colFlagUpdateable | colFlagIdentity | colFlagEncrypted)
+		binary.Write(buf, binary.LittleEndian, flags)
 
 		writeTypeInfo(buf, &b.bulkColumns[i].ti, false, b.cn.sess.encoding)
 
@@ -438,14 +719,23 @@ func (b *Bulk) makeParam(val DataValue, col columnStruct) (res param, err error)
 		res.ti.Size = len(res.buffer)
 
 	case typeBit, typeBitN:
-		if reflect.TypeOf(val).Kind() != reflect.Bool {
+		// accept *bool and types based on bool as well as bool
+		rv := reflect.ValueOf(val)
+		if rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				res.ti.Size = 0
+				return
+			}
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Bool {
 			err = fmt.Errorf("mssql: invalid type for bit column: %T %s", val, val)
 			return
 		}
 		res.ti.TypeId = typeBitN
 		res.ti.Size = 1
 		res.buffer = make([]byte, 1)
-		if val.(bool) {
+		if rv.Bool() {
 			res.buffer[0] = 1
 		}
 	case typeDateTime2N:
@@ -485,6 +775,9 @@ func (b *Bulk) makeParam(val DataValue, col columnStruct) (res param, err error)
 		case time.Time:
 			res.buffer = encodeDate(val)
 			res.ti.Size = len(res.buffer)
+		case Date:
+			res.buffer = encodeDate(time.Time(val))
+			res.ti.Size = len(res.buffer)
 		case string:
 			var t time.Time
 			if t, err = time.ParseInLocation(sqlDateFormat, val, time.UTC); err != nil {
@@ -535,6 +828,9 @@ func (b *Bulk) makeParam(val DataValue, col columnStruct) (res param, err error)
 			err = fmt.Errorf("mssql: invalid type for time column: %T %s", val, val)
 			return
 		}
+	case typeVariant:
+		res.buffer, err = encodeVariant(val, b.cn.sess.collation)
+		res.ti.Size = len(res.buffer)
 	// case typeMoney, typeMoney4, typeMoneyN:
 	case typeDecimal, typeDecimalN, typeNumeric, typeNumericN:
 		prec := col.ti.Prec
@@ -564,41 +860,12 @@ func (b *Bulk) makeParam(val DataValue, col columnStruct) (res param, err error)
 		if err != nil {
 			return res, err
 		}
-		dec.SetPrec(prec)
-
-		var length byte
-		switch {
-		case prec <= 9:
-			length = 4
-		case prec <= 19:
-			length = 8
-		case prec <= 28:
-			length = 12
-		default:
-			length = 16
-		}
-
-		buf := make([]byte, length+1)
-		// first byte length written by typeInfo.writer
-		res.ti.Size = int(length) + 1
-		// second byte sign
-		if !dec.IsPositive() {
-			buf[0] = 0
-		} else {
-			buf[0] = 1
-		}
-
-		ub := dec.UnscaledBytes()
-		l := len(ub)
-		if l > int(length) {
-			err = fmt.Errorf("decimal out of range: %s", dec)
+		res.buffer, err = encodeDecimal(dec, prec)
+		if err != nil {
 			return res, err
 		}
-		// reverse the bytes
-		for i, j := 1, l-1; j >= 0; i, j = i+1, j-1 {
-			buf[i] = ub[j]
-		}
-		res.buffer = buf
+		// first byte length written by typeInfo.writer
+		res.ti.Size = len(res.buffer)
 	case typeBigVarBin, typeBigBinary:
 		switch val := val.(type) {
 		case []byte:
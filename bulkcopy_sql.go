@@ -2,9 +2,11 @@ package mssql
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
 )
 
 type copyin struct {
@@ -43,6 +45,11 @@ func (c *Conn) prepareCopyIn(ctx context.Context, query string) (_ driver.Stmt,
 	return ci, nil
 }
 
+// CopyIn returns the statement to prepare for the bulk copy of columns
+// into table, each Exec of the statement adding a row, and the final Exec
+// without arguments loading them. The columns are those of
+// Conn.CreateBulk, computed columns and the sparse columns of a table with
+// a column set must be omitted.
 func CopyIn(table string, options BulkOptions, columns ...string) string {
 	bulkconfig := &serializableBulkConfig{TableName: table, Options: options, ColumnsName: columns}
 
@@ -65,12 +72,16 @@ func (ci *copyin) Query(v []driver.Value) (r driver.Rows, err error) {
 }
 
 func (ci *copyin) Exec(v []driver.Value) (r driver.Result, err error) {
+	return ci.exec(ci.bulkcopy.ctx, v)
+}
+
+func (ci *copyin) exec(ctx context.Context, v []driver.Value) (r driver.Result, err error) {
 	if ci.closed {
 		return nil, errors.New("copyin query is closed")
 	}
 
 	if len(v) == 0 {
-		rowCount, err := ci.bulkcopy.Done()
+		rowCount, err := ci.bulkcopy.done(ctx)
 		ci.closed = true
 		return driver.RowsAffected(rowCount), err
 	}
@@ -80,7 +91,7 @@ func (ci *copyin) Exec(v []driver.Value) (r driver.Result, err error) {
 		t[i] = val
 	}
 
-	err = ci.bulkcopy.AddRow(t)
+	err = ci.bulkcopy.addRow(ctx, t)
 	if err != nil {
 		return
 	}
@@ -88,6 +99,115 @@ func (ci *copyin) Exec(v []driver.Value) (r driver.Result, err error) {
 	return driver.RowsAffected(0), nil
 }
 
+// ExecContext adds a row, or finishes the bulk copy when called without
+// arguments. If ctx is done the bulk copy is canceled, see Bulk.Cancel,
+// and the context error is returned.
+func (ci *copyin) ExecContext(ctx context.Context, args []driver.NamedValue) (r driver.Result, err error) {
+	if ci.closed {
+		return nil, errors.New("copyin query is closed")
+	}
+	if err = ctx.Err(); err != nil {
+		ci.closed = true
+		if cerr := ci.bulkcopy.Cancel(); cerr != nil {
+			return nil, cerr
+		}
+		return nil, err
+	}
+	v := make([]driver.Value, len(args))
+	for i, arg := range args {
+		v[i] = arg.Value
+	}
+	return ci.exec(ctx, v)
+}
+
+// Close cancels the bulk copy unless it was done.
 func (ci *copyin) Close() (err error) {
-	return nil
+	if ci.closed {
+		return nil
+	}
+	ci.closed = true
+	return ci.bulkcopy.Cancel()
+}
+
+// BulkCopyFromRows copies the rows of src into table with a bulk copy run
+// on conn, and returns the number of rows inserted. The columns of src are
+// copied into the columns of table in the same position; table may have
+// more columns, they are then left to their default. Copying from a query
+// of another server, or of another database/sql driver, avoids loading
+// the rows in memory or inserting them one by one:
+//
+//	rows, err := srcDB.QueryContext(ctx, "select id, name from users")
+//	...
+//	conn, err := destDB.Conn(ctx)
+//	...
+//	n, err := mssql.BulkCopyFromRows(ctx, conn, "dbo.users", rows, mssql.BulkOptions{Tablock: true})
+//
+// src must not be read from conn, and is closed when BulkCopyFromRows
+// returns. A value that cannot be converted to the type of its destination
+// column cancels the copy with an error naming the row and the column.
+func BulkCopyFromRows(ctx context.Context, conn *sql.Conn, table string, src *sql.Rows, opts BulkOptions) (rowcount int64, err error) {
+	defer src.Close()
+	srcTypes, err := src.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+	err = conn.Raw(func(driverConn interface{}) error {
+		cn, ok := driverConn.(*Conn)
+		if !ok {
+			return fmt.Errorf("mssql: BulkCopyFromRows needs a connection of this driver, got %T", driverConn)
+		}
+		b := cn.CreateBulkContext(ctx, table, nil)
+		b.Options = opts
+		if err := b.getMetadata(ctx); err != nil {
+			return err
+		}
+		if len(srcTypes) > len(b.metadata) {
+			return fmt.Errorf("mssql: the source has %d columns, table %s has %d", len(srcTypes), table, len(b.metadata))
+		}
+		for _, col := range b.metadata[:len(srcTypes)] {
+			b.columnsName = append(b.columnsName, col.ColName)
+		}
+		rowcount, err = b.copyRows(src, srcTypes)
+		if err != nil {
+			if cerr := b.Cancel(); cerr != nil {
+				return cerr
+			}
+		}
+		return err
+	})
+	return rowcount, err
+}
+
+// copyRows adds the rows of src to the bulk copy and finishes it.
+func (b *Bulk) copyRows(src *sql.Rows, srcTypes []*sql.ColumnType) (int64, error) {
+	values := make([]interface{}, len(srcTypes))
+	dest := make([]interface{}, len(srcTypes))
+	for i := range dest {
+		dest[i] = &values[i]
+	}
+	// the exact numeric types are scanned as their decimal text
+	numeric := make([]bool, len(srcTypes))
+	for i, ct := range srcTypes {
+		switch ct.DatabaseTypeName() {
+		case "DECIMAL", "NUMERIC", "MONEY", "SMALLMONEY":
+			numeric[i] = true
+		}
+	}
+	for n := 1; src.Next(); n++ {
+		if err := src.Scan(dest...); err != nil {
+			return 0, err
+		}
+		for i, v := range values {
+			if text, ok := v.([]byte); ok && numeric[i] {
+				values[i] = string(text)
+			}
+		}
+		if err := b.AddRow(values); err != nil {
+			return 0, fmt.Errorf("mssql: row %d: %v", n, err)
+		}
+	}
+	if err := src.Err(); err != nil {
+		return 0, err
+	}
+	return b.Done()
 }
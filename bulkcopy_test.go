@@ -4,14 +4,21 @@
 package mssql
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"math"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
 )
 
 func TestBulkcopyWithInvalidNullableType(t *testing.T) {
@@ -308,6 +315,879 @@ func TestBulkcopy(t *testing.T) {
 	testBulkcopy(t, false /*guidConversion*/)
 }
 
+func TestBulkcopyRowsBeforeFlush(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal("failed to pull connection from pool", err)
+	}
+	defer conn.Close()
+
+	tableName := "#table_test_flush"
+	_, err = conn.ExecContext(ctx, "CREATE TABLE "+tableName+" (id int NOT NULL, name nvarchar(50) NULL)")
+	if err != nil {
+		t.Fatal("create table failed: ", err)
+	}
+
+	stmt, err := conn.PrepareContext(ctx, CopyIn(tableName, BulkOptions{RowsBeforeFlush: 3}, "id", "name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	const rows = 10
+	for i := 0; i < rows; i++ {
+		_, err = stmt.Exec(i, fmt.Sprintf("row %d", i))
+		if err != nil {
+			t.Fatal("AddRow failed: ", err)
+		}
+	}
+
+	result, err := stmt.Exec()
+	if err != nil {
+		t.Fatal("bulkcopy failed: ", err)
+	}
+	insertedRowCount, _ := result.RowsAffected()
+	if insertedRowCount != rows {
+		t.Errorf("expected %d rows affected, got %d", rows, insertedRowCount)
+	}
+
+	var rowCount int
+	err = conn.QueryRowContext(ctx, "select count(*) from "+tableName).Scan(&rowCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rowCount != rows {
+		t.Errorf("expected %d rows in table, got %d", rows, rowCount)
+	}
+}
+
+func TestBulkcopyProgressCallback(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal("failed to pull connection from pool", err)
+	}
+	defer conn.Close()
+
+	tableName := "#table_test_progress"
+	_, err = conn.ExecContext(ctx, "CREATE TABLE "+tableName+" (id int NOT NULL)")
+	if err != nil {
+		t.Fatal("create table failed: ", err)
+	}
+
+	var counts []int64
+	opts := BulkOptions{
+		RowsBeforeFlush:  4,
+		ProgressInterval: 3,
+		ProgressCallback: func(rowsSent int64) { counts = append(counts, rowsSent) },
+	}
+	stmt, err := conn.PrepareContext(ctx, CopyIn(tableName, opts, "id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err = stmt.Exec(i); err != nil {
+			t.Fatal("AddRow failed: ", err)
+		}
+	}
+	if _, err = stmt.Exec(); err != nil {
+		t.Fatal("bulkcopy failed: ", err)
+	}
+	if want := []int64{3, 6, 9, 10}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("expected the progress %v, got %v", want, counts)
+	}
+}
+
+func TestBulkReportProgress(t *testing.T) {
+	var counts []int64
+	b := &Bulk{Options: BulkOptions{ProgressCallback: func(rowsSent int64) { counts = append(counts, rowsSent) }}}
+	for _, rows := range []int{defaultProgressInterval, defaultProgressInterval, 2*defaultProgressInterval + 1} {
+		b.numRows = rows
+		b.reportProgress()
+	}
+	for i := 1; i < len(counts); i++ {
+		if counts[i] <= counts[i-1] {
+			t.Errorf("expected increasing counts, got %v", counts)
+		}
+	}
+	if len(counts) != 2 {
+		t.Errorf("expected each count to be reported once, got %v", counts)
+	}
+}
+
+type bulkFlag bool
+
+func TestBulkMakeParamBit(t *testing.T) {
+	yes, no := true, false
+	b := &Bulk{}
+	col := columnStruct{ti: typeInfo{TypeId: typeBitN, Size: 1}}
+	for _, tc := range []struct {
+		in   interface{}
+		want []byte
+	}{
+		{true, []byte{1}},
+		{false, []byte{0}},
+		{sql.NullBool{Bool: true, Valid: true}, []byte{1}},
+		{sql.NullBool{}, nil},
+		{&yes, []byte{1}},
+		{&no, []byte{0}},
+		{(*bool)(nil), nil},
+		{bulkFlag(true), []byte{1}},
+	} {
+		p, err := b.makeParam(tc.in, col)
+		if err != nil {
+			t.Errorf("%T %v: %v", tc.in, tc.in, err)
+			continue
+		}
+		if !bytes.Equal(p.buffer, tc.want) || p.ti.Size != len(tc.want) {
+			t.Errorf("%T %v: expected %v, got %v with size %d", tc.in, tc.in, tc.want, p.buffer, p.ti.Size)
+		}
+	}
+	if _, err := b.makeParam(1, col); err == nil {
+		t.Error("expected an error for an int value")
+	}
+}
+
+func TestBulkcopyRowCount(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal("failed to pull connection from pool", err)
+	}
+	defer conn.Close()
+
+	tableName := "#table_test_rowcount"
+	_, err = conn.ExecContext(ctx, "CREATE TABLE "+tableName+" (id int NOT NULL)")
+	if err != nil {
+		t.Fatal("create table failed: ", err)
+	}
+
+	const rows = 10
+	options := []BulkOptions{
+		{},
+		{RowsBeforeFlush: 4},
+		{RowsPerBatch: 3, KilobytesPerBatch: 1},
+	}
+	var total int
+	for _, opts := range options {
+		var rowCount int64
+		err = conn.Raw(func(driverConn interface{}) error {
+			bulk := driverConn.(*Conn).CreateBulkContext(ctx, tableName, []string{"id"})
+			bulk.Options = opts
+			for i := 0; i < rows; i++ {
+				if err := bulk.AddRow([]interface{}{i}); err != nil {
+					return err
+				}
+			}
+			var err error
+			rowCount, err = bulk.Done()
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%+v: bulkcopy failed: %v", opts, err)
+		}
+		if rowCount != rows {
+			t.Errorf("%+v: expected %d rows, got %d", opts, rows, rowCount)
+		}
+		total += rows
+	}
+
+	// the final Exec of a CopyIn statement returns the rows copied
+	for _, opts := range options {
+		stmt, err := conn.PrepareContext(ctx, CopyIn(tableName, opts, "id"))
+		if err != nil {
+			t.Fatal("prepare failed: ", err)
+		}
+		for i := 0; i < rows; i++ {
+			if _, err = stmt.ExecContext(ctx, i); err != nil {
+				t.Fatalf("%+v: AddRow failed: %v", opts, err)
+			}
+		}
+		res, err := stmt.ExecContext(ctx)
+		if err != nil {
+			t.Fatalf("%+v: bulkcopy failed: %v", opts, err)
+		}
+		rowCount, err := res.RowsAffected()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rowCount != rows {
+			t.Errorf("%+v: expected RowsAffected %d, got %d", opts, rows, rowCount)
+		}
+		if err = stmt.Close(); err != nil {
+			t.Fatal(err)
+		}
+		total += rows
+	}
+
+	var count int
+	err = conn.QueryRowContext(ctx, "select count(*) from "+tableName).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != total {
+		t.Errorf("expected %d rows in table, got %d", total, count)
+	}
+}
+
+func TestBulkcopyCancel(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal("failed to pull connection from pool", err)
+	}
+	defer conn.Close()
+
+	tableName := "#table_test_cancel"
+	_, err = conn.ExecContext(ctx, "CREATE TABLE "+tableName+" (id int NOT NULL, name nvarchar(50) NULL)")
+	if err != nil {
+		t.Fatal("create table failed: ", err)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := tx.PrepareContext(ctx, CopyIn(tableName, BulkOptions{RowsBeforeFlush: 3}, "id", "name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadCtx, cancel := context.WithCancel(ctx)
+	const rows = 5
+	for i := 0; i < rows; i++ {
+		_, err = stmt.ExecContext(loadCtx, i, fmt.Sprintf("row %d", i))
+		if err != nil {
+			t.Fatal("AddRow failed: ", err)
+		}
+	}
+	cancel()
+	// database/sql does not call the statement of the transaction once
+	// loadCtx is done, closing it cancels the rows not flushed yet
+	_, err = stmt.ExecContext(loadCtx, rows, "canceled")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if err = stmt.Close(); err != nil {
+		t.Fatal("close failed: ", err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal("rollback failed: ", err)
+	}
+
+	var rowCount int
+	err = conn.QueryRowContext(ctx, "select count(*) from "+tableName).Scan(&rowCount)
+	if err != nil {
+		t.Fatal("connection is not usable after the canceled bulk copy: ", err)
+	}
+	if rowCount != 0 {
+		t.Errorf("expected an empty table, got %d rows", rowCount)
+	}
+}
+
+// packetTypes returns the types of the request packets.
+func (t *scriptedTransport) packetTypes() (types []packetType) {
+	for b := t.requests.Bytes(); len(b) > 0; b = b[binary.BigEndian.Uint16(b[2:]):] {
+		types = append(types, packetType(b[0]))
+	}
+	return
+}
+
+func TestBulkCancelOnClose(t *testing.T) {
+	done := func(status uint16) []byte {
+		b := make([]byte, 13)
+		b[0] = byte(tokenDone)
+		binary.LittleEndian.PutUint16(b[1:], status)
+		return b
+	}
+	for _, rollback := range []bool{false, true} {
+		transport := &scriptedTransport{}
+		// the INSERT BULK statement, then the acknowledgement of the attention
+		transport.reply(done(0))
+		transport.reply(done(doneAttn))
+		transport.reply(done(0))
+		c := testConn(newConnector(msdsn.Config{}, nil), transport)
+		ci := &copyin{cn: c, bulkcopy: c.CreateBulk("t", []string{"id"})}
+		ci.bulkcopy.metadata = []columnStruct{{ColName: "id", ti: typeInfo{TypeId: typeIntN, Size: 4, Writer: writeByteLenType}}}
+		if _, err := ci.ExecContext(context.Background(), []driver.NamedValue{{Ordinal: 1, Value: int64(1)}}); err != nil {
+			t.Fatal(err)
+		}
+
+		// the rows are sent in a message the server ignores, then the
+		// attention aborts the load
+		want := []packetType{packSQLBatch, packBulkLoadBCP, packAttention}
+		var err error
+		if rollback {
+			err = c.Rollback()
+			want = append(want, packTransMgrReq)
+		} else {
+			err = ci.Close()
+		}
+		if err != nil {
+			t.Fatalf("rollback=%v: %v", rollback, err)
+		}
+		if got := transport.packetTypes(); !reflect.DeepEqual(got, want) {
+			t.Errorf("rollback=%v: expected the packets %v, got %v", rollback, want, got)
+		} else if bulk := transport.requests.Bytes()[binary.BigEndian.Uint16(transport.requests.Bytes()[2:]):]; bulk[1]&2 == 0 {
+			t.Errorf("rollback=%v: expected the rows to be ignored, got the status %#x", rollback, bulk[1])
+		}
+		if !c.connectionGood || c.bulk != nil {
+			t.Errorf("rollback=%v: expected the connection to remain usable", rollback)
+		}
+	}
+}
+
+func TestBulkcopyNoTransaction(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal("failed to pull connection from pool", err)
+	}
+	defer conn.Close()
+
+	tableName := "#table_test_notransaction"
+	_, err = conn.ExecContext(ctx, "CREATE TABLE "+tableName+" (id int NOT NULL CHECK (id >= 0))")
+	if err != nil {
+		t.Fatal("create table failed: ", err)
+	}
+	_, err = conn.ExecContext(ctx, "SET IMPLICIT_TRANSACTIONS ON")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.ExecContext(ctx, "IF @@TRANCOUNT > 0 ROLLBACK; SET IMPLICIT_TRANSACTIONS OFF")
+
+	// the second batch holds a row violating the check constraint
+	load := func(noTransaction bool) (count int) {
+		options := BulkOptions{RowsBeforeFlush: 3, CheckConstraints: true, NoTransaction: noTransaction}
+		err = conn.Raw(func(driverConn interface{}) error {
+			bulk := driverConn.(*Conn).CreateBulkContext(ctx, tableName, []string{"id"})
+			bulk.Options = options
+			for _, id := range []int{1, 2, 3, 4, -5, 6} {
+				if err := bulk.AddRow([]interface{}{id}); err != nil {
+					return err
+				}
+			}
+			_, err := bulk.Done()
+			return err
+		})
+		if err == nil {
+			t.Fatalf("NoTransaction=%v: expected the bad row to fail the bulk copy", noTransaction)
+		}
+		_, err = conn.ExecContext(ctx, "IF @@TRANCOUNT > 0 ROLLBACK")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = conn.QueryRowContext(ctx, "select count(*) from "+tableName).Scan(&count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = conn.ExecContext(ctx, "IF @@TRANCOUNT > 0 ROLLBACK")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	if count := load(false); count != 0 {
+		t.Errorf("expected the implicit transaction to be rolled back, got %d rows", count)
+	}
+	if count := load(true); count != 3 {
+		t.Errorf("expected the first batch to be committed, got %d rows", count)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, CopyIn(tableName, BulkOptions{NoTransaction: true}, "id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if _, err = stmt.Exec(7); err == nil {
+		t.Error("expected NoTransaction to be refused in a transaction")
+	}
+}
+
+func TestBulkcopyKeepNulls(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal("failed to pull connection from pool", err)
+	}
+	defer conn.Close()
+
+	for _, keepNulls := range []bool{true, false} {
+		tableName := "#table_test_keepnulls"
+		_, err = conn.ExecContext(ctx, "IF OBJECT_ID('tempdb.."+tableName+"') IS NOT NULL DROP TABLE "+tableName+";"+
+			"CREATE TABLE "+tableName+" (id int NOT NULL, val int NULL DEFAULT 42)")
+		if err != nil {
+			t.Fatal("create table failed: ", err)
+		}
+
+		stmt, err := conn.PrepareContext(ctx, CopyIn(tableName, BulkOptions{KeepNulls: keepNulls}, "id", "val"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = stmt.Exec(1, nil); err != nil {
+			t.Fatal("AddRow failed: ", err)
+		}
+		if _, err = stmt.Exec(); err != nil {
+			t.Fatal("bulkcopy failed: ", err)
+		}
+		stmt.Close()
+
+		var val sql.NullInt64
+		err = conn.QueryRowContext(ctx, "select val from "+tableName).Scan(&val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keepNulls && val.Valid {
+			t.Errorf("KeepNulls: expected NULL, got %d", val.Int64)
+		}
+		if !keepNulls && (!val.Valid || val.Int64 != 42) {
+			t.Errorf("expected the column default 42, got %v", val)
+		}
+	}
+}
+
+func TestBulkcopyCheckConstraints(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal("failed to pull connection from pool", err)
+	}
+	defer conn.Close()
+
+	for _, checkConstraints := range []bool{true, false} {
+		tableName := "#table_test_checkconstraints"
+		_, err = conn.ExecContext(ctx, "IF OBJECT_ID('tempdb.."+tableName+"') IS NOT NULL DROP TABLE "+tableName+";"+
+			"CREATE TABLE "+tableName+" (id int NOT NULL CHECK (id > 0))")
+		if err != nil {
+			t.Fatal("create table failed: ", err)
+		}
+
+		stmt, err := conn.PrepareContext(ctx, CopyIn(tableName, BulkOptions{CheckConstraints: checkConstraints}, "id"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = stmt.Exec(-1); err != nil {
+			t.Fatal("AddRow failed: ", err)
+		}
+		_, err = stmt.Exec()
+		stmt.Close()
+		if checkConstraints {
+			if err == nil {
+				t.Error("CheckConstraints: expected the check constraint to reject the row")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("bulkcopy failed: ", err)
+		}
+
+		var rowCount int
+		err = conn.QueryRowContext(ctx, "select count(*) from "+tableName).Scan(&rowCount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rowCount != 1 {
+			t.Errorf("expected the row to be loaded without checking the constraint, got %d rows", rowCount)
+		}
+	}
+}
+
+func TestBulkcopyKeepIdentity(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal("failed to pull connection from pool", err)
+	}
+	defer conn.Close()
+
+	for _, keepIdentity := range []bool{true, false} {
+		tableName := "#table_test_keepidentity"
+		_, err = conn.ExecContext(ctx, "IF OBJECT_ID('tempdb.."+tableName+"') IS NOT NULL DROP TABLE "+tableName+";"+
+			"CREATE TABLE "+tableName+" (id int IDENTITY(1,1) NOT NULL, name nvarchar(10) NOT NULL)")
+		if err != nil {
+			t.Fatal("create table failed: ", err)
+		}
+
+		stmt, err := conn.PrepareContext(ctx, CopyIn(tableName, BulkOptions{KeepIdentity: keepIdentity}, "id", "name"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []int{10, 20, 30} {
+			if _, err = stmt.Exec(id, fmt.Sprint("row", id)); err != nil {
+				t.Fatal("AddRow failed: ", err)
+			}
+		}
+		if _, err = stmt.Exec(); err != nil {
+			t.Fatal("bulkcopy failed: ", err)
+		}
+		stmt.Close()
+
+		rows, err := conn.QueryContext(ctx, "select id, name from "+tableName+" order by name")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for rows.Next() {
+			var id int
+			var name string
+			if err = rows.Scan(&id, &name); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, fmt.Sprint(id, " ", name))
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"10 row10", "20 row20", "30 row30"}
+		if !keepIdentity {
+			want = []string{"1 row10", "2 row20", "3 row30"}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("KeepIdentity=%v: expected %v, got %v", keepIdentity, want, got)
+		}
+	}
+}
+
+func TestBulkColMetadataFlags(t *testing.T) {
+	b := &Bulk{
+		cn: &Conn{sess: &tdsSession{loginAck: loginAckStruct{TDSVersion: verTDS74}}},
+		bulkColumns: []columnStruct{
+			{ColName: "id", Flags: colFlagIdentity | 0x08, ti: typeInfo{TypeId: typeIntN, Size: 4}},
+			{ColName: "total", Flags: colFlagComputed | colFlagSparseColumnSet | colFlagNullable | 0x08 | 0x40, ti: typeInfo{TypeId: typeIntN, Size: 4}},
+		},
+	}
+	md := b.createColMetadata()
+	// the token, the column count, then the user type and the flags of each column
+	first := binary.LittleEndian.Uint16(md[7:])
+	second := binary.LittleEndian.Uint16(md[7+2+2+1+2*len("id")+4:])
+	if first != colFlagIdentity|0x08 {
+		t.Errorf("expected the flags of the identity column to be kept, got %#x", first)
+	}
+	if second != colFlagNullable|0x08 {
+		t.Errorf("expected only the flags of the values sent, got %#x", second)
+	}
+}
+
+func TestBulkcopyComputedColumn(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal("failed to pull connection from pool", err)
+	}
+	defer conn.Close()
+
+	tableName := "#table_test_computed"
+	_, err = conn.ExecContext(ctx, "IF OBJECT_ID('tempdb.."+tableName+"') IS NOT NULL DROP TABLE "+tableName+";"+
+		"CREATE TABLE "+tableName+" (id int IDENTITY(1,1) NOT NULL, price int NOT NULL, quantity int NOT NULL,"+
+		" total AS price * quantity, note varchar(10) SPARSE NULL, attributes xml COLUMN_SET FOR ALL_SPARSE_COLUMNS)")
+	if err != nil {
+		t.Fatal("create table failed: ", err)
+	}
+
+	// the computed column can not be loaded, nor the sparse columns of the column set
+	for _, columns := range [][]string{{"price", "quantity", "total"}, {"price", "quantity", "note"}} {
+		stmt, err := conn.PrepareContext(ctx, CopyIn(tableName, BulkOptions{}, columns...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := make([]interface{}, len(columns))
+		for i := range values {
+			values[i] = 1
+		}
+		if _, err = stmt.Exec(values...); err == nil || !strings.Contains(err.Error(), columns[2]) {
+			t.Errorf("expected an error naming the column %s, got %v", columns[2], err)
+		}
+		stmt.Close()
+	}
+
+	stmt, err := conn.PrepareContext(ctx, CopyIn(tableName, BulkOptions{}, "price", "quantity"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		if _, err = stmt.Exec(10*i, i); err != nil {
+			t.Fatal("AddRow failed: ", err)
+		}
+	}
+	if _, err = stmt.Exec(); err != nil {
+		t.Fatal("bulkcopy failed: ", err)
+	}
+	stmt.Close()
+
+	rows, err := conn.QueryContext(ctx, "select id, total, note from "+tableName+" order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for rows.Next() {
+		var id, total int
+		var note sql.NullString
+		if err = rows.Scan(&id, &total, &note); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprint(id, " ", total, " ", note.Valid))
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1 10 false", "2 40 false", "3 90 false"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestBulkcopyHints(t *testing.T) {
+	opts := BulkOptions{Tablock: true, KeepIdentity: true, Hints: []string{"tablock", " check_constraints ", "ignore_triggers", "keep_identity", "ROWS_PER_BATCH = 100", "ORDER([id] ASC, name desc)"}}
+	got, err := opts.insertBulkOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"KEEP_IDENTITY", "TABLOCK", "CHECK_CONSTRAINTS", "ROWS_PER_BATCH = 100", "ORDER([id] ASC, name desc)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	for _, hint := range []string{"NOLOCK", "TABLOCK) ; drop table t --", "ORDER(id; drop table t)", "ROWS_PER_BATCH = -1"} {
+		if _, err = (BulkOptions{Hints: []string{hint}}).insertBulkOptions(); err == nil {
+			t.Errorf("expected hint %q to be rejected", hint)
+		}
+	}
+	if _, err = (BulkOptions{Order: []string{"id"}, Hints: []string{"ORDER(id)"}}).insertBulkOptions(); err == nil {
+		t.Error("expected an ORDER hint to conflict with the Order option")
+	}
+	for _, opts := range []BulkOptions{
+		{CheckConstraints: true, Hints: []string{"IGNORE_CONSTRAINTS"}},
+		{Hints: []string{"ignore_constraints", "check_constraints"}},
+		{FireTriggers: true, Hints: []string{"IGNORE_TRIGGERS"}},
+		{Hints: []string{"FIRE_TRIGGERS", "IGNORE_TRIGGERS"}},
+	} {
+		if _, err = opts.insertBulkOptions(); err == nil {
+			t.Errorf("expected the hints %v to conflict", opts.Hints)
+		}
+	}
+}
+
+func TestBulkMatchColumnsMetadata(t *testing.T) {
+	transport := &scriptedTransport{}
+	b := testConn(newConnector(msdsn.Config{}, nil), transport).CreateBulk("t", []string{"name"})
+	b.metadata = []columnStruct{
+		{ColName: "id", ti: typeInfo{TypeId: typeIntN, Size: 4}},
+		{ColName: "name", ti: typeInfo{TypeId: typeNVarChar, Size: 20}},
+	}
+	if err := b.matchColumns(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if transport.requests.Len() != 0 {
+		t.Errorf("expected the metadata to be reused, got the requests % x", transport.requests.Bytes())
+	}
+	if len(b.bulkColumns) != 1 || b.bulkColumns[0].ColName != "name" {
+		t.Errorf("expected the name column, got %v", b.bulkColumns)
+	}
+}
+
+func TestBulkcopyHintsStatement(t *testing.T) {
+	transport := &scriptedTransport{}
+	transport.reply([]byte{byte(tokenDone), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	b := testConn(newConnector(msdsn.Config{}, nil), transport).CreateBulk("t", []string{"id"})
+	b.Options.Hints = []string{"tablock", "ignore_constraints", "ORDER([id] ASC)"}
+	b.bulkColumns = []columnStruct{{ColName: "id", ti: typeInfo{TypeId: typeIntN, Size: 4}}}
+	if err := b.sendBulkCommand(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// ignoring the constraints is the default, there is no such option
+	want := str2ucs2("INSERT BULK t ([id] int) WITH (TABLOCK,ORDER([id] ASC))")
+	if !bytes.Contains(transport.requests.Bytes(), want) {
+		t.Errorf("expected the hints in the INSERT BULK statement, got % x", transport.requests.Bytes())
+	}
+}
+
+func TestBulkcopyWithHints(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal("failed to pull connection from pool", err)
+	}
+	defer conn.Close()
+
+	tableName := "#table_test_hints"
+	_, err = conn.ExecContext(ctx, "CREATE TABLE "+tableName+" (id int NOT NULL PRIMARY KEY CHECK (id > 1))")
+	if err != nil {
+		t.Fatal("create table failed: ", err)
+	}
+	// the check constraint is not enforced
+	hints := []string{"TABLOCK", "ORDER([id] ASC)", "IGNORE_CONSTRAINTS", "IGNORE_TRIGGERS"}
+	stmt, err := conn.PrepareContext(ctx, CopyIn(tableName, BulkOptions{Hints: hints}, "id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for i := 1; i <= 3; i++ {
+		if _, err = stmt.Exec(i); err != nil {
+			t.Fatal("AddRow failed: ", err)
+		}
+	}
+	result, err := stmt.Exec()
+	if err != nil {
+		t.Fatal("bulkcopy failed: ", err)
+	}
+	if n, _ := result.RowsAffected(); n != 3 {
+		t.Errorf("expected 3 rows, got %d", n)
+	}
+}
+
+func TestBulkcopyColumnCountMismatch(t *testing.T) {
+	// no connection is needed, the check happens before anything is sent
+	b := &Bulk{ctx: context.Background(), tablename: "#table_test", columnsName: []string{"a", "b", "c"}}
+
+	err := b.AddRow([]interface{}{1, 2})
+	var countErr BulkCopyColumnCountError
+	if !errors.As(err, &countErr) {
+		t.Fatalf("expected BulkCopyColumnCountError, got %v", err)
+	}
+	if countErr.Expected != 3 || countErr.Got != 2 {
+		t.Errorf("expected Expected=3 Got=2, got Expected=%d Got=%d", countErr.Expected, countErr.Got)
+	}
+	if b.headerSent {
+		t.Error("bulk command must not be sent when the column count does not match")
+	}
+}
+
+func TestBulkcopyVariant(t *testing.T) {
+	ctx := context.Background()
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	tableName := "#table_test_variant"
+	if _, err := conn.Exec("create table " + tableName + " (id int, v sql_variant)"); err != nil {
+		t.Fatal(err)
+	}
+
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+	values := []struct {
+		in       interface{}
+		baseType string
+	}{
+		{int64(42), "bigint"},
+		{int32(-7), "int"},
+		{"hello", "nvarchar"},
+		{[]byte{1, 2, 3}, "varbinary"},
+		{tm, "datetimeoffset"},
+		{3.5, "float"},
+		{true, "bit"},
+		{nil, ""},
+	}
+
+	stmt, err := conn.PrepareContext(ctx, CopyIn(tableName, BulkOptions{}, "id", "v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range values {
+		if _, err = stmt.Exec(i, v.in); err != nil {
+			t.Fatal("AddRow failed: ", err)
+		}
+	}
+	if _, err = stmt.Exec(); err != nil {
+		t.Fatal("bulkcopy failed: ", err)
+	}
+	stmt.Close()
+
+	rows, err := conn.QueryContext(ctx, "select id, v, cast(isnull(sql_variant_property(v, 'BaseType'), '') as nvarchar(20)) from "+tableName+" order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var id int
+		var got interface{}
+		var baseType string
+		if err = rows.Scan(&id, &got, &baseType); err != nil {
+			t.Fatal(err)
+		}
+		want := values[id]
+		if baseType != want.baseType {
+			t.Errorf("row %d: expected base type %q, got %q", id, want.baseType, baseType)
+		}
+		if wantTime, ok := want.in.(time.Time); ok {
+			if gotTime, ok := got.(time.Time); !ok || !gotTime.Equal(wantTime) {
+				t.Errorf("row %d: expected %v, got %v", id, wantTime, got)
+			}
+		} else if !compareValue(got, want.in) && !(want.in == nil && got == nil) {
+			t.Errorf("row %d: expected %v (%T), got %v (%T)", id, want.in, want.in, got, got)
+		}
+		n++
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(values) {
+		t.Errorf("expected %d rows, got %d", len(values), n)
+	}
+}
+
 func compareValue(a interface{}, expected interface{}) bool {
 	if got, ok := a.([]uint8); ok {
 		if _, ok := expected.([]uint8); !ok {
@@ -446,3 +1326,78 @@ func setupTable(ctx context.Context, t *testing.T, conn *sql.Conn, tableName str
 	}
 	return
 }
+
+func TestBulkCopyFromRows(t *testing.T) {
+	ctx := context.Background()
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	const rowCount = 2500
+	db.Exec("drop table if exists dbo.bulk_from_rows_src")
+	db.Exec("drop table if exists dbo.bulk_from_rows_dest")
+	_, err := db.Exec(`create table dbo.bulk_from_rows_src (id int not null, name nvarchar(50) null, price decimal(10, 2) null, created datetime2 null, active bit null)
+		create table dbo.bulk_from_rows_dest (item_id int not null, item_name nvarchar(50) null, item_price decimal(10, 2) null, created datetime2 null, active bit null, note varchar(10) null default 'copied')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("drop table dbo.bulk_from_rows_src; drop table dbo.bulk_from_rows_dest")
+	_, err = db.Exec(`insert into dbo.bulk_from_rows_src
+		select top (@p1) n, case when n % 10 = 0 then null else 'item ' + cast(n as nvarchar(10)) end,
+			n * 1.25, dateadd(second, n, '2020-01-02T03:04:05.1234567'), n % 2
+		from (select row_number() over (order by a.object_id) as n from sys.all_objects a cross join sys.all_objects b) t`, rowCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	src, err := db.QueryContext(ctx, "select id, name, price, created, active from dbo.bulk_from_rows_src order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := BulkCopyFromRows(ctx, conn, "dbo.bulk_from_rows_dest", src, BulkOptions{Tablock: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != rowCount {
+		t.Errorf("expected %d rows copied, got %d", rowCount, n)
+	}
+
+	var differ, copied int
+	err = conn.QueryRowContext(ctx, `select
+		(select count(*) from dbo.bulk_from_rows_src s full join dbo.bulk_from_rows_dest d on s.id = d.item_id
+			where d.item_id is null or s.id is null or isnull(s.name, '-') <> isnull(d.item_name, '-')
+			or s.price <> d.item_price or s.created <> d.created or s.active <> d.active),
+		(select count(*) from dbo.bulk_from_rows_dest where note = 'copied')`).Scan(&differ, &copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if differ != 0 || copied != rowCount {
+		t.Errorf("expected the copied rows to match the source, %d differ and %d have the default note", differ, copied)
+	}
+
+	// a value that does not match its destination column
+	src, err = db.QueryContext(ctx, "select 'not a number'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = BulkCopyFromRows(ctx, conn, "dbo.bulk_from_rows_dest", src, BulkOptions{})
+	if err == nil || !strings.Contains(err.Error(), "row 1") || !strings.Contains(err.Error(), "column item_id") {
+		t.Errorf("expected an error naming the row and the column, got %v", err)
+	}
+	if err = conn.PingContext(ctx); err != nil {
+		t.Errorf("expected the connection to be usable after the failed copy, got %v", err)
+	}
+
+	src, err = db.QueryContext(ctx, "select 1, 2, 3, 4, 5, 6, 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = BulkCopyFromRows(ctx, conn, "dbo.bulk_from_rows_dest", src, BulkOptions{}); err == nil {
+		t.Error("expected an error for a source with more columns than the table")
+	}
+}
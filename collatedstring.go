@@ -0,0 +1,193 @@
+package mssql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/microsoft/go-mssqldb/internal/cp"
+)
+
+// CollatedString sends Value as an nvarchar parameter having the collation
+// named by Collation, such as "Latin1_General_100_CI_AS" or
+// "SQL_Latin1_General_CP1_CS_AS", instead of the default collation of the
+// database. Comparing the parameter with a column of the same collation
+// then needs no conversion:
+//
+//	rows, err := db.Query("select id from users where login = @p1",
+//		mssql.CollatedString{Value: login, Collation: "Latin1_General_CS_AS"})
+//
+// Windows collations of the common languages are supported with their
+// version and sensitivity suffixes. SQL collations are limited to the
+// SQL_Latin1_General_CP1 ones.
+type CollatedString struct {
+	Value     string
+	Collation string
+}
+
+// collation flags, the bits 20 to 27 of cp.Collation.LcidAndFlags
+// http://msdn.microsoft.com/en-us/library/dd340437.aspx
+const (
+	collationIgnoreCase   = 0x01
+	collationIgnoreAccent = 0x02
+	collationIgnoreKana   = 0x04
+	collationIgnoreWidth  = 0x08
+	collationBinary       = 0x10
+	collationBinary2      = 0x20
+	collationUTF8         = 0x40
+)
+
+// collationLCIDs holds the locale of the Windows collation designators.
+var collationLCIDs = map[string]uint32{
+	"albanian":              0x041c,
+	"arabic":                0x0401,
+	"chinese_prc":           0x0804,
+	"chinese_taiwan_stroke": 0x0404,
+	"croatian":              0x041a,
+	"cyrillic_general":      0x0419,
+	"czech":                 0x0405,
+	"danish_norwegian":      0x0406,
+	"estonian":              0x0425,
+	"finnish_swedish":       0x040b,
+	"french":                0x040c,
+	"german_phonebook":      0x10407,
+	"greek":                 0x0408,
+	"hebrew":                0x040d,
+	"hungarian":             0x040e,
+	"icelandic":             0x040f,
+	"japanese":              0x0411,
+	"japanese_xjis":         0x0411,
+	"korean_wansung":        0x0412,
+	"latin1_general":        0x0409,
+	"latvian":               0x0426,
+	"lithuanian":            0x0427,
+	"modern_spanish":        0x0c0a,
+	"polish":                0x0415,
+	"romanian":              0x0418,
+	"slovak":                0x041b,
+	"slovenian":             0x0424,
+	"thai":                  0x041e,
+	"traditional_spanish":   0x040a,
+	"turkish":               0x041f,
+	"ukrainian":             0x0422,
+	"vietnamese":            0x042a,
+}
+
+// sqlCollationSortIds holds the sort order of the supported SQL
+// collations, without their SQL_ prefix.
+var sqlCollationSortIds = map[string]uint8{
+	"latin1_general_cp1_cs_as":      51,
+	"latin1_general_cp1_ci_as":      52,
+	"latin1_general_pref_cp1_ci_as": 53,
+	"latin1_general_cp1_ci_ai":      54,
+}
+
+// parseCollation returns the collation sent in the TYPE_INFO of a
+// parameter for a collation name.
+func parseCollation(name string) (col cp.Collation, err error) {
+	lower := strings.ToLower(name)
+	if strings.HasPrefix(lower, "sql_") {
+		sortId, ok := sqlCollationSortIds[lower[4:]]
+		if !ok {
+			return col, fmt.Errorf("mssql: unsupported SQL collation %q", name)
+		}
+		// the sensitivity of SQL collations follows the same suffixes
+		parts := strings.Split(lower, "_")
+		flags, _ := collationFlags(parts[len(parts)-2:])
+		col.LcidAndFlags = 0x0409 | flags<<20
+		col.SortId = sortId
+		return col, nil
+	}
+
+	parts := strings.Split(lower, "_")
+	// the designator is the longest known prefix, as in Chinese_Taiwan_Stroke
+	n := 0
+	var lcid uint32
+	for i := 1; i <= len(parts); i++ {
+		if l, ok := collationLCIDs[strings.Join(parts[:i], "_")]; ok {
+			n, lcid = i, l
+		}
+	}
+	if n == 0 {
+		return col, fmt.Errorf("mssql: unknown collation %q", name)
+	}
+	parts = parts[n:]
+	var version uint32
+	if len(parts) > 0 {
+		switch parts[0] {
+		case "90":
+			version = 1
+		case "100":
+			version = 2
+		case "140":
+			version = 3
+		}
+		if version != 0 {
+			parts = parts[1:]
+		}
+	}
+	flags, ok := collationFlags(parts)
+	if !ok {
+		return col, fmt.Errorf("mssql: unknown collation %q", name)
+	}
+	col.LcidAndFlags = lcid | flags<<20 | version<<28
+	return col, nil
+}
+
+// collationFlags returns the flags of the sensitivity suffixes of a
+// collation name, ok is false for an unknown or missing suffix.
+func collationFlags(suffixes []string) (flags uint32, ok bool) {
+	var caseSet, accentSet, kana, width, binary bool
+	for _, s := range suffixes {
+		switch s {
+		case "ci":
+			flags |= collationIgnoreCase
+			caseSet = true
+		case "cs":
+			caseSet = true
+		case "ai":
+			flags |= collationIgnoreAccent
+			accentSet = true
+		case "as":
+			accentSet = true
+		case "ks":
+			kana = true
+		case "ws":
+			width = true
+		case "bin":
+			flags |= collationBinary
+			binary = true
+		case "bin2":
+			flags |= collationBinary2
+			binary = true
+		case "utf8":
+			flags |= collationUTF8
+		case "sc", "vss":
+			// no flag, supplementary characters are handled by the version
+		default:
+			return 0, false
+		}
+	}
+	if binary {
+		return flags, !caseSet && !accentSet
+	}
+	if !caseSet || !accentSet {
+		return 0, false
+	}
+	if !kana {
+		flags |= collationIgnoreKana
+	}
+	if !width {
+		flags |= collationIgnoreWidth
+	}
+	return flags, true
+}
+
+func makeCollatedStringParam(s CollatedString) (res param, err error) {
+	col, err := parseCollation(s.Collation)
+	if err != nil {
+		return
+	}
+	res = makeStrParam(s.Value)
+	res.ti.Collation = col
+	return
+}
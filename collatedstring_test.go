@@ -0,0 +1,88 @@
+package mssql
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/internal/cp"
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func TestParseCollation(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		want cp.Collation
+	}{
+		{"Latin1_General_CI_AS", cp.Collation{LcidAndFlags: 0x00d00409}},
+		{"latin1_general_cs_as", cp.Collation{LcidAndFlags: 0x00c00409}},
+		{"Latin1_General_CS_AS_KS_WS", cp.Collation{LcidAndFlags: 0x00000409}},
+		{"Latin1_General_100_CI_AI", cp.Collation{LcidAndFlags: 0x20f00409}},
+		{"Latin1_General_100_CI_AS_SC_UTF8", cp.Collation{LcidAndFlags: 0x24d00409}},
+		{"Latin1_General_BIN2", cp.Collation{LcidAndFlags: 0x02000409}},
+		{"Japanese_XJIS_140_CI_AS", cp.Collation{LcidAndFlags: 0x30d00411}},
+		{"Chinese_Taiwan_Stroke_CI_AS", cp.Collation{LcidAndFlags: 0x00d00404}},
+		{"German_PhoneBook_BIN", cp.Collation{LcidAndFlags: 0x01010407}},
+		{"SQL_Latin1_General_CP1_CI_AS", cp.Collation{LcidAndFlags: 0x00d00409, SortId: 52}},
+		{"SQL_Latin1_General_CP1_CS_AS", cp.Collation{LcidAndFlags: 0x00c00409, SortId: 51}},
+	}
+	for _, tt := range tests {
+		got, err := parseCollation(tt.name)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+	for _, name := range []string{"", "Klingon_CI_AS", "Latin1_General", "Latin1_General_CI", "Latin1_General_CI_AS_XX", "Latin1_General_BIN_CI", "SQL_EBCDIC037_CP1_CS_AS"} {
+		if _, err := parseCollation(name); err == nil {
+			t.Errorf("expected an error for %q", name)
+		}
+	}
+}
+
+func TestCollatedStringParam(t *testing.T) {
+	t.Parallel()
+	p, err := (&Stmt{}).makeParam(CollatedString{Value: "abc", Collation: "SQL_Latin1_General_CP1_CS_AS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = writeTypeInfo(&buf, &p.ti, false, msdsn.EncodeParameters{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{typeNVarChar, 6, 0, 0x09, 0x04, 0xc0, 0x00, 51}; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("expected type info % x, got % x", want, buf.Bytes())
+	}
+	if decl := makeDecl(p.ti); decl != "nvarchar(3)" {
+		t.Errorf("expected nvarchar(3), got %s", decl)
+	}
+	if s, _ := ucs22str(p.buffer); s != "abc" {
+		t.Errorf("unexpected value %s", s)
+	}
+
+	if _, err = (&Stmt{}).makeParam(CollatedString{Value: "abc", Collation: "Klingon_CI_AS"}); err == nil {
+		t.Error("expected an error for an unknown collation")
+	}
+}
+
+func TestCollatedStringQuery(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	// the default collation of the database can not match both
+	for _, collation := range []string{"French_100_CI_AS", "Japanese_CS_AS"} {
+		var got string
+		err := db.QueryRow("select cast(sql_variant_property(cast(@p1 as sql_variant), 'Collation') as nvarchar(128))",
+			CollatedString{Value: "élan", Collation: collation}).Scan(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != collation {
+			t.Errorf("expected the parameter to have the collation %s, got %s", collation, got)
+		}
+	}
+}
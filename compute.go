@@ -0,0 +1,66 @@
+package mssql
+
+import "fmt"
+
+// computeOps names the aggregate operators of the ALTMETADATA token.
+var computeOps = map[byte]string{
+	0x09: "count_big",
+	0x30: "stdev",
+	0x31: "stdevp",
+	0x32: "var",
+	0x33: "varp",
+	0x4b: "count",
+	0x4d: "sum",
+	0x4f: "avg",
+	0x51: "min",
+	0x52: "max",
+}
+
+// parseAltMetadata reads the columns of the rows of a COMPUTE clause, sent
+// in ALTROW tokens with the id returned. The columns not named by the
+// query are named after their aggregate, as "sum".
+func parseAltMetadata(r *tdsBuffer, s *tdsSession) (id uint16, columns []columnStruct) {
+	count := r.uint16()
+	id = r.uint16()
+	// the columns of the BY list
+	byCols := int(r.byte())
+	for i := 0; i < byCols; i++ {
+		r.uint16()
+	}
+	columns = make([]columnStruct, count)
+	for i := range columns {
+		column := &columns[i]
+		op := r.byte()
+		// the query column aggregated
+		r.uint16()
+		baseTi := getBaseTypeInfo(r, true, s.beforeTDS72())
+		typeInfo := readTypeInfo(r, baseTi.TypeId, nil, s.encoding)
+		typeInfo.UserType = baseTi.UserType
+		typeInfo.Flags = baseTi.Flags
+		typeInfo.TypeId = baseTi.TypeId
+
+		column.Flags = baseTi.Flags
+		column.UserType = baseTi.UserType
+		column.ti = typeInfo
+		column.ColName = r.BVarChar()
+		if column.ColName == "" {
+			column.ColName = computeOps[op]
+		}
+	}
+	return id, columns
+}
+
+// parseAltRow reads the row of a COMPUTE clause, using the columns of the
+// ALTMETADATA token having its id.
+func parseAltRow(r *tdsBuffer, computeCols map[uint16][]columnStruct) (id uint16, columns []columnStruct, row []interface{}) {
+	id = r.uint16()
+	columns, ok := computeCols[id]
+	if !ok {
+		badStreamPanic(fmt.Errorf("ALTROW token with unknown id %d", id))
+	}
+	row = make([]interface{}, len(columns))
+	for i := range columns {
+		row[i] = columns[i].ti.Reader(&columns[i].ti, r, nil)
+	}
+	return id, columns, row
+}
@@ -0,0 +1,117 @@
+package mssql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// computeMetadata returns the ALTMETADATA token of a COMPUTE SUM(n)
+// clause, with the given id.
+func computeMetadata(id uint16) []byte {
+	b := []byte{byte(tokenAltMetadata), 1, 0, byte(id), byte(id >> 8), 0, 0x4d, 1, 0}
+	return append(b, cursorIntColumn("", colFlagNullable)...)
+}
+
+func computeRow(id uint16, v int32) []byte {
+	return append([]byte{byte(tokenAltRow), byte(id), byte(id >> 8)}, cursorIntValue(v)...)
+}
+
+func TestComputeRows(t *testing.T) {
+	transport := &scriptedTransport{}
+	metadata := append([]byte{byte(tokenColMetadata), 1, 0}, cursorIntColumn("n", colFlagNullable)...)
+	transport.reply(metadata, computeMetadata(1),
+		cursorRow(1), cursorRow(2), computeRow(1, 3),
+		cursorRow(4), computeRow(1, 4),
+		rawDoneToken(tokenDone, doneCount, cmdSelect, 3))
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	rows, err := (&Stmt{c: c, query: "select n from numbers order by g compute sum(n) by g"}).queryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	r := rows.(*Rows)
+	var got [][]interface{}
+	for {
+		set := []interface{}{r.Columns()}
+		dest := make([]driver.Value, 1)
+		for err = r.Next(dest); err == nil; err = r.Next(dest) {
+			set = append(set, dest[0])
+		}
+		if err != io.EOF {
+			t.Fatal(err)
+		}
+		got = append(got, set)
+		if err = r.NextResultSet(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := [][]interface{}{
+		{[]string{"n"}, int64(1), int64(2)},
+		{[]string{"sum"}, int64(3)},
+		{[]string{"n"}, int64(4)},
+		{[]string{"sum"}, int64(4)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the result sets %v, got %v", want, got)
+	}
+}
+
+func TestComputeRowUnknownId(t *testing.T) {
+	transport := &scriptedTransport{}
+	metadata := append([]byte{byte(tokenColMetadata), 1, 0}, cursorIntColumn("n", colFlagNullable)...)
+	transport.reply(metadata, computeMetadata(1), cursorRow(1), computeRow(2, 3),
+		rawDoneToken(tokenDone, doneCount, cmdSelect, 1))
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	rows, err := (&Stmt{c: c, query: "select n from numbers compute sum(n)"}).queryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, 1)
+	for err == nil {
+		err = rows.Next(dest)
+	}
+	var streamErr StreamError
+	if !errors.As(err, &streamErr) {
+		t.Errorf("expected a StreamError for an ALTROW without ALTMETADATA, got %v", err)
+	}
+}
+
+func TestComputeQuery(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	rows, err := db.Query("select n from (values (1), (2), (3)) v(n) compute sum(n)")
+	if err != nil {
+		// COMPUTE is not supported from SQL Server 2012 on
+		t.Skip("COMPUTE is not supported by the server: ", err)
+	}
+	defer rows.Close()
+	var n, sets int
+	for {
+		for rows.Next() {
+			if err = rows.Scan(&n); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sets++
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if sets != 2 || n != 6 {
+		t.Errorf("expected the sum 6 in a second result set, got %d in result set %d", n, sets)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !illumos && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!illumos,!linux,!netbsd,!openbsd,!solaris
+
+package mssql
+
+import "net"
+
+// connCheck does not check the connections on this platform.
+func connCheck(conn net.Conn) error {
+	return nil
+}
@@ -0,0 +1,47 @@
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd illumos linux netbsd openbsd solaris
+
+package mssql
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+var errUnexpectedRead = errors.New("unexpected read from an idle connection")
+
+// connCheck reports an error when conn, idle in the pool, was closed by the
+// server or a proxy in between, without blocking nor sending anything.
+func connCheck(conn net.Conn) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var checkErr error
+	err = rc.Read(func(fd uintptr) bool {
+		var buf [1]byte
+		n, err := syscall.Read(int(fd), buf[:])
+		switch {
+		case n == 0 && err == nil:
+			checkErr = io.EOF
+		case n > 0:
+			checkErr = errUnexpectedRead
+		case err == syscall.EAGAIN || err == syscall.EWOULDBLOCK:
+			// nothing to read, the connection is alive
+		default:
+			checkErr = err
+		}
+		// do not wait for the connection to be readable
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return checkErr
+}
@@ -0,0 +1,56 @@
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd illumos linux netbsd openbsd solaris
+
+package mssql
+
+import (
+	"context"
+	"database/sql/driver"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func TestResetSessionClosedConn(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no TCP listener:", err)
+	}
+	defer listener.Close()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := testConn(newConnector(msdsn.Config{}, nil), client)
+	c.sess.conn = client
+	ctx := context.Background()
+	if err = c.ResetSession(ctx); err != nil {
+		t.Fatalf("expected the idle connection to be reused, got %v", err)
+	}
+
+	// a proxy drops the idle connection: writing to it still succeeds,
+	// only reading the response fails
+	server.Close()
+	for deadline := time.Now().Add(5 * time.Second); connCheck(client) == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the connection was not closed")
+		}
+	}
+	if _, err = client.Write([]byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.ResetSession(ctx); err != driver.ErrBadConn {
+		t.Errorf("expected driver.ErrBadConn, got %v", err)
+	}
+	if c.connectionGood {
+		t.Error("expected the connection to be bad")
+	}
+}
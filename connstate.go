@@ -0,0 +1,44 @@
+package mssql
+
+import (
+	"crypto/tls"
+	"database/sql/driver"
+)
+
+// ConnState describes the security and protocol version negotiated by a
+// connection during PRELOGIN and login.
+type ConnState struct {
+	// Encrypted is set when all the traffic of the connection is encrypted.
+	// With encrypt=false and a server that does not require encryption only
+	// the login packet is encrypted.
+	Encrypted bool
+	// TLS is the state of the TLS connection, nil when no TLS handshake took
+	// place. It is also set when only the login packet was encrypted.
+	TLS *tls.ConnectionState
+	// TDSVersion is the TDS version acknowledged by the server at login,
+	// for instance 0x74000004 for TDS 7.4.
+	TDSVersion uint32
+	// UTF8 is set when the server acknowledged the UTF8_SUPPORT feature
+	// extension, sending the values of UTF-8 collations as UTF-8. Servers
+	// before SQL Server 2019 convert them to the code page of the collation.
+	UTF8 bool
+}
+
+// ConnectionState returns the encryption state, the TDS version and the
+// UTF-8 support of the connection. Use it through sql.Conn.Raw:
+//
+//	err = conn.Raw(func(driverConn interface{}) error {
+//		state, err = driverConn.(*mssql.Conn).ConnectionState()
+//		return err
+//	})
+func (c *Conn) ConnectionState() (ConnState, error) {
+	if !c.connectionGood {
+		return ConnState{}, driver.ErrBadConn
+	}
+	return ConnState{
+		Encrypted:  c.sess.encrypted,
+		TLS:        c.sess.tlsState,
+		TDSVersion: c.sess.loginAck.TDSVersion,
+		UTF8:       c.sess.utf8Support,
+	}, nil
+}
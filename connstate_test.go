@@ -0,0 +1,73 @@
+package mssql
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func TestConnectionState(t *testing.T) {
+	c := &Conn{
+		sess: &tdsSession{
+			tlsState:  &tls.ConnectionState{Version: tls.VersionTLS12},
+			encrypted: true,
+			loginAck:  loginAckStruct{TDSVersion: verTDS74},
+		},
+		connectionGood: true,
+	}
+	state, err := c.ConnectionState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Encrypted || state.TLS.Version != tls.VersionTLS12 || state.TDSVersion != verTDS74 {
+		t.Errorf("unexpected state %+v", state)
+	}
+	c.connectionGood = false
+	if _, err = c.ConnectionState(); err != driver.ErrBadConn {
+		t.Errorf("expected ErrBadConn, got %v", err)
+	}
+}
+
+func TestConnectionStateStrict(t *testing.T) {
+	checkConnStr(t)
+	config := testConnParams(t)
+	dsn := config.URL()
+	dsnParams := dsn.Query()
+	dsnParams.Set(msdsn.Encrypt, "strict")
+	dsn.RawQuery = dsnParams.Encode()
+	connector, err := NewConnector(dsn.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Skip("the server does not accept encrypt=strict: ", err)
+	}
+	defer conn.Close()
+
+	var state ConnState
+	err = conn.Raw(func(driverConn interface{}) error {
+		state, err = driverConn.(*Conn).ConnectionState()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Encrypted || state.TLS == nil {
+		t.Fatalf("expected an encrypted connection, got %+v", state)
+	}
+	if state.TLS.Version < tls.VersionTLS12 {
+		t.Errorf("expected TLS 1.2 or later, got %x", state.TLS.Version)
+	}
+	if state.TDSVersion == 0 {
+		t.Error("expected the TDS version of the login acknowledgment")
+	}
+}
@@ -0,0 +1,64 @@
+package mssql
+
+import "sync/atomic"
+
+// ConnStats holds the traffic of a connection since it was opened or since
+// the last call to Conn.ResetStats. The packets and bytes are those of the
+// TDS packets, headers included, before TLS encryption.
+type ConnStats struct {
+	PacketsSent     uint64
+	PacketsReceived uint64
+	BytesSent       uint64
+	BytesReceived   uint64
+	// RoundTrips is the number of requests sent to the server, each of
+	// them waiting for a response, the login included.
+	RoundTrips uint64
+}
+
+// connStats are the counters of a tdsBuffer, updated atomically so they
+// can be read while the connection is in use.
+type connStats struct {
+	packetsSent     uint64
+	packetsReceived uint64
+	bytesSent       uint64
+	bytesReceived   uint64
+	roundTrips      uint64
+}
+
+func (s *connStats) sent(n int) {
+	atomic.AddUint64(&s.packetsSent, 1)
+	atomic.AddUint64(&s.bytesSent, uint64(n))
+}
+
+func (s *connStats) received(n int) {
+	atomic.AddUint64(&s.packetsReceived, 1)
+	atomic.AddUint64(&s.bytesReceived, uint64(n))
+}
+
+// Stats returns the traffic of the connection. It is safe to call while
+// the connection runs a statement. Use it through sql.Conn.Raw:
+//
+//	err = conn.Raw(func(driverConn interface{}) error {
+//		stats = driverConn.(*mssql.Conn).Stats()
+//		return nil
+//	})
+func (c *Conn) Stats() ConnStats {
+	s := &c.sess.buf.stats
+	return ConnStats{
+		PacketsSent:     atomic.LoadUint64(&s.packetsSent),
+		PacketsReceived: atomic.LoadUint64(&s.packetsReceived),
+		BytesSent:       atomic.LoadUint64(&s.bytesSent),
+		BytesReceived:   atomic.LoadUint64(&s.bytesReceived),
+		RoundTrips:      atomic.LoadUint64(&s.roundTrips),
+	}
+}
+
+// ResetStats sets the counters returned by Stats back to zero.
+func (c *Conn) ResetStats() {
+	s := &c.sess.buf.stats
+	atomic.StoreUint64(&s.packetsSent, 0)
+	atomic.StoreUint64(&s.packetsReceived, 0)
+	atomic.StoreUint64(&s.bytesSent, 0)
+	atomic.StoreUint64(&s.bytesReceived, 0)
+	atomic.StoreUint64(&s.roundTrips, 0)
+}
@@ -0,0 +1,77 @@
+package mssql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func TestConnStats(t *testing.T) {
+	transport := &scriptedTransport{}
+	done := rawDoneToken(tokenDone, doneCount, testCmdInsert, 1)
+	transport.reply(done)
+	transport.reply(done)
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	ctx := context.Background()
+	if _, err := (&Stmt{c: c, query: "update t set n = 1"}).exec(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	stats := c.Stats()
+	want := ConnStats{
+		PacketsSent:     1,
+		PacketsReceived: 1,
+		BytesSent:       uint64(transport.requests.Len()),
+		BytesReceived:   uint64(8 + len(done)),
+		RoundTrips:      1,
+	}
+	if stats != want {
+		t.Errorf("expected %+v, got %+v", want, stats)
+	}
+
+	c.ResetStats()
+	if stats = c.Stats(); stats != (ConnStats{}) {
+		t.Errorf("expected the counters to be reset, got %+v", stats)
+	}
+	if _, err := (&Stmt{c: c, query: "update t set n = 1"}).exec(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stats = c.Stats(); stats != want {
+		t.Errorf("expected %+v after the second statement, got %+v", want, stats)
+	}
+}
+
+func TestConnStatsQuery(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stats := func() (s ConnStats) {
+		err := conn.Raw(func(driverConn interface{}) error {
+			s = driverConn.(*Conn).Stats()
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	before := stats()
+	if before.RoundTrips == 0 || before.BytesReceived == 0 {
+		t.Errorf("expected the login to be counted, got %+v", before)
+	}
+	var s string
+	if err = conn.QueryRowContext(ctx, "select replicate('x', 10000)").Scan(&s); err != nil {
+		t.Fatal(err)
+	}
+	after := stats()
+	if after.BytesSent <= before.BytesSent || after.BytesReceived < before.BytesReceived+10000 || after.RoundTrips <= before.RoundTrips {
+		t.Errorf("expected the query to be counted, got %+v then %+v", before, after)
+	}
+}
@@ -0,0 +1,167 @@
+package mssql
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// defaultCursorFetchSize is the number of rows fetched at a time by a server
+// cursor when the connection string does not set the cursor fetch size.
+const defaultCursorFetchSize = 1000
+
+// sp_cursoropen options
+const (
+	cursorScrollFastForward   = 0x0010
+	cursorScrollParameterized = 0x1000
+	cursorConcurReadOnly      = 0x0001
+)
+
+// sp_cursorfetch fetch type
+const cursorFetchNext = 0x0002
+
+// serverCursor reads the rows of a query through a server API cursor,
+// opened by sp_cursoropen and read by sp_cursorfetch, so that only a page
+// of rows is transferred at a time.
+type serverCursor struct {
+	fetchSize int
+	// columns returned by sp_cursoropen, the fetches send no metadata
+	columns []columnStruct
+	// handle and outputs are written by the goroutine reading the response
+	// of sp_cursoropen, the cursor is the first of its output parameters
+	handle   int32
+	outputs  int
+	fetches  int
+	pageRows int
+	done     bool
+}
+
+// useServerCursor tells whether the rows of the query are read through a
+// server cursor. Stored procedure calls and queries with output parameters,
+// encrypted parameters or query notifications use the default result set.
+func (s *Stmt) useServerCursor(ctx context.Context, args []namedValue) bool {
+	if s.c.connector == nil || !s.c.connector.params.ServerCursor {
+		return false
+	}
+	if s.notifSub != nil || s.c.outs.msgq != nil || s.c.outs.returnStatus != nil || isProc(s.query) {
+		return false
+	}
+	if setIsolation, err := isolationStatement(ctx); err != nil || len(setIsolation) > 0 {
+		return false
+	}
+	for _, arg := range args {
+		if arg.encrypt != nil || isOutputValue(arg.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func newServerCursor(p msdsn.Config) *serverCursor {
+	fetchSize := p.CursorFetchSize
+	if fetchSize <= 0 {
+		fetchSize = defaultCursorFetchSize
+	}
+	return &serverCursor{fetchSize: fetchSize}
+}
+
+func makeIntParam(v int32) (p param) {
+	p.ti.TypeId = typeIntN
+	p.ti.Size = 4
+	p.buffer = make([]byte, 4)
+	binary.LittleEndian.PutUint32(p.buffer, uint32(v))
+	return
+}
+
+// openCall turns the sp_executesql call with params into the sp_cursoropen
+// call of the query.
+func (cur *serverCursor) openCall(query, decls string, params []param, hasArgs bool) (procId, []param) {
+	var handle param
+	handle.Flags = fByRevValue
+	handle.ti.TypeId = typeIntN
+	handle.ti.Size = 4
+	scrollOpt := int32(cursorScrollFastForward)
+	if hasArgs {
+		scrollOpt |= cursorScrollParameterized
+	}
+	scroll := makeIntParam(scrollOpt)
+	scroll.Flags = fByRevValue
+	concur := makeIntParam(cursorConcurReadOnly)
+	concur.Flags = fByRevValue
+	rowCount := makeIntParam(0)
+	rowCount.Flags = fByRevValue
+	call := []param{handle, makeStrParam(query), scroll, concur, rowCount}
+	if hasArgs {
+		call = append(call, makeStrParam(decls))
+		call = append(call, params[2:]...)
+	}
+	return sp_CursorOpen, call
+}
+
+// returnValue records an output parameter of sp_cursoropen.
+func (cur *serverCursor) returnValue(nv namedValue) {
+	cur.outputs++
+	if handle, ok := nv.Value.(int64); ok && cur.outputs == 1 {
+		cur.handle = int32(handle)
+	}
+}
+
+// visibleColumns returns the columns without the hidden columns the server
+// appends to the rows of a cursor or of a browse mode query.
+func visibleColumns(columns []columnStruct) []columnStruct {
+	n := len(columns)
+	for n > 0 && columns[n-1].Flags&colFlagHidden != 0 {
+		n--
+	}
+	return columns[:n]
+}
+
+// fetch requests the next page of rows once the rows of the previous page
+// are read, and returns false when all the rows are read.
+func (cur *serverCursor) fetch(rc *Rows) (bool, error) {
+	if cur.done || cur.handle == 0 {
+		return false, nil
+	}
+	if cur.fetches > 0 && cur.pageRows < cur.fetchSize {
+		// a short page is the last one
+		cur.done = true
+		return false, nil
+	}
+	c := rc.stmt.c
+	ctx := rc.reader.ctx
+	params := []param{makeIntParam(cur.handle), makeIntParam(cursorFetchNext), makeIntParam(0), makeIntParam(int32(cur.fetchSize))}
+	if err := cur.send(ctx, c, sp_CursorFetch, params); err != nil {
+		c.sess.LogF(ctx, msdsn.LogErrors, "Failed to send cursor fetch with %v", err)
+		return false, err
+	}
+	cur.fetches++
+	cur.pageRows = 0
+	rc.reader = startReading(c.sess, ctx, outputs{cursor: cur})
+	c.openRows = rc
+	return true, nil
+}
+
+// close closes the cursor on the server.
+func (cur *serverCursor) close(c *Conn) error {
+	if cur.handle == 0 || !c.connectionGood {
+		return nil
+	}
+	handle := cur.handle
+	cur.handle = 0
+	if err := cur.send(context.Background(), c, sp_CursorClose, []param{makeIntParam(handle)}); err != nil {
+		return err
+	}
+	return c.simpleProcessResp(context.Background())
+}
+
+func (cur *serverCursor) send(ctx context.Context, c *Conn, proc procId, params []param) error {
+	c.bufferOpenRows()
+	headers := c.requestHeaders(ctx, c.sess.tranid)
+	if err := sendRpc(c.sess.buf, headers, proc, 0, params, false, c.sess.encoding); err != nil {
+		c.connectionGood = false
+		return fmt.Errorf("failed to send RPC: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// scriptedTransport records the requests written to it and answers them
+// with the responses, in order.
+type scriptedTransport struct {
+	requests  bytes.Buffer
+	responses bytes.Buffer
+}
+
+func (t *scriptedTransport) Read(p []byte) (int, error) {
+	return t.responses.Read(p)
+}
+
+func (t *scriptedTransport) Write(p []byte) (int, error) {
+	return t.requests.Write(p)
+}
+
+func (t *scriptedTransport) Close() error {
+	return nil
+}
+
+// reply queues a reply packet holding the tokens.
+func (t *scriptedTransport) reply(tokens ...[]byte) {
+	data := bytes.Join(tokens, nil)
+	header := []byte{byte(packReply), 1, 0, 0, 0, 0, 1, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(data)+len(header)))
+	t.responses.Write(header)
+	t.responses.Write(data)
+}
+
+// procIds returns the procedure ids of the RPC requests, each of them
+// being a single packet.
+func (t *scriptedTransport) procIds() (ids []uint16) {
+	b := t.requests.Bytes()
+	for len(b) > 0 {
+		size := binary.BigEndian.Uint16(b[2:])
+		data := b[8:size]
+		data = data[binary.LittleEndian.Uint32(data):] // skip the headers
+		ids = append(ids, binary.LittleEndian.Uint16(data[2:]))
+		b = b[size:]
+	}
+	return
+}
+
+func cursorIntColumn(name string, flags uint16) []byte {
+	b := []byte{0, 0, 0, 0, byte(flags), byte(flags >> 8), typeIntN, 4, byte(len(name))}
+	return append(b, str2ucs2(name)...)
+}
+
+func cursorIntValue(v int32) []byte {
+	b := []byte{4, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(b[1:], uint32(v))
+	return b
+}
+
+func cursorDoneProc() []byte {
+	return []byte{byte(tokenDoneProc), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+}
+
+func cursorRow(values ...int32) []byte {
+	b := []byte{byte(tokenRow)}
+	for _, v := range values {
+		b = append(b, cursorIntValue(v)...)
+	}
+	return b
+}
+
+func cursorReturnValue(v int32) []byte {
+	b := []byte{byte(tokenReturnValue), 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, typeIntN, 4}
+	return append(b, cursorIntValue(v)...)
+}
+
+func TestServerCursorFetch(t *testing.T) {
+	transport := &scriptedTransport{}
+	// sp_cursoropen returns the metadata, with the hidden ROWSTAT column, and the cursor
+	metadata := append([]byte{byte(tokenColMetadata), 2, 0}, cursorIntColumn("n", colFlagNullable)...)
+	metadata = append(metadata, cursorIntColumn("ROWSTAT", colFlagHidden)...)
+	transport.reply(metadata, cursorReturnValue(5), cursorReturnValue(cursorScrollFastForward), cursorDoneProc())
+	// each fetch returns a page of rows without metadata
+	noMetadata := []byte{byte(tokenColMetadata), 0xff, 0xff}
+	transport.reply(noMetadata, cursorRow(1, 1), cursorRow(2, 1), cursorDoneProc())
+	transport.reply(noMetadata, cursorRow(3, 1), cursorDoneProc())
+	// sp_cursorclose
+	transport.reply(cursorDoneProc())
+
+	c := testConn(newConnector(msdsn.Config{ServerCursor: true, CursorFetchSize: 2}, nil), transport)
+	s := &Stmt{c: c, query: "select n from numbers"}
+	rows, err := s.queryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cols := rows.Columns(); !reflect.DeepEqual(cols, []string{"n"}) {
+		t.Errorf("expected the hidden column to be left out, got %v", cols)
+	}
+	var got []int64
+	dest := make([]driver.Value, 1)
+	for {
+		err = rows.Next(dest)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, dest[0].(int64))
+	}
+	if err = rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Errorf("expected the rows of both pages, got %v", got)
+	}
+	want := []uint16{sp_CursorOpen.id, sp_CursorFetch.id, sp_CursorFetch.id, sp_CursorClose.id}
+	if ids := transport.procIds(); !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected the procedures %v, got %v", want, ids)
+	}
+}
+
+func TestServerCursorQuery(t *testing.T) {
+	checkConnStr(t)
+	p := makeConnStr(t)
+	q := p.Query()
+	q.Set("cursor", "server")
+	q.Set("cursor fetch size", "100")
+	p.RawQuery = q.Encode()
+	connector, err := NewConnector(p.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	const count = 1050
+	rows, err := db.Query(`select top (@p1) row_number() over (order by a.object_id), 'row' + cast(a.object_id as nvarchar(20))
+		from sys.all_objects a cross join sys.all_objects b order by 1`, count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if cols, _ := rows.Columns(); len(cols) != 2 {
+		t.Errorf("expected 2 columns, got %v", cols)
+	}
+	n := 0
+	for rows.Next() {
+		var id int64
+		var name string
+		if err = rows.Scan(&id, &name); err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if id != int64(n) {
+			t.Fatalf("expected row %d, got %d", n, id)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != count {
+		t.Errorf("expected %d rows, got %d", count, n)
+	}
+}
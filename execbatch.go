@@ -0,0 +1,140 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// Batch is a statement run by Conn.ExecBatch. Args are its positional
+// parameters @p1, @p2 and so on, or sql.NamedArg values.
+type Batch struct {
+	Query string
+	Args  []interface{}
+}
+
+// BatchError is returned by Conn.ExecBatch when a statement fails.
+type BatchError struct {
+	// Index of the failing statement in the batches.
+	Index int
+	Err   error
+}
+
+func (e BatchError) Error() string {
+	return fmt.Sprintf("mssql: statement %d of the batch failed: %v", e.Index, e.Err)
+}
+
+func (e BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ExecBatch sends the statements to the server in a single request, each
+// of them as its own sp_executesql call, and returns a result for each
+// statement. This saves the round trip per statement of running them one
+// by one, for instance for a list of DDL statements.
+//
+// The server runs every statement of the request, even after one of them
+// failed. ExecBatch then returns the results of the statements before the
+// first failing one and a BatchError holding its index.
+//
+// Use it through sql.Conn.Raw:
+//
+//	err = conn.Raw(func(driverConn interface{}) error {
+//		results, err = driverConn.(*mssql.Conn).ExecBatch(ctx, batches)
+//		return err
+//	})
+func (c *Conn) ExecBatch(ctx context.Context, batches []Batch) ([]driver.Result, error) {
+	if !c.connectionGood {
+		return nil, driver.ErrBadConn
+	}
+	if len(batches) == 0 {
+		return nil, nil
+	}
+	defer c.clearOuts()
+
+	calls := make([]rpcCall, len(batches))
+	for i, b := range batches {
+		args := make([]namedValue, len(b.Args))
+		for j, arg := range b.Args {
+			nv := namedValue{Ordinal: j + 1, Value: arg}
+			if named, ok := arg.(sql.NamedArg); ok {
+				nv.Name = named.Name
+				nv.Value = named.Value
+			}
+			if isOutputValue(nv.Value) {
+				return nil, BatchError{Index: i, Err: errors.New("output parameters are not supported")}
+			}
+			var err error
+			if nv.Value, err = convertInputParameter(nv.Value); err != nil {
+				return nil, BatchError{Index: i, Err: err}
+			}
+			args[j] = nv
+		}
+		s := &Stmt{c: c, query: b.Query, skipEncryption: true}
+		params, decls, err := s.makeRPCParams(args, false)
+		if err != nil {
+			return nil, BatchError{Index: i, Err: err}
+		}
+		params[0] = makeStrParam(b.Query)
+		params[1] = makeStrParam(strings.Join(decls, ","))
+		calls[i] = rpcCall{proc: sp_ExecuteSql, params: params}
+		c.sess.LogS(ctx, msdsn.LogSQL, b.Query)
+	}
+
+	c.bufferOpenRows()
+	headers := c.requestHeaders(ctx, c.sess.tranid)
+	reset := c.resetSession
+	c.resetSession = false
+	if err := sendRpcBatch(c.sess.buf, headers, calls, reset, c.sess.encoding); err != nil {
+		c.sess.LogF(ctx, msdsn.LogErrors, "Failed to send Rpc batch with %v", err)
+		c.connectionGood = false
+		return nil, fmt.Errorf("failed to send RPC batch: %v", err)
+	}
+
+	reader := startReading(c.sess, ctx, c.outs)
+	c.clearOuts()
+	results := make([]driver.Result, 0, len(batches))
+	var firstErr error
+	var rowCount int64
+	var rowCounts []int64
+	for {
+		tok, err := reader.nextToken()
+		if err != nil {
+			err = c.checkBadConn(ctx, err, false)
+			if firstErr == nil {
+				firstErr = BatchError{Index: len(results), Err: err}
+			}
+			return results, firstErr
+		}
+		if tok == nil {
+			return results, firstErr
+		}
+		switch token := tok.(type) {
+		case doneInProcStruct:
+			if token.Status&doneCount != 0 {
+				rowCount += int64(token.RowCount)
+				rowCounts = append(rowCounts, int64(token.RowCount))
+			}
+		case doneStruct:
+			// each call ends with a DONEPROC token
+			if token.Status&doneCount != 0 {
+				rowCount += int64(token.RowCount)
+				rowCounts = append(rowCounts, int64(token.RowCount))
+			}
+			if firstErr == nil {
+				if token.isError() {
+					firstErr = BatchError{Index: len(results), Err: token.getError()}
+				} else {
+					results = append(results, &Result{c, rowCount, rowCounts})
+				}
+			}
+			rowCount = 0
+			rowCounts = nil
+		}
+	}
+}
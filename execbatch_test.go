@@ -0,0 +1,121 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func TestSendRpcBatch(t *testing.T) {
+	calls := []rpcCall{
+		{proc: sp_ExecuteSql, params: []param{makeStrParam("select 1"), makeStrParam("")}},
+		{proc: sp_ExecuteSql, params: []param{makeStrParam("select 2"), makeStrParam("")}},
+	}
+	send := func(calls ...rpcCall) []byte {
+		memBuf := new(MockTransport)
+		buf := newTdsBuffer(1024, memBuf)
+		if err := sendRpcBatch(buf, []headerStruct{}, calls, false, msdsn.EncodeParameters{}); err != nil {
+			t.Fatal(err)
+		}
+		// skip the packet header
+		return memBuf.Bytes()[8:]
+	}
+	first := send(calls[0])
+	second := send(calls[1])
+	// the calls share the headers and are separated by the batch flag
+	want := append(append(first, rpcBatchFlag), second[4:]...)
+	if got := send(calls...); !bytes.Equal(got, want) {
+		t.Errorf("expected % x, got % x", want, got)
+	}
+}
+
+func TestExecBatchOpenRowsTransaction(t *testing.T) {
+	transport := &scriptedTransport{}
+	replyBeginTran(transport, 0x1122334455667788)
+	transport.reply(cursorDoneProc())
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	ctx := context.Background()
+	rows, err := (&Stmt{c: c, query: "begin tran; select n from numbers"}).queryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if _, err = c.ExecBatch(ctx, []Batch{{Query: "update numbers set n = 1"}}); err != nil {
+		t.Fatal(err)
+	}
+	// the transaction begun by the buffered response is sent
+	if got := lastRequestTranid(transport); got != 0x1122334455667788 {
+		t.Errorf("expected the transaction of the open rows, got %x", got)
+	}
+}
+
+func TestExecBatch(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	execBatch := func(batches []Batch) (results []driver.Result, err error) {
+		rawErr := conn.Raw(func(driverConn interface{}) error {
+			results, err = driverConn.(*Conn).ExecBatch(ctx, batches)
+			return nil
+		})
+		if rawErr != nil {
+			t.Fatal(rawErr)
+		}
+		return
+	}
+
+	results, err := execBatch([]Batch{
+		{Query: "create table #exec_batch (id int, name nvarchar(10))"},
+		{Query: "insert into #exec_batch values (@p1, @p2), (@p1 + 1, @p2)", Args: []interface{}{1, "a"}},
+		{Query: "update #exec_batch set name = @name", Args: []interface{}{sql.Named("name", "b")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	for i, want := range []int64{0, 2, 2} {
+		if n, _ := results[i].RowsAffected(); n != want {
+			t.Errorf("statement %d: expected %d rows affected, got %d", i, want, n)
+		}
+	}
+
+	results, err = execBatch([]Batch{
+		{Query: "delete from #exec_batch where id = 1"},
+		{Query: "insert into #exec_batch values (1, 'too long for the column')"},
+		{Query: "delete from #exec_batch"},
+	})
+	var batchErr BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 {
+		t.Fatalf("expected the second statement to fail, got %v", err)
+	}
+	var sqlErr Error
+	if !errors.As(err, &sqlErr) || sqlErr.Number != 2628 && sqlErr.Number != 8152 {
+		t.Errorf("expected a truncation error, got %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected the result of the first statement, got %d results", len(results))
+	}
+
+	var count int
+	if err = conn.QueryRowContext(ctx, "select count(*) from #exec_batch").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("expected the statements after the failing one to run, %d rows left", count)
+	}
+}
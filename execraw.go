@@ -0,0 +1,140 @@
+package mssql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// Status flags of RawDone.
+const (
+	DoneMore     = doneMore
+	DoneError    = doneError
+	DoneInxact   = doneInxact
+	DoneCount    = doneCount
+	DoneAttn     = doneAttn
+	DoneSrvError = doneSrvError
+)
+
+// RawColumn describes a column of a COLMETADATA token.
+type RawColumn struct {
+	Name             string
+	DatabaseTypeName string
+	Nullable         bool
+}
+
+// RawDone is a DONE, DONEPROC or DONEINPROC token. Status holds the
+// DoneMore, DoneError, DoneCount and other flags, RowCount is only set
+// when Status has DoneCount.
+type RawDone struct {
+	Status   uint16
+	CurCmd   uint16
+	RowCount uint64
+	// InProc is set for a DONEINPROC token, which ends a statement of a
+	// stored procedure or of a batch run by sp_executesql.
+	InProc bool
+}
+
+// TokenVisitor receives the tokens of a response read by Conn.ExecRaw, in
+// the order the server sent them. When a method returns an error ExecRaw
+// reads the rest of the response without visiting it and returns the
+// error.
+type TokenVisitor interface {
+	// Columns is called for the COLMETADATA token starting a result set.
+	Columns(cols []RawColumn) error
+	// Row is called for each ROW or NBCROW token, the values are the ones
+	// database/sql would get from Rows.Next.
+	Row(values []driver.Value) error
+	// Message is called for each INFO and ERROR token, Class tells them
+	// apart.
+	Message(msg Error) error
+	// Done is called for each DONE, DONEPROC and DONEINPROC token.
+	Done(done RawDone) error
+}
+
+// rawMessage carries an INFO or ERROR token to ExecRaw, it does not
+// implement error so that the reader does not stop on it.
+type rawMessage struct {
+	msg Error
+}
+
+// ExecRaw sends query as a SQL batch and calls the visitor with the
+// tokens of the response as they are parsed.
+//
+// This is a low level API for tools which need the details database/sql
+// hides, such as the DONE token of each statement. The token types may
+// grow new fields and the visitor is called from the goroutine calling
+// ExecRaw. Errors reported by the server are passed to the visitor as
+// messages and to the DONE tokens as the DoneError flag, ExecRaw only
+// returns the errors of the connection, of the visitor, or of a DONE token
+// having DoneSrvError.
+//
+// Use it through sql.Conn.Raw:
+//
+//	err = conn.Raw(func(driverConn interface{}) error {
+//		return driverConn.(*mssql.Conn).ExecRaw(ctx, "exec sp_who", visitor)
+//	})
+func (c *Conn) ExecRaw(ctx context.Context, query string, visitor TokenVisitor) error {
+	if !c.connectionGood {
+		return driver.ErrBadConn
+	}
+	defer c.clearOuts()
+
+	c.sess.LogS(ctx, msdsn.LogSQL, query)
+	c.bufferOpenRows()
+	headers := c.requestHeaders(ctx, c.sess.tranid)
+	reset := c.resetSession
+	c.resetSession = false
+	if err := sendSqlBatch72(c.sess.buf, query, headers, reset); err != nil {
+		c.sess.LogF(ctx, msdsn.LogErrors, "Failed to send SqlBatch with %v", err)
+		c.connectionGood = false
+		return fmt.Errorf("failed to send SQL Batch: %v", err)
+	}
+
+	outs := c.outs
+	outs.messages = true
+	reader := startReading(c.sess, ctx, outs)
+	c.clearOuts()
+	var cols []columnStruct
+	var visitErr error
+	for {
+		tok, err := reader.nextToken()
+		if err != nil {
+			return c.checkBadConn(ctx, err, false)
+		}
+		if tok == nil {
+			return visitErr
+		}
+		if visitErr != nil {
+			continue
+		}
+		switch token := tok.(type) {
+		case []columnStruct:
+			cols = token
+			raw := make([]RawColumn, len(cols))
+			for i, col := range cols {
+				raw[i] = RawColumn{
+					Name:             col.ColName,
+					DatabaseTypeName: makeGoLangTypeName(col.originalTypeInfo()),
+					Nullable:         col.Flags&colFlagNullable != 0,
+				}
+			}
+			visitErr = visitor.Columns(raw)
+		case []interface{}:
+			values := make([]driver.Value, len(token))
+			for i, v := range token {
+				values[i] = v
+			}
+			trimFixedChar(c, cols, values)
+			visitErr = visitor.Row(values)
+		case rawMessage:
+			visitErr = visitor.Message(token.msg)
+		case doneStruct:
+			visitErr = visitor.Done(RawDone{Status: token.Status, CurCmd: token.CurCmd, RowCount: token.RowCount})
+		case doneInProcStruct:
+			visitErr = visitor.Done(RawDone{Status: token.Status, CurCmd: token.CurCmd, RowCount: token.RowCount, InProc: true})
+		}
+	}
+}
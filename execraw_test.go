@@ -0,0 +1,172 @@
+package mssql
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// testCmdInsert is the CurCmd of the DONE token of an insert.
+const testCmdInsert = 0xc3
+
+// recordingVisitor records the tokens it visits, as strings, and the DONE
+// tokens.
+type recordingVisitor struct {
+	tokens []string
+	dones  []RawDone
+	err    error
+}
+
+func (v *recordingVisitor) Columns(cols []RawColumn) error {
+	v.tokens = append(v.tokens, fmt.Sprintf("columns %v", cols))
+	return v.err
+}
+
+func (v *recordingVisitor) Row(values []driver.Value) error {
+	v.tokens = append(v.tokens, fmt.Sprintf("row %v", values))
+	return v.err
+}
+
+func (v *recordingVisitor) Message(msg Error) error {
+	v.tokens = append(v.tokens, fmt.Sprintf("message %d %s", msg.Class, msg.Message))
+	return v.err
+}
+
+func (v *recordingVisitor) Done(done RawDone) error {
+	v.tokens = append(v.tokens, "done")
+	v.dones = append(v.dones, done)
+	return v.err
+}
+
+func rawDoneToken(token token, status, curCmd uint16, rowCount uint64) []byte {
+	b := make([]byte, 13)
+	b[0] = byte(token)
+	binary.LittleEndian.PutUint16(b[1:], status)
+	binary.LittleEndian.PutUint16(b[3:], curCmd)
+	binary.LittleEndian.PutUint64(b[5:], rowCount)
+	return b
+}
+
+func TestExecRaw(t *testing.T) {
+	transport := &scriptedTransport{}
+	metadata := append([]byte{byte(tokenColMetadata), 1, 0}, cursorIntColumn("n", colFlagNullable)...)
+	errToken := infoToken(16, "failed")
+	errToken[0] = byte(tokenError)
+	transport.reply(
+		metadata, cursorRow(1), cursorRow(2),
+		rawDoneToken(tokenDone, doneMore|doneCount, cmdSelect, 2),
+		infoToken(0, "hello"),
+		rawDoneToken(tokenDoneInProc, doneMore|doneCount, testCmdInsert, 3),
+		errToken,
+		rawDoneToken(tokenDone, doneError, 0, 0),
+	)
+	visitor := &recordingVisitor{}
+	if err := testConn(newConnector(msdsn.Config{}, nil), transport).ExecRaw(context.Background(), "select n from numbers", visitor); err != nil {
+		t.Fatal(err)
+	}
+	wantTokens := []string{
+		"columns [{n INT true}]", "row [1]", "row [2]", "done",
+		"message 0 hello", "done", "message 16 failed", "done",
+	}
+	if !reflect.DeepEqual(visitor.tokens, wantTokens) {
+		t.Errorf("expected the tokens %v, got %v", wantTokens, visitor.tokens)
+	}
+	wantDones := []RawDone{
+		{Status: DoneMore | DoneCount, CurCmd: cmdSelect, RowCount: 2},
+		{Status: DoneMore | DoneCount, CurCmd: testCmdInsert, RowCount: 3, InProc: true},
+		{Status: DoneError},
+	}
+	if !reflect.DeepEqual(visitor.dones, wantDones) {
+		t.Errorf("expected the DONE tokens %+v, got %+v", wantDones, visitor.dones)
+	}
+}
+
+func TestExecRawOpenRowsTransaction(t *testing.T) {
+	transport := &scriptedTransport{}
+	replyBeginTran(transport, 0x1122334455667788)
+	transport.reply(rawDoneToken(tokenDone, doneCount, testCmdInsert, 1))
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	ctx := context.Background()
+	rows, err := (&Stmt{c: c, query: "begin tran; select n from numbers"}).queryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if err = c.ExecRaw(ctx, "update numbers set n = 1", &recordingVisitor{}); err != nil {
+		t.Fatal(err)
+	}
+	// the transaction begun by the buffered response is sent
+	if got := lastRequestTranid(transport); got != 0x1122334455667788 {
+		t.Errorf("expected the transaction of the open rows, got %x", got)
+	}
+}
+
+func TestExecRawVisitorError(t *testing.T) {
+	transport := &scriptedTransport{}
+	transport.reply(
+		rawDoneToken(tokenDone, doneMore|doneCount, testCmdInsert, 1),
+		rawDoneToken(tokenDone, doneCount, testCmdInsert, 1),
+	)
+	// the next request must find the connection ready
+	transport.reply(rawDoneToken(tokenDone, doneCount, testCmdInsert, 4))
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	stop := errors.New("stop")
+	visitor := &recordingVisitor{err: stop}
+	if err := c.ExecRaw(context.Background(), "insert ...", visitor); err != stop {
+		t.Fatalf("expected the visitor error, got %v", err)
+	}
+	if len(visitor.dones) != 1 {
+		t.Errorf("expected the tokens after the error to be skipped, got %+v", visitor.dones)
+	}
+	visitor = &recordingVisitor{}
+	if err := c.ExecRaw(context.Background(), "insert ...", visitor); err != nil {
+		t.Fatal(err)
+	}
+	if len(visitor.dones) != 1 || visitor.dones[0].RowCount != 4 {
+		t.Errorf("expected the DONE token of the second request, got %+v", visitor.dones)
+	}
+}
+
+func TestExecRawQuery(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	visitor := &recordingVisitor{}
+	err = conn.Raw(func(driverConn interface{}) error {
+		return driverConn.(*Conn).ExecRaw(ctx, "select 1 as n union all select 2; print 'hello'; select 1/0", visitor)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visitor.dones) != 3 {
+		t.Fatalf("expected a DONE token per statement, got %+v", visitor.dones)
+	}
+	if d := visitor.dones[0]; d.Status&(DoneMore|DoneCount) != DoneMore|DoneCount || d.RowCount != 2 {
+		t.Errorf("expected the select to count 2 rows, got %+v", d)
+	}
+	if d := visitor.dones[2]; d.Status&DoneError == 0 || d.Status&DoneMore != 0 {
+		t.Errorf("expected the last statement to fail, got %+v", d)
+	}
+	var messages int
+	for _, tok := range visitor.tokens {
+		if tok == "message 0 hello" || tok == "message 16 Divide by zero error encountered." {
+			messages++
+		}
+	}
+	if messages != 2 {
+		t.Errorf("expected the print and the error messages, got %v", visitor.tokens)
+	}
+}
@@ -3,6 +3,7 @@ package mssql
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"github.com/microsoft/go-mssqldb/msdsn"
 )
@@ -79,3 +80,26 @@ func NewActiveDirectoryTokenConnector(config msdsn.Config, adalWorkflow byte, to
 
 	return conn, nil
 }
+
+// loginFailedNumber is the error number of a failed login.
+const loginFailedNumber = 18456
+
+type tokenRefreshContextKey struct{}
+
+// TokenRefreshRequired reports whether a token provider is called to log in
+// again after the server rejected the previous token as expired. The
+// provider should then obtain a new token rather than return a cached one.
+func TokenRefreshRequired(ctx context.Context) bool {
+	refresh, _ := ctx.Value(tokenRefreshContextKey{}).(bool)
+	return refresh
+}
+
+// isTokenExpiredError tells whether the login failed because the server
+// found the federated authentication token expired.
+func isTokenExpiredError(err error) bool {
+	var sqlErr Error
+	if !errors.As(err, &sqlErr) || sqlErr.Number != loginFailedNumber {
+		return false
+	}
+	return strings.Contains(strings.ToLower(sqlErr.Message), "expired")
+}
@@ -0,0 +1,37 @@
+package mssql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsTokenExpiredError(t *testing.T) {
+	expired := Error{Number: 18456, Message: "login error: Login failed for user '<token-identified principal>'. Token is expired."}
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{expired, true},
+		{fmt.Errorf("wrapped: %w", expired), true},
+		{Error{Number: 18456, Message: "login error: Login failed for user 'sa'."}, false},
+		{Error{Number: 4060, Message: "Cannot open database requested by the login. The password expired."}, false},
+		{errors.New("token expired"), false},
+	}
+	for _, tst := range tests {
+		if got := isTokenExpiredError(tst.err); got != tst.expected {
+			t.Errorf("isTokenExpiredError(%v) = %v, expected %v", tst.err, got, tst.expected)
+		}
+	}
+}
+
+func TestTokenRefreshRequired(t *testing.T) {
+	ctx := context.Background()
+	if TokenRefreshRequired(ctx) {
+		t.Error("Expected no refresh for a plain context")
+	}
+	if !TokenRefreshRequired(context.WithValue(ctx, tokenRefreshContextKey{}, true)) {
+		t.Error("Expected a refresh after an expired token login failure")
+	}
+}
@@ -0,0 +1,64 @@
+package mssql
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// FileStream holds what the Win32 streaming API, OpenSqlFilestream, needs
+// to open the file of a FILESTREAM value: its logical path and the
+// transaction context of the current transaction. Select it with the
+// expression returned by FileStreamColumn, in a transaction:
+//
+//	var fs mssql.FileStream
+//	err = tx.QueryRow("select "+mssql.FileStreamColumn("doc")+" from docs where id = @p1", id).Scan(&fs)
+//
+// The transaction context is only valid until the transaction ends.
+type FileStream struct {
+	// Path is the logical path of the file, as returned by PathName().
+	Path string
+	// TransactionContext is the token returned by
+	// GET_FILESTREAM_TRANSACTION_CONTEXT(), nil outside a transaction.
+	TransactionContext []byte
+}
+
+// FileStreamColumn returns the expression selecting the path and the
+// transaction context of the FILESTREAM column, to scan into a FileStream.
+// The column, such as "doc" or "d.[doc]", is used in the query as is and
+// must not come from user input.
+func FileStreamColumn(column string) string {
+	// the length of the transaction context, in 4 big endian bytes, the
+	// transaction context and the path in UTF-16
+	return "cast(isnull(datalength(GET_FILESTREAM_TRANSACTION_CONTEXT()), 0) as binary(4))" +
+		" + isnull(GET_FILESTREAM_TRANSACTION_CONTEXT(), 0x)" +
+		" + cast(" + column + ".PathName() as varbinary(max))"
+}
+
+// Scan implements sql.Scanner.
+func (fs *FileStream) Scan(v interface{}) error {
+	switch vt := v.(type) {
+	case []byte:
+		if len(vt) < 4 {
+			return fmt.Errorf("mssql: invalid FILESTREAM value of %d bytes", len(vt))
+		}
+		n := binary.BigEndian.Uint32(vt)
+		if uint64(n) > uint64(len(vt)-4) {
+			return fmt.Errorf("mssql: invalid FILESTREAM transaction context of %d bytes", n)
+		}
+		path, err := ucs22str(vt[4+n:])
+		if err != nil {
+			return err
+		}
+		fs.Path = path
+		fs.TransactionContext = nil
+		if n > 0 {
+			fs.TransactionContext = append([]byte{}, vt[4:4+n]...)
+		}
+		return nil
+	case nil:
+		return errors.New("mssql: cannot scan NULL into FileStream")
+	default:
+		return fmt.Errorf("mssql: cannot convert %T to FileStream", v)
+	}
+}
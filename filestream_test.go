@@ -0,0 +1,78 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFileStreamScan(t *testing.T) {
+	path := `\\server\mssqlserver\v02-A60EC2F8\db\dbo\docs\doc\FF4D0ED4`
+	txContext := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	v := append([]byte{0, 0, 0, 16}, txContext...)
+	v = append(v, str2ucs2(path)...)
+	var fs FileStream
+	if err := fs.Scan(v); err != nil {
+		t.Fatal(err)
+	}
+	if fs.Path != path || !bytes.Equal(fs.TransactionContext, txContext) {
+		t.Errorf("unexpected FileStream %+v", fs)
+	}
+
+	// outside a transaction
+	if err := fs.Scan(append([]byte{0, 0, 0, 0}, str2ucs2(path)...)); err != nil {
+		t.Fatal(err)
+	}
+	if fs.Path != path || fs.TransactionContext != nil {
+		t.Errorf("expected no transaction context, got %+v", fs)
+	}
+
+	for _, v := range []interface{}{nil, "path", []byte{0, 0}, []byte{0, 0, 0, 3, 1, 2}, []byte{0, 0, 0, 0, 1}} {
+		if err := fs.Scan(v); err == nil {
+			t.Errorf("expected an error scanning %v", v)
+		}
+	}
+}
+
+func TestFileStreamQuery(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	var filegroups int
+	if err := db.QueryRow("select count(*) from sys.filegroups where type = 'FD'").Scan(&filegroups); err != nil {
+		t.Fatal(err)
+	}
+	if filegroups == 0 {
+		t.Skip("the database has no FILESTREAM filegroup")
+	}
+	db.Exec("drop table if exists dbo.filestream_test")
+	_, err := db.Exec(`create table dbo.filestream_test (
+		id uniqueidentifier rowguidcol not null unique default newid(),
+		doc varbinary(max) filestream null)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("drop table dbo.filestream_test")
+	if _, err = db.Exec("insert into dbo.filestream_test (doc) values (0x0102)"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	var fs FileStream
+	if err = tx.QueryRow("select " + FileStreamColumn("doc") + " from dbo.filestream_test").Scan(&fs); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(fs.Path, `\\`) || !strings.Contains(fs.Path, `\filestream_test\doc\`) {
+		t.Errorf("expected the UNC path of the value, got %q", fs.Path)
+	}
+	if len(fs.TransactionContext) == 0 {
+		t.Error("expected the transaction context of the transaction")
+	}
+}
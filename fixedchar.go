@@ -0,0 +1,25 @@
+package mssql
+
+import (
+	"database/sql/driver"
+	"strings"
+)
+
+// trimFixedChar removes the trailing spaces SQL Server pads the char and
+// nchar values of row with, when the connector of c sets TrimFixedChar.
+func trimFixedChar(c *Conn, cols []columnStruct, row []driver.Value) {
+	if c.connector == nil || !c.connector.TrimFixedChar {
+		return
+	}
+	for i := range row {
+		if i >= len(cols) {
+			return
+		}
+		switch cols[i].ti.TypeId {
+		case typeChar, typeBigChar, typeNChar:
+			if s, ok := row[i].(string); ok {
+				row[i] = strings.TrimRight(s, " ")
+			}
+		}
+	}
+}
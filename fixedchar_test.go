@@ -0,0 +1,78 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func charColumn(typeId uint8, size uint16, name string) []byte {
+	b := []byte{0, 0, 0, 0, byte(colFlagNullable), 0, typeId, byte(size), byte(size >> 8), 0x09, 0x04, 0xd0, 0x00, 0x34, byte(len(name))}
+	return append(b, str2ucs2(name)...)
+}
+
+func charValue(v string) []byte {
+	return append([]byte{byte(len(v)), byte(len(v) >> 8)}, v...)
+}
+
+func TestTrimFixedChar(t *testing.T) {
+	for _, trim := range []bool{false, true} {
+		transport := &scriptedTransport{}
+		metadata := append([]byte{byte(tokenColMetadata), 2, 0}, charColumn(typeBigChar, 10, "c")...)
+		metadata = append(metadata, charColumn(typeBigVarChar, 10, "v")...)
+		row := append([]byte{byte(tokenRow)}, charValue("ab        ")...)
+		row = append(row, charValue("ab  ")...)
+		transport.reply(metadata, row, []byte{byte(tokenDone), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+
+		connector := newConnector(msdsn.Config{}, nil)
+		connector.TrimFixedChar = trim
+		c := testConn(connector, transport)
+		rows, err := (&Stmt{c: c, query: "select c, v from t"}).queryContext(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dest := make([]driver.Value, 2)
+		if err = rows.Next(dest); err != nil {
+			t.Fatal(err)
+		}
+		want := []driver.Value{"ab        ", "ab  "}
+		if trim {
+			want[0] = "ab"
+		}
+		if !reflect.DeepEqual(dest, want) {
+			t.Errorf("TrimFixedChar %v: expected %q, got %q", trim, want, dest)
+		}
+		rows.Close()
+	}
+}
+
+func TestTrimFixedCharQuery(t *testing.T) {
+	checkConnStr(t)
+	connector, err := NewConnector(makeConnStr(t).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	const query = "select cast('ab' as char(10)), cast(N'ab' as nchar(10)), cast('ab  ' as varchar(10))"
+	for _, trim := range []bool{false, true} {
+		connector.TrimFixedChar = trim
+		// the option is checked for each row, so the pooled connection follows it
+		var c, nc, v string
+		if err = db.QueryRow(query).Scan(&c, &nc, &v); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"ab        ", "ab        ", "ab  "}
+		if trim {
+			want[0], want[1] = "ab", "ab"
+		}
+		if got := []string{c, nc, v}; !reflect.DeepEqual(got, want) {
+			t.Errorf("TrimFixedChar %v: expected %q, got %q", trim, want, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package mssql
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HierarchyID is a HIERARCHYID value, scanned as its binary representation:
+//
+//	var node mssql.HierarchyID
+//	err = db.QueryRow("select node from org where id = @p1", id).Scan(&node)
+//	fmt.Println(node) // "/1/2/3/"
+//
+// String decodes it into the canonical path representation, as returned by
+// the ToString method of the hierarchyid type.
+type HierarchyID []byte
+
+// Scan implements sql.Scanner.
+func (h *HierarchyID) Scan(v interface{}) error {
+	switch vt := v.(type) {
+	case []byte:
+		*h = append(HierarchyID{}, vt...)
+		return nil
+	case nil:
+		return errors.New("mssql: cannot scan NULL into HierarchyID")
+	default:
+		return fmt.Errorf("mssql: cannot convert %T to HierarchyID", v)
+	}
+}
+
+// String returns the path of the node, such as "/" for the root or
+// "/1/2.1/" for a descendant. A value which can not be decoded is returned
+// in hexadecimal, e.g. "0xFF".
+func (h HierarchyID) String() string {
+	s, err := h.path()
+	if err != nil {
+		return fmt.Sprintf("0x%X", []byte(h))
+	}
+	return s
+}
+
+// hierarchyIDPattern is the encoding of the labels between min and the
+// min of the next pattern, see
+// https://learn.microsoft.com/en-us/openspecs/sql_server_protocols/ms-ssclrt
+//
+// The bits of the label minus min go in the x of the layout, the 0 and 1
+// are fixed. The layout is followed by a bit set on the last label of a
+// level, the other labels of a level such as the 1 of "/1.2/" being stored
+// plus one.
+type hierarchyIDPattern struct {
+	prefix string
+	layout string
+	min    int64
+}
+
+var (
+	hierarchyIDLayout32 = strings.Repeat("x", 19) + "0" + strings.Repeat("x", 6) + "0xxx0x1xxx"
+	hierarchyIDLayout48 = strings.Repeat("x", 14) + "0" + strings.Repeat("x", 21) + "0" + strings.Repeat("x", 6) + "0xxx0x1xxx"
+)
+
+var hierarchyIDPatterns = []hierarchyIDPattern{
+	{"000100", hierarchyIDLayout48, -281479271682120},
+	{"000101", hierarchyIDLayout32, -4294971464},
+	{"000110", "xxxxx0xxx0x1xxx", -4168},
+	{"0010", "xx0x1xxx", -72},
+	{"00111", "xxx", -8},
+	{"01", "xx", 0},
+	{"100", "xx", 4},
+	{"101", "xxx", 8},
+	{"110", "xx0x1xxx", 16},
+	{"1110", "xxx0xxx0x1xxx", 80},
+	{"11110", "xxxxx0xxx0x1xxx", 1104},
+	{"111110", hierarchyIDLayout32, 5200},
+	{"111111", hierarchyIDLayout48, 4294972496},
+}
+
+// hierarchyIDBits reads the bits of a HIERARCHYID value, the most
+// significant bit of each byte first.
+type hierarchyIDBits struct {
+	b   []byte
+	pos int
+}
+
+func (r *hierarchyIDBits) bit() (byte, bool) {
+	if r.pos >= len(r.b)*8 {
+		return 0, false
+	}
+	bit := r.b[r.pos/8] >> (7 - r.pos%8) & 1
+	r.pos++
+	return bit, true
+}
+
+// done tells whether the bits left are the zero padding of the last byte.
+func (r *hierarchyIDBits) done() bool {
+	for i := r.pos; i < len(r.b)*8; i++ {
+		if r.b[i/8]>>(7-i%8)&1 != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *hierarchyIDBits) label() (label int64, last bool, err error) {
+	var prefix []byte
+	var p *hierarchyIDPattern
+	for p == nil {
+		bit, ok := r.bit()
+		if !ok || len(prefix) == 6 {
+			return 0, false, errors.New("mssql: invalid HierarchyID label")
+		}
+		prefix = append(prefix, '0'+bit)
+		for i := range hierarchyIDPatterns {
+			if hierarchyIDPatterns[i].prefix == string(prefix) {
+				p = &hierarchyIDPatterns[i]
+			}
+		}
+	}
+	var v int64
+	for _, c := range p.layout + "T" {
+		bit, ok := r.bit()
+		if !ok {
+			return 0, false, errors.New("mssql: truncated HierarchyID label")
+		}
+		switch c {
+		case 'x':
+			v = v<<1 | int64(bit)
+		case 'T':
+			last = bit == 1
+		default:
+			if bit != byte(c-'0') {
+				return 0, false, errors.New("mssql: invalid HierarchyID label")
+			}
+		}
+	}
+	label = p.min + v
+	if !last {
+		label--
+	}
+	return label, last, nil
+}
+
+func (h HierarchyID) path() (string, error) {
+	r := &hierarchyIDBits{b: h}
+	var s strings.Builder
+	s.WriteByte('/')
+	for !r.done() {
+		label, last, err := r.label()
+		if err != nil {
+			return "", err
+		}
+		s.WriteString(strconv.FormatInt(label, 10))
+		if last {
+			s.WriteByte('/')
+		} else {
+			s.WriteByte('.')
+		}
+	}
+	if str := s.String(); str[len(str)-1] == '.' {
+		return "", errors.New("mssql: truncated HierarchyID level")
+	}
+	return s.String(), nil
+}
@@ -0,0 +1,87 @@
+package mssql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHierarchyIDString(t *testing.T) {
+	t.Parallel()
+	values := []struct {
+		b    []byte
+		want string
+	}{
+		{[]byte{}, "/"},
+		{[]byte{0x58}, "/1/"},
+		{[]byte{0x68}, "/2/"},
+		{[]byte{0x5a, 0xc0}, "/1/1/"},
+		{[]byte{0x5a, 0xde}, "/1/1/3/"},
+		{[]byte{0x62, 0xc0}, "/1.1/"},
+		{[]byte{0xc1, 0x10}, "/16/"},
+		{[]byte{0x3f, 0x80}, "/-1/"},
+		{[]byte{0xff}, "0xFF"},
+		{[]byte{0x5a}, "0x5A"}, // a level ending with a dot
+	}
+	for _, v := range values {
+		var h HierarchyID
+		if err := h.Scan(v.b); err != nil {
+			t.Fatal(err)
+		}
+		if got := h.String(); got != v.want {
+			t.Errorf("% x: expected %s, got %s", v.b, v.want, got)
+		}
+	}
+	var h HierarchyID
+	for _, in := range []interface{}{nil, "/1/", int64(1)} {
+		if err := h.Scan(in); err == nil {
+			t.Errorf("expected an error for Scan(%v)", in)
+		}
+	}
+}
+
+func TestHierarchyIDPatterns(t *testing.T) {
+	t.Parallel()
+	// the patterns cover the labels with no gap
+	for i, p := range hierarchyIDPatterns[1:] {
+		prev := hierarchyIDPatterns[i]
+		size := int64(1) << strings.Count(prev.layout, "x")
+		if prev.min+size != p.min {
+			t.Errorf("pattern %s: expected %d to follow the labels of %s, got %d", p.prefix, prev.min+size, prev.prefix, p.min)
+		}
+	}
+}
+
+func TestHierarchyIDQuery(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	rows, err := db.Query(`declare @root hierarchyid = hierarchyid::GetRoot()
+		declare @a hierarchyid = @root.GetDescendant(null, null)
+		declare @b hierarchyid = @root.GetDescendant(@a, null)
+		declare @c hierarchyid = @root.GetDescendant(@a, @b)
+		select n, n.ToString() from (values (@root), (@a), (@b), (@c), (@c.GetDescendant(null, null)),
+			(cast('/-7/100/5000/' as hierarchyid)), (cast('/4294972500/-5000000000/' as hierarchyid))) v(n)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var n int
+	for rows.Next() {
+		var h HierarchyID
+		var want string
+		if err = rows.Scan(&h, &want); err != nil {
+			t.Fatal(err)
+		}
+		if got := h.String(); got != want {
+			t.Errorf("% x: expected %s, got %s", []byte(h), want, got)
+		}
+		n++
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 7 {
+		t.Errorf("expected 7 rows, got %d", n)
+	}
+}
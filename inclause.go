@@ -0,0 +1,45 @@
+package mssql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// InClause returns the condition column IN (@p1, @p2, ...) with a
+// positional parameter for each value, and the values to pass as the
+// arguments of the query:
+//
+//	cond, args, err := mssql.InClause("id", []interface{}{1, 2, 3})
+//	rows, err := db.QueryContext(ctx, "select name from users where "+cond, args...)
+//
+// The parameters are numbered from @p1, other parameters of the query are
+// numbered after them and appended to args. column is written as is, it
+// must not come from untrusted input. An empty list gives the condition
+// 1=0, which matches no row.
+//
+// A request has at most 2100 parameters. A longer list returns an error,
+// pass the values in a table-valued parameter (see TVP) instead and use
+// column IN (SELECT value FROM @list).
+func InClause(column string, values []interface{}) (string, []interface{}, error) {
+	if len(values) == 0 {
+		return "1=0", nil, nil
+	}
+	if len(values) > maxValuesBatchParams {
+		return "", nil, fmt.Errorf("mssql: InClause supports at most %d values, got %d, use a table-valued parameter instead", maxValuesBatchParams, len(values))
+	}
+	var q strings.Builder
+	q.WriteString(column)
+	q.WriteString(" IN (")
+	for i := range values {
+		if i > 0 {
+			q.WriteString(", ")
+		}
+		q.WriteString("@p")
+		q.WriteString(strconv.Itoa(i + 1))
+	}
+	q.WriteByte(')')
+	args := make([]interface{}, len(values))
+	copy(args, values)
+	return q.String(), args, nil
+}
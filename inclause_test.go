@@ -0,0 +1,75 @@
+package mssql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInClause(t *testing.T) {
+	cond, args, err := InClause("u.id", []interface{}{1, "b", nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "u.id IN (@p1, @p2, @p3)"; cond != want {
+		t.Errorf("expected %s, got %s", want, cond)
+	}
+	if fmt.Sprint(args) != "[1 b <nil>]" {
+		t.Errorf("unexpected arguments %v", args)
+	}
+
+	cond, args, err = InClause("id", nil)
+	if err != nil || cond != "1=0" || len(args) != 0 {
+		t.Errorf("expected 1=0 without arguments for an empty list, got %s, %v, %v", cond, args, err)
+	}
+}
+
+func TestInClauseLarge(t *testing.T) {
+	values := make([]interface{}, maxValuesBatchParams)
+	for i := range values {
+		values[i] = i
+	}
+	cond, args, err := InClause("id", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != len(values) || !strings.HasSuffix(cond, fmt.Sprintf("@p%d)", len(values))) {
+		t.Errorf("expected %d parameters, got %d", len(values), len(args))
+	}
+
+	_, _, err = InClause("id", append(values, 0))
+	if err == nil || !strings.Contains(err.Error(), "table-valued parameter") {
+		t.Errorf("expected an error suggesting a table-valued parameter, got %v", err)
+	}
+}
+
+func TestInClauseQuery(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	values := make([]interface{}, 1500)
+	for i := range values {
+		values[i] = i * 2
+	}
+	cond, args, err := InClause("n", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	query := "select count(*) from (select top (3000) row_number() over (order by object_id) - 1 as n from sys.all_columns) t where " + cond
+	if err = conn.QueryRow(query, args...).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(values) {
+		t.Errorf("expected %d matching rows, got %d", len(values), count)
+	}
+
+	cond, args, _ = InClause("n", nil)
+	if err = conn.QueryRow("select count(*) from (select 1 as n) t where "+cond, args...).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("expected no row for an empty list, got %d", count)
+	}
+}
@@ -0,0 +1,38 @@
+package integratedauth
+
+import (
+	"crypto"
+	"crypto/x509"
+
+	// the hashes of the certificate signature algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// ChannelBinder is implemented by the IntegratedAuthenticators that can bind
+// the authentication to the TLS channel of the connection, which servers
+// with Extended Protection for Authentication require. The driver calls
+// SetChannelBinding before InitialBytes when the connection negotiated TLS.
+type ChannelBinder interface {
+	// SetChannelBinding sets the application data of the channel bindings,
+	// as returned by TLSServerEndPoint.
+	SetChannelBinding(applicationData []byte)
+}
+
+// TLSServerEndPoint returns the tls-server-end-point channel binding of
+// RFC 5929 for the server certificate cert: "tls-server-end-point:"
+// followed by the hash of the certificate. The hash is the one of the
+// certificate signature algorithm, SHA-256 when that is MD5, SHA-1 or an
+// algorithm without a separate hash.
+func TLSServerEndPoint(cert *x509.Certificate) []byte {
+	h := crypto.SHA256
+	switch cert.SignatureAlgorithm {
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384, x509.SHA384WithRSAPSS:
+		h = crypto.SHA384
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512, x509.SHA512WithRSAPSS:
+		h = crypto.SHA512
+	}
+	hash := h.New()
+	hash.Write(cert.Raw)
+	return hash.Sum([]byte("tls-server-end-point:"))
+}
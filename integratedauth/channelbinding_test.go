@@ -0,0 +1,32 @@
+package integratedauth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"testing"
+)
+
+func TestTLSServerEndPoint(t *testing.T) {
+	raw := []byte("certificate")
+	sha256Hash := sha256.Sum256(raw)
+	sha384Hash := sha512.Sum384(raw)
+	tests := []struct {
+		alg  x509.SignatureAlgorithm
+		hash []byte
+	}{
+		{x509.SHA1WithRSA, sha256Hash[:]},
+		{x509.MD5WithRSA, sha256Hash[:]},
+		{x509.SHA256WithRSA, sha256Hash[:]},
+		{x509.ECDSAWithSHA384, sha384Hash[:]},
+		{x509.PureEd25519, sha256Hash[:]},
+	}
+	for _, tt := range tests {
+		got := TLSServerEndPoint(&x509.Certificate{Raw: raw, SignatureAlgorithm: tt.alg})
+		want := append([]byte("tls-server-end-point:"), tt.hash...)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%v: expected %x, got %x", tt.alg, want, got)
+		}
+	}
+}
@@ -72,6 +72,34 @@ func getAuth(config msdsn.Config) (integratedauth.IntegratedAuthenticator, error
 	}, nil
 }
 
+// CredentialsFunc returns the Kerberos client, holding the credentials, to
+// log in to the server of config with. The client is logged in and destroyed
+// by the authenticator, so a new client must be returned by each call.
+type CredentialsFunc func(config msdsn.Config) (*client.Client, error)
+
+// NewProvider returns a provider authenticating with the credentials of the
+// clients returned by credentials, instead of the credentials named by the
+// connection string. It lets the connections of a process use different
+// principals, keytabs or credential caches. Use it as the
+// IntegratedAuthProvider of a connector:
+//
+//	connector.IntegratedAuthProvider = krb5.NewProvider(func(config msdsn.Config) (*client.Client, error) {
+//		return client.NewWithKeytab(user, realm, kt, krbConf, client.DisablePAFXFAST(true)), nil
+//	})
+func NewProvider(credentials CredentialsFunc) integratedauth.Provider {
+	return integratedauth.ProviderFunc(func(config msdsn.Config) (integratedauth.IntegratedAuthenticator, error) {
+		if credentials == nil {
+			return nil, ErrRequiredParametersMissing
+		}
+		return &krbAuth{
+			krb5Config: &krb5Login{ServerSPN: config.ServerSPN},
+			newClient: func(*krb5Login) (*client.Client, error) {
+				return credentials(config)
+			},
+		}, nil
+	})
+}
+
 type loginMethod uint8
 
 const (
@@ -252,13 +280,22 @@ type krbAuth struct {
 	krb5Config   *krb5Login
 	spnegoClient *spnego.SPNEGO
 	krb5Client   *client.Client
+	// newClient creates the client, getKrb5Client when nil
+	newClient func(*krb5Login) (*client.Client, error)
 }
 
 func (k *krbAuth) InitialBytes() ([]byte, error) {
-	krbClient, err := getKrb5Client(k.krb5Config)
+	newClient := k.newClient
+	if newClient == nil {
+		newClient = getKrb5Client
+	}
+	krbClient, err := newClient(k.krb5Config)
 	if err != nil {
 		return nil, err
 	}
+	if krbClient == nil {
+		return nil, ErrRequiredParametersMissing
+	}
 
 	err = krbClient.Login()
 	if err != nil {
@@ -1,10 +1,12 @@
 package krb5
 
 import (
+	"errors"
 	"os"
 	"strings"
 	"testing"
 
+	"github.com/jcmturner/gokrb5/v8/client"
 	"github.com/jcmturner/gokrb5/v8/config"
 	"github.com/microsoft/go-mssqldb/msdsn"
 )
@@ -568,3 +570,32 @@ func TestGetAuth(t *testing.T) {
 		t.Errorf("Expected UDPPreferenceLimit %v, found %v", 1234, actual.krb5Config.UDPPreferenceLimit)
 	}
 }
+
+func TestNewProvider(t *testing.T) {
+	config := msdsn.Config{ServerSPN: "MSSQLSvc/db.example.com:1433"}
+	errNoTicket := errors.New("no ticket")
+	var got msdsn.Config
+	provider := NewProvider(func(cfg msdsn.Config) (*client.Client, error) {
+		got = cfg
+		return nil, errNoTicket
+	})
+
+	a, err := provider.GetIntegratedAuthenticator(config)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if actual := a.(*krbAuth); actual.krb5Config.ServerSPN != config.ServerSPN {
+		t.Errorf("Expected serverSpn %v, found %v", config.ServerSPN, actual.krb5Config.ServerSPN)
+	}
+	if _, err = a.InitialBytes(); err != errNoTicket {
+		t.Errorf("Expected the error of the credentials, found %v", err)
+	}
+	if got.ServerSPN != config.ServerSPN {
+		t.Errorf("Expected the credentials to be asked for %v, found %v", config.ServerSPN, got.ServerSPN)
+	}
+	a.Free()
+
+	if _, err = NewProvider(nil).GetIntegratedAuthenticator(config); err != ErrRequiredParametersMissing {
+		t.Errorf("Expected ErrRequiredParametersMissing without credentials, found %v", err)
+	}
+}
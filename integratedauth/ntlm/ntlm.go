@@ -62,6 +62,58 @@ type Auth struct {
 	UserName    string
 	Password    string
 	Workstation string
+
+	// channelBinding is the application data of the channel bindings of
+	// the TLS connection, nil without TLS
+	channelBinding []byte
+}
+
+// SetChannelBinding implements integratedauth.ChannelBinder, the NTLMv2
+// response then carries the hash of the channel bindings.
+func (auth *Auth) SetChannelBinding(applicationData []byte) {
+	auth.channelBinding = applicationData
+}
+
+const (
+	_MSV_AV_EOL              = 0x0000
+	_MSV_AV_CHANNEL_BINDINGS = 0x000A
+)
+
+// channelBindingsHash returns the MD5 hash of the gss_channel_bindings_struct
+// with the application data and without addresses.
+func channelBindingsHash(applicationData []byte) []byte {
+	// initiator and acceptor address types and lengths, application data length
+	bindings := make([]byte, 20, 20+len(applicationData))
+	binary.LittleEndian.PutUint32(bindings[16:], uint32(len(applicationData)))
+	bindings = append(bindings, applicationData...)
+	hash := md5.Sum(bindings)
+	return hash[:]
+}
+
+// addChannelBindings returns the target information AV pairs with a
+// MsvAvChannelBindings pair holding the hash of the channel bindings.
+func addChannelBindings(targetInfo, applicationData []byte) ([]byte, error) {
+	var pairs []byte
+	for b := targetInfo; ; {
+		if len(b) < 4 {
+			return nil, errorNTLM
+		}
+		id := binary.LittleEndian.Uint16(b)
+		size := int(binary.LittleEndian.Uint16(b[2:]))
+		if id == _MSV_AV_EOL {
+			break
+		}
+		if len(b) < 4+size {
+			return nil, errorNTLM
+		}
+		if id != _MSV_AV_CHANNEL_BINDINGS {
+			pairs = append(pairs, b[:4+size]...)
+		}
+		b = b[4+size:]
+	}
+	pairs = append(pairs, _MSV_AV_CHANNEL_BINDINGS, 0, 16, 0)
+	pairs = append(pairs, channelBindingsHash(applicationData)...)
+	return append(pairs, _MSV_AV_EOL, 0, 0, 0), nil
 }
 
 // getAuth returns an authentication handle Auth to provide authentication content
@@ -243,7 +295,7 @@ func getNTLMv2AndLMv2ResponsePayloads(userDomain, username, password string, cha
 	return
 }
 
-func negotiateExtendedSessionSecurity(flags uint32, message []byte, challenge [8]byte, username, password, userDom string) (lm, nt []byte, err error) {
+func negotiateExtendedSessionSecurity(flags uint32, message []byte, challenge [8]byte, username, password, userDom string, channelBinding []byte) (lm, nt []byte, err error) {
 	nonce := clientChallenge()
 
 	// Official specification: https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-nlmp/b38c36ed-2804-4868-a9ff-8dd3182128e4
@@ -253,6 +305,12 @@ func negotiateExtendedSessionSecurity(flags uint32, message []byte, challenge [8
 		if err != nil {
 			return lm, nt, err
 		}
+		if channelBinding != nil {
+			targetInfoFields, err = addChannelBindings(targetInfoFields, channelBinding)
+			if err != nil {
+				return lm, nt, err
+			}
+		}
 
 		nt, lm = getNTLMv2AndLMv2ResponsePayloads(userDom, username, password, challenge, nonce, targetInfoFields, time.Now())
 
@@ -376,7 +434,7 @@ func (auth *Auth) NextBytes(bytes []byte) ([]byte, error) {
 	copy(challenge[:], bytes[24:32])
 	flags := binary.LittleEndian.Uint32(bytes[20:24])
 	if (flags & _NEGOTIATE_EXTENDED_SESSIONSECURITY) != 0 {
-		lm, nt, err := negotiateExtendedSessionSecurity(flags, bytes, challenge, auth.UserName, auth.Password, auth.Domain)
+		lm, nt, err := negotiateExtendedSessionSecurity(flags, bytes, challenge, auth.UserName, auth.Password, auth.Domain, auth.channelBinding)
 		if err != nil {
 			return nil, err
 		}
@@ -2,9 +2,14 @@ package ntlm
 
 import (
 	"bytes"
+	"crypto/md5"
+	"crypto/x509"
+	"encoding/binary"
 	"encoding/hex"
 	"testing"
 	"time"
+
+	"github.com/microsoft/go-mssqldb/integratedauth"
 )
 
 func TestLMOWFv1(t *testing.T) {
@@ -122,3 +127,65 @@ func TestGetNTLMv2TargetInfoFieldsInvalidMessage(t *testing.T) {
 		t.Error("expected to get an error")
 	}
 }
+
+// avPairs returns the AV pairs of the target information of the NTLMv2
+// response in an AUTHENTICATE message.
+func avPairs(t *testing.T, msg []byte) map[uint16][]byte {
+	t.Helper()
+	ntLen := binary.LittleEndian.Uint16(msg[20:])
+	ntOffset := binary.LittleEndian.Uint32(msg[24:])
+	nt := msg[ntOffset : ntOffset+uint32(ntLen)]
+	// the proof, the blob header, the timestamp, the client challenge and 4 reserved bytes
+	info := nt[16+28:]
+	pairs := map[uint16][]byte{}
+	for {
+		id := binary.LittleEndian.Uint16(info)
+		size := binary.LittleEndian.Uint16(info[2:])
+		if id == _MSV_AV_EOL {
+			return pairs
+		}
+		pairs[id] = info[4 : 4+size]
+		info = info[4+size:]
+	}
+}
+
+func TestChannelBinding(t *testing.T) {
+	type2Message, _ := hex.DecodeString("4e544c4d53535000020000000600060038000000058289026999bc21067c77f40000000000000000ac00ac003e0000000a0039380000000f4600570042000200060046005700420001000c00590037004100410041003400040022006000700065002e00610058006e0071006e0070006e00650074002e0063006f006d00030030007900370041004100410034002e006000700065002e00610058006e0071006e0070006e00650074002e0063006f006d00050024006100610058006d002e00610058006e0071006e0070006e00650074002e0063006f006d00070008007d9647e8aed6d50100000000")
+	auth := &Auth{Domain: "FWB", UserName: "user", Password: "SecREt01", Workstation: "WS"}
+	var _ integratedauth.ChannelBinder = auth
+
+	msg, err := auth.NextBytes(type2Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := avPairs(t, msg)[_MSV_AV_CHANNEL_BINDINGS]; ok {
+		t.Error("expected no channel bindings without TLS")
+	}
+
+	// the state of a TLS connection whose server certificate is stubbed
+	cert := &x509.Certificate{Raw: []byte("server certificate"), SignatureAlgorithm: x509.SHA256WithRSA}
+	appData := integratedauth.TLSServerEndPoint(cert)
+	auth.SetChannelBinding(appData)
+	msg, err = auth.NextBytes(type2Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs := avPairs(t, msg)
+	bindings := append(make([]byte, 16), byte(len(appData)), 0, 0, 0)
+	want := md5.Sum(append(bindings, appData...))
+	if got := pairs[_MSV_AV_CHANNEL_BINDINGS]; !bytes.Equal(got, want[:]) {
+		t.Errorf("expected the channel bindings hash %x, got %x", want, got)
+	}
+	// the pairs sent by the server are kept
+	if len(pairs[0x0007]) != 8 || len(pairs[0x0002]) == 0 {
+		t.Errorf("expected the timestamp and the domain name of the server, got %v", pairs)
+	}
+}
+
+func TestAddChannelBindingsInvalidTargetInfo(t *testing.T) {
+	for _, info := range [][]byte{nil, {0x02, 0x00, 0x10, 0x00, 0x41}} {
+		if _, err := addChannelBindings(info, []byte("data")); err == nil {
+			t.Errorf("expected an error for the target information %x", info)
+		}
+	}
+}
@@ -1,3 +1,4 @@
+//go:build windows
 // +build windows
 
 package winsspi
@@ -12,4 +13,4 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,61 @@
+//go:build windows
+// +build windows
+
+package winsspi
+
+import (
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/integratedauth"
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func TestGetAuth(t *testing.T) {
+	a, err := getAuth(msdsn.Config{ServerSPN: "MSSQLSvc/server:1433"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth := a.(*Auth); *auth != (Auth{Service: "MSSQLSvc/server:1433"}) {
+		t.Errorf("expected the credentials of the current user, got %+v", auth)
+	}
+
+	a, err = getAuth(msdsn.Config{User: `DOMAIN\user`, Password: "secret", ServerSPN: "MSSQLSvc/server:1433"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Auth{Domain: "DOMAIN", UserName: "user", Password: "secret", Service: "MSSQLSvc/server:1433"}
+	if auth := a.(*Auth); *auth != want {
+		t.Errorf("expected %+v, got %+v", want, auth)
+	}
+
+	if _, err = getAuth(msdsn.Config{User: "user"}); err == nil {
+		t.Error("expected an error for a user without a domain")
+	}
+}
+
+func TestProviderRegistered(t *testing.T) {
+	a, err := integratedauth.GetIntegratedAuthenticator(msdsn.Config{
+		Parameters: map[string]string{"authenticator": "winsspi"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.(*Auth); !ok {
+		t.Errorf("expected a winsspi authenticator, got %T", a)
+	}
+}
+
+func TestInitialBytes(t *testing.T) {
+	a, err := getAuth(msdsn.Config{ServerSPN: "MSSQLSvc/localhost:1433"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Free()
+	b, err := a.InitialBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) == 0 {
+		t.Error("expected the first token of the Negotiate exchange")
+	}
+}
@@ -91,6 +91,9 @@ func collation2charset(col Collation) *charsetMap {
 }
 
 func CharsetToUTF8(col Collation, s []byte) string {
+	if col.IsUTF8() {
+		return string(s)
+	}
 	cm := collation2charset(col)
 	if cm == nil {
 		return string(s)
@@ -7,6 +7,14 @@ type Collation struct {
 	SortId       uint8
 }
 
+// FlagUTF8 is the fUTF8 bit of LcidAndFlags, set for UTF-8 collations.
+const FlagUTF8 uint32 = 0x04000000
+
+// IsUTF8 reports whether character data of the collation is UTF-8 encoded.
+func (c Collation) IsUTF8() bool {
+	return c.LcidAndFlags&FlagUTF8 != 0
+}
+
 func (c Collation) getLcid() uint32 {
 	return c.LcidAndFlags & 0x000fffff
 }
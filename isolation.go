@@ -0,0 +1,43 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type isolationContextKey struct{}
+
+// IsolationContext returns a copy of ctx that makes queries run with it use
+// the given isolation level without starting a transaction. The level only
+// applies to the query itself: the isolation level of the session is left
+// unchanged, even if the query fails.
+// It cannot be used with stored procedure calls.
+func IsolationContext(ctx context.Context, level sql.IsolationLevel) context.Context {
+	return context.WithValue(ctx, isolationContextKey{}, level)
+}
+
+// isolationStatement returns the SET statement for the isolation level
+// attached to ctx, or an empty string if there is none.
+func isolationStatement(ctx context.Context) (string, error) {
+	level, ok := ctx.Value(isolationContextKey{}).(sql.IsolationLevel)
+	if !ok {
+		return "", nil
+	}
+	switch level {
+	case sql.LevelDefault:
+		return "", nil
+	case sql.LevelReadUncommitted:
+		return "SET TRANSACTION ISOLATION LEVEL READ UNCOMMITTED", nil
+	case sql.LevelReadCommitted:
+		return "SET TRANSACTION ISOLATION LEVEL READ COMMITTED", nil
+	case sql.LevelRepeatableRead:
+		return "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ", nil
+	case sql.LevelSnapshot:
+		return "SET TRANSACTION ISOLATION LEVEL SNAPSHOT", nil
+	case sql.LevelSerializable:
+		return "SET TRANSACTION ISOLATION LEVEL SERIALIZABLE", nil
+	default:
+		return "", errors.New("isolation level is not supported or unknown")
+	}
+}
@@ -0,0 +1,75 @@
+package mssql
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// JSON sends V marshalled with encoding/json as an nvarchar(max)
+// parameter, and unmarshals a character column into V when used as a
+// Scan destination, V being then a pointer:
+//
+//	_, err = db.Exec("insert into orders (doc) values (@p1)", mssql.JSON{V: order})
+//	err = db.QueryRow("select doc from orders where id = @p1", id).Scan(&mssql.JSON{V: &order})
+//
+// A nil V, or a nil map, slice or pointer, is sent as NULL. Scanning NULL
+// unmarshals the JSON null, which sets a map, slice or pointer to nil and
+// leaves other values unchanged.
+type JSON struct {
+	V interface{}
+}
+
+// Value implements driver.Valuer and returns the JSON text.
+func (j JSON) Value() (driver.Value, error) {
+	if isNilJSON(j.V) {
+		return nil, nil
+	}
+	b, err := json.Marshal(j.V)
+	if err != nil {
+		return nil, fmt.Errorf("mssql: cannot marshal %T to JSON: %v", j.V, err)
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (j *JSON) Scan(v interface{}) error {
+	var b []byte
+	switch vt := v.(type) {
+	case string:
+		b = []byte(vt)
+	case []byte:
+		b = vt
+	case nil:
+		b = []byte("null")
+	default:
+		return fmt.Errorf("mssql: cannot convert %T to JSON", v)
+	}
+	if err := json.Unmarshal(b, j.V); err != nil {
+		return fmt.Errorf("mssql: invalid JSON value for %T: %v", j.V, err)
+	}
+	return nil
+}
+
+func isNilJSON(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func makeJSONParam(j JSON) (res param, err error) {
+	res.ti.TypeId = typeNVarChar
+	res.ti.Size = 0 // zero forces nvarchar(max)
+	v, err := j.Value()
+	if err != nil || v == nil {
+		return
+	}
+	res.buffer = str2ucs2(v.(string))
+	return
+}
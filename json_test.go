@@ -0,0 +1,122 @@
+package mssql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type jsonLine struct {
+	Product  string            `json:"product"`
+	Quantity int               `json:"quantity"`
+	Tags     []string          `json:"tags"`
+	Attrs    map[string]string `json:"attrs,omitempty"`
+}
+
+type jsonOrder struct {
+	ID       int64      `json:"id"`
+	Customer *jsonLine  `json:"customer"`
+	Lines    []jsonLine `json:"lines"`
+}
+
+func TestJSONParam(t *testing.T) {
+	t.Parallel()
+	var nilMap map[string]int
+	var nilSlice []int
+	var nilOrder *jsonOrder
+	for _, v := range []interface{}{nil, nilMap, nilSlice, nilOrder} {
+		p, err := (&Stmt{}).makeParam(JSON{V: v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ti.TypeId != typeNVarChar || p.ti.Size != 0 || p.buffer != nil {
+			t.Errorf("expected a NULL nvarchar(max) for %#v, got %+v", v, p)
+		}
+	}
+
+	p, err := (&Stmt{}).makeParam(JSON{V: map[string]int{"a": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ti.TypeId != typeNVarChar || p.ti.Size != 0 {
+		t.Errorf("expected an nvarchar(max), got %+v", p.ti)
+	}
+	if s, _ := ucs22str(p.buffer); s != `{"a":1}` {
+		t.Errorf("unexpected JSON %s", s)
+	}
+
+	if _, err = (&Stmt{}).makeParam(JSON{V: func() {}}); err == nil {
+		t.Error("expected an error for a value that can not be marshalled")
+	}
+}
+
+func TestJSONScan(t *testing.T) {
+	t.Parallel()
+	var order jsonOrder
+	err := (&JSON{V: &order}).Scan(`{"id":1,"customer":{"product":"x"},"lines":[{"product":"a","quantity":2,"tags":["t"]}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := jsonOrder{ID: 1, Customer: &jsonLine{Product: "x"}, Lines: []jsonLine{{Product: "a", Quantity: 2, Tags: []string{"t"}}}}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("expected %+v, got %+v", want, order)
+	}
+
+	m := map[string]int{"a": 1}
+	if err = (&JSON{V: &m}).Scan(nil); err != nil || m != nil {
+		t.Errorf("expected NULL to reset the map, got %v, %v", m, err)
+	}
+
+	err = (&JSON{V: &order}).Scan([]byte(`{"id":`))
+	if err == nil || !strings.Contains(err.Error(), "invalid JSON value") {
+		t.Errorf("expected an invalid JSON error, got %v", err)
+	}
+	if err = (&JSON{V: &order}).Scan(int64(1)); err == nil {
+		t.Error("expected an error for a non character value")
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	order := jsonOrder{
+		ID:       7,
+		Customer: &jsonLine{Product: "contoso", Attrs: map[string]string{"tier": "gold"}},
+		Lines: []jsonLine{
+			{Product: "widget", Quantity: 3, Tags: []string{"red", "large"}},
+			{Product: "gadget", Quantity: 1},
+		},
+	}
+	var got jsonOrder
+	var valid bool
+	err := conn.QueryRow("select @p1, isjson(@p1)", JSON{V: order}).Scan(&JSON{V: &got}, &valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, order) {
+		t.Errorf("expected %+v, got %+v", order, got)
+	}
+	if !valid {
+		t.Error("expected the server to parse the parameter as JSON")
+	}
+
+	var quantity int
+	err = conn.QueryRow("select json_value(@p1, '$.lines[0].quantity')", JSON{V: order}).Scan(&quantity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quantity != 3 {
+		t.Errorf("expected the nested quantity 3, got %d", quantity)
+	}
+
+	var lines []jsonLine
+	err = conn.QueryRow("select @p1", JSON{V: lines}).Scan(&JSON{V: &lines})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines != nil {
+		t.Errorf("expected a nil slice for NULL, got %v", lines)
+	}
+}
@@ -0,0 +1,37 @@
+package mssql
+
+import "context"
+
+type messageHandlerContextKey struct{}
+
+// MessageHandler is called with an informational message of the server,
+// such as the output of PRINT or of RAISERROR with a severity below 11.
+type MessageHandler func(msg Error)
+
+// MessageHandlerContext returns a copy of ctx that makes the statements run
+// with it call h for each informational message as soon as it is read from
+// the connection, instead of after the statement completes. A batch running
+// for a long time can report its progress this way:
+//
+//	ctx = mssql.MessageHandlerContext(ctx, func(msg mssql.Error) {
+//		log.Println(msg.Message)
+//	})
+//	_, err = db.ExecContext(ctx, "print 'step 1'; waitfor delay '00:01'; print 'step 2'")
+//
+// The server sends the messages it buffers when its output buffer is full
+// or when it is flushed, as RAISERROR ... WITH NOWAIT does. h is called on
+// the goroutine reading the response and must not block it for long.
+func MessageHandlerContext(ctx context.Context, h MessageHandler) context.Context {
+	return context.WithValue(ctx, messageHandlerContextKey{}, h)
+}
+
+// handleMessage passes the informational message to the handler attached
+// to ctx, if any.
+func handleMessage(ctx context.Context, msg Error) {
+	if msg.Class >= 11 {
+		return
+	}
+	if h, ok := ctx.Value(messageHandlerContextKey{}).(MessageHandler); ok && h != nil {
+		h(msg)
+	}
+}
@@ -0,0 +1,96 @@
+package mssql
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func infoToken(class uint8, msg string) []byte {
+	b := []byte{0, 0, 0, 0, 1, class}
+	binary.LittleEndian.PutUint32(b, 50000)
+	b = append(b, byte(len(msg)), byte(len(msg)>>8))
+	b = append(b, str2ucs2(msg)...)
+	b = append(b, 0, 0, 0, 0, 0, 0) // no server and procedure names, line 0
+	token := []byte{byte(tokenInfo), 0, 0}
+	binary.LittleEndian.PutUint16(token[1:], uint16(len(b)))
+	return append(token, b...)
+}
+
+func TestMessageHandler(t *testing.T) {
+	transport := &scriptedTransport{}
+	metadata := append([]byte{byte(tokenColMetadata), 1, 0}, cursorIntColumn("n", colFlagNullable)...)
+	transport.reply(infoToken(0, "step 1"), metadata, cursorRow(1), infoToken(10, "step 2"), infoToken(16, "not a message"),
+		[]byte{byte(tokenDone), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	var mu sync.Mutex
+	var got []string
+	ctx := MessageHandlerContext(context.Background(), func(msg Error) {
+		mu.Lock()
+		got = append(got, msg.Message)
+		mu.Unlock()
+	})
+	rows, err := (&Stmt{c: c, query: "select n from numbers"}).queryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the response is still being read, the first message came before the columns
+	mu.Lock()
+	if len(got) == 0 || got[0] != "step 1" {
+		t.Errorf("expected the message sent before the columns to be handled, got %v", got)
+	}
+	mu.Unlock()
+	dest := make([]driver.Value, 1)
+	for err == nil {
+		err = rows.Next(dest)
+	}
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	if err = rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"step 1", "step 2"}) {
+		t.Errorf("expected the informational messages, got %v", got)
+	}
+}
+
+func TestMessageHandlerQuery(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	type message struct {
+		text string
+		at   time.Time
+	}
+	var got []message
+	ctx := MessageHandlerContext(context.Background(), func(msg Error) {
+		got = append(got, message{msg.Message, time.Now()})
+	})
+	_, err := db.ExecContext(ctx, `print 'step 1'; raiserror('step 2', 0, 1) with nowait;
+		waitfor delay '00:00:01'; print 'step 3'; raiserror('step 4', 10, 1) with nowait;
+		waitfor delay '00:00:01'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := time.Now()
+	if len(got) != 4 {
+		t.Fatalf("expected 4 messages, got %v", got)
+	}
+	for i, msg := range got {
+		if want := "step " + string(rune('1'+i)); msg.text != want {
+			t.Errorf("expected message %q, got %q", want, msg.text)
+		}
+	}
+	if done.Sub(got[1].at) < time.Second {
+		t.Errorf("expected the messages flushed with nowait to be handled before the batch completes, got them %v before", done.Sub(got[1].at))
+	}
+}
@@ -0,0 +1,82 @@
+package mssql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// moneyScale is the number of decimal places of MONEY and SMALLMONEY.
+const moneyScale = 4
+
+// Money is a MONEY value stored as the number of ten-thousandths of a unit,
+// which is how SQL Server stores it. Scanning a MONEY or SMALLMONEY column
+// into a Money and sending it back as a parameter never goes through a float,
+// so the value round-trips exactly.
+type Money int64
+
+// Scan implements sql.Scanner. MONEY columns are scanned from their exact
+// decimal text.
+func (m *Money) Scan(v interface{}) error {
+	switch vt := v.(type) {
+	case []byte:
+		return m.parse(string(vt))
+	case string:
+		return m.parse(vt)
+	case nil:
+		return errors.New("mssql: cannot scan NULL into Money")
+	default:
+		return fmt.Errorf("mssql: cannot convert %T to Money", v)
+	}
+}
+
+func (m *Money) parse(s string) error {
+	s = strings.TrimSpace(s)
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+	if len(frac) > moneyScale {
+		if strings.TrimRight(frac[moneyScale:], "0") != "" {
+			return fmt.Errorf("mssql: money value %q has more than %d decimal places", s, moneyScale)
+		}
+		frac = frac[:moneyScale]
+	}
+	if intPart == "" || intPart == "-" || intPart == "+" {
+		intPart += "0"
+	}
+	scaled, err := strconv.ParseInt(intPart+frac+strings.Repeat("0", moneyScale-len(frac)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("mssql: invalid money value %q: %v", s, err)
+	}
+	*m = Money(scaled)
+	return nil
+}
+
+// Value implements driver.Valuer and returns the exact decimal text.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// String returns the value with all four decimal places, e.g. "-12.3400".
+func (m Money) String() string {
+	return string(decodeMoneyValue(int64(m)))
+}
+
+// encodeMoney encodes a scaled value the way MONEY is sent on the wire:
+// the high 32 bits first, each half in little endian order.
+func encodeMoney(v int64) []byte {
+	buf := make([]byte, 8)
+	u := uint64(v)
+	buf[0] = byte(u >> 32)
+	buf[1] = byte(u >> 40)
+	buf[2] = byte(u >> 48)
+	buf[3] = byte(u >> 56)
+	buf[4] = byte(u)
+	buf[5] = byte(u >> 8)
+	buf[6] = byte(u >> 16)
+	buf[7] = byte(u >> 24)
+	return buf
+}
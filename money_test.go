@@ -0,0 +1,82 @@
+package mssql
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMoneyScan(t *testing.T) {
+	t.Parallel()
+	values := []struct {
+		in   interface{}
+		want Money
+	}{
+		{[]byte("1234.5600"), 12345600},
+		{"-0.0001", -1},
+		{"12", 120000},
+		{".5", 5000},
+		{"1.23450000", 12345},
+		{"922337203685477.5807", math.MaxInt64},
+		{"-922337203685477.5808", math.MinInt64},
+	}
+	for _, v := range values {
+		var m Money
+		if err := m.Scan(v.in); err != nil {
+			t.Errorf("Scan(%v) failed: %v", v.in, err)
+			continue
+		}
+		if m != v.want {
+			t.Errorf("Scan(%v): expected %d, got %d", v.in, v.want, m)
+		}
+	}
+
+	for _, in := range []interface{}{nil, "1.00001", "abc", 1.5} {
+		var m Money
+		if err := m.Scan(in); err == nil {
+			t.Errorf("expected an error for Scan(%v)", in)
+		}
+	}
+}
+
+func TestMoneyEncodeRoundTrip(t *testing.T) {
+	t.Parallel()
+	for _, v := range []Money{0, 1, -1, 12345600, math.MaxInt64, math.MinInt64} {
+		text := decodeMoney(encodeMoney(int64(v)))
+		var got Money
+		if err := got.Scan(text); err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("expected %d, got %d (%s)", v, got, text)
+		}
+		if v.String() != string(text) {
+			t.Errorf("expected String() %s, got %s", text, v.String())
+		}
+	}
+}
+
+func TestMoneyParam(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	for _, s := range []string{"922337203685477.5807", "-922337203685477.5808", "0.0001", "1234.5600"} {
+		var m Money
+		err := conn.QueryRow("select cast(@p1 as money)", s).Scan(&m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Money
+		var typ string
+		err = conn.QueryRow("select @p1, cast(sql_variant_property(@p1, 'BaseType') as nvarchar(20))", m).Scan(&back, &typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if back != m || back.String() != s {
+			t.Errorf("expected %s, got %s", s, back)
+		}
+		if typ != "money" {
+			t.Errorf("expected money parameter, got %s", typ)
+		}
+	}
+}
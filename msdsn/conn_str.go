@@ -71,6 +71,7 @@ const (
 	ConnectionTimeout      = "connection timeout"
 	HostNameInCertificate  = "hostnameincertificate"
 	KeepAlive              = "keepalive"
+	TCPNoDelay             = "tcpnodelay"
 	ServerSpn              = "serverspn"
 	WorkstationID          = "workstation id"
 	AppName                = "app name"
@@ -85,11 +86,24 @@ const (
 	MultiSubnetFailover    = "multisubnetfailover"
 	NoTraceID              = "notraceid"
 	GuidConversion         = "guid conversion"
+	Encoding               = "encoding"
+	Decimal                = "decimal"
+	Cursor                 = "cursor"
+	CursorFetchSize        = "cursor fetch size"
+	QueryTimeout           = "query timeout"
+
+	// DisableHostNameVerification skips matching the host name against the
+	// certificate of the server, its chain is still verified.
+	DisableHostNameVerification = "disablehostnameverification"
 )
 
 type EncodeParameters struct {
 	// Properly convert GUIDs, using correct byte endianness
 	GuidConversion bool
+	// Decode CHAR, VARCHAR and TEXT values as UTF-8 regardless of the column collation
+	UTF8 bool
+	// Return DECIMAL and NUMERIC values as strings instead of []byte
+	DecimalAsString bool
 }
 
 type Config struct {
@@ -126,7 +140,7 @@ type Config struct {
 
 	DialTimeout time.Duration // DialTimeout defaults to 15s per protocol. Set negative to disable.
 	ConnTimeout time.Duration // Use context for timeouts.
-	KeepAlive   time.Duration // Leave at default.
+	KeepAlive   time.Duration // KeepAlive defaults to 30s for TCP connections. Set negative to disable.
 	PacketSize  uint16
 
 	Parameters map[string]string
@@ -149,6 +163,17 @@ type Config struct {
 	NoTraceID bool
 	// Parameters related to type encoding
 	Encoding EncodeParameters
+	// When true, queries read their rows through a server cursor, fetching
+	// CursorFetchSize rows at a time instead of receiving all of them at once.
+	ServerCursor bool
+	// Number of rows fetched at a time by a server cursor, zero for the default.
+	CursorFetchSize int
+	// QueryTimeout is the deadline given to statements run with a context
+	// without a deadline, zero for no deadline.
+	QueryTimeout time.Duration
+	// When true, TCP connections leave Nagle's algorithm enabled instead
+	// of setting TCP_NODELAY.
+	DisableTCPNoDelay bool
 }
 
 func readDERFile(filename string) ([]byte, error) {
@@ -184,6 +209,10 @@ func readCertificate(certificate string) ([]byte, error) {
 
 // Build a tls.Config object from the supplied certificate.
 func SetupTLS(certificate string, insecureSkipVerify bool, hostInCertificate string, minTLSVersion string) (*tls.Config, error) {
+	return setupTLS(certificate, insecureSkipVerify, false, hostInCertificate, minTLSVersion)
+}
+
+func setupTLS(certificate string, insecureSkipVerify bool, skipHostName bool, hostInCertificate string, minTLSVersion string) (*tls.Config, error) {
 	config := tls.Config{
 		ServerName:         hostInCertificate,
 		InsecureSkipVerify: insecureSkipVerify,
@@ -197,13 +226,16 @@ func SetupTLS(certificate string, insecureSkipVerify bool, hostInCertificate str
 	}
 
 	if len(certificate) == 0 {
+		if skipHostName && !insecureSkipVerify {
+			skipHostNameVerification(&config)
+		}
 		return &config, nil
 	}
 	pem, err := readCertificate(certificate)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read certificate %q: %w", certificate, err)
 	}
-	if strings.Contains(config.ServerName, ":") && !insecureSkipVerify {
+	if strings.Contains(config.ServerName, ":") && !insecureSkipVerify && !skipHostName {
 		err := setupTLSCommonName(&config, pem)
 		if err != skipSetup {
 			return &config, err
@@ -212,9 +244,44 @@ func SetupTLS(certificate string, insecureSkipVerify bool, hostInCertificate str
 	certs := x509.NewCertPool()
 	certs.AppendCertsFromPEM(pem)
 	config.RootCAs = certs
+	if skipHostName && !insecureSkipVerify {
+		skipHostNameVerification(&config)
+	}
 	return &config, nil
 }
 
+// skipHostNameVerification makes config verify the certificate chain of the
+// server against its root certificates, or the system ones when it has
+// none, without matching the host name. crypto/tls can only skip both, so
+// the chain is verified by VerifyPeerCertificate.
+func skipHostNameVerification(config *tls.Config) {
+	roots := config.RootCAs
+	config.InsecureSkipVerify = true
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("the server did not send a certificate")
+		}
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: x509.NewCertPool(),
+		}
+		var leaf *x509.Certificate
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("invalid server certificate: %w", err)
+			}
+			if i == 0 {
+				leaf = cert
+			} else {
+				opts.Intermediates.AddCert(cert)
+			}
+		}
+		_, err := leaf.Verify(opts)
+		return err
+	}
+}
+
 // Parse and handle encryption parameters. If encryption is desired, it returns the corresponding tls.Config object.
 func parseTLS(params map[string]string, host string) (Encryption, *tls.Config, error) {
 	trustServerCert := false
@@ -248,13 +315,22 @@ func parseTLS(params map[string]string, host string) (Encryption, *tls.Config, e
 			return encryption, nil, fmt.Errorf(f, trust, err.Error())
 		}
 	}
+	skipHostName := false
+	if v, ok := params[DisableHostNameVerification]; ok {
+		var err error
+		skipHostName, err = strconv.ParseBool(v)
+		if err != nil {
+			f := "invalid disable host name verification '%s': %s"
+			return encryption, nil, fmt.Errorf(f, v, err.Error())
+		}
+	}
 	certificate := params[Certificate]
 	if encryption != EncryptionDisabled {
 		tlsMin := params[TLSMin]
 		if encrypt == "strict" {
 			trustServerCert = false
 		}
-		tlsConfig, err := SetupTLS(certificate, trustServerCert, host, tlsMin)
+		tlsConfig, err := setupTLS(certificate, trustServerCert, skipHostName, host, tlsMin)
 		if err != nil {
 			return encryption, nil, fmt.Errorf("failed to setup TLS: %w", err)
 		}
@@ -385,6 +461,19 @@ func Parse(dsn string) (Config, error) {
 			return p, fmt.Errorf(f, keepAlive, err.Error())
 		}
 		p.KeepAlive = time.Duration(timeout) * time.Second
+		if timeout == 0 {
+			// disabled
+			p.KeepAlive = -1
+		}
+	}
+
+	if noDelay, ok := params[TCPNoDelay]; ok {
+		v, err := strconv.ParseBool(noDelay)
+		if err != nil {
+			f := "invalid tcpnodelay '%s': %s"
+			return p, fmt.Errorf(f, noDelay, err.Error())
+		}
+		p.DisableTCPNoDelay = !v
 	}
 
 	serverSPN, ok := params[ServerSpn]
@@ -547,6 +636,54 @@ func Parse(dsn string) (Config, error) {
 		p.Encoding.GuidConversion = false
 	}
 
+	if encoding, ok := params[Encoding]; ok {
+		switch strings.ToLower(encoding) {
+		case "utf8", "utf-8":
+			p.Encoding.UTF8 = true
+		default:
+			return p, fmt.Errorf("invalid encoding '%s', only utf8 is supported", encoding)
+		}
+	}
+
+	if decimal, ok := params[Decimal]; ok {
+		switch strings.ToLower(decimal) {
+		case "string":
+			p.Encoding.DecimalAsString = true
+		case "bytes":
+			p.Encoding.DecimalAsString = false
+		default:
+			return p, fmt.Errorf("invalid decimal '%s', expected string or bytes", decimal)
+		}
+	}
+
+	if cursor, ok := params[Cursor]; ok {
+		switch strings.ToLower(cursor) {
+		case "server":
+			p.ServerCursor = true
+		case "default":
+			p.ServerCursor = false
+		default:
+			return p, fmt.Errorf("invalid cursor '%s', expected server or default", cursor)
+		}
+	}
+
+	if fetchSize, ok := params[CursorFetchSize]; ok {
+		size, err := strconv.Atoi(fetchSize)
+		if err != nil || size <= 0 {
+			return p, fmt.Errorf("invalid cursor fetch size '%s', expected a positive number of rows", fetchSize)
+		}
+		p.CursorFetchSize = size
+	}
+
+	if strquerytimeout, ok := params[QueryTimeout]; ok {
+		timeout, err := strconv.ParseUint(strquerytimeout, 10, 64)
+		if err != nil {
+			f := "invalid query timeout '%v': %v"
+			return p, fmt.Errorf(f, strquerytimeout, err.Error())
+		}
+		p.QueryTimeout = time.Duration(timeout) * time.Second
+	}
+
 	return p, nil
 }
 
@@ -597,6 +734,9 @@ func (p Config) URL() *url.URL {
 		res.Path = p.Instance
 	}
 	q.Add(DialTimeout, strconv.FormatFloat(float64(p.DialTimeout.Seconds()), 'f', 0, 64))
+	if p.DisableTCPNoDelay {
+		q.Add(TCPNoDelay, "false")
+	}
 
 	switch p.Encryption {
 	case EncryptionDisabled:
@@ -612,6 +752,26 @@ func (p Config) URL() *url.URL {
 		q.Add(GuidConversion, strconv.FormatBool(p.Encoding.GuidConversion))
 	}
 
+	if p.Encoding.UTF8 {
+		q.Add(Encoding, "utf8")
+	}
+
+	if p.Encoding.DecimalAsString {
+		q.Add(Decimal, "string")
+	}
+
+	if p.ServerCursor {
+		q.Add(Cursor, "server")
+	}
+
+	if p.CursorFetchSize > 0 {
+		q.Add(CursorFetchSize, strconv.Itoa(p.CursorFetchSize))
+	}
+
+	if p.QueryTimeout > 0 {
+		q.Add(QueryTimeout, strconv.FormatFloat(p.QueryTimeout.Seconds(), 'f', 0, 64))
+	}
+
 	if len(q) > 0 {
 		res.RawQuery = q.Encode()
 	}
@@ -630,6 +790,7 @@ var adoSynonyms = map[string]string{
 	"uid":                       UserID,
 	"initial catalog":           Database,
 	"column encryption setting": "columnencryption",
+	"command timeout":           QueryTimeout,
 }
 
 func splitConnectionString(dsn string) (res map[string]string) {
@@ -1,9 +1,17 @@
 package msdsn
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/hex"
+	"encoding/pem"
 	"io"
+	"math/big"
+	"net"
 	"os"
 	"reflect"
 	"testing"
@@ -20,12 +28,20 @@ func TestInvalidConnectionString(t *testing.T) {
 		"connection timeout=invalid",
 		"dial timeout=invalid",
 		"keepalive=invalid",
+		"tcpnodelay=invalid",
 		"encrypt=invalid",
 		"trustservercertificate=invalid",
+		"disablehostnameverification=invalid",
 		"failoverport=invalid",
 		"applicationintent=ReadOnly",
 		"disableretry=invalid",
 		"multisubnetfailover=invalid",
+		"encoding=latin1",
+		"decimal=float",
+		"cursor=keyset",
+		"cursor fetch size=0",
+		"cursor fetch size=many",
+		"query timeout=-1",
 
 		// ODBC mode
 		"odbc:password={",
@@ -93,6 +109,10 @@ func TestValidConnectionString(t *testing.T) {
 		{"connection timeout=3;dial timeout=4;keepalive=5", func(p Config) bool {
 			return p.ConnTimeout == 3*time.Second && p.DialTimeout == 4*time.Second && p.KeepAlive == 5*time.Second
 		}},
+		{"keepalive=0", func(p Config) bool { return p.KeepAlive < 0 }},
+		{"", func(p Config) bool { return p.KeepAlive == 30*time.Second && !p.DisableTCPNoDelay }},
+		{"tcpnodelay=false", func(p Config) bool { return p.DisableTCPNoDelay }},
+		{"tcpnodelay=true", func(p Config) bool { return !p.DisableTCPNoDelay }},
 		{"log=63", func(p Config) bool { return p.LogFlags == 63 && p.Port == 0 }},
 		{"log=63;port=1000", func(p Config) bool { return p.LogFlags == 63 && p.Port == 1000 }},
 		{"log=64", func(p Config) bool { return p.LogFlags == 64 }},
@@ -195,6 +215,24 @@ func TestValidConnectionString(t *testing.T) {
 		{"sqlserver://somehost?encrypt=true&tlsmin=1.1&columnencryption=1&guid+conversion=true", func(p Config) bool {
 			return p.Host == "somehost" && p.Encryption == EncryptionRequired && p.TLSConfig.MinVersion == tls.VersionTLS11 && p.ColumnEncryption && p.Encoding.GuidConversion
 		}},
+		{"server=somehost;encoding=UTF8", func(p Config) bool {
+			return p.Host == "somehost" && p.Encoding.UTF8 && !p.Encoding.GuidConversion
+		}},
+		{"server=somehost", func(p Config) bool {
+			return p.Host == "somehost" && !p.Encoding.UTF8 && !p.Encoding.DecimalAsString
+		}},
+		{"server=somehost;decimal=string", func(p Config) bool {
+			return p.Host == "somehost" && p.Encoding.DecimalAsString
+		}},
+		{"server=somehost;cursor=server;cursor fetch size=500", func(p Config) bool {
+			return p.Host == "somehost" && p.ServerCursor && p.CursorFetchSize == 500
+		}},
+		{"server=somehost;query timeout=30", func(p Config) bool {
+			return p.Host == "somehost" && p.QueryTimeout == 30*time.Second
+		}},
+		{"server=somehost;command timeout=5", func(p Config) bool {
+			return p.QueryTimeout == 5*time.Second
+		}},
 	}
 	for _, ts := range connStrings {
 		p, err := Parse(ts.connStr)
@@ -219,7 +257,7 @@ func TestSplitConnectionStringURL(t *testing.T) {
 }
 
 func TestConnParseRoundTripFixed(t *testing.T) {
-	connStr := "sqlserver://sa:sa@localhost/sqlexpress?database=master&log=127&disableretry=true&dial+timeout=30"
+	connStr := "sqlserver://sa:sa@localhost/sqlexpress?database=master&log=127&disableretry=true&dial+timeout=30&encoding=utf8&decimal=string&cursor=server&cursor+fetch+size=500&query+timeout=30&tcpnodelay=false"
 	params, err := Parse(connStr)
 	if err != nil {
 		t.Fatal("Test URL is not valid", err)
@@ -328,3 +366,79 @@ func TestReadCertificate(t *testing.T) {
 	assert.NotNil(t, err, "Expected error while reading certificate, found nil")
 	assert.Nil(t, cert, "Expected certificate to be nil, found %v", cert)
 }
+
+// testCertificate returns a self-signed certificate for host and its PEM file.
+func testCertificate(t *testing.T, host string) (tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.CreateTemp(t.TempDir(), "*.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err = pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, f.Name()
+}
+
+func handshake(config *tls.Config, serverCert tls.Certificate) error {
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		_ = tls.Server(server, &tls.Config{Certificates: []tls.Certificate{serverCert}}).Handshake()
+	}()
+	return tls.Client(client, config).Handshake()
+}
+
+func TestDisableHostNameVerification(t *testing.T) {
+	serverCert, certFile := testCertificate(t, "sql.example.com")
+	otherCert, _ := testCertificate(t, "sql.example.com")
+	dsn := "sqlserver://127.0.0.1?encrypt=true&certificate=" + certFile
+
+	cfg, err := Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = handshake(cfg.TLSConfig, serverCert); err == nil {
+		t.Error("expected the host name of the certificate not to match 127.0.0.1")
+	}
+
+	cfg, err = Parse(dsn + "&DisableHostNameVerification=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = handshake(cfg.TLSConfig, serverCert); err != nil {
+		t.Errorf("expected the trusted certificate to be accepted, got %v", err)
+	}
+	if err = handshake(cfg.TLSConfig, otherCert); err == nil {
+		t.Error("expected a certificate of an unknown authority to be rejected")
+	}
+
+	// without a certificate file the chain is verified with the system roots
+	cfg, err = Parse("sqlserver://127.0.0.1?encrypt=true&DisableHostNameVerification=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = handshake(cfg.TLSConfig, serverCert); err == nil {
+		t.Error("expected a self-signed certificate not to be trusted by the system roots")
+	}
+}
@@ -2,6 +2,7 @@ package mssql
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"database/sql/driver"
 	"encoding/binary"
@@ -13,11 +14,13 @@ import (
 	"net"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
 	"github.com/golang-sql/sqlexp"
 	"github.com/microsoft/go-mssqldb/aecmk"
+	"github.com/microsoft/go-mssqldb/integratedauth"
 	"github.com/microsoft/go-mssqldb/internal/querytext"
 	"github.com/microsoft/go-mssqldb/msdsn"
 )
@@ -37,11 +40,7 @@ func init() {
 	sql.Register("mssql", driverInstance)
 	sql.Register("sqlserver", driverInstanceNoProcess)
 	createDialer = func(p *msdsn.Config) Dialer {
-		ka := p.KeepAlive
-		if ka == 0 {
-			ka = 30 * time.Second
-		}
-		return netDialer{&net.Dialer{KeepAlive: ka}}
+		return netDialer{&net.Dialer{KeepAlive: keepAlivePeriod(p)}}
 	}
 	msdsn.ProtocolDialers["tcp"] = *tcpDialerInstance
 	msdsn.ProtocolDialers["admin"] = *tcpDialerInstance
@@ -195,19 +194,153 @@ type Connector struct {
 	// SessionInitSQL is empty.
 	SessionInitSQL string
 
+	// SessionSettings sets session options, such as ANSI_NULLS,
+	// QUOTED_IDENTIFIER or ARITHABORT, on every connection of the pool.
+	// The keys are the option names and the values their settings:
+	//
+	//    connector.SessionSettings = map[string]string{
+	//        "ARITHABORT":        "ON",
+	//        "QUOTED_IDENTIFIER": "ON",
+	//        "LOCK_TIMEOUT":      "10000",
+	//    }
+	//
+	// A SET statement is run for each option, by name order, after the
+	// login and after each session reset, before SessionInitSQL. Names
+	// and values are words of letters, digits and underscores. It is not
+	// set by default.
+	SessionSettings map[string]string
+
+	// MaxRows runs SET ROWCOUNT with it on every connection of the pool,
+	// after the login and after each session reset, so that a query stops
+	// after returning that many rows, a safety net against runaway queries
+	// in development. The limit also applies to the rows changed by UPDATE
+	// and DELETE statements. It is not set by default.
+	MaxRows int
+
+	// QueryGovernorCostLimit runs SET QUERY_GOVERNOR_COST_LIMIT with it on
+	// every connection of the pool, as MaxRows does, so that the server
+	// refuses to run the queries whose estimated cost is higher, with the
+	// error 8649. It is not set by default.
+	QueryGovernorCostLimit int
+
 	// Dialer sets a custom dialer for all network operations, except DNS resolution unless
 	// the dialer implements the HostDialer.
 	//
 	// If Dialer is not set, normal net dialers are used.
 	Dialer Dialer
 
+	// RetryPolicy, when set, retries statements that fail with a transient
+	// error, see IsTransientError. It is not set by default.
+	RetryPolicy *RetryPolicy
+
+	// ResumeRetry, when set, makes Connect wait for a database which is
+	// not currently available, such as a paused Azure SQL serverless
+	// database, by logging in again until it resumed. Unlike RetryPolicy it
+	// only applies to establishing connections. It is not set by default.
+	ResumeRetry *ResumeRetry
+
+	// PacketSize, when non-zero, overrides the packet size from the
+	// connection string. It must be between 512 and 32767 bytes.
+	// The size is only requested, the server may negotiate a smaller one,
+	// e.g. encrypted connections are limited to 16383 bytes.
+	PacketSize int
+
+	// PacketTracer, when set, is called with the header of each TDS packet
+	// sent to or received from the server, which helps to debug protocol
+	// issues. Payloads are not passed. It is called from the goroutine
+	// doing the network IO and must not block.
+	PacketTracer func(header PacketHeader, direction Direction)
+
+	// PreparedStatementCaching, when set, makes each connection prepare the
+	// statements it runs with parameters on the server and reuse the handle
+	// with sp_execute when the same statement text is run again with the
+	// same parameter types, even from another *sql.Stmt. The handles are
+	// released when the connection is reset, which database/sql does when
+	// it takes the connection from the pool again, so the statements should
+	// be run on a *sql.Conn. Statements with output parameters, query
+	// notifications or Always Encrypted parameters are not prepared.
+	// See PreparedStatementStats for the cache hits.
+	PreparedStatementCaching bool
+
+	// IntegratedAuthProvider, when set, creates the integrated authenticator
+	// of the connections instead of the provider named by the authenticator
+	// connection string parameter. For instance krb5.NewProvider chooses the
+	// Kerberos credentials of each connection.
+	IntegratedAuthProvider integratedauth.Provider
+
+	// TLSConfig, when set, is used for the TLS handshake of the connections
+	// instead of the configuration built from the certificate, hostname in
+	// certificate, TrustServerCertificate and tlsmin connection string
+	// parameters, for instance to verify the server with root certificates
+	// held in memory or to present a client certificate. It is cloned for
+	// each connection, so it should not be modified once the connector is
+	// in use; replace it with a new configuration to rotate certificates.
+	// The server name defaults to the host of the connection string.
+	// Whether the connection is encrypted is still set by the encrypt
+	// connection string parameter.
+	TLSConfig *tls.Config
+
+	// SendTraceActivity makes every request carry a trace activity header,
+	// with the activity id attached to its context by ActivityIDContext or
+	// else the activity id of the connection, and a sequence number. SQL
+	// Server can then correlate its extended events with the requests.
+	SendTraceActivity bool
+
+	// TrimFixedChar removes the trailing spaces of the char and nchar
+	// values read from result sets, which SQL Server pads to the length of
+	// the column. Variable length types such as varchar are returned as
+	// stored. It is not set by default.
+	TrimFixedChar bool
+
+	// SlowQueryThreshold is the duration above which a statement is
+	// reported to SlowQueryCallback, measured from sending the statement to
+	// reading the end of its response. Zero reports every statement.
+	SlowQueryThreshold time.Duration
+
+	// SlowQueryCallback, when set, is called with the SQL, the arguments and
+	// the duration of each statement taking longer than SlowQueryThreshold.
+	// It is called from the goroutine running the statement, or reading
+	// and closing its rows, and must not block. It is not set by default.
+	SlowQueryCallback func(sql string, args []driver.NamedValue, dur time.Duration)
+
+	// RedactSlowQueryArgs passes the arguments to SlowQueryCallback with a
+	// nil value, for the values which may hold personal data to stay out of
+	// the logs. The names and ordinals are kept.
+	RedactSlowQueryArgs bool
+
 	keyProviders aecmk.ColumnEncryptionKeyProviderMap
+
+	// route is the server a read-only intent connection was last routed
+	// to, new read-only connections connect to it directly.
+	routeMu sync.Mutex
+	route   *connRoute
+
+	prepMu    sync.Mutex
+	prepStats PreparedStatementStats
+}
+
+type connRoute struct {
+	server string
+	port   uint16
 }
 
 type Dialer interface {
 	DialContext(ctx context.Context, network string, addr string) (net.Conn, error)
 }
 
+// DialerFunc adapts a function to the Dialer interface, to set a custom
+// dial function, such as one going through a proxy, as Connector.Dialer:
+//
+//	connector.Dialer = mssql.DialerFunc(proxy.DialContext)
+//
+// The connection returned is wrapped by the TLS handshake as needed.
+type DialerFunc func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+// DialContext calls f(ctx, network, addr).
+func (f DialerFunc) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
 // HostDialer should be used if the dialer is proxying requests to a different network
 // and DNS should be resolved in that other network
 type HostDialer interface {
@@ -215,6 +348,28 @@ type HostDialer interface {
 	HostName() string
 }
 
+func (c *Connector) getIntegratedAuthenticator(p msdsn.Config) (integratedauth.IntegratedAuthenticator, error) {
+	if c != nil && c.IntegratedAuthProvider != nil {
+		return c.IntegratedAuthProvider.GetIntegratedAuthenticator(p)
+	}
+	return integratedauth.GetIntegratedAuthenticator(p)
+}
+
+// applyTLSConfig replaces the TLS configuration of the connection string
+// with a copy of the TLSConfig of the connector, when set.
+func (c *Connector) applyTLSConfig(p *msdsn.Config) {
+	if c == nil || c.TLSConfig == nil {
+		return
+	}
+	p.TLSConfig = c.TLSConfig.Clone()
+	if p.TLSConfig.ServerName == "" {
+		p.TLSConfig.ServerName = p.Host
+	} else {
+		// keep the server name when routed to another server
+		p.HostInCertificateProvided = true
+	}
+}
+
 func (c *Connector) getDialer(p *msdsn.Config) Dialer {
 	if c != nil && c.Dialer != nil {
 		return c.Dialer
@@ -222,6 +377,35 @@ func (c *Connector) getDialer(p *msdsn.Config) Dialer {
 	return createDialer(p)
 }
 
+func (c *Connector) getRoute() *connRoute {
+	c.routeMu.Lock()
+	defer c.routeMu.Unlock()
+	return c.route
+}
+
+func (c *Connector) setRoute(route *connRoute) {
+	c.routeMu.Lock()
+	c.route = route
+	c.routeMu.Unlock()
+}
+
+func (c *Connector) getPacketTracer() func(PacketHeader, Direction) {
+	if c == nil {
+		return nil
+	}
+	return c.PacketTracer
+}
+
+func (c *Connector) getPacketSize(p *msdsn.Config) (uint16, error) {
+	if c == nil || c.PacketSize == 0 {
+		return p.PacketSize, nil
+	}
+	if c.PacketSize < 512 || c.PacketSize > 32767 {
+		return 0, fmt.Errorf("mssql: invalid packet size %d, it must be between 512 and 32767", c.PacketSize)
+	}
+	return uint16(c.PacketSize), nil
+}
+
 // RegisterCekProvider associates the given provider with the named key store. If an entry of the given name already exists, that entry is overwritten
 func (c *Connector) RegisterCekProvider(name string, provider aecmk.ColumnEncryptionKeyProvider) {
 	c.keyProviders[name] = aecmk.NewCekProvider(provider)
@@ -232,17 +416,34 @@ type Conn struct {
 	sess           *tdsSession
 	transactionCtx context.Context
 	resetSession   bool
+	// application name sent when logging in
+	appName string
+	// handles of the statements prepared when the connector caches them
+	prepared *prepCache
 
 	processQueryText bool
 	connectionGood   bool
 
 	outs outputs
+	// openRows are the rows whose response is still being read from the
+	// connection, see bufferOpenRows
+	openRows *Rows
+	// bulk is the bulk copy whose rows are being sent, it is canceled
+	// when the transaction is rolled back
+	bulk *Bulk
+	// attention cancels the statement being run, see Attention
+	attnMu    sync.Mutex
+	attention context.CancelFunc
 }
 
 type outputs struct {
 	params       map[string]interface{}
 	returnStatus *ReturnStatus
 	msgq         *sqlexp.ReturnMessage
+	prepared     *preparedHandle
+	cursor       *serverCursor
+	// messages sends the INFO and ERROR tokens to the reader, for ExecRaw
+	messages bool
 }
 
 // IsValid satisfies the driver.Validator interface.
@@ -250,6 +451,23 @@ func (c *Conn) IsValid() bool {
 	return c.connectionGood
 }
 
+// ResetConnection makes the next request on the connection reset the session
+// state on the server, the same way sp_reset_connection does. Temp tables,
+// SET options and open cursors are discarded. Pooled connections are already
+// reset by database/sql when they are reused; use this through sql.Conn.Raw to
+// clear the state of a connection that is kept out of the pool. The statements
+// prepared by the connection are discarded too.
+//
+// Unlike the reset of a pooled connection, it does not run the statements of
+// the connector, SessionSettings, MaxRows, QueryGovernorCostLimit and
+// SessionInitSQL, again: the session gets the defaults of the database.
+func (c *Conn) ResetConnection() {
+	c.resetSession = true
+	if c.prepared != nil {
+		c.prepared.reset()
+	}
+}
+
 // checkBadConn marks the connection as bad based on the characteristics
 // of the supplied error. Bad connections will be dropped from the connection
 // pool rather than reused.
@@ -292,6 +510,32 @@ func (c *Conn) clearOuts() {
 	c.outs = outputs{}
 }
 
+// bufferOpenRows reads the rest of the response of the open rows into
+// memory before another request is sent on the connection. The driver does
+// not support MARS (Multiple Active Result Sets), which interleaves the
+// responses of several requests, so the rows would otherwise read the
+// response of the new request. The rows are then read from memory.
+func (c *Conn) bufferOpenRows() {
+	if c.openRows == nil {
+		return
+	}
+	// the response is not read further while a streamed XML value is
+	// unread, its rest is discarded
+	closeXML(&c.openRows.xml)
+	reader := c.openRows.reader
+	c.openRows = nil
+	var tokens []tokenStruct
+	for tok := range reader.tokChan {
+		tokens = append(tokens, tok)
+	}
+	buffered := make(chan tokenStruct, len(tokens))
+	for _, tok := range tokens {
+		buffered <- tok
+	}
+	close(buffered)
+	reader.tokChan = buffered
+}
+
 func (c *Conn) simpleProcessResp(ctx context.Context) error {
 	reader := startReading(c.sess, ctx, c.outs)
 	c.clearOuts()
@@ -315,10 +559,8 @@ func (c *Conn) Commit() error {
 }
 
 func (c *Conn) sendCommitRequest() error {
-	headers := []headerStruct{
-		{hdrtype: dataStmHdrTransDescr,
-			data: transDescrHdr{c.sess.tranid, 1}.pack()},
-	}
+	c.bufferOpenRows()
+	headers := c.requestHeaders(c.transactionCtx, c.sess.tranid)
 	reset := c.resetSession
 	c.resetSession = false
 	if err := sendCommitXact(c.sess.buf, headers, "", 0, 0, "", reset); err != nil {
@@ -333,6 +575,11 @@ func (c *Conn) Rollback() error {
 	if !c.connectionGood {
 		return driver.ErrBadConn
 	}
+	if c.bulk != nil {
+		if err := c.bulk.Cancel(); err != nil {
+			return err
+		}
+	}
 	if err := c.sendRollbackRequest(); err != nil {
 		return c.checkBadConn(c.transactionCtx, err, true)
 	}
@@ -340,10 +587,8 @@ func (c *Conn) Rollback() error {
 }
 
 func (c *Conn) sendRollbackRequest() error {
-	headers := []headerStruct{
-		{hdrtype: dataStmHdrTransDescr,
-			data: transDescrHdr{c.sess.tranid, 1}.pack()},
-	}
+	c.bufferOpenRows()
+	headers := c.requestHeaders(c.transactionCtx, c.sess.tranid)
 	reset := c.resetSession
 	c.resetSession = false
 	if err := sendRollbackXact(c.sess.buf, headers, "", 0, 0, "", reset); err != nil {
@@ -374,11 +619,9 @@ func (c *Conn) begin(ctx context.Context, tdsIsolation isoLevel) (tx driver.Tx,
 }
 
 func (c *Conn) sendBeginRequest(ctx context.Context, tdsIsolation isoLevel) error {
+	c.bufferOpenRows()
 	c.transactionCtx = ctx
-	headers := []headerStruct{
-		{hdrtype: dataStmHdrTransDescr,
-			data: transDescrHdr{0, 1}.pack()},
-	}
+	headers := c.requestHeaders(ctx, 0)
 	reset := c.resetSession
 	c.resetSession = false
 	if err := sendBeginXact(c.sess.buf, headers, tdsIsolation, "", reset); err != nil {
@@ -411,7 +654,7 @@ func (d *Driver) open(ctx context.Context, dsn string) (*Conn, error) {
 func (d *Driver) connect(ctx context.Context, c *Connector, params msdsn.Config) (*Conn, error) {
 	sess, err := connect(ctx, c, d.logger, params)
 	if err != nil {
-		// main server failed, try fail-over partner
+		// main server, or the replica it routed to, failed, try fail-over partner
 		if params.FailOverPartner == "" {
 			return nil, err
 		}
@@ -432,9 +675,13 @@ func (d *Driver) connect(ctx context.Context, c *Connector, params msdsn.Config)
 		connector:        c,
 		sess:             sess,
 		transactionCtx:   context.Background(),
+		appName:          params.AppName,
 		processQueryText: d.processQueryText,
 		connectionGood:   true,
 	}
+	if c.PreparedStatementCaching {
+		conn.prepared = &prepCache{}
+	}
 
 	return conn, nil
 }
@@ -496,10 +743,8 @@ func (s *Stmt) NumInput() int {
 }
 
 func (s *Stmt) sendQuery(ctx context.Context, args []namedValue) (err error) {
-	headers := []headerStruct{
-		{hdrtype: dataStmHdrTransDescr,
-			data: transDescrHdr{s.c.sess.tranid, 1}.pack()},
-	}
+	s.c.bufferOpenRows()
+	headers := s.c.requestHeaders(ctx, s.c.sess.tranid)
 
 	if s.notifSub != nil {
 		headers = append(headers,
@@ -527,10 +772,24 @@ func (s *Stmt) sendQuery(ctx context.Context, args []namedValue) (err error) {
 		}
 	}
 
+	query := s.query
+	isProc := isProc(s.query)
+	// a SET inside sp_executesql only lasts until the statement completes,
+	// so the session isolation level is restored even if the query fails
+	setIsolation, err := isolationStatement(ctx)
+	if err != nil {
+		return err
+	}
+	if len(setIsolation) > 0 {
+		if isProc {
+			return errors.New("mssql: IsolationContext cannot be used with a stored procedure call")
+		}
+		query = setIsolation + ";\n" + query
+	}
+
 	reset := conn.resetSession
 	conn.resetSession = false
-	isProc := isProc(s.query)
-	if len(args) == 0 && !isProc {
+	if len(args) == 0 && !isProc && len(setIsolation) == 0 && conn.outs.cursor == nil {
 		if err = sendSqlBatch72(conn.sess.buf, s.query, headers, reset); err != nil {
 			conn.sess.LogF(ctx, msdsn.LogErrors, "Failed to send SqlBatch with %v", err)
 			conn.connectionGood = false
@@ -551,8 +810,13 @@ func (s *Stmt) sendQuery(ctx context.Context, args []namedValue) (err error) {
 			if err != nil {
 				return
 			}
-			params[0] = makeStrParam(s.query)
+			params[0] = makeStrParam(query)
 			params[1] = makeStrParam(strings.Join(decls, ","))
+			if conn.outs.cursor != nil {
+				proc, params = conn.outs.cursor.openCall(query, strings.Join(decls, ","), params, len(args) > 0)
+			} else if s.canPrepare(args) {
+				proc, params = s.preparedCall(query, strings.Join(decls, ","), params)
+			}
 		}
 		if err = sendRpc(conn.sess.buf, headers, proc, 0, params, reset, conn.sess.encoding); err != nil {
 			conn.sess.LogF(ctx, msdsn.LogErrors, "Failed to send Rpc with %v", err)
@@ -563,6 +827,47 @@ func (s *Stmt) sendQuery(ctx context.Context, args []namedValue) (err error) {
 	return
 }
 
+// canPrepare tells whether the statement is run with a cached handle
+// when the connector caches prepared statements.
+func (s *Stmt) canPrepare(args []namedValue) bool {
+	if s.c.prepared == nil || s.notifSub != nil {
+		return false
+	}
+	for _, arg := range args {
+		if arg.encrypt != nil || isOutputValue(arg.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// preparedCall turns the sp_executesql call with params into a sp_execute
+// call of the cached handle of the statement, or into a sp_prepexec call
+// which caches the handle it returns.
+func (s *Stmt) preparedCall(query, decls string, params []param) (procId, []param) {
+	key := decls + "\x00" + query
+	if handle, ok := s.c.prepared.get(key); ok {
+		s.c.connector.countPrepared(true)
+		var handleParam param
+		handleParam.ti.TypeId = typeIntN
+		handleParam.ti.Size = 4
+		handleParam.buffer = make([]byte, 4)
+		binary.LittleEndian.PutUint32(handleParam.buffer, uint32(handle))
+		return sp_Execute, append([]param{handleParam}, params[2:]...)
+	}
+	if s.c.prepared.full() {
+		return sp_ExecuteSql, params
+	}
+	s.c.connector.countPrepared(false)
+	s.c.outs.prepared = &preparedHandle{cache: s.c.prepared, key: key}
+	// the handle is returned in the NULL output parameter
+	var handleParam param
+	handleParam.Flags = fByRevValue
+	handleParam.ti.TypeId = typeIntN
+	handleParam.ti.Size = 4
+	return sp_PrepExec, append([]param{handleParam, params[1], params[0]}, params[2:]...)
+}
+
 // Builtin commands are not stored procs, but rather T-SQL commands
 // those commands can be invoke without extra options
 var builtinCommands = []string{"RECONFIGURE", "SHUTDOWN", "CHECKPOINT"}
@@ -634,6 +939,17 @@ func isProc(s string) bool {
 	return true
 }
 
+// hasStreamedArgs reports whether any argument is read while the query is
+// sent, which makes it unsafe to retry the query.
+func hasStreamedArgs(args []namedValue) bool {
+	for _, a := range args {
+		if isStreamedValue(a.Value) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Stmt) makeRPCParams(args []namedValue, isProc bool) ([]param, []string, error) {
 	var err error
 	var offset int
@@ -661,6 +977,9 @@ func (s *Stmt) makeRPCParams(args []namedValue, isProc bool) ([]param, []string,
 		}
 		tiDecl := params[i+offset].ti
 		if val.encrypt != nil {
+			if params[i+offset].stream != nil {
+				return nil, nil, fmt.Errorf("mssql: streamed parameter %s cannot be encrypted", name)
+			}
 			// Encrypted parameters have a few requirements:
 			// 1. Copy original typeinfo to a block after the data
 			// 2. Set the parameter type to varbinary(max)
@@ -718,20 +1037,35 @@ func (s *Stmt) queryContext(ctx context.Context, args []namedValue) (rows driver
 	if !s.c.connectionGood {
 		return nil, driver.ErrBadConn
 	}
+	plainArgs := args
 	if s.doEncryption() && len(args) > 0 {
 		args, err = s.encryptArgs(ctx, args)
 	}
 	if err != nil {
 		return nil, err
 	}
+	if s.useServerCursor(ctx, args) {
+		s.c.outs.cursor = newServerCursor(s.c.connector.params)
+	}
+	slow := s.c.startSlowQuery(s.query, plainArgs)
 	if err = s.sendQuery(ctx, args); err != nil {
-		return nil, s.c.checkBadConn(ctx, err, true)
+		return nil, s.c.checkBadConn(ctx, err, !hasStreamedArgs(args))
+	}
+	rows, err = s.processQueryResponse(ctx)
+	// the rows report the statement once its response is read
+	switch r := rows.(type) {
+	case *Rows:
+		r.slow = slow
+	case *Rowsq:
+		r.slow = slow
+	default:
+		slow.done()
 	}
-	return s.processQueryResponse(ctx)
+	return
 }
 
 func (s *Stmt) processQueryResponse(ctx context.Context) (res driver.Rows, err error) {
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := s.c.withQueryTimeout(ctx)
 	reader := startReading(s.c.sess, ctx, s.c.outs)
 	s.c.clearOuts()
 	// For apps using a message queue, return right away and let Rowsq do all the work
@@ -777,8 +1111,14 @@ loop:
 			return nil, s.c.checkBadConn(ctx, err, false)
 		}
 	}
-	res = &Rows{stmt: s, reader: reader, cols: cols, cancel: cancel}
-	return
+	// the hidden columns of cursors and browse mode queries are not returned
+	rows := &Rows{stmt: s, reader: reader, cols: visibleColumns(cols), cancel: cancel}
+	s.c.openRows = rows
+	if cur := reader.outs.cursor; cur != nil {
+		cur.columns = cols
+		rows.cursor = cur
+	}
+	return rows, nil
 }
 
 func (s *Stmt) Exec(args []driver.Value) (driver.Result, error) {
@@ -791,14 +1131,19 @@ func (s *Stmt) exec(ctx context.Context, args []namedValue) (res driver.Result,
 	if !s.c.connectionGood {
 		return nil, driver.ErrBadConn
 	}
+	ctx, cancel := s.c.withQueryTimeout(ctx)
+	defer cancel()
+	plainArgs := args
 	if s.doEncryption() && len(args) > 0 {
 		args, err = s.encryptArgs(ctx, args)
 	}
 	if err != nil {
 		return nil, err
 	}
+	slow := s.c.startSlowQuery(s.query, plainArgs)
+	defer slow.done()
 	if err = s.sendQuery(ctx, args); err != nil {
-		return nil, s.c.checkBadConn(ctx, err, true)
+		return nil, s.c.checkBadConn(ctx, err, !hasStreamedArgs(args))
 	}
 	if res, err = s.processExec(ctx); err != nil {
 		return nil, err
@@ -813,7 +1158,24 @@ func (s *Stmt) processExec(ctx context.Context) (res driver.Result, err error) {
 	if err != nil {
 		return nil, s.c.checkBadConn(ctx, err, false)
 	}
-	return &Result{s.c, reader.rowCount}, nil
+	return &Result{s.c, reader.rowCount, reader.rowCounts}, nil
+}
+
+// withQueryTimeout returns a cancellable copy of ctx which, when ctx has no
+// deadline, expires after the query timeout of the connection string. The
+// statement is then cancelled on the server with an attention, as it is
+// when the copy is cancelled by Attention.
+func (c *Conn) withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	var cancel context.CancelFunc
+	if _, ok := ctx.Deadline(); !ok && c.connector != nil && c.connector.params.QueryTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.connector.params.QueryTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	c.attnMu.Lock()
+	c.attention = cancel
+	c.attnMu.Unlock()
+	return ctx, cancel
 }
 
 // Rows represents the non-experimental data/sql model for Query and QueryContext
@@ -823,12 +1185,32 @@ type Rows struct {
 	reader   *tokenProcessor
 	nextCols []columnStruct
 	cancel   func()
-}
-
-func (rc *Rows) Close() error {
+	// xml streams the last value of the current row, see XMLStreamContext
+	xml *XMLReader
+	// cursor fetches the rows when they are read through a server cursor
+	cursor *serverCursor
+	// slow reports the statement to Connector.SlowQueryCallback
+	slow *slowQuery
+	// row holds the values of the current row read by NextRow
+	row []driver.Value
+}
+
+func (rc *Rows) Close() (err error) {
+	defer rc.slow.done()
 	// need to add a test which returns lots of rows
 	// and check closing after reading only few rows
+	if rc.stmt.c.openRows == rc {
+		rc.stmt.c.openRows = nil
+	}
+	closeXML(&rc.xml)
 	rc.cancel()
+	if rc.cursor != nil {
+		defer func() {
+			if cerr := rc.cursor.close(rc.stmt.c); err == nil {
+				err = cerr
+			}
+		}()
+	}
 
 	for {
 		tok, err := rc.reader.nextToken()
@@ -865,10 +1247,21 @@ func (rc *Rows) Next(dest []driver.Value) error {
 	if rc.nextCols != nil {
 		return io.EOF
 	}
+	closeXML(&rc.xml)
 	for {
 		tok, err := rc.reader.nextToken()
 		if err == nil {
 			if tok == nil {
+				if rc.cursor != nil {
+					more, err := rc.cursor.fetch(rc)
+					if err != nil {
+						return rc.stmt.c.checkBadConn(rc.reader.ctx, err, false)
+					}
+					if more {
+						continue
+					}
+				}
+				rc.slow.done()
 				return io.EOF
 			} else {
 				switch tokdata := tok.(type) {
@@ -880,7 +1273,14 @@ func (rc *Rows) Next(dest []driver.Value) error {
 					for i := range dest {
 						dest[i] = tokdata[i]
 					}
+					trimFixedChar(rc.stmt.c, rc.cols, dest)
+					rc.xml = streamedXML(tokdata)
+					if rc.cursor != nil {
+						rc.cursor.pageRows++
+					}
 					return nil
+				case columnSources:
+					setColumnSources(rc.cols, tokdata)
 				case doneStruct:
 					if tokdata.isError() {
 						return rc.stmt.c.checkBadConn(rc.reader.ctx, tokdata.getError(), false)
@@ -902,8 +1302,19 @@ func (rc *Rows) HasNextResultSet() bool {
 	return rc.nextCols != nil
 }
 
+// NextResultSet advances to the next result set, skipping the rows of the
+// current one that were not read. Once it returns io.EOF the whole response
+// has been processed and sql.Out parameters and the ReturnStatus are set.
 func (rc *Rows) NextResultSet() error {
-	rc.cols = rc.nextCols
+	for rc.nextCols == nil {
+		if err := rc.Next(nil); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+	}
+	rc.cols = visibleColumns(rc.nextCols)
 	rc.nextCols = nil
 	if rc.cols == nil {
 		return io.EOF
@@ -915,7 +1326,7 @@ func (rc *Rows) NextResultSet() error {
 // the value type that can be used to scan types into. For example, the database
 // column type "bigint" this should return "reflect.TypeOf(int64(0))".
 func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
-	return makeGoLangScanType(r.cols[index].originalTypeInfo())
+	return makeGoLangScanTypeWithEncoding(r.cols[index].originalTypeInfo(), r.stmt.c.sess.encoding)
 }
 
 // RowsColumnTypeDatabaseTypeName may be implemented by Rows. It should return the
@@ -923,10 +1334,21 @@ func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
 // Examples of returned types: "VARCHAR", "NVARCHAR", "VARCHAR2", "CHAR", "TEXT",
 // "DECIMAL", "SMALLINT", "INT", "BIGINT", "BOOL", "[]BIGINT", "JSONB", "XML",
 // "TIMESTAMP".
+//
+// User defined CLR types are returned with their schema, as "DBO.POINT".
+// Alias types are returned by their base type, a column of a type created
+// with CREATE TYPE dbo.SSN FROM CHAR(9) is "CHAR", see ColumnTypeUserType.
 func (r *Rows) ColumnTypeDatabaseTypeName(index int) string {
 	return makeGoLangTypeName(r.cols[index].originalTypeInfo())
 }
 
+// ColumnTypeUserType returns the user type of the column the server sends in
+// the column metadata. For a column of an alias type it is the user_type_id
+// of the alias in sys.types, which gives its name.
+func (r *Rows) ColumnTypeUserType(index int) uint32 {
+	return r.cols[index].UserType
+}
+
 // RowsColumnTypeLength may be implemented by Rows. It should return the length
 // of the column type if the column is a variable length type. If the column is
 // not a variable length type ok should return false.
@@ -964,6 +1386,13 @@ func (r *Rows) ColumnTypeNullable(index int) (nullable, ok bool) {
 	return
 }
 
+// ColumnTypeCollation returns the collation of a character column, which
+// determines the code page of CHAR, VARCHAR and TEXT data. ok is false for
+// other column types.
+func (r *Rows) ColumnTypeCollation(index int) (collation ColumnCollation, ok bool) {
+	return makeColumnCollation(r.cols[index].originalTypeInfo())
+}
+
 func makeStrParam(val string) (res param) {
 	res.ti.TypeId = typeNVarChar
 	res.buffer = str2ucs2(val)
@@ -992,8 +1421,38 @@ func (s *Stmt) makeParam(val driver.Value) (res param, err error) {
 		if valuer.Valid {
 			return s.makeParam(valuer.Int32)
 		}
+	// the other sql.Nullxxx types are handled here too rather than as a
+	// driver.Valuer, their NULL values are typed in the second switch
+	case sql.NullInt64:
+		if valuer.Valid {
+			return s.makeParam(valuer.Int64)
+		}
+	case sql.NullFloat64:
+		if valuer.Valid {
+			return s.makeParam(valuer.Float64)
+		}
+	case sql.NullString:
+		if valuer.Valid {
+			return s.makeParam(valuer.String)
+		}
+	case sql.NullBool:
+		if valuer.Valid {
+			return s.makeParam(valuer.Bool)
+		}
+	case sql.NullTime:
+		if valuer.Valid {
+			return s.makeParam(valuer.Time)
+		}
+	case *sql.NullByte, *sql.NullInt16, *sql.NullInt32, *sql.NullInt64, *sql.NullFloat64, *sql.NullString, *sql.NullBool, *sql.NullTime:
+		// a NULL would lose its type through driver.Valuer
+		return s.makeParam(derefNull(valuer))
 	case UniqueIdentifier:
 	case NullUniqueIdentifier:
+	case Money:
+	case SmallDateTime:
+	case RowVersion:
+	case JSON:
+	case TimeOfDay:
 	default:
 		break
 	case driver.Valuer:
@@ -1022,6 +1481,28 @@ func (s *Stmt) makeParam(val driver.Value) (res param, err error) {
 		} else {
 			res.buffer = []byte{}
 		}
+	case Money:
+		res.ti.TypeId = typeMoneyN
+		res.ti.Size = 8
+		res.buffer = encodeMoney(int64(val))
+	case SmallDateTime:
+		res.ti.TypeId = typeDateTimeN
+		res.buffer = encodeDateTim4(time.Time(val))
+		res.ti.Size = len(res.buffer)
+	case RowVersion:
+		res.ti.TypeId = typeBigBinary
+		res.ti.Size = len(val)
+		res.buffer = append([]byte(nil), val[:]...)
+	case JSON:
+		return makeJSONParam(val)
+	case TimeOfDay:
+		if err = val.check(); err != nil {
+			return
+		}
+		res.ti.TypeId = typeTimeN
+		res.ti.Scale = val.Scale
+		res.buffer = val.encode()
+		res.ti.Size = len(res.buffer)
 	case int:
 		res.ti.TypeId = typeIntN
 		// Rather than guess if the caller intends to pass a 32bit int from a 64bit app based on the
@@ -1148,24 +1629,112 @@ func (s *Stmt) makeParam(val driver.Value) (res param, err error) {
 	return
 }
 
+// derefNull returns the sql.Nullxxx value pointed to by val, a nil pointer
+// being a NULL of the type.
+func derefNull(val driver.Value) driver.Value {
+	switch v := val.(type) {
+	case *sql.NullByte:
+		if v == nil {
+			return sql.NullByte{}
+		}
+		return *v
+	case *sql.NullInt16:
+		if v == nil {
+			return sql.NullInt16{}
+		}
+		return *v
+	case *sql.NullInt32:
+		if v == nil {
+			return sql.NullInt32{}
+		}
+		return *v
+	case *sql.NullInt64:
+		if v == nil {
+			return sql.NullInt64{}
+		}
+		return *v
+	case *sql.NullFloat64:
+		if v == nil {
+			return sql.NullFloat64{}
+		}
+		return *v
+	case *sql.NullString:
+		if v == nil {
+			return sql.NullString{}
+		}
+		return *v
+	case *sql.NullBool:
+		if v == nil {
+			return sql.NullBool{}
+		}
+		return *v
+	case *sql.NullTime:
+		if v == nil {
+			return sql.NullTime{}
+		}
+		return *v
+	}
+	return val
+}
+
 type Result struct {
 	c            *Conn
 	rowsAffected int64
+	rowCounts    []int64
 }
 
 func (r *Result) RowsAffected() (int64, error) {
 	return r.rowsAffected, nil
 }
 
+// RowCounts returns the number of rows affected by each statement of the
+// batch which reported a count, in the order they ran. Statements run with
+// SET NOCOUNT ON report none. RowsAffected is the sum of the counts.
+func (r *Result) RowCounts() []int64 {
+	return r.rowCounts
+}
+
+// StatementRowCounts is implemented by the results of the driver. As
+// database/sql does not expose the driver result, run the batch through
+// sql.Conn.Raw to get it:
+//
+//	err = conn.Raw(func(driverConn interface{}) error {
+//		stmt, err := driverConn.(*mssql.Conn).PrepareContext(ctx, "update t set a = 1; delete from u")
+//		if err != nil {
+//			return err
+//		}
+//		defer stmt.Close()
+//		res, err := stmt.(driver.StmtExecContext).ExecContext(ctx, nil)
+//		if err != nil {
+//			return err
+//		}
+//		counts = res.(mssql.StatementRowCounts).RowCounts()
+//		return nil
+//	})
+type StatementRowCounts interface {
+	driver.Result
+	RowCounts() []int64
+}
+
+var _ StatementRowCounts = &Result{}
+
 var _ driver.Pinger = &Conn{}
 
 // Ping is used to check if the remote server is available and satisfies the Pinger interface.
+//
+// It runs a trivial batch and reads its response to the end. An error
+// matching driver.ErrBadConn is returned when the connection was lost, so
+// that database/sql discards it. The batch is cancelled when ctx is done.
 func (c *Conn) Ping(ctx context.Context) error {
 	if !c.connectionGood {
 		return driver.ErrBadConn
 	}
 	stmt := &Stmt{c, `select 1;`, 0, nil, true}
 	_, err := stmt.ExecContext(ctx, nil)
+	if err != nil && !c.connectionGood && !errors.Is(err, driver.ErrBadConn) {
+		// pinging again is always safe, unlike retrying a statement
+		return newRetryableError(err)
+	}
 	return err
 }
 
@@ -1231,7 +1800,12 @@ func (s *Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driv
 	for i, nv := range args {
 		list[i] = namedValueFromDriverNamedValue(nv)
 	}
-	return s.queryContext(ctx, list)
+	var rows driver.Rows
+	err := s.retry(ctx, list, func() (err error) {
+		rows, err = s.queryContext(ctx, list)
+		return err
+	})
+	return rows, err
 }
 
 func (s *Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
@@ -1244,7 +1818,12 @@ func (s *Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (drive
 	for i, nv := range args {
 		list[i] = namedValueFromDriverNamedValue(nv)
 	}
-	return s.exec(ctx, list)
+	var res driver.Result
+	err := s.retry(ctx, list, func() (err error) {
+		res, err = s.exec(ctx, list)
+		return err
+	})
+	return res, err
 }
 
 func namedValueFromDriverNamedValue(v driver.NamedValue) namedValue {
@@ -1260,9 +1839,18 @@ type Rowsq struct {
 	cancel      func()
 	requestDone bool
 	inResultSet bool
+	// nextCols holds the columns of the result set read by Next, such as
+	// the rows of a COMPUTE clause
+	nextCols []columnStruct
+	// xml streams the last value of the current row, see XMLStreamContext
+	xml *XMLReader
+	// slow reports the statement to Connector.SlowQueryCallback
+	slow *slowQuery
 }
 
 func (rc *Rowsq) Close() error {
+	defer rc.slow.done()
+	closeXML(&rc.xml)
 	rc.cancel()
 
 	for {
@@ -1319,6 +1907,10 @@ func (rc *Rowsq) Next(dest []driver.Value) error {
 	if !rc.stmt.c.connectionGood {
 		return driver.ErrBadConn
 	}
+	if rc.nextCols != nil {
+		return io.EOF
+	}
+	closeXML(&rc.xml)
 	for {
 		tok, err := rc.reader.nextToken()
 		rc.reader.sess.LogF(rc.reader.ctx, msdsn.LogDebug, "Next() token type:%v", reflect.TypeOf(tok))
@@ -1335,7 +1927,13 @@ func (rc *Rowsq) Next(dest []driver.Value) error {
 					for i := range dest {
 						dest[i] = tokdata[i]
 					}
+					trimFixedChar(rc.stmt.c, rc.cols, dest)
+					rc.xml = streamedXML(tokdata)
 					return nil
+				case []columnStruct:
+					rc.nextCols = tokdata
+					rc.inResultSet = false
+					return io.EOF
 				case doneStruct:
 					if tokdata.Status&doneMore == 0 {
 						rc.requestDone = true
@@ -1380,6 +1978,13 @@ func (rc *Rowsq) NextResultSet() error {
 	if rc.requestDone {
 		return io.EOF
 	}
+	closeXML(&rc.xml)
+	if rc.nextCols != nil {
+		rc.cols = rc.nextCols
+		rc.nextCols = nil
+		rc.inResultSet = true
+		return nil
+	}
 scan:
 	for {
 		tok, err := rc.reader.nextToken()
@@ -1435,7 +2040,7 @@ scan:
 // the value type that can be used to scan types into. For example, the database
 // column type "bigint" this should return "reflect.TypeOf(int64(0))".
 func (r *Rowsq) ColumnTypeScanType(index int) reflect.Type {
-	return makeGoLangScanType(r.cols[index].originalTypeInfo())
+	return makeGoLangScanTypeWithEncoding(r.cols[index].originalTypeInfo(), r.stmt.c.sess.encoding)
 }
 
 // RowsColumnTypeDatabaseTypeName may be implemented by Rows. It should return the
@@ -1443,10 +2048,18 @@ func (r *Rowsq) ColumnTypeScanType(index int) reflect.Type {
 // Examples of returned types: "VARCHAR", "NVARCHAR", "VARCHAR2", "CHAR", "TEXT",
 // "DECIMAL", "SMALLINT", "INT", "BIGINT", "BOOL", "[]BIGINT", "JSONB", "XML",
 // "TIMESTAMP".
+//
+// See Rows.ColumnTypeDatabaseTypeName for user defined and alias types.
 func (r *Rowsq) ColumnTypeDatabaseTypeName(index int) string {
 	return makeGoLangTypeName(r.cols[index].originalTypeInfo())
 }
 
+// ColumnTypeUserType returns the user type of the column, see
+// Rows.ColumnTypeUserType.
+func (r *Rowsq) ColumnTypeUserType(index int) uint32 {
+	return r.cols[index].UserType
+}
+
 // RowsColumnTypeLength may be implemented by Rows. It should return the length
 // of the column type if the column is a variable length type. If the column is
 // not a variable length type ok should return false.
@@ -1483,3 +2096,10 @@ func (r *Rowsq) ColumnTypeNullable(index int) (nullable, ok bool) {
 	ok = true
 	return
 }
+
+// ColumnTypeCollation returns the collation of a character column, which
+// determines the code page of CHAR, VARCHAR and TEXT data. ok is false for
+// other column types.
+func (r *Rowsq) ColumnTypeCollation(index int) (collation ColumnCollation, ok bool) {
+	return makeColumnCollation(r.cols[index].originalTypeInfo())
+}
@@ -6,6 +6,8 @@ import (
 	"context"
 	"database/sql/driver"
 	"errors"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
 )
 
 var _ driver.Connector = &Connector{}
@@ -15,13 +17,36 @@ func (c *Conn) ResetSession(ctx context.Context) error {
 	if !c.connectionGood {
 		return driver.ErrBadConn
 	}
+	if c.sess != nil && c.sess.conn != nil {
+		// a connection dropped while it was idle would only fail once the
+		// statement was sent, when it can no longer be retried
+		if err := connCheck(c.sess.conn); err != nil {
+			c.sess.LogF(ctx, msdsn.LogErrors, "Pooled connection is closed: %v", err)
+			c.connectionGood = false
+			return driver.ErrBadConn
+		}
+	}
+	if c.connector != nil && appNameFromContext(ctx, c.connector.params.AppName) != c.appName {
+		// the application name can only be set when logging in
+		return driver.ErrBadConn
+	}
 	c.resetSession = true
+	if c.prepared != nil {
+		c.prepared.reset()
+	}
 
-	if c.connector == nil || len(c.connector.SessionInitSQL) == 0 {
+	if c.connector == nil {
+		return nil
+	}
+	query, err := c.connector.sessionSQL()
+	if err != nil {
+		return err
+	}
+	if len(query) == 0 {
 		return nil
 	}
 
-	s, err := c.prepareContext(ctx, c.connector.SessionInitSQL)
+	s, err := c.prepareContext(ctx, query)
 	if err != nil {
 		return driver.ErrBadConn
 	}
@@ -35,7 +60,14 @@ func (c *Conn) ResetSession(ctx context.Context) error {
 
 // Connect to the server and return a TDS connection.
 func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
-	conn, err := c.driver.connect(ctx, c, c.params)
+	params := c.params
+	params.AppName = appNameFromContext(ctx, params.AppName)
+	conn, err := c.connectResume(ctx, params)
+	if err != nil && c.fedAuthRequired && isTokenExpiredError(err) {
+		// the token expired before the server checked it, log in once more
+		// with a new token
+		conn, err = c.driver.connect(context.WithValue(ctx, tokenRefreshContextKey{}, true), c, params)
+	}
 	if err == nil {
 		err = conn.ResetSession(ctx)
 	}
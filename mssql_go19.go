@@ -4,17 +4,22 @@
 package mssql
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-sql/sqlexp"
 
 	// "github.com/cockroachdb/apd"
 	"github.com/golang-sql/civil"
+	"github.com/microsoft/go-mssqldb/internal/decimal"
 )
 
 // Type alias provided for compatibility.
@@ -41,12 +46,63 @@ type VarCharMax string
 // NChar is used to encode a string parameter as NChar instead of a sized NVarChar
 type NChar string
 
+// VarBinary is used to encode a byte slice parameter as VarBinary(n), n being
+// the length of the value, instead of choosing the type by the length.
+// Values longer than 8000 bytes are rejected, use VarBinaryMax for them.
+type VarBinary []byte
+
+// VarBinaryMax is used to encode a byte slice parameter as VarBinary(max)
+// instead of choosing the type by the length.
+type VarBinaryMax []byte
+
 // DateTime1 encodes parameters to original DateTime SQL types.
 type DateTime1 time.Time
 
 // DateTimeOffset encodes parameters to DateTimeOffset, preserving the UTC offset.
 type DateTimeOffset time.Time
 
+// Date encodes parameters to the Date SQL type, the calendar date of the
+// time in its own location, the time of day being ignored. Scanning a date
+// column into a Date gives midnight UTC of the stored date, whatever the
+// location of the process.
+type Date time.Time
+
+// Scan implements sql.Scanner.
+func (d *Date) Scan(v interface{}) error {
+	switch vt := v.(type) {
+	case time.Time:
+		y, m, day := vt.Date()
+		*d = Date(time.Date(y, m, day, 0, 0, 0, 0, time.UTC))
+		return nil
+	case nil:
+		return errors.New("mssql: cannot scan NULL into Date")
+	default:
+		return fmt.Errorf("mssql: cannot convert %T to Date", v)
+	}
+}
+
+// Decimal encodes Value as a decimal(Precision, Scale) parameter instead of
+// inferring the type from Value. Value may be an integer, a float or a string
+// holding the decimal text; values that do not fit the precision or have more
+// decimal places than Scale are rejected before the query is sent.
+type Decimal struct {
+	Value     interface{}
+	Precision uint8
+	Scale     uint8
+}
+
+// Blob streams the content of Reader as a varbinary(max) parameter without
+// buffering it in memory. Length is the number of bytes Reader yields, or
+// negative if unknown. A plain io.Reader parameter is sent as a Blob of
+// unknown length.
+//
+// The reader is consumed exactly once, so a query with a Blob parameter is
+// never retried on another connection. A nil Reader is sent as NULL.
+type Blob struct {
+	Reader io.Reader
+	Length int64
+}
+
 func convertInputParameter(val interface{}) (interface{}, error) {
 	switch v := val.(type) {
 	case int, int16, int32, int64, int8:
@@ -61,10 +117,24 @@ func convertInputParameter(val interface{}) (interface{}, error) {
 		return val, nil
 	case NChar:
 		return val, nil
+	case CollatedString:
+		return val, nil
+	case VariantValue:
+		return val, nil
+	case VarBinary:
+		return val, nil
+	case VarBinaryMax:
+		return val, nil
 	case DateTime1:
 		return val, nil
 	case DateTimeOffset:
 		return val, nil
+	case Date:
+		return val, nil
+	case Blob:
+		return val, nil
+	case Decimal:
+		return val, nil
 	case civil.Date:
 		return val, nil
 	case civil.DateTime:
@@ -77,6 +147,8 @@ func convertInputParameter(val interface{}) (interface{}, error) {
 		return val, nil
 	case driver.Valuer:
 		return val, nil
+	case io.Reader:
+		return val, nil
 	default:
 		return driver.DefaultParameterConverter.ConvertValue(v)
 	}
@@ -131,7 +203,7 @@ func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
 		*v = 0 // By default the return value should be zero.
 		c.outs.returnStatus = v
 		return driver.ErrRemoveArgument
-	case TVP:
+	case TVP, TVPRaw:
 		return nil
 	case *sqlexp.ReturnMessage:
 		sqlexp.ReturnMessageInit(v)
@@ -144,6 +216,33 @@ func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
 	}
 }
 
+// SetSessionContext sets key to value in the session context of the
+// connection with sp_set_session_context, triggers and row-level security
+// predicates can read it with SESSION_CONTEXT(). A key set with readOnly
+// can not be changed again in the same session.
+//
+// The session context belongs to the physical connection, so set it on a
+// *sql.Conn and run the statements which depend on it on the same *sql.Conn:
+//
+//	conn, err := db.Conn(ctx)
+//	...
+//	err = conn.Raw(func(driverConn interface{}) error {
+//		return driverConn.(*mssql.Conn).SetSessionContext(ctx, "user_id", 42, false)
+//	})
+func (c *Conn) SetSessionContext(ctx context.Context, key string, value interface{}, readOnly bool) error {
+	value, err := convertInputParameter(value)
+	if err != nil {
+		return err
+	}
+	stmt := &Stmt{c: c, query: "exec sp_set_session_context @key = @p1, @value = @p2, @read_only = @p3", paramCount: 3, skipEncryption: true}
+	_, err = stmt.ExecContext(ctx, []driver.NamedValue{
+		{Ordinal: 1, Value: key},
+		{Ordinal: 2, Value: value},
+		{Ordinal: 3, Value: readOnly},
+	})
+	return err
+}
+
 func (s *Stmt) makeParamExtra(val driver.Value) (res param, err error) {
 	switch val := val.(type) {
 	case VarChar:
@@ -162,6 +261,24 @@ func (s *Stmt) makeParamExtra(val driver.Value) (res param, err error) {
 		res.ti.TypeId = typeNChar
 		res.buffer = str2ucs2(string(val))
 		res.ti.Size = len(res.buffer)
+	case CollatedString:
+		return makeCollatedStringParam(val)
+	case VariantValue:
+		return s.makeVariantParam(val)
+	case VarBinary:
+		if len(val) > 8000 {
+			return res, fmt.Errorf("mssql: VarBinary parameter of %d bytes is longer than 8000 bytes, use VarBinaryMax", len(val))
+		}
+		res.ti.TypeId = typeBigVarBin
+		res.buffer = val
+		res.ti.Size = len(res.buffer)
+		if res.ti.Size == 0 {
+			res.ti.Size = 1 // zero forces varbinary(max)
+		}
+	case VarBinaryMax:
+		res.ti.TypeId = typeBigVarBin
+		res.buffer = val
+		res.ti.Size = 0 // currently zero forces varbinary(max)
 	case DateTime1:
 		t := time.Time(val)
 		res.ti.TypeId = typeDateTimeN
@@ -172,6 +289,10 @@ func (s *Stmt) makeParamExtra(val driver.Value) (res param, err error) {
 		res.ti.Scale = 7
 		res.buffer = encodeDateTimeOffset(time.Time(val), int(res.ti.Scale))
 		res.ti.Size = len(res.buffer)
+	case Date:
+		res.ti.TypeId = typeDateN
+		res.buffer = encodeDate(time.Time(val))
+		res.ti.Size = len(res.buffer)
 	case civil.Date:
 		res.ti.TypeId = typeDateN
 		res.buffer = encodeDate(val.In(time.UTC))
@@ -186,9 +307,36 @@ func (s *Stmt) makeParamExtra(val driver.Value) (res param, err error) {
 		res.ti.Scale = 7
 		res.buffer = encodeTime(val.Hour, val.Minute, val.Second, val.Nanosecond, int(res.ti.Scale))
 		res.ti.Size = len(res.buffer)
+	case Decimal:
+		res.ti.TypeId = typeDecimalN
+		res.ti.Prec = val.Precision
+		res.ti.Scale = val.Scale
+		res.buffer, err = val.encode()
+		res.ti.Size = len(res.buffer)
+	case Blob:
+		res.ti.TypeId = typeBigVarBin
+		res.ti.Size = 0 // zero forces varbinary(max)
+		if val.Reader != nil {
+			res.stream = func(w io.Writer) error {
+				return writePLPReader(w, val.Reader, val.Length)
+			}
+		}
+	case io.Reader:
+		res.ti.TypeId = typeBigVarBin
+		res.ti.Size = 0
+		res.stream = func(w io.Writer) error {
+			return writePLPReader(w, val, -1)
+		}
 	case sql.Out:
 		res, err = s.makeParam(val.Dest)
 		res.Flags = fByRevValue
+		switch res.ti.TypeId {
+		case typeNVarChar, typeBigVarChar, typeBigVarBin:
+			// the length of the input value says nothing about the length
+			// of the output value, declare the parameter as max so that
+			// the server does not truncate it
+			res.ti.Size = 0
+		}
 	case TVP:
 		err = val.check()
 		if err != nil {
@@ -206,7 +354,41 @@ func (s *Stmt) makeParamExtra(val driver.Value) (res param, err error) {
 			err = errCalTypes
 			return
 		}
-		res.buffer, err = val.encode(schema, name, columnStr, tvpFieldIndexes, s.c.sess.encoding)
+		if val.isStream() {
+			// only the header is kept, rows are encoded as they are sent
+			res.buffer, err = val.encodeHeader(schema, name, columnStr, tvpFieldIndexes, s.c.sess.encoding)
+			if err != nil {
+				return
+			}
+			header := res.buffer
+			res.stream = func(w io.Writer) error {
+				return val.writeStream(w, header, columnStr, tvpFieldIndexes)
+			}
+		} else {
+			res.buffer, err = val.encode(schema, name, columnStr, tvpFieldIndexes, s.c.sess.encoding)
+			if err != nil {
+				return
+			}
+		}
+		res.ti.Size = len(res.buffer)
+	case TVPRaw:
+		if err = val.check(); err != nil {
+			return
+		}
+		schema, name, errGetName := getSchemeAndName(val.TypeName)
+		if errGetName != nil {
+			err = errGetName
+			return
+		}
+		res.ti.UdtInfo.TypeName = name
+		res.ti.UdtInfo.SchemaName = schema
+		res.ti.TypeId = typeTvp
+		columnStr, errCalTypes := val.columnTypes()
+		if errCalTypes != nil {
+			err = errCalTypes
+			return
+		}
+		res.buffer, err = val.encode(schema, name, columnStr, s.c.sess.encoding)
 		if err != nil {
 			return
 		}
@@ -222,6 +404,57 @@ func scanIntoOut(name string, fromServer, scanInto interface{}) error {
 	return convertAssign(scanInto, fromServer)
 }
 
+func (d Decimal) encode() ([]byte, error) {
+	if d.Precision < 1 || d.Precision > 38 {
+		return nil, fmt.Errorf("mssql: invalid decimal precision %d, must be between 1 and 38", d.Precision)
+	}
+	if d.Scale > d.Precision {
+		return nil, fmt.Errorf("mssql: decimal scale %d is larger than precision %d", d.Scale, d.Precision)
+	}
+	var dec decimal.Decimal
+	var err error
+	switch v := d.Value.(type) {
+	case int:
+		dec, err = decimal.StringToDecimalScale(strconv.FormatInt(int64(v), 10), d.Scale)
+	case int8:
+		dec, err = decimal.StringToDecimalScale(strconv.FormatInt(int64(v), 10), d.Scale)
+	case int16:
+		dec, err = decimal.StringToDecimalScale(strconv.FormatInt(int64(v), 10), d.Scale)
+	case int32:
+		dec, err = decimal.StringToDecimalScale(strconv.FormatInt(int64(v), 10), d.Scale)
+	case int64:
+		dec, err = decimal.StringToDecimalScale(strconv.FormatInt(v, 10), d.Scale)
+	case float32:
+		dec, err = decimal.Float64ToDecimalScale(float64(v), d.Scale)
+	case float64:
+		dec, err = decimal.Float64ToDecimalScale(v, d.Scale)
+	case string:
+		dec, err = decimal.StringToDecimalScale(v, d.Scale)
+	default:
+		return nil, fmt.Errorf("mssql: unsupported value for Decimal: %T", d.Value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mssql: invalid value for decimal(%d, %d): %v", d.Precision, d.Scale, err)
+	}
+	unscaled := dec.BigInt()
+	if digits := strings.TrimPrefix(unscaled.String(), "-"); len(digits) > int(d.Precision) {
+		return nil, fmt.Errorf("mssql: value %v does not fit in decimal(%d, %d)", d.Value, d.Precision, d.Scale)
+	}
+	return encodeDecimal(dec, d.Precision)
+}
+
+// isStreamedValue reports whether val is consumed while being sent,
+// in which case the query must not be retried.
+func isStreamedValue(val driver.Value) bool {
+	switch v := val.(type) {
+	case Blob, io.Reader:
+		return true
+	case TVP:
+		return v.isStream()
+	}
+	return false
+}
+
 func isOutputValue(val driver.Value) bool {
 	_, out := val.(sql.Out)
 	return out
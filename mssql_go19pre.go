@@ -18,3 +18,7 @@ func scanIntoOut(name string, fromServer, scanInto interface{}) error {
 func isOutputValue(val driver.Value) bool {
 	return false
 }
+
+func isStreamedValue(val driver.Value) bool {
+	return false
+}
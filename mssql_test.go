@@ -4,10 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"reflect"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
 
 	"github.com/microsoft/go-mssqldb/msdsn"
@@ -176,6 +182,181 @@ func TestCheckBadConn(t *testing.T) {
 	t.Fatalf("checkBadConn did not panic as expected when passed driverErrBadConn")
 }
 
+func TestIsolationStatement(t *testing.T) {
+	stmt, err := isolationStatement(context.Background())
+	if stmt != "" || err != nil {
+		t.Fatal("expected no statement without an isolation context")
+	}
+	stmt, err = isolationStatement(IsolationContext(context.Background(), sql.LevelSnapshot))
+	if stmt != "SET TRANSACTION ISOLATION LEVEL SNAPSHOT" || err != nil {
+		t.Fatalf("invalid statement returned: %s %v", stmt, err)
+	}
+	stmt, err = isolationStatement(IsolationContext(context.Background(), sql.LevelDefault))
+	if stmt != "" || err != nil {
+		t.Fatalf("expected no statement for LevelDefault, got %s %v", stmt, err)
+	}
+	_, err = isolationStatement(IsolationContext(context.Background(), sql.LevelLinearizable))
+	if err == nil {
+		t.Fatal("must fail but it didn't")
+	}
+}
+
+func TestIsolationContext(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	const levelQuery = "select transaction_isolation_level from sys.dm_exec_sessions where session_id = @@SPID"
+	var before, during, after int
+	if err = conn.QueryRowContext(ctx, levelQuery).Scan(&before); err != nil {
+		t.Fatal(err)
+	}
+	isoCtx := IsolationContext(ctx, sql.LevelSerializable)
+	if err = conn.QueryRowContext(isoCtx, levelQuery).Scan(&during); err != nil {
+		t.Fatal(err)
+	}
+	if during != int(isolationSerializable) {
+		t.Errorf("expected serializable isolation level during the query, got %d", during)
+	}
+	if _, err = conn.ExecContext(isoCtx, "select 1/0"); err == nil {
+		t.Error("expected the query to fail")
+	}
+	if err = conn.QueryRowContext(ctx, levelQuery).Scan(&after); err != nil {
+		t.Fatal(err)
+	}
+	if after != before {
+		t.Errorf("expected the session isolation level %d to be restored, got %d", before, after)
+	}
+}
+
+func TestResetSessionAppName(t *testing.T) {
+	conn := &Conn{
+		connector:      newConnector(msdsn.Config{AppName: "default"}, nil),
+		appName:        "default",
+		connectionGood: true,
+	}
+	ctx := context.Background()
+	if err := conn.ResetSession(ctx); err != nil {
+		t.Errorf("expected the connection to be reused, got %v", err)
+	}
+	if err := conn.ResetSession(AppNameContext(ctx, "default")); err != nil {
+		t.Errorf("expected the connection to be reused for the same name, got %v", err)
+	}
+	if err := conn.ResetSession(AppNameContext(ctx, "tenant-a")); err != driver.ErrBadConn {
+		t.Errorf("expected ErrBadConn for another name, got %v", err)
+	}
+	conn.appName = "tenant-a"
+	if err := conn.ResetSession(ctx); err != driver.ErrBadConn {
+		t.Errorf("expected ErrBadConn for the default name, got %v", err)
+	}
+}
+
+func TestAppNameContext(t *testing.T) {
+	checkConnStr(t)
+	connector, err := NewConnector(makeConnStr(t).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := sql.OpenDB(connector)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+
+	appName := func(ctx context.Context) string {
+		conn, err := pool.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		var name string
+		if err = conn.QueryRowContext(ctx, "select app_name()").Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+	ctx := context.Background()
+	def := appName(ctx)
+	if name := appName(AppNameContext(ctx, "tenant-a")); name != "tenant-a" {
+		t.Errorf("expected tenant-a, got %s", name)
+	}
+	if name := appName(AppNameContext(ctx, "tenant-b")); name != "tenant-b" {
+		t.Errorf("expected tenant-b, got %s", name)
+	}
+	if name := appName(ctx); name != def {
+		t.Errorf("expected %s, got %s", def, name)
+	}
+}
+
+// failingDialer records the addresses dialed and fails every dial.
+type failingDialer struct {
+	addrs []string
+}
+
+func (d *failingDialer) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	d.addrs = append(d.addrs, addr)
+	return nil, fmt.Errorf("dial %s: connection refused", addr)
+}
+
+func (d *failingDialer) HostName() string {
+	return ""
+}
+
+func TestReadOnlyFailoverOrder(t *testing.T) {
+	p, err := msdsn.Parse("sqlserver://primary:1433?failoverpartner=partner&failoverport=1434&database=reports&applicationintent=ReadOnly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer := &failingDialer{}
+	c := newConnector(p, nil)
+	c.Dialer = dialer
+	c.setRoute(&connRoute{server: "replica", port: 11000})
+
+	drv := &Driver{logger: optionalLogger{}}
+	if _, err = drv.connect(context.Background(), c, p); err == nil {
+		t.Fatal("expected the connection to fail")
+	}
+	want := []string{"replica:11000", "primary:1433", "partner:1434"}
+	if !reflect.DeepEqual(dialer.addrs, want) {
+		t.Errorf("expected the dial order %v, got %v", want, dialer.addrs)
+	}
+	if c.getRoute() != nil {
+		t.Error("expected the replica to be forgotten")
+	}
+}
+
+func TestConnectorPacketSize(t *testing.T) {
+	p := msdsn.Config{PacketSize: 4096}
+	values := []struct {
+		size    int
+		want    uint16
+		wantErr bool
+	}{
+		{0, 4096, false},
+		{512, 512, false},
+		{16383, 16383, false},
+		{32767, 32767, false},
+		{511, 0, true},
+		{32768, 0, true},
+		{-1, 0, true},
+	}
+	for _, v := range values {
+		c := &Connector{params: p, PacketSize: v.size}
+		got, err := c.getPacketSize(&p)
+		if (err != nil) != v.wantErr {
+			t.Errorf("PacketSize %d: unexpected error %v", v.size, err)
+		}
+		if got != v.want {
+			t.Errorf("PacketSize %d: expected %d, got %d", v.size, v.want, got)
+		}
+	}
+}
+
 // TestBadConnRejection verifies that database operations that start
 // with a bad connection fail with the sentinel error driver.ErrBadConn.
 // That instructs the database/sql connection pool logic to discard the
@@ -250,3 +431,210 @@ func TestBadConnRejection(t *testing.T) {
 	}
 
 }
+
+// resetConn fails the writes after the first ones with a connection reset,
+// as when a proxy dropped the idle connection.
+type resetConn struct {
+	net.Conn
+	writes int
+}
+
+func (c *resetConn) Write(b []byte) (int, error) {
+	if c.writes == 0 {
+		return 0, &net.OpError{Op: "write", Net: "tcp", Err: syscall.ECONNRESET}
+	}
+	c.writes--
+	return c.Conn.Write(b)
+}
+
+// serveStatements logs in the client then answers each request with a
+// DONE token counting one row.
+func serveStatements(conn net.Conn, loginAck []byte) {
+	defer conn.Close()
+	buf := newTdsBuffer(4096, conn)
+	read := func() error {
+		if _, err := buf.BeginRead(); err != nil {
+			return err
+		}
+		_, err := io.ReadAll(buf)
+		return err
+	}
+	if read() != nil {
+		return
+	}
+	if writePrelogin(packReply, buf, map[uint8][]byte{preloginENCRYPTION: {encryptNotSup}}) != nil {
+		return
+	}
+	reply := loginAck
+	for read() == nil {
+		buf.BeginPacket(packReply, false)
+		if _, err := buf.Write(reply); err != nil || buf.FinishPacket() != nil {
+			return
+		}
+		reply = []byte{byte(tokenDone), doneCount, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0}
+	}
+}
+
+// resetDialer resets the statement sent on the first connection it dials.
+type resetDialer struct {
+	loginAck []byte
+	dials    int32
+}
+
+func (d *resetDialer) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	server, client := net.Pipe()
+	go serveStatements(server, d.loginAck)
+	if atomic.AddInt32(&d.dials, 1) == 1 {
+		// the prelogin and the login go through, the statement is reset
+		return &resetConn{Conn: client, writes: 2}, nil
+	}
+	return client, nil
+}
+
+func TestRetryOnReset(t *testing.T) {
+	loginAck, err := hex.DecodeString(strings.ReplaceAll(testLoginAck, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer := &resetDialer{loginAck: loginAck}
+	config, err := msdsn.Parse("sqlserver://localhost?encrypt=disable&protocol=tcp&dial timeout=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewConnectorConfig(config)
+	c.Dialer = dialer
+	db := sql.OpenDB(c)
+	defer db.Close()
+	res, err := db.Exec("insert into t values (1)")
+	if err != nil {
+		t.Fatalf("expected the statement to be retried on a new connection, got %v", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Errorf("expected 1 row affected, got %d", n)
+	}
+	if n := atomic.LoadInt32(&dialer.dials); n != 2 {
+		t.Errorf("expected a second connection, got %d dials", n)
+	}
+}
+
+// replyBeginTran queues the response of a query which begins the
+// transaction tranid after more rows than the token channel holds, so that
+// the transaction is only known once the response is read to the end.
+func replyBeginTran(transport *scriptedTransport, tranid uint64) {
+	tokens := [][]byte{append([]byte{byte(tokenColMetadata), 1, 0}, cursorIntColumn("n", colFlagNullable)...)}
+	for n := int32(0); n < 10; n++ {
+		tokens = append(tokens, cursorRow(n))
+	}
+	env := []byte{byte(tokenEnvChange), 11, 0, envTypBeginTran, 8, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint64(env[5:], tranid)
+	tokens = append(tokens, env, []byte{byte(tokenDone), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	transport.reply(tokens...)
+}
+
+// lastRequestTranid returns the transaction descriptor of the headers of
+// the last request, a single packet.
+func lastRequestTranid(transport *scriptedTransport) uint64 {
+	var last []byte
+	for b := transport.requests.Bytes(); len(b) > 0; b = b[binary.BigEndian.Uint16(b[2:]):] {
+		last = b
+	}
+	// the packet header, the total length of the headers, the length and
+	// the type of the transaction descriptor header
+	return binary.LittleEndian.Uint64(last[8+4+4+2:])
+}
+
+func TestInterleavedRows(t *testing.T) {
+	// more rows than the token channel holds, so that the first response
+	// is still being read when the second query is sent
+	const count = 10
+	reply := func(transport *scriptedTransport, first int32) {
+		tokens := [][]byte{append([]byte{byte(tokenColMetadata), 1, 0}, cursorIntColumn("n", colFlagNullable)...)}
+		for n := first; n < first+count; n++ {
+			tokens = append(tokens, cursorRow(n))
+		}
+		tokens = append(tokens, []byte{byte(tokenDone), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+		transport.reply(tokens...)
+	}
+	transport := &scriptedTransport{}
+	reply(transport, 100)
+	reply(transport, 200)
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	query := func() driver.Rows {
+		rows, err := (&Stmt{c: c, query: "select n from numbers"}).queryContext(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+	next := func(rows driver.Rows, want int64) {
+		t.Helper()
+		dest := make([]driver.Value, 1)
+		if err := rows.Next(dest); err != nil {
+			t.Fatal(err)
+		}
+		if dest[0] != want {
+			t.Errorf("expected %d, got %v", want, dest[0])
+		}
+	}
+
+	first := query()
+	next(first, 100)
+	second := query()
+	for i := int64(1); i < count; i++ {
+		next(second, 200+i-1)
+		next(first, 100+i)
+	}
+	next(second, 200+count-1)
+	dest := make([]driver.Value, 1)
+	if err := first.Next(dest); err != io.EOF {
+		t.Errorf("expected the end of the first rows, got %v", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if c.openRows != nil {
+		t.Error("expected no open rows once both are closed")
+	}
+}
+
+func TestPingBadConn(t *testing.T) {
+	closed, peer := net.Pipe()
+	peer.Close()
+	for name, transport := range map[string]io.ReadWriteCloser{
+		"closed socket":    closed,
+		"closed by server": &scriptedTransport{}, // reading the response ends with EOF
+	} {
+		c := testConn(newConnector(msdsn.Config{DisableRetry: true}, nil), transport)
+		if err := c.Ping(context.Background()); !errors.Is(err, driver.ErrBadConn) {
+			t.Errorf("%s: expected driver.ErrBadConn, got %v", name, err)
+		}
+		if err := c.Ping(context.Background()); err != driver.ErrBadConn {
+			t.Errorf("%s: expected driver.ErrBadConn once the connection is bad, got %v", name, err)
+		}
+	}
+}
+
+func TestPingCancel(t *testing.T) {
+	transport := &scriptedTransport{}
+	// the server acknowledges the attention
+	transport.reply([]byte{byte(tokenDone), doneAttn, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Ping(ctx); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if !c.connectionGood {
+		t.Error("expected the connection to stay usable after the attention is acknowledged")
+	}
+	var types []packetType
+	for b := transport.requests.Bytes(); len(b) > 0; b = b[binary.BigEndian.Uint16(b[2:]):] {
+		types = append(types, packetType(b[0]))
+	}
+	if want := []packetType{packSQLBatch, packAttention}; !reflect.DeepEqual(types, want) {
+		t.Errorf("expected the packets %v, got %v", want, types)
+	}
+}
@@ -0,0 +1,78 @@
+package mssql
+
+import "sync"
+
+// maxPreparedStatements is the number of statements a connection keeps
+// prepared when Connector.PreparedStatementCaching is set, further
+// statements run with sp_executesql.
+const maxPreparedStatements = 256
+
+// PreparedStatementStats counts the uses of the prepared statement cache
+// of the connections of a connector.
+type PreparedStatementStats struct {
+	// Hits is the number of statements run with sp_execute on a cached handle.
+	Hits int64
+	// Misses is the number of statements prepared with sp_prepexec.
+	Misses int64
+}
+
+// PreparedStatementStats returns the uses of the prepared statement cache
+// since the connector was created, see PreparedStatementCaching.
+func (c *Connector) PreparedStatementStats() PreparedStatementStats {
+	c.prepMu.Lock()
+	defer c.prepMu.Unlock()
+	return c.prepStats
+}
+
+func (c *Connector) countPrepared(hit bool) {
+	c.prepMu.Lock()
+	defer c.prepMu.Unlock()
+	if hit {
+		c.prepStats.Hits++
+	} else {
+		c.prepStats.Misses++
+	}
+}
+
+// prepCache holds the server handles of the statements prepared on a
+// connection, keyed by the parameter declarations and the statement text.
+// The handles are stored by the goroutine reading the response.
+type prepCache struct {
+	mu      sync.Mutex
+	handles map[string]int32
+}
+
+func (p *prepCache) get(key string) (int32, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	handle, ok := p.handles[key]
+	return handle, ok
+}
+
+func (p *prepCache) put(key string, handle int32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.handles == nil {
+		p.handles = make(map[string]int32)
+	}
+	p.handles[key] = handle
+}
+
+func (p *prepCache) full() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.handles) >= maxPreparedStatements
+}
+
+// reset forgets the handles, the server frees them when the session is reset.
+func (p *prepCache) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handles = nil
+}
+
+// preparedHandle receives the handle sp_prepexec returns for key.
+type preparedHandle struct {
+	cache *prepCache
+	key   string
+}
@@ -0,0 +1,115 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func TestPreparedCall(t *testing.T) {
+	memBuf := new(MockTransport)
+	connector := newConnector(msdsn.Config{}, nil)
+	connector.PreparedStatementCaching = true
+	c := testConn(connector, memBuf)
+	c.prepared = &prepCache{}
+	// returns the procedure id of the RPC request sent for the query
+	send := func(query string, args ...interface{}) uint16 {
+		memBuf.Reset()
+		c.clearOuts()
+		list := make([]namedValue, len(args))
+		for i, arg := range args {
+			list[i] = namedValue{Ordinal: i + 1, Value: arg}
+		}
+		s := &Stmt{c: c, query: query}
+		if err := s.sendQuery(context.Background(), list); err != nil {
+			t.Fatal(err)
+		}
+		b := memBuf.Bytes()[8:]
+		b = b[binary.LittleEndian.Uint32(b):] // skip the headers
+		if binary.LittleEndian.Uint16(b) != 0xffff {
+			t.Fatal("expected a procedure id")
+		}
+		return binary.LittleEndian.Uint16(b[2:])
+	}
+
+	if id := send("select @p1", int64(1)); id != sp_PrepExec.id {
+		t.Fatalf("expected sp_prepexec, got procedure %d", id)
+	}
+	if c.outs.prepared == nil {
+		t.Fatal("expected the handle to be awaited")
+	}
+	// the handle returned by the server
+	c.outs.prepared.cache.put(c.outs.prepared.key, 7)
+
+	if id := send("select @p1", int64(2)); id != sp_Execute.id {
+		t.Errorf("expected sp_execute, got procedure %d", id)
+	}
+	if id := send("select @p1", "a"); id != sp_PrepExec.id {
+		t.Errorf("expected sp_prepexec for other parameter types, got procedure %d", id)
+	}
+	if got := connector.PreparedStatementStats(); got != (PreparedStatementStats{Hits: 1, Misses: 2}) {
+		t.Errorf("unexpected stats %+v", got)
+	}
+
+	if err := c.ResetSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if id := send("select @p1", int64(3)); id != sp_PrepExec.id {
+		t.Errorf("expected sp_prepexec after a session reset, got procedure %d", id)
+	}
+
+	c.outs.prepared.cache.put(c.outs.prepared.key, 8)
+	c.ResetConnection()
+	if id := send("select @p1", int64(3)); id != sp_PrepExec.id {
+		t.Errorf("expected sp_prepexec after a connection reset, got procedure %d", id)
+	}
+
+	c.prepared = nil
+	if id := send("select @p1", int64(4)); id != sp_ExecuteSql.id {
+		t.Errorf("expected sp_executesql without caching, got procedure %d", id)
+	}
+}
+
+func TestPreparedStatementCaching(t *testing.T) {
+	checkConnStr(t)
+	connector, err := NewConnector(makeConnStr(t).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	connector.PreparedStatementCaching = true
+	pool := sql.OpenDB(connector)
+	defer pool.Close()
+
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	const query = "select @p1 + 1"
+	for i := 0; i < 3; i++ {
+		// a new statement each time, the handle is cached by the connection
+		stmt, err := conn.PrepareContext(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got int64
+		if err = stmt.QueryRowContext(ctx, int64(i)).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		stmt.Close()
+		if got != int64(i+1) {
+			t.Errorf("expected %d, got %d", i+1, got)
+		}
+	}
+	if _, err = conn.ExecContext(ctx, "declare @a int = @p1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := connector.PreparedStatementStats(); got != (PreparedStatementStats{Hits: 2, Misses: 2}) {
+		t.Errorf("expected a single sp_prepexec for the repeated statement, got %+v", got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/microsoft/go-mssqldb/msdsn"
 )
@@ -77,7 +78,15 @@ func (t tcpDialer) DialSqlConnection(ctx context.Context, c *Connector, p *msdsn
 		d := c.getDialer(p)
 		if _, ok := d.(HostDialer); ok {
 			addr := net.JoinHostPort(p.Host, portStr)
-			return d.DialContext(ctx, "tcp", addr)
+			conn, err = d.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+			if err = setTCPOptions(conn, p); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
 		}
 
 		ips, err = net.LookupIP(p.Host)
@@ -145,7 +154,47 @@ func (t tcpDialer) DialSqlConnection(ctx context.Context, c *Connector, p *msdsn
 		p.ServerSPN = generateSpn(p.Host, instanceOrPort(p.Instance, p.Port))
 	}
 	p.Port = resolveServerPort(p.Port)
-	return conn, err
+	if err = setTCPOptions(conn, p); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// keepAlivePeriod returns the period of the TCP keep-alive probes, 30
+// seconds by default, or a negative value when they are disabled.
+func keepAlivePeriod(p *msdsn.Config) time.Duration {
+	if p.KeepAlive == 0 {
+		return 30 * time.Second
+	}
+	return p.KeepAlive
+}
+
+// setTCPOptions sets the keep-alive probes and TCP_NODELAY of the
+// connection dialed, when it supports them as a *net.TCPConn does. The
+// connections of custom dialers get the same options as those of the
+// default dialer.
+func setTCPOptions(conn net.Conn, p *msdsn.Config) error {
+	if c, ok := conn.(interface {
+		SetKeepAlive(bool) error
+		SetKeepAlivePeriod(time.Duration) error
+	}); ok {
+		period := keepAlivePeriod(p)
+		if err := c.SetKeepAlive(period > 0); err != nil {
+			return fmt.Errorf("unable to set the TCP keep-alive: %w", err)
+		}
+		if period > 0 {
+			if err := c.SetKeepAlivePeriod(period); err != nil {
+				return fmt.Errorf("unable to set the TCP keep-alive period: %w", err)
+			}
+		}
+	}
+	if c, ok := conn.(interface{ SetNoDelay(bool) error }); ok {
+		if err := c.SetNoDelay(!p.DisableTCPNoDelay); err != nil {
+			return fmt.Errorf("unable to set TCP_NODELAY: %w", err)
+		}
+	}
+	return nil
 }
 
 func (t tcpDialer) CallBrowser(p *msdsn.Config) bool {
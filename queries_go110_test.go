@@ -54,6 +54,39 @@ select Options = @@OPTIONS;
 	}
 }
 
+func TestResetConnection(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "create table #reset_test (id int)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.Raw(func(driverConn interface{}) error {
+		driverConn.(*Conn).ResetConnection()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id sql.NullInt64
+	err = conn.QueryRowContext(ctx, "select object_id('tempdb..#reset_test')").Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Valid {
+		t.Error("expected the temp table to be dropped by the connection reset")
+	}
+}
+
 func TestParameterTypes(t *testing.T) {
 	checkConnStr(t)
 	pool, err := sql.Open("sqlserver", makeConnStr(t).String())
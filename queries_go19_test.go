@@ -7,7 +7,11 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"net"
 	"reflect"
 	"regexp"
 	"strings"
@@ -15,6 +19,7 @@ import (
 	"time"
 
 	"github.com/golang-sql/sqlexp"
+	"github.com/microsoft/go-mssqldb/msdsn"
 )
 
 func TestOutputParam(t *testing.T) {
@@ -798,6 +803,91 @@ func TestOutputParamWithRows(t *testing.T) {
 	})
 }
 
+func TestOutputParamWithMultipleResultSets(t *testing.T) {
+	sqltextcreate := `
+	CREATE PROCEDURE spwithoutputandresultsets
+		@intparam INT OUTPUT
+	AS BEGIN
+		SELECT 'Row 1'
+		SELECT 1 UNION ALL SELECT 2
+		SET @intparam = 42
+	END
+	`
+	sqltextdrop := `DROP PROCEDURE spwithoutputandresultsets;`
+	sqltextrun := `spwithoutputandresultsets`
+
+	checkConnStr(t)
+	tl := testLogger{t: t}
+	defer tl.StopLogging()
+	SetLogger(&tl)
+
+	db, err := sql.Open("sqlserver", makeConnStr(t).String())
+	if err != nil {
+		t.Fatalf("failed to open driver sqlserver")
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db.ExecContext(ctx, sqltextdrop)
+	_, err = db.ExecContext(ctx, sqltextcreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.ExecContext(ctx, sqltextdrop)
+
+	t.Run("Read all result sets", func(t *testing.T) {
+		var intout int64
+		rows, err := db.QueryContext(ctx, sqltextrun, sql.Named("intparam", sql.Out{Dest: &intout}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var sets, count int
+		for {
+			for rows.Next() {
+				count++
+			}
+			sets++
+			if !rows.NextResultSet() {
+				break
+			}
+		}
+		if err = rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if sets != 2 || count != 3 {
+			t.Errorf("expected 2 result sets with 3 rows, got %d with %d rows", sets, count)
+		}
+		if intout != 42 {
+			t.Errorf("expected 42, got %d", intout)
+		}
+	})
+
+	t.Run("Skip rows of the result sets", func(t *testing.T) {
+		var intout int64
+		rows, err := db.QueryContext(ctx, sqltextrun, sql.Named("intparam", sql.Out{Dest: &intout}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		sets := 1
+		for rows.NextResultSet() {
+			sets++
+		}
+		if err = rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if sets != 2 {
+			t.Errorf("expected 2 result sets, got %d", sets)
+		}
+		if intout != 42 {
+			t.Errorf("expected 42, got %d", intout)
+		}
+	})
+}
+
 func TestParamNoName(t *testing.T) {
 	checkConnStr(t)
 	tl := testLogger{t: t}
@@ -1189,6 +1279,200 @@ with
 	}
 }
 
+func TestDecimalParamEncode(t *testing.T) {
+	valid := []Decimal{
+		{Value: "12345678901234567890123456.1234567890", Precision: 38, Scale: 10},
+		{Value: -1234.5, Precision: 6, Scale: 2},
+		{Value: int64(99), Precision: 4, Scale: 2},
+		{Value: "0.1", Precision: 1, Scale: 1},
+	}
+	for _, d := range valid {
+		if _, err := d.encode(); err != nil {
+			t.Errorf("%v: unexpected error %v", d, err)
+		}
+	}
+	invalid := []Decimal{
+		{Value: "123.45", Precision: 4, Scale: 2},
+		{Value: int64(100), Precision: 4, Scale: 2},
+		{Value: "1.234", Precision: 10, Scale: 2},
+		{Value: 1, Precision: 0, Scale: 0},
+		{Value: 1, Precision: 39, Scale: 0},
+		{Value: 1, Precision: 5, Scale: 6},
+		{Value: true, Precision: 5, Scale: 0},
+	}
+	for _, d := range invalid {
+		if _, err := d.encode(); err == nil {
+			t.Errorf("%v: expected an error", d)
+		}
+	}
+}
+
+func TestDecimalParam(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	values := []struct {
+		in   Decimal
+		want string
+	}{
+		{Decimal{Value: "12345678901234567890123456.1234567890", Precision: 38, Scale: 10}, "12345678901234567890123456.1234567890"},
+		{Decimal{Value: -1234.5, Precision: 6, Scale: 2}, "-1234.50"},
+		{Decimal{Value: 42, Precision: 10, Scale: 3}, "42.000"},
+	}
+	for _, v := range values {
+		var got, typ string
+		err := conn.QueryRow("select cast(@p1 as nvarchar(50)), cast(sql_variant_property(@p1, 'Precision') as nvarchar(5)) + ',' + cast(sql_variant_property(@p1, 'Scale') as nvarchar(5))", v.in).Scan(&got, &typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v.want {
+			t.Errorf("expected %s, got %s", v.want, got)
+		}
+		if want := fmt.Sprintf("%d,%d", v.in.Precision, v.in.Scale); typ != want {
+			t.Errorf("expected decimal(%s), got decimal(%s)", want, typ)
+		}
+	}
+}
+
+func TestBlobParam(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	for _, arg := range []interface{}{
+		Blob{Reader: bytes.NewReader(data), Length: int64(len(data))},
+		Blob{Reader: bytes.NewReader(data), Length: -1},
+		bytes.NewReader(data),
+	} {
+		var got []byte
+		err := conn.QueryRow("select @p1", arg).Scan(&got)
+		if err != nil {
+			t.Fatalf("%T: %v", arg, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%T: expected %d bytes, got %d", arg, len(data), len(got))
+		}
+	}
+
+	var got []byte
+	err := conn.QueryRow("select @p1", Blob{}).Scan(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("expected NULL for Blob without reader, got %v", got)
+	}
+}
+
+func TestVarBinaryParamTypeInfo(t *testing.T) {
+	values := []struct {
+		in       interface{}
+		typeInfo []byte
+		decl     string
+	}{
+		{VarBinary([]byte{1, 2, 3}), []byte{typeBigVarBin, 3, 0}, "varbinary(3)"},
+		{VarBinary([]byte{}), []byte{typeBigVarBin, 1, 0}, "varbinary(1)"},
+		{VarBinary(bytes.Repeat([]byte{1}, 8000)), []byte{typeBigVarBin, 0x40, 0x1f}, "varbinary(8000)"},
+		{VarBinaryMax([]byte{1, 2, 3}), []byte{typeBigVarBin, 0xff, 0xff}, "varbinary(max)"},
+	}
+	s := &Stmt{}
+	for _, v := range values {
+		p, err := s.makeParam(v.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err = writeTypeInfo(&buf, &p.ti, false, msdsn.EncodeParameters{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), v.typeInfo) {
+			t.Errorf("%T of %d bytes: expected type info % x, got % x", v.in, p.ti.Size, v.typeInfo, buf.Bytes())
+		}
+		if decl := makeDecl(p.ti); decl != v.decl {
+			t.Errorf("%T: expected %s, got %s", v.in, v.decl, decl)
+		}
+	}
+	if _, err := s.makeParam(VarBinary(make([]byte, 8001))); err == nil {
+		t.Error("expected an error for a VarBinary longer than 8000 bytes")
+	}
+}
+
+func TestNullTypesParamTypeInfo(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	nvarchar4000 := []byte{typeNVarChar, 0x40, 0x1f, 0, 0, 0, 0, 0}
+	values := []struct {
+		in       interface{}
+		typeInfo []byte
+		decl     string
+		valid    bool
+	}{
+		{sql.NullByte{Byte: 1, Valid: true}, []byte{typeIntN, 1}, "tinyint", true},
+		{sql.NullByte{}, []byte{typeIntN, 1}, "tinyint", false},
+		{sql.NullInt16{Int16: 1, Valid: true}, []byte{typeIntN, 2}, "smallint", true},
+		{sql.NullInt16{}, []byte{typeIntN, 2}, "smallint", false},
+		{sql.NullInt32{Int32: 1, Valid: true}, []byte{typeIntN, 4}, "int", true},
+		{sql.NullInt32{}, []byte{typeIntN, 4}, "int", false},
+		{sql.NullInt64{Int64: 1, Valid: true}, []byte{typeIntN, 8}, "bigint", true},
+		{sql.NullInt64{}, []byte{typeIntN, 8}, "bigint", false},
+		{sql.NullFloat64{Float64: 1.5, Valid: true}, []byte{typeFltN, 8}, "float", true},
+		{sql.NullFloat64{}, []byte{typeFltN, 8}, "float", false},
+		{sql.NullBool{Bool: true, Valid: true}, []byte{typeBitN, 1}, "bit", true},
+		{sql.NullBool{}, []byte{typeBitN, 1}, "bit", false},
+		{sql.NullString{String: "ab", Valid: true}, []byte{typeNVarChar, 4, 0, 0, 0, 0, 0, 0}, "nvarchar(2)", true},
+		{sql.NullString{}, nvarchar4000, "nvarchar(4000)", false},
+		{sql.NullTime{Time: now, Valid: true}, []byte{typeDateTimeOffsetN, 7}, "datetimeoffset(7)", true},
+		{sql.NullTime{}, []byte{typeDateTimeOffsetN, 7}, "datetimeoffset(7)", false},
+		{&sql.NullInt32{Int32: 1, Valid: true}, []byte{typeIntN, 4}, "int", true},
+		{&sql.NullInt32{}, []byte{typeIntN, 4}, "int", false},
+		{(*sql.NullString)(nil), nvarchar4000, "nvarchar(4000)", false},
+		{&sql.NullTime{}, []byte{typeDateTimeOffsetN, 7}, "datetimeoffset(7)", false},
+	}
+	s := &Stmt{c: &Conn{sess: &tdsSession{loginAck: loginAckStruct{TDSVersion: verTDS74}}}}
+	for _, v := range values {
+		p, err := s.makeParam(v.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err = writeTypeInfo(&buf, &p.ti, false, msdsn.EncodeParameters{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), v.typeInfo) {
+			t.Errorf("%#v: expected type info % x, got % x", v.in, v.typeInfo, buf.Bytes())
+		}
+		if decl := makeDecl(p.ti); decl != v.decl {
+			t.Errorf("%#v: expected %s, got %s", v.in, v.decl, decl)
+		}
+		if (len(p.buffer) > 0) != v.valid {
+			t.Errorf("%#v: unexpected value % x", v.in, p.buffer)
+		}
+	}
+}
+
+func TestVarBinaryParam(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	for _, data := range [][]byte{{}, {1, 2, 3}, bytes.Repeat([]byte{1}, 9000)} {
+		args := []interface{}{VarBinaryMax(data)}
+		if len(data) <= 8000 {
+			args = append(args, VarBinary(data))
+		}
+		for _, arg := range args {
+			var got []byte
+			if err := conn.QueryRow("select @p1", arg).Scan(&got); err != nil {
+				t.Fatalf("%T: %v", arg, err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("%T: expected %d bytes, got %d", arg, len(data), len(got))
+			}
+		}
+	}
+}
+
 func TestReturnStatus(t *testing.T) {
 	conn, logger := open(t)
 	defer conn.Close()
@@ -1214,6 +1498,36 @@ func TestReturnStatus(t *testing.T) {
 	}
 }
 
+func TestReturnStatusWithOutputParam(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "CREATE PROC #answer @half int OUTPUT AS BEGIN SET @half = 21; RETURN 42 END")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rs ReturnStatus
+	var half int64
+	_, err = conn.ExecContext(ctx, "#answer", sql.Named("half", sql.Out{Dest: &half}), &rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs != 42 {
+		t.Errorf("expected status=42, got %d", rs)
+	}
+	if half != 21 {
+		t.Errorf("expected the output parameter 21, got %d", half)
+	}
+}
+
 func TestClearReturnStatus(t *testing.T) {
 	db, logger := open(t)
 	defer db.Close()
@@ -1648,3 +1962,273 @@ func TestErrorAsLastResult(t *testing.T) {
 		}
 	}
 }
+
+func TestSetSessionContext(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	setSessionContext := func(key string, value interface{}, readOnly bool) error {
+		return conn.Raw(func(driverConn interface{}) error {
+			return driverConn.(*Conn).SetSessionContext(ctx, key, value, readOnly)
+		})
+	}
+	if err = setSessionContext("user_id", 42, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = setSessionContext("tenant", "contoso", true); err != nil {
+		t.Fatal(err)
+	}
+
+	var userID int64
+	var tenant string
+	err = conn.QueryRowContext(ctx, "select cast(session_context(N'user_id') as int), cast(session_context(N'tenant') as nvarchar(100))").Scan(&userID, &tenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != 42 || tenant != "contoso" {
+		t.Errorf("expected 42 and contoso, got %d and %s", userID, tenant)
+	}
+
+	if err = setSessionContext("tenant", "fabrikam", false); err == nil {
+		t.Error("expected an error changing a read only key")
+	}
+}
+
+func TestDateParam(t *testing.T) {
+	// early in the morning east of UTC, the UTC date is the previous day
+	loc := time.FixedZone("UTC+5", 5*60*60)
+	tin := time.Date(2024, 3, 10, 1, 30, 0, 0, loc)
+	p, err := (&Stmt{}).makeParam(Date(tin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ti.TypeId != typeDateN || makeDecl(p.ti) != "date" {
+		t.Errorf("expected a date parameter, got %s", makeDecl(p.ti))
+	}
+	if got := decodeDate(p.buffer); !got.Equal(time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the calendar date 2024-03-10, got %v", got)
+	}
+
+	var d Date
+	if err = d.Scan(tin); err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Time(d); got != time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("expected midnight UTC of the date, got %v", got)
+	}
+	for _, in := range []interface{}{nil, "2024-03-10"} {
+		if err = d.Scan(in); err == nil {
+			t.Errorf("expected an error for Scan(%v)", in)
+		}
+	}
+}
+
+func TestDateRoundTrip(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	loc := time.FixedZone("UTC-7", -7*60*60)
+	tin := time.Date(2024, 3, 10, 22, 45, 10, 0, loc)
+	want := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	for _, arg := range []interface{}{Date(tin), tin} {
+		var d Date
+		var s string
+		err := conn.QueryRow("declare @d date = @p1; select @d, convert(varchar(10), @d, 23)", arg).Scan(&d, &s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Time(d) != want {
+			t.Errorf("%T: expected %v, got %v", arg, want, time.Time(d))
+		}
+		if s != "2024-03-10" {
+			t.Errorf("%T: expected the stored date 2024-03-10, got %s", arg, s)
+		}
+	}
+}
+
+func TestOutputStringParamDecl(t *testing.T) {
+	s := "short"
+	b := []byte{1, 2}
+	for _, dest := range []interface{}{s, VarChar(s), NVarCharMax(s), sql.NullString{}, b, VarBinary(nil)} {
+		p, err := (&Stmt{}).makeParam(sql.Out{Dest: dest})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decl := makeDecl(p.ti); !strings.HasSuffix(decl, "(max)") {
+			t.Errorf("%T: expected a max output parameter, got %s", dest, decl)
+		}
+	}
+	p, err := (&Stmt{}).makeParam(sql.Out{Dest: NChar(s)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decl := makeDecl(p.ti); decl != "nchar(5)" {
+		t.Errorf("expected the fixed length nchar(5), got %s", decl)
+	}
+}
+
+func TestOutputPLPParam(t *testing.T) {
+	want := strings.Repeat("x", 5000)
+	data := str2ucs2(want)
+	// an nvarchar(max) return value sent in two chunks of unknown total length
+	token := []byte{byte(tokenReturnValue), 0, 0, 4}
+	token = append(token, str2ucs2("@out")...)
+	token = append(token, 1, 0, 0, 0, 0, 0, 0, typeNVarChar, 0xff, 0xff, 0, 0, 0, 0, 0)
+	token = append(token, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	for _, chunk := range [][]byte{data[:6000], data[6000:]} {
+		size := make([]byte, 4)
+		binary.LittleEndian.PutUint32(size, uint32(len(chunk)))
+		token = append(token, size...)
+		token = append(token, chunk...)
+	}
+	token = append(token, 0, 0, 0, 0)
+	transport := &scriptedTransport{}
+	transport.reply(token, cursorDoneProc())
+
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	c.sess.buf = newTdsBuffer(16384, transport)
+	var out string
+	nv := driver.NamedValue{Name: "out", Ordinal: 1, Value: sql.Out{Dest: &out}}
+	if err := c.CheckNamedValue(&nv); err != nil {
+		t.Fatal(err)
+	}
+	s := &Stmt{c: c, query: "set @out = replicate(cast('x' as nvarchar(max)), 5000)", paramCount: -1}
+	if _, err := s.ExecContext(context.Background(), []driver.NamedValue{nv}); err != nil {
+		t.Fatal(err)
+	}
+	if out != want {
+		t.Errorf("expected the %d characters of both chunks, got %d", len(want), len(out))
+	}
+	if !bytes.Contains(transport.requests.Bytes(), str2ucs2("@out nvarchar(max) output")) {
+		t.Error("expected the output parameter to be declared as nvarchar(max)")
+	}
+}
+
+func TestOutputParamsCancelled(t *testing.T) {
+	name := str2ucs2("@a")
+	returnValue := []byte{byte(tokenReturnValue), 1, 0, byte(len(name) / 2)}
+	returnValue = append(returnValue, name...)
+	returnValue = append(returnValue, 1, 0, 0, 0, 0, colFlagNullable, 0, typeIntN, 4, 4, 7, 0, 0, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		buf := newTdsBuffer(1024, server)
+		if _, err := buf.BeginRead(); err != nil {
+			return
+		}
+		if _, err := io.ReadAll(buf); err != nil {
+			return
+		}
+		// the server sends the first output parameter, then the statement
+		// is cancelled before the second one
+		reply := append([]byte{byte(packReply), 0, 0, byte(8 + len(returnValue)), 0, 0, 1, 0}, returnValue...)
+		if _, err := server.Write(reply); err != nil {
+			return
+		}
+		cancel()
+		if packet, err := buf.BeginRead(); err != nil || packet != packAttention {
+			return
+		}
+		done := []byte{byte(tokenDone), doneAttn, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		server.Write(append([]byte{byte(packReply), 1, 0, byte(8 + len(done)), 0, 0, 2, 0}, done...))
+	}()
+
+	c := testConn(newConnector(msdsn.Config{}, nil), client)
+	a, b := int64(1), int64(2)
+	args := []driver.NamedValue{{Name: "a", Ordinal: 1, Value: sql.Out{Dest: &a}}, {Name: "b", Ordinal: 2, Value: sql.Out{Dest: &b}}}
+	for i := range args {
+		if err := c.CheckNamedValue(&args[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &Stmt{c: c, query: "exec slow @a output, @b output", paramCount: -1}
+	if _, err := s.ExecContext(ctx, args); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if a != 0 || b != 0 {
+		t.Errorf("expected the output parameters of the interrupted statement to be zero, got %d and %d", a, b)
+	}
+}
+
+func TestOutputParamsCancelledProc(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, `create procedure #slowout @n int output as
+		set @n = 5
+		waitfor delay '00:00:05'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 1
+	timeoutCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	_, err = conn.ExecContext(timeoutCtx, "#slowout", sql.Named("n", sql.Out{Dest: &n}))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if n != 0 {
+		t.Errorf("expected the output parameter of the interrupted procedure to be zero, got %d", n)
+	}
+	if err = conn.PingContext(ctx); err != nil {
+		t.Errorf("expected the connection to stay usable, got %v", err)
+	}
+}
+
+func TestOutputStringMaxParam(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	// the temporary procedure only exists on the connection which created it
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, `create procedure #longout @n int, @s nvarchar(max) output as
+		set @s = replicate(cast(N'ü' as nvarchar(max)), @n)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{0, 10, 9000} {
+		out := "short"
+		_, err = conn.ExecContext(ctx, "#longout", sql.Named("n", n), sql.Named("s", sql.Out{Dest: &out}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != strings.Repeat("ü", n) {
+			t.Errorf("expected %d characters, got %d", n, len([]rune(out)))
+		}
+	}
+
+	var out string
+	_, err = conn.ExecContext(ctx, "set @s = replicate(cast('y' as varchar(max)), 10000)", sql.Named("s", sql.Out{Dest: &out}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != strings.Repeat("y", 10000) {
+		t.Errorf("expected 10000 characters from the batch, got %d", len(out))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -224,6 +225,81 @@ func TestSelect(t *testing.T) {
 	testSelect(t, false /*guidConversion*/)
 }
 
+func TestSelectUTF8Encoding(t *testing.T) {
+	config := testConnParams(t)
+	config.Encoding.UTF8 = true
+	db, err := sql.Open("sqlserver", config.URL().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "create table #utf8_test (v varchar(100) collate Latin1_General_100_CI_AS_SC_UTF8, t varchar(max) collate Latin1_General_100_CI_AS_SC_UTF8)")
+	if err != nil {
+		t.Skip("UTF-8 collations are not supported by the server: ", err)
+	}
+	const want = "ab©ĎéⒻghïjklmnopqЯ☀tuvwxyz😀"
+	_, err = conn.ExecContext(ctx, "insert into #utf8_test values (@p1, @p1)", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v, text string
+	err = conn.QueryRowContext(ctx, "select v, t from #utf8_test").Scan(&v, &text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != want || text != want {
+		t.Errorf("expected %q, got %q and %q", want, v, text)
+	}
+}
+
+func TestSelectDecimalAsString(t *testing.T) {
+	config := testConnParams(t)
+	config.Encoding.DecimalAsString = true
+	db, err := sql.Open("sqlserver", config.URL().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	values := []struct {
+		sql  string
+		want interface{}
+	}{
+		{"cast(-12.3400 as decimal(10, 4))", "-12.3400"},
+		{"cast(42 as numeric(5, 0))", "42"},
+		{"cast(-7 as decimal(5, 0))", "-7"},
+		{"cast(0.10 as decimal(38, 2))", "0.10"},
+		{"cast(12345678901234567890.123456789 as decimal(38, 9))", "12345678901234567890.123456789"},
+		{"cast(null as decimal(10, 2))", nil},
+		{"cast(cast(-1.50 as decimal(5, 2)) as sql_variant)", "-1.50"},
+	}
+	for _, v := range values {
+		var got interface{}
+		if err := db.QueryRow("select " + v.sql).Scan(&got); err != nil {
+			t.Fatal(v.sql, err)
+		}
+		if got != v.want {
+			t.Errorf("%s: expected %#v, got %#v", v.sql, v.want, got)
+		}
+	}
+
+	var s string
+	if err := db.QueryRow("select cast(1.5 as decimal(5, 3))").Scan(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s != "1.500" {
+		t.Errorf("expected 1.500, got %s", s)
+	}
+}
+
 func TestSelectDateTimeOffset(t *testing.T) {
 	type testStruct struct {
 		sql string
@@ -370,6 +446,95 @@ func TestTrans(t *testing.T) {
 	}
 }
 
+func TestNullWideRow(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	// a row with many NULLs is sent as an NBCROW, the odd columns are NULL
+	const count = 20
+	cols := make([]string, count)
+	for i := range cols {
+		switch {
+		case i%2 == 1:
+			cols[i] = fmt.Sprintf("cast(null as nvarchar(%d)) c%d", i, i)
+		case i%4 == 0:
+			cols[i] = fmt.Sprintf("cast(%d as int) c%d", i, i)
+		default:
+			cols[i] = fmt.Sprintf("N'v%d' c%d", i, i)
+		}
+	}
+	row := conn.QueryRow("select " + strings.Join(cols, ", "))
+	values := make([]interface{}, count)
+	dest := make([]interface{}, count)
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := row.Scan(dest...); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range values {
+		var want interface{}
+		switch {
+		case i%2 == 1:
+			want = nil
+		case i%4 == 0:
+			want = int64(i)
+		default:
+			want = fmt.Sprintf("v%d", i)
+		}
+		if v != want {
+			t.Errorf("column %d: expected %v, got %v", i, want, v)
+		}
+	}
+}
+
+func TestInterleavedQueries(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	const query = "select top (@p1) @p2 + row_number() over (order by object_id) from sys.all_objects"
+	first, err := conn.QueryContext(ctx, query, 50, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := conn.QueryContext(ctx, query, 50, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	for i := int64(1); i <= 50; i++ {
+		for _, rows := range []struct {
+			rows *sql.Rows
+			base int64
+		}{{first, 100}, {second, 200}} {
+			if !rows.rows.Next() {
+				t.Fatalf("expected row %d, got %v", i, rows.rows.Err())
+			}
+			var n int64
+			if err = rows.rows.Scan(&n); err != nil {
+				t.Fatal(err)
+			}
+			if n != rows.base+i {
+				t.Fatalf("expected %d, got %d", rows.base+i, n)
+			}
+		}
+	}
+	if first.Next() || second.Next() {
+		t.Error("expected no more rows")
+	}
+}
+
 func TestNull(t *testing.T) {
 	conn, logger := open(t)
 	defer conn.Close()
@@ -879,6 +1044,71 @@ func TestAffectedRows(t *testing.T) {
 	}
 }
 
+func TestStatementRowCounts(t *testing.T) {
+	transport := &scriptedTransport{}
+	done := func(status uint16, count uint64) []byte {
+		b := make([]byte, 13)
+		b[0] = byte(tokenDone)
+		binary.LittleEndian.PutUint16(b[1:], status)
+		binary.LittleEndian.PutUint64(b[5:], count)
+		return b
+	}
+	// the declare statement reports no count
+	transport.reply(done(doneMore, 0), done(doneMore|doneCount, 3), done(doneMore|doneCount, 0), done(doneCount, 2))
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	s := &Stmt{c: c, query: "declare @a int; update t set a = 1; delete from t where 1 = 0; insert into t values (1), (2)"}
+	res, err := s.ExecContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts := res.(StatementRowCounts).RowCounts(); !reflect.DeepEqual(counts, []int64{3, 0, 2}) {
+		t.Errorf("expected the counts [3 0 2], got %v", counts)
+	}
+	if n, _ := res.RowsAffected(); n != 5 {
+		t.Errorf("expected 5 rows affected in total, got %d", n)
+	}
+}
+
+func TestStatementRowCountsQuery(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, "create table #counts (a int); insert into #counts values (1), (2), (3), (4)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var counts []int64
+	err = conn.Raw(func(driverConn interface{}) error {
+		stmt, err := driverConn.(*Conn).PrepareContext(ctx, `update #counts set a = a + 1;
+			delete from #counts where a > 4;
+			insert into #counts values (10), (11), (12)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		res, err := stmt.(driver.StmtExecContext).ExecContext(ctx, nil)
+		if err != nil {
+			return err
+		}
+		counts = res.(StatementRowCounts).RowCounts()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(counts, []int64{4, 1, 3}) {
+		t.Errorf("expected the counts [4 1 3], got %v", counts)
+	}
+}
+
 func queryParamRoundTrip(db *sql.DB, param interface{}, dest interface{}) {
 	err := db.QueryRow("select @p1", param).Scan(dest)
 	if err != nil {
@@ -1002,6 +1232,22 @@ func TestBigQuery(t *testing.T) {
 	}
 }
 
+func TestBigNVarCharMax(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	// 50KB, several PLP chunks and TDS packets
+	var s string
+	err := conn.QueryRow("select replicate(cast(N'\u00e9' as nvarchar(max)), 25600) + N'end'").Scan(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Repeat("\u00e9", 25600) + "end"; s != want {
+		t.Errorf("expected %d characters, got %d", len([]rune(want)), len([]rune(s)))
+	}
+}
+
 func TestBug32(t *testing.T) {
 	conn, logger := open(t)
 	defer conn.Close()
@@ -1118,6 +1364,43 @@ func TestErrorInfo(t *testing.T) {
 	}
 }
 
+func TestErrorInfoLineNo(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	// the temporary procedure is only visible on the connection creating it
+	conn, err := pool.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), "declare @a int = 1\nselect @a\nselect bad\nselect 2")
+	sqlError, ok := err.(Error)
+	if !ok {
+		t.Fatal("Failed to convert error to SQLErorr", err)
+	}
+	if sqlError.Number != 207 || sqlError.LineNo != 3 {
+		t.Errorf("expected error 207 on line 3, got %d on line %d", sqlError.Number, sqlError.LineNo)
+	}
+	if sqlError.ServerName == "" {
+		t.Error("expected the server name to be set")
+	}
+
+	_, err = conn.ExecContext(context.Background(), "create procedure #error_proc as\nbegin\n\tselect 1\n\traiserror('proc failed', 16, 1)\nend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.ExecContext(context.Background(), "exec #error_proc")
+	if sqlError, ok = err.(Error); !ok {
+		t.Fatal("Failed to convert error to SQLErorr", err)
+	}
+	if !strings.HasPrefix(sqlError.ProcName, "#error_proc") || sqlError.LineNo != 4 {
+		t.Errorf("expected the error in #error_proc on line 4, got %s on line %d", sqlError.ProcName, sqlError.LineNo)
+	}
+}
+
 func TestSetLanguage(t *testing.T) {
 	conn, logger := open(t)
 	defer conn.Close()
@@ -1685,14 +1968,14 @@ func TestColumnTypeIntrospection(t *testing.T) {
 		{"cast(1 as numeric(10, 4))", "DECIMAL", reflect.TypeOf([]byte{}), false, 0, true, 10, 4},
 		{"cast(1 as money)", "MONEY", reflect.TypeOf([]byte{}), false, 0, false, 0, 0},
 		{"cast(1 as smallmoney)", "SMALLMONEY", reflect.TypeOf([]byte{}), false, 0, false, 0, 0},
-		{"cast(0x6F9619FF8B86D011B42D00C04FC964FF as uniqueidentifier)", "UNIQUEIDENTIFIER", reflect.TypeOf([]byte{}), false, 0, false, 0, 0},
+		{"cast(0x6F9619FF8B86D011B42D00C04FC964FF as uniqueidentifier)", "UNIQUEIDENTIFIER", reflect.TypeOf(UniqueIdentifier{}), false, 0, false, 0, 0},
 		{"cast('<root/>' as xml)", "XML", reflect.TypeOf(""), true, 1073741822, false, 0, 0},
 		{"cast('abc' as text)", "TEXT", reflect.TypeOf(""), true, 2147483647, false, 0, 0},
 		{"cast(N'abc' as ntext)", "NTEXT", reflect.TypeOf(""), true, 1073741823, false, 0, 0},
 		{"cast('abc' as image)", "IMAGE", reflect.TypeOf([]byte{}), true, 2147483647, false, 0, 0},
 		{"cast('abc' as char(3))", "CHAR", reflect.TypeOf(""), true, 3, false, 0, 0},
 		{"cast(N'abc' as nchar(3))", "NCHAR", reflect.TypeOf(""), true, 3, false, 0, 0},
-		{"cast(1 as sql_variant)", "SQL_VARIANT", reflect.TypeOf(nil), false, 0, false, 0, 0},
+		{"cast(1 as sql_variant)", "SQL_VARIANT", reflect.TypeOf((*interface{})(nil)).Elem(), false, 0, false, 0, 0},
 		{"geometry::STGeomFromText('LINESTRING (100 100, 20 180, 180 180)', 0)", "GEOMETRY", reflect.TypeOf([]byte{}), true, 2147483647, false, 0, 0},
 		{"geography::STGeomFromText('LINESTRING(-122.360 47.656, -122.343 47.656 )', 4326)", "GEOGRAPHY", reflect.TypeOf([]byte{}), false, 2147483647, false, 0, 0},
 		{"cast('/1/2/3/' as hierarchyid)", "HIERARCHYID", reflect.TypeOf([]byte{}), true, 892, false, 0, 0},
@@ -1744,6 +2027,68 @@ func TestColumnTypeIntrospection(t *testing.T) {
 	}
 }
 
+func TestColumnTypeAlias(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	conn.Exec("drop type dbo.SSN")
+	if _, err := conn.Exec("create type dbo.SSN from char(9)"); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Exec("drop type dbo.SSN")
+
+	rows, err := conn.Query("declare @tbl table(ssn dbo.SSN not null); select ssn from @tbl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	ct, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the column metadata describes the base type of the alias type
+	if name := ct[0].DatabaseTypeName(); name != "CHAR" {
+		t.Errorf("expected the base type CHAR, got %s", name)
+	}
+	if size, ok := ct[0].Length(); !ok || size != 9 {
+		t.Errorf("expected the length 9, got %d, %v", size, ok)
+	}
+	if nullable, ok := ct[0].Nullable(); !ok || nullable {
+		t.Errorf("expected a not null column, got %v, %v", nullable, ok)
+	}
+	rows.Close()
+
+	// the user type of the column names the alias type
+	var userTypeID uint32
+	if err = conn.QueryRow("select user_type_id from sys.types where name = 'SSN'").Scan(&userTypeID); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	dc, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close()
+	var userType uint32
+	err = dc.Raw(func(driverConn interface{}) error {
+		s := &Stmt{c: driverConn.(*Conn), query: "declare @tbl table(ssn dbo.SSN not null); select ssn from @tbl"}
+		r, err := s.queryContext(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		userType = r.(*Rows).ColumnTypeUserType(0)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userType != userTypeID {
+		t.Errorf("expected the user type %d of dbo.SSN, got %d", userTypeID, userType)
+	}
+}
+
 func TestColumnIntrospection(t *testing.T) {
 	type tst struct {
 		expr         string
@@ -1760,6 +2105,9 @@ func TestColumnIntrospection(t *testing.T) {
 		{"f1 int null", "f1", "INT", true, false, 0, false, 0, 0},
 		{"f2 varchar(15) not null", "f2", "VARCHAR", false, true, 15, false, 0, 0},
 		{"f3 decimal(5, 2) null", "f3", "DECIMAL", true, false, 0, true, 5, 2},
+		{"f4 decimal(18, 4) not null", "f4", "DECIMAL", false, false, 0, true, 18, 4},
+		{"f5 nvarchar(20) null", "f5", "NVARCHAR", true, true, 20, false, 0, 0},
+		{"f6 datetime2(3) not null", "f6", "DATETIME2", false, false, 0, true, 0, 3},
 	}
 	conn, logger := open(t)
 	defer conn.Close()
@@ -2155,6 +2503,94 @@ func TestQueryTimeout(t *testing.T) {
 	}
 }
 
+func TestQueryTimeoutSendsAttention(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	attention := make(chan bool, 1)
+	go func() {
+		defer server.Close()
+		header := make([]byte, 8)
+		// the request, then the attention sent when the timeout expires
+		for {
+			if _, err := io.ReadFull(server, header); err != nil {
+				attention <- false
+				return
+			}
+			size := int(header[2])<<8 | int(header[3])
+			if _, err := io.CopyN(io.Discard, server, int64(size-8)); err != nil {
+				attention <- false
+				return
+			}
+			if packetType(header[0]) == packAttention {
+				break
+			}
+		}
+		attention <- true
+		done := []byte{byte(tokenDone), doneAttn, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		reply := append([]byte{byte(packReply), 1, 0, byte(8 + len(done)), 0, 0, 1, 0}, done...)
+		server.Write(reply)
+	}()
+
+	c := testConn(newConnector(msdsn.Config{QueryTimeout: 50 * time.Millisecond}, nil), client)
+	s := &Stmt{c: c, query: "waitfor delay '00:00:20'"}
+	_, err := s.exec(context.Background(), nil)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+	if !<-attention {
+		t.Error("expected an attention to be sent")
+	}
+}
+
+func TestQueryTimeoutConnStr(t *testing.T) {
+	checkConnStr(t)
+	p := makeConnStr(t)
+	q := p.Query()
+	q.Set("query timeout", "1")
+	p.RawQuery = q.Encode()
+	db, err := sql.Open("sqlserver", p.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = db.Exec("waitfor delay '00:00:20'")
+	if err != context.DeadlineExceeded {
+		t.Errorf("Exec expected to fail with DeadlineExceeded but it returned %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the statement to be cancelled after the query timeout, it took %v", elapsed)
+	}
+	rows, err := db.Query("waitfor delay '00:00:20'; select 1")
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		rows.Close()
+	}
+	if err != context.DeadlineExceeded {
+		t.Errorf("Query expected to fail with DeadlineExceeded but it returned %v", err)
+	}
+
+	// a deadline of the caller replaces the query timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err = db.ExecContext(ctx, "waitfor delay '00:00:02'"); err != nil {
+		t.Errorf("expected the context deadline to be used, got %v", err)
+	}
+
+	// the connection is usable after the timeout
+	var val int64
+	if err = db.QueryRow("select 1").Scan(&val); err != nil {
+		t.Fatal("Scan failed with", err)
+	}
+}
+
 // Regression test for #679
 func TestLoginTimeout(t *testing.T) {
 	conn, logger := open(t)
@@ -35,6 +35,22 @@ func (TSQLQuoter) Value(v interface{}) string {
 	}
 }
 
+// QuoteIdentifier quotes name as a single part identifier, such as a table
+// or column name, to embed it in SQL text. The name is enclosed in brackets
+// with its closing brackets doubled, so that a name like "a]b" or "dbo.t"
+// is a single identifier. Quote the parts of a multi-part name separately:
+//
+//	table := mssql.QuoteIdentifier(schema) + "." + mssql.QuoteIdentifier(name)
+func QuoteIdentifier(name string) string {
+	return TSQLQuoter{}.ID(name)
+}
+
+// QuoteLiteral quotes s as a Unicode string literal to embed it in SQL text,
+// doubling its single quotes. Prefer query parameters where possible.
+func QuoteLiteral(s string) string {
+	return "N" + sqlString(s)
+}
+
 func sqlString(v string) string {
 	return "'" + strings.Replace(string(v), "'", "''", -1) + "'"
 }
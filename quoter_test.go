@@ -0,0 +1,68 @@
+package mssql
+
+import "testing"
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"orders", "[orders]"},
+		{"a]b", "[a]]b]"},
+		{"]]", "[]]]]]"},
+		{"[x]", "[[x]]]"},
+		{"dbo.orders", "[dbo.orders]"},
+		{"naïve 表", "[naïve 表]"},
+		{"", "[]"},
+	}
+	for _, tt := range tests {
+		if got := QuoteIdentifier(tt.name); got != tt.want {
+			t.Errorf("QuoteIdentifier(%q): expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"abc", "N'abc'"},
+		{"it's", "N'it''s'"},
+		{"''", "N''''''"},
+		{"a]b.c", "N'a]b.c'"},
+		{"naïve 表", "N'naïve 表'"},
+		{"", "N''"},
+	}
+	for _, tt := range tests {
+		if got := QuoteLiteral(tt.s); got != tt.want {
+			t.Errorf("QuoteLiteral(%q): expected %s, got %s", tt.s, tt.want, got)
+		}
+	}
+}
+
+func TestQuoteRoundTrip(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	for _, name := range []string{"a]b", "[x]", "dbo.t", "naïve 表", "it's"} {
+		var got string
+		err := conn.QueryRow("select " + QuoteLiteral(name) + " as " + QuoteIdentifier(name)).Scan(&got)
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		if got != name {
+			t.Errorf("expected %q, got %q", name, got)
+		}
+		rows, err := conn.Query("select 1 as " + QuoteIdentifier(name))
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		cols, _ := rows.Columns()
+		rows.Close()
+		if len(cols) != 1 || cols[0] != name {
+			t.Errorf("expected the column name %q, got %v", name, cols)
+		}
+	}
+}
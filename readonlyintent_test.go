@@ -0,0 +1,164 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// routingDialer serves a listener on listener:1433 routing the read-only
+// logins to replica:11000, as an availability group listener does, and
+// records the addresses dialed. The replica refuses the connections when
+// down is set.
+type routingDialer struct {
+	loginAck []byte
+	down     bool
+	mu       sync.Mutex
+	addrs    []string
+}
+
+func (d *routingDialer) HostName() string {
+	return ""
+}
+
+func (d *routingDialer) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	d.mu.Lock()
+	d.addrs = append(d.addrs, addr)
+	d.mu.Unlock()
+	if addr == "replica:11000" && d.down {
+		return nil, errors.New("connection refused")
+	}
+	server, client := net.Pipe()
+	if addr == "replica:11000" {
+		go serveStatements(server, d.loginAck)
+	} else {
+		go d.serveListener(server)
+	}
+	return client, nil
+}
+
+func (d *routingDialer) dialed() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.addrs...)
+}
+
+func (d *routingDialer) serveListener(conn net.Conn) {
+	defer conn.Close()
+	buf := newTdsBuffer(4096, conn)
+	read := func() ([]byte, error) {
+		if _, err := buf.BeginRead(); err != nil {
+			return nil, err
+		}
+		return io.ReadAll(buf)
+	}
+	if _, err := read(); err != nil {
+		return
+	}
+	if writePrelogin(packReply, buf, map[uint8][]byte{preloginENCRYPTION: {encryptNotSup}}) != nil {
+		return
+	}
+	login, err := read()
+	if err != nil {
+		return
+	}
+	reply := d.loginAck
+	// the type flags of the LOGIN7 record
+	if login[26]&fReadOnlyIntent != 0 {
+		server := str2ucs2("replica")
+		value := []byte{0, 0xf8, 0x2a, byte(len(server) / 2), 0} // TCP, port 11000
+		value = append(value, server...)
+		data := append([]byte{envRouting, byte(len(value)), 0}, value...)
+		data = append(data, 0, 0)
+		route := append([]byte{byte(tokenEnvChange), byte(len(data)), 0}, data...)
+		reply = append(route, d.loginAck...)
+	}
+	for {
+		buf.BeginPacket(packReply, false)
+		if _, err := buf.Write(reply); err != nil || buf.FinishPacket() != nil {
+			return
+		}
+		if _, err := read(); err != nil {
+			return
+		}
+		reply = []byte{byte(tokenDone), doneCount, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0}
+	}
+}
+
+func TestReadOnlyIntentPool(t *testing.T) {
+	loginAck, err := hex.DecodeString(strings.ReplaceAll(testLoginAck, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer := &routingDialer{loginAck: loginAck}
+	pool := func(dsn string) *sql.DB {
+		config, err := msdsn.Parse(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := NewConnectorConfig(config)
+		c.Dialer = dialer
+		db := sql.OpenDB(c)
+		db.SetMaxIdleConns(1)
+		return db
+	}
+	// the read-only statements run on a second pool
+	db := pool("sqlserver://listener?database=reports&encrypt=disable&protocol=tcp&dial timeout=5")
+	defer db.Close()
+	replica := pool("sqlserver://listener?database=reports&encrypt=disable&protocol=tcp&dial timeout=5&applicationintent=ReadOnly")
+	defer replica.Close()
+
+	steps := []struct {
+		db   *sql.DB
+		want []string
+	}{
+		{db, []string{"listener:1433"}},
+		{replica, []string{"listener:1433", "replica:11000"}},
+		// the connections of both pools are reused
+		{replica, []string{}},
+		{db, []string{}},
+	}
+	for i, step := range steps {
+		before := len(dialer.dialed())
+		if _, err = step.db.Exec("select 1"); err != nil {
+			t.Fatal(err)
+		}
+		if got := dialer.dialed()[before:]; !reflect.DeepEqual(got, step.want) {
+			t.Errorf("step %d: expected the dials %v, got %v", i, step.want, got)
+		}
+	}
+}
+
+func TestReadOnlyIntentReplicaDown(t *testing.T) {
+	loginAck, err := hex.DecodeString(strings.ReplaceAll(testLoginAck, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := msdsn.Parse("sqlserver://listener?database=reports&encrypt=disable&protocol=tcp&dial timeout=5&applicationintent=ReadOnly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer := &routingDialer{loginAck: loginAck, down: true}
+	c := NewConnectorConfig(config)
+	c.Dialer = dialer
+	// the listener routes the login to the replica, which can not be reached
+	_, err = c.Connect(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "unable to connect to the replica replica") {
+		t.Errorf("expected an error naming the replica, got %v", err)
+	}
+	if want := []string{"listener:1433", "replica:11000"}; !reflect.DeepEqual(dialer.dialed(), want) {
+		t.Errorf("expected the dials %v, got %v", want, dialer.dialed())
+	}
+	if route := c.getRoute(); route != nil {
+		t.Errorf("expected the route to the replica to be forgotten, got %v", route)
+	}
+}
@@ -0,0 +1,178 @@
+package mssql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// transientErrors are the error numbers Azure SQL returns for conditions
+// that usually go away on their own, such as throttling or a database
+// being moved to another node.
+var transientErrors = map[int32]bool{
+	4060:  true, // cannot open database
+	4221:  true, // login to read-secondary failed due to long wait on HADR_DATABASE_WAIT_FOR_TRANSITION_TO_VERSIONING
+	10928: true, // resource limit reached
+	10929: true, // resource minimum guarantee not available
+	40197: true, // service error processing the request
+	40501: true, // service is busy
+	40613: true, // database is not currently available
+	49918: true, // not enough resources to process the request
+	49919: true, // too many create or update operations in progress
+	49920: true, // too many operations in progress
+}
+
+// IsTransientError reports whether err, or any error it wraps, is an SQL
+// Server error that is known to be transient and so can be retried.
+func IsTransientError(err error) bool {
+	var sqlErr Error
+	if !errors.As(err, &sqlErr) {
+		return false
+	}
+	if transientErrors[sqlErr.Number] {
+		return true
+	}
+	for _, e := range sqlErr.All {
+		if transientErrors[e.Number] {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryPolicy makes statements that fail with a transient error be executed
+// again on the same connection, waiting longer before each attempt.
+//
+// Only use it if the statements run through the connector can safely be
+// executed more than once. Statements are never retried inside a transaction,
+// when the connection was lost or when a parameter is streamed, and the wait
+// never goes past the deadline of the statement context.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a failed statement is executed again.
+	MaxRetries int
+	// MinBackoff is the wait before the first retry, 100ms if it is not
+	// set. It doubles on every following retry, up to MaxBackoff if that
+	// is set.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	return backoff(p.MinBackoff, p.MaxBackoff, attempt)
+}
+
+// defaultMinBackoff is the wait before the first retry when the policy
+// does not set one, retrying at once would only add to the load of a
+// throttled server.
+const defaultMinBackoff = 100 * time.Millisecond
+
+// backoff doubles min, or defaultMinBackoff if min is not set, for each
+// attempt, up to max if that is set.
+func backoff(min, max time.Duration, attempt int) time.Duration {
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max > 0 && min >= max {
+		return max
+	}
+	d := min
+	for i := 0; i < attempt; i++ {
+		d *= 2
+		if max > 0 && d >= max {
+			return max
+		}
+	}
+	return d
+}
+
+// retry calls f until it succeeds or the connector retry policy says to stop.
+func (s *Stmt) retry(ctx context.Context, args []namedValue, f func() error) error {
+	var policy *RetryPolicy
+	if s.c.connector != nil {
+		policy = s.c.connector.RetryPolicy
+	}
+	// the outputs are cleared by every attempt
+	outs := s.c.outs
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || policy == nil || attempt >= policy.MaxRetries {
+			return err
+		}
+		if !s.c.connectionGood || s.c.sess.tranid != 0 || hasStreamedArgs(args) || !IsTransientError(err) {
+			return err
+		}
+		wait := policy.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		s.c.sess.LogF(ctx, msdsn.LogRetries, "Retrying after transient error in %v: %v", wait, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		s.c.outs = outs
+	}
+}
+
+// databaseUnavailableNumber is the error number of a login to a database
+// which is not currently available.
+const databaseUnavailableNumber = 40613
+
+// ResumeRetry makes connections wait for a database which is not currently
+// available, error 40613, as an Azure SQL serverless database is while it
+// resumes from a pause, which can take a minute. The login is tried again,
+// waiting longer before each attempt, until Timeout is reached.
+type ResumeRetry struct {
+	// Timeout is the time given to the database to become available,
+	// measured from the first failed login. The wait never goes past the
+	// deadline of the context passed to Connect.
+	Timeout time.Duration
+	// MinBackoff is the wait before the first retry, 100ms if it is not
+	// set. It doubles on every following retry, up to MaxBackoff if that
+	// is set.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func isDatabaseUnavailable(err error) bool {
+	var sqlErr Error
+	return errors.As(err, &sqlErr) && sqlErr.Number == databaseUnavailableNumber
+}
+
+// connectResume connects to the server, logging in again while the database
+// is not available if the connector has a ResumeRetry.
+func (c *Connector) connectResume(ctx context.Context, params msdsn.Config) (*Conn, error) {
+	conn, err := c.driver.connect(ctx, c, params)
+	r := c.ResumeRetry
+	if r == nil {
+		return conn, err
+	}
+	deadline := time.Now().Add(r.Timeout)
+	for attempt := 0; isDatabaseUnavailable(err); attempt++ {
+		wait := backoff(r.MinBackoff, r.MaxBackoff, attempt)
+		if time.Until(deadline) < wait {
+			return nil, err
+		}
+		if d, ok := ctx.Deadline(); ok && time.Until(d) < wait {
+			return nil, err
+		}
+		if uint64(params.LogFlags)&logRetries != 0 {
+			c.driver.logger.Log(ctx, msdsn.LogRetries, fmt.Sprintf("Connecting again in %v, the database is not available: %v", wait, err))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		conn, err = c.driver.connect(ctx, c, params)
+	}
+	return conn, err
+}
@@ -0,0 +1,230 @@
+package mssql
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func TestIsTransientError(t *testing.T) {
+	t.Parallel()
+	values := []struct {
+		err  error
+		want bool
+	}{
+		{Error{Number: 40613}, true},
+		{Error{Number: 208}, false},
+		{Error{Number: 208, All: []Error{{Number: 40501}, {Number: 208}}}, true},
+		{fmt.Errorf("wrapped: %w", Error{Number: 49918}), true},
+		{ServerError{Error{Number: 40197}}, true},
+		{errors.New("some error"), false},
+		{nil, false},
+	}
+	for _, v := range values {
+		if got := IsTransientError(v.err); got != v.want {
+			t.Errorf("IsTransientError(%v) = %v, want %v", v.err, got, v.want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	t.Parallel()
+	p := RetryPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for attempt, w := range want {
+		if got := p.backoff(attempt); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, w*time.Millisecond)
+		}
+	}
+
+	// without a minimum the retries do not run back to back
+	p = RetryPolicy{MaxBackoff: time.Second}
+	want = []time.Duration{100, 200, 400, 800, 1000}
+	for attempt, w := range want {
+		if got := p.backoff(attempt); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, w*time.Millisecond)
+		}
+	}
+	if got := backoff(0, 50*time.Millisecond, 0); got != 50*time.Millisecond {
+		t.Errorf("expected the default minimum to be limited by the maximum, got %v", got)
+	}
+}
+
+func newRetryTestStmt(policy *RetryPolicy) *Stmt {
+	return &Stmt{c: &Conn{
+		connector:      &Connector{RetryPolicy: policy},
+		sess:           &tdsSession{},
+		connectionGood: true,
+	}}
+}
+
+func TestStmtRetry(t *testing.T) {
+	t.Parallel()
+	transient := Error{Number: 40613, Message: "database is not currently available"}
+
+	s := newRetryTestStmt(&RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond})
+	calls := 0
+	err := s.retry(context.Background(), nil, func() error {
+		calls++
+		if calls < 3 {
+			return transient
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("expected success after 3 calls, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = s.retry(context.Background(), nil, func() error {
+		calls++
+		return transient
+	})
+	if !IsTransientError(err) || calls != 4 {
+		t.Errorf("expected the transient error after 4 calls, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = s.retry(context.Background(), nil, func() error {
+		calls++
+		return Error{Number: 208}
+	})
+	if calls != 1 {
+		t.Errorf("expected no retry for a non transient error, got %d calls", calls)
+	}
+
+	s.c.sess.tranid = 1
+	calls = 0
+	s.retry(context.Background(), nil, func() error {
+		calls++
+		return transient
+	})
+	if calls != 1 {
+		t.Errorf("expected no retry inside a transaction, got %d calls", calls)
+	}
+}
+
+func TestStmtRetryRespectsContext(t *testing.T) {
+	t.Parallel()
+	transient := Error{Number: 40501}
+
+	s := newRetryTestStmt(&RetryPolicy{MaxRetries: 10, MinBackoff: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	calls := 0
+	start := time.Now()
+	err := s.retry(ctx, nil, func() error {
+		calls++
+		return transient
+	})
+	if !IsTransientError(err) || calls != 1 {
+		t.Errorf("expected the transient error after 1 call, got %v after %d calls", err, calls)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("retry waited past the context deadline")
+	}
+
+	s = newRetryTestStmt(&RetryPolicy{MaxRetries: 10, MinBackoff: 10 * time.Millisecond})
+	ctx, cancel = context.WithCancel(context.Background())
+	calls = 0
+	err = s.retry(ctx, nil, func() error {
+		calls++
+		if calls == 2 {
+			cancel()
+		}
+		return transient
+	})
+	if !IsTransientError(err) || calls != 2 {
+		t.Errorf("expected retries to stop once the context is canceled, got %v after %d calls", err, calls)
+	}
+}
+
+func TestStmtRetryWithoutPolicy(t *testing.T) {
+	t.Parallel()
+	s := newRetryTestStmt(nil)
+	calls := 0
+	s.retry(context.Background(), nil, func() error {
+		calls++
+		return Error{Number: 40613}
+	})
+	if calls != 1 {
+		t.Errorf("expected no retry without a policy, got %d calls", calls)
+	}
+}
+
+// resumingDialer answers the first logins with error 40613, as a paused
+// database does while it resumes.
+type resumingDialer struct {
+	loginAck []byte
+	failures int32
+	dials    int32
+}
+
+func (d *resumingDialer) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	server, client := net.Pipe()
+	reply := d.loginAck
+	if atomic.AddInt32(&d.dials, 1) <= d.failures {
+		reply = infoToken(14, "Database 'db' on server 'server' is not currently available.")
+		reply[0] = byte(tokenError)
+		binary.LittleEndian.PutUint32(reply[3:], databaseUnavailableNumber)
+		reply = append(reply, byte(tokenDone), doneError, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	}
+	go serveStatements(server, reply)
+	return client, nil
+}
+
+func TestConnectResumeRetry(t *testing.T) {
+	loginAck, err := hex.DecodeString(strings.ReplaceAll(testLoginAck, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := msdsn.Parse("sqlserver://localhost?encrypt=disable&protocol=tcp&dial timeout=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	dialer := &resumingDialer{loginAck: loginAck, failures: 1}
+	c := NewConnectorConfig(config)
+	c.Dialer = dialer
+	c.ResumeRetry = &ResumeRetry{Timeout: time.Second, MinBackoff: time.Millisecond}
+	conn, err := c.Connect(ctx)
+	if err != nil {
+		t.Fatalf("expected the login to be retried once the database resumed, got %v", err)
+	}
+	conn.Close()
+	if n := atomic.LoadInt32(&dialer.dials); n != 2 {
+		t.Errorf("expected a second login, got %d dials", n)
+	}
+
+	// the database does not resume before the timeout
+	dialer = &resumingDialer{loginAck: loginAck, failures: 1000}
+	c.Dialer = dialer
+	c.ResumeRetry = &ResumeRetry{Timeout: 50 * time.Millisecond, MinBackoff: 10 * time.Millisecond}
+	if _, err = c.Connect(ctx); !isDatabaseUnavailable(err) {
+		t.Errorf("expected error 40613 after the timeout, got %v", err)
+	}
+	if n := atomic.LoadInt32(&dialer.dials); n < 2 || n > 4 {
+		t.Errorf("expected the logins to stop at the timeout, got %d dials", n)
+	}
+
+	// without ResumeRetry the error is returned at once
+	dialer = &resumingDialer{loginAck: loginAck, failures: 1}
+	c.Dialer = dialer
+	c.ResumeRetry = nil
+	if _, err = c.Connect(ctx); !isDatabaseUnavailable(err) {
+		t.Errorf("expected error 40613, got %v", err)
+	}
+	if n := atomic.LoadInt32(&dialer.dials); n != 1 {
+		t.Errorf("expected a single login, got %d dials", n)
+	}
+}
@@ -0,0 +1,48 @@
+//go:build go1.23
+// +build go1.23
+
+package mssql
+
+import (
+	"database/sql"
+	"iter"
+)
+
+// Iter returns an iterator over the rows, each of them converted by scan,
+// so that the rows of a query can be read with a range loop:
+//
+//	rows, err := db.QueryContext(ctx, "select id, name from products")
+//	...
+//	for p, err := range mssql.Iter(rows, func(rows *sql.Rows) (p product, err error) {
+//		err = rows.Scan(&p.ID, &p.Name)
+//		return
+//	}) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// The rows are closed when the loop ends, also when it exits early. An
+// error of scan, or of the rows once they are all read, is yielded with the
+// zero value of T and ends the iteration.
+func Iter[T any](rows *sql.Rows, scan func(*sql.Rows) (T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			v, err := scan(rows)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
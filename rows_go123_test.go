@@ -0,0 +1,136 @@
+//go:build go1.23
+// +build go1.23
+
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// scriptedConnector connects to the scripted transport.
+type scriptedConnector struct {
+	transport *scriptedTransport
+}
+
+func (c scriptedConnector) Connect(context.Context) (driver.Conn, error) {
+	return testConn(newConnector(msdsn.Config{}, nil), c.transport), nil
+}
+
+func (c scriptedConnector) Driver() driver.Driver {
+	return &Driver{}
+}
+
+// queryNumbers returns the rows 1 to count of a scripted query.
+func queryNumbers(t *testing.T, count int32) *sql.Rows {
+	transport := &scriptedTransport{}
+	tokens := [][]byte{append([]byte{byte(tokenColMetadata), 1, 0}, cursorIntColumn("n", colFlagNullable)...)}
+	for n := int32(1); n <= count; n++ {
+		tokens = append(tokens, cursorRow(n))
+	}
+	tokens = append(tokens, []byte{byte(tokenDone), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	transport.reply(tokens...)
+	db := sql.OpenDB(scriptedConnector{transport})
+	t.Cleanup(func() { db.Close() })
+	rows, err := db.Query("select n from numbers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func scanNumber(rows *sql.Rows) (n int64, err error) {
+	err = rows.Scan(&n)
+	return
+}
+
+func TestIter(t *testing.T) {
+	var got []int64
+	for n, err := range Iter(queryNumbers(t, 3), scanNumber) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, n)
+	}
+	if !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Errorf("expected all the rows, got %v", got)
+	}
+}
+
+func TestIterBreak(t *testing.T) {
+	rows := queryNumbers(t, 3)
+	for n, err := range Iter(rows, scanNumber) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("expected the first row, got %d", n)
+		}
+		break
+	}
+	if rows.Next() {
+		t.Error("expected the rows to be closed when the loop exits early")
+	}
+	if err := rows.Err(); err != nil {
+		t.Errorf("expected no error after closing the rows, got %v", err)
+	}
+}
+
+func TestIterScanError(t *testing.T) {
+	scanErr := errors.New("scan failed")
+	rows := queryNumbers(t, 3)
+	var got []int64
+	var errs []error
+	for n, err := range Iter(rows, func(rows *sql.Rows) (int64, error) {
+		n, err := scanNumber(rows)
+		if err == nil && n == 2 {
+			err = scanErr
+		}
+		return n, err
+	}) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		got = append(got, n)
+	}
+	if !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("expected the rows before the error, got %v", got)
+	}
+	if len(errs) != 1 || errs[0] != scanErr {
+		t.Errorf("expected the scan error to end the iteration, got %v", errs)
+	}
+	if rows.Next() {
+		t.Error("expected the rows to be closed after the scan error")
+	}
+}
+
+func TestIterQuery(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	rows, err := conn.Query("select n from (values (1), (2), (3)) t(n) order by n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum int64
+	for n, err := range Iter(rows, scanNumber) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum += n
+	}
+	if sum != 6 {
+		t.Errorf("expected the sum 6, got %d", sum)
+	}
+	if stats := conn.Stats(); stats.InUse != 0 {
+		t.Errorf("expected the connection to be released, %d in use", stats.InUse)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build go1.27
+// +build go1.27
+
+package mssql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"time"
+)
+
+// NextRow implements driver.RowsColumnScanner, it reads the values of the
+// next row for ScanColumn.
+func (rc *Rows) NextRow() error {
+	if len(rc.row) != len(rc.cols) {
+		rc.row = make([]driver.Value, len(rc.cols))
+	}
+	return rc.Next(rc.row)
+}
+
+// ScanColumn implements driver.RowsColumnScanner. A TIME(n) column scanned
+// into a TimeOfDay has the scale n of the column, so that it is sent back as
+// a time(n) parameter. The other values are converted as database/sql does.
+func (rc *Rows) ScanColumn(scanCtx driver.ScanContext, index int, dest any) error {
+	v := rc.row[index]
+	if t, ok := dest.(*TimeOfDay); ok {
+		ti := rc.cols[index].originalTypeInfo()
+		if vt, ok := v.(time.Time); ok && ti.TypeId == typeTimeN {
+			if err := t.Scan(vt); err != nil {
+				return err
+			}
+			t.Scale = ti.Scale
+			return nil
+		}
+	}
+	return sql.ConvertAssign(scanCtx, dest, v)
+}
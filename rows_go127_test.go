@@ -0,0 +1,48 @@
+//go:build go1.27
+// +build go1.27
+
+package mssql
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestScanTimeOfDayScale(t *testing.T) {
+	d := 9*time.Hour + 15*time.Minute + 1234*time.Millisecond
+	column := func(name string) []byte {
+		b := []byte{0, 0, 0, 0, byte(colFlagNullable), 0, typeTimeN, 3, byte(len(name))}
+		return append(b, str2ucs2(name)...)
+	}
+	value := []byte{4, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(value[1:], uint32(d/time.Millisecond))
+	metadata := append([]byte{byte(tokenColMetadata), 2, 0}, column("opens")...)
+	metadata = append(metadata, column("closes")...)
+	row := append(append([]byte{byte(tokenRow)}, value...), value...)
+	transport := &scriptedTransport{}
+	transport.reply(metadata, row, []byte{byte(tokenDone), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	db := sql.OpenDB(scriptedConnector{transport})
+	defer db.Close()
+
+	var tod TimeOfDay
+	var tm time.Time
+	if err := db.QueryRow("select opens, closes from shops").Scan(&tod, &tm); err != nil {
+		t.Fatal(err)
+	}
+	if tod.Duration != d || tod.Scale != 3 {
+		t.Errorf("expected %v with the scale 3 of the column, got %v with the scale %d", d, tod.Duration, tod.Scale)
+	}
+	if got := tm.Sub(time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)); got != d {
+		t.Errorf("expected the time.Time at %v, got %v", d, tm)
+	}
+	// the scanned value is sent back as a time(3) parameter
+	p, err := (&Stmt{}).makeParam(tod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decl := makeDecl(p.ti); decl != "time(3)" {
+		t.Errorf("expected time(3), got %s", decl)
+	}
+}
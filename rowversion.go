@@ -0,0 +1,47 @@
+package mssql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// RowVersion is a ROWVERSION (TIMESTAMP) value. The server changes the
+// rowversion of a row each time the row is updated, so a row read with its
+// rowversion can be updated only if nobody changed it in the meantime:
+//
+//	var rv mssql.RowVersion
+//	err = db.QueryRow("select name, rv from products where id = @p1", id).Scan(&name, &rv)
+//	...
+//	res, err := db.Exec("update products set name = @p1 where id = @p2 and rv = @p3", name, id, rv)
+//
+// The update affects no row when the rowversion changed. A RowVersion is
+// sent as a binary(8) parameter.
+type RowVersion [8]byte
+
+// Scan implements sql.Scanner.
+func (rv *RowVersion) Scan(v interface{}) error {
+	switch vt := v.(type) {
+	case []byte:
+		if len(vt) != len(rv) {
+			return fmt.Errorf("mssql: invalid RowVersion length %d, expected %d bytes", len(vt), len(rv))
+		}
+		copy(rv[:], vt)
+		return nil
+	case nil:
+		return errors.New("mssql: cannot scan NULL into RowVersion")
+	default:
+		return fmt.Errorf("mssql: cannot convert %T to RowVersion", v)
+	}
+}
+
+// Value implements driver.Valuer.
+func (rv RowVersion) Value() (driver.Value, error) {
+	return rv[:], nil
+}
+
+// String returns the rowversion in hexadecimal, as SQL Server shows it,
+// e.g. "0x00000000000007D1".
+func (rv RowVersion) String() string {
+	return fmt.Sprintf("0x%X", rv[:])
+}
@@ -0,0 +1,101 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func TestRowVersionScan(t *testing.T) {
+	t.Parallel()
+	var rv RowVersion
+	if err := rv.Scan([]byte{0, 0, 0, 0, 0, 0, 0x07, 0xd1}); err != nil {
+		t.Fatal(err)
+	}
+	if rv != (RowVersion{0, 0, 0, 0, 0, 0, 0x07, 0xd1}) {
+		t.Errorf("unexpected rowversion %v", rv)
+	}
+	if s := rv.String(); s != "0x00000000000007D1" {
+		t.Errorf("expected 0x00000000000007D1, got %s", s)
+	}
+	for _, in := range []interface{}{nil, []byte{1, 2, 3}, "0x00000000000007D1", int64(1)} {
+		if err := rv.Scan(in); err == nil {
+			t.Errorf("expected an error for Scan(%v)", in)
+		}
+	}
+}
+
+func TestRowVersionParamTypeInfo(t *testing.T) {
+	t.Parallel()
+	rv := RowVersion{1, 2, 3, 4, 5, 6, 7, 8}
+	p, err := (&Stmt{}).makeParam(rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = writeTypeInfo(&buf, &p.ti, false, msdsn.EncodeParameters{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{typeBigBinary, 8, 0}; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("expected type info % x, got % x", want, buf.Bytes())
+	}
+	if decl := makeDecl(p.ti); decl != "binary(8)" {
+		t.Errorf("expected binary(8), got %s", decl)
+	}
+	if !bytes.Equal(p.buffer, rv[:]) {
+		t.Errorf("expected % x, got % x", rv[:], p.buffer)
+	}
+}
+
+func TestRowVersionRoundTrip(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	// the temporary table belongs to the connection
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "create table #products (id int primary key, name nvarchar(20), rv rowversion)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.ExecContext(ctx, "insert into #products (id, name) values (1, N'widget')"); err != nil {
+		t.Fatal(err)
+	}
+	var rv RowVersion
+	if err = conn.QueryRowContext(ctx, "select rv from #products where id = 1").Scan(&rv); err != nil {
+		t.Fatal(err)
+	}
+
+	update := "update #products set name = @p1 where id = 1 and rv = @p2"
+	res, err := conn.ExecContext(ctx, update, "gadget", rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Fatalf("expected the update with the current rowversion to affect 1 row, got %d", n)
+	}
+	var changed RowVersion
+	if err = conn.QueryRowContext(ctx, "select rv from #products where id = 1").Scan(&changed); err != nil {
+		t.Fatal(err)
+	}
+	if changed == rv {
+		t.Errorf("expected the rowversion %v to change", rv)
+	}
+
+	// the row changed since rv was read
+	res, err = conn.ExecContext(ctx, update, "gizmo", rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 0 {
+		t.Errorf("expected the update with a stale rowversion to affect no row, got %d", n)
+	}
+}
@@ -2,6 +2,7 @@ package mssql
 
 import (
 	"encoding/binary"
+	"io"
 
 	"github.com/microsoft/go-mssqldb/msdsn"
 )
@@ -25,6 +26,9 @@ type param struct {
 	buffer     []byte
 	tiOriginal typeInfo
 	cipherInfo []byte
+	// stream, when set, writes the value to the request instead of buffer
+	// so that it does not have to be held in memory.
+	stream func(w io.Writer) error
 }
 
 var (
@@ -39,15 +43,51 @@ var (
 	sp_CursorClose     = procId{9, ""}
 	sp_ExecuteSql      = procId{10, ""}
 	sp_Prepare         = procId{11, ""}
+	sp_Execute         = procId{12, ""}
 	sp_PrepExec        = procId{13, ""}
 	sp_PrepExecRpc     = procId{14, ""}
 	sp_Unprepare       = procId{15, ""}
 )
 
+// rpcBatchFlag separates the calls of a request with several RPC calls
+const rpcBatchFlag = 0xff
+
+// rpcCall is a single call of a request with several RPC calls
+type rpcCall struct {
+	proc   procId
+	flags  uint16
+	params []param
+}
+
 // http://msdn.microsoft.com/en-us/library/dd357576.aspx
 func sendRpc(buf *tdsBuffer, headers []headerStruct, proc procId, flags uint16, params []param, resetSession bool, encoding msdsn.EncodeParameters) (err error) {
 	buf.BeginPacket(packRPCRequest, resetSession)
 	writeAllHeaders(buf, headers)
+	if err = writeRpc(buf, proc, flags, params, encoding); err != nil {
+		return
+	}
+	return buf.FinishPacket()
+}
+
+// sendRpcBatch sends the calls in a single request, the server
+// answers each of them with a DONEPROC token.
+func sendRpcBatch(buf *tdsBuffer, headers []headerStruct, calls []rpcCall, resetSession bool, encoding msdsn.EncodeParameters) (err error) {
+	buf.BeginPacket(packRPCRequest, resetSession)
+	writeAllHeaders(buf, headers)
+	for i, call := range calls {
+		if i > 0 {
+			if err = buf.WriteByte(rpcBatchFlag); err != nil {
+				return
+			}
+		}
+		if err = writeRpc(buf, call.proc, call.flags, call.params, encoding); err != nil {
+			return
+		}
+	}
+	return buf.FinishPacket()
+}
+
+func writeRpc(buf *tdsBuffer, proc procId, flags uint16, params []param, encoding msdsn.EncodeParameters) (err error) {
 	if len(proc.name) == 0 {
 		var idswitch uint16 = 0xffff
 		err = binary.Write(buf, binary.LittleEndian, &idswitch)
@@ -79,7 +119,11 @@ func sendRpc(buf *tdsBuffer, headers []headerStruct, proc procId, flags uint16,
 		if err != nil {
 			return
 		}
-		err = param.ti.Writer(buf, param.ti, param.buffer)
+		if param.stream != nil {
+			err = param.stream(buf)
+		} else {
+			err = param.ti.Writer(buf, param.ti, param.buffer)
+		}
 		if err != nil {
 			return
 		}
@@ -93,5 +137,5 @@ func sendRpc(buf *tdsBuffer, headers []headerStruct, proc procId, flags uint16,
 			}
 		}
 	}
-	return buf.FinishPacket()
+	return
 }
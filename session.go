@@ -73,6 +73,13 @@ func (s *tdsSession) preparePreloginFields(ctx context.Context, p msdsn.Config,
 	return fields
 }
 
+// beforeTDS72 tells whether the server acknowledged a TDS version older
+// than 7.2 at login, such servers send 32-bit row counts and 16-bit user
+// types. The driver requests TDS 7.4 and uses the version of the server.
+func (s *tdsSession) beforeTDS72() bool {
+	return s.loginAck.TDSVersion != 0 && s.loginAck.TDSVersion < verTDS72
+}
+
 type logFunc func() string
 
 func (s *tdsSession) logPrefix() string {
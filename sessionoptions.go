@@ -0,0 +1,93 @@
+package mssql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sessionSettingRE matches the names and the values of
+// Connector.SessionSettings, such as ANSI_NULLS, ON or -1.
+var sessionSettingRE = regexp.MustCompile(`^-?[A-Za-z0-9_]+( [A-Za-z0-9_]+)*$`)
+
+// sessionSettingsSQL returns the SET statements of Connector.SessionSettings,
+// by name order.
+func sessionSettingsSQL(settings map[string]string) (string, error) {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		value := settings[name]
+		if !sessionSettingRE.MatchString(name) || !sessionSettingRE.MatchString(value) {
+			return "", fmt.Errorf("mssql: invalid session setting %q %q", name, value)
+		}
+		fmt.Fprintf(&b, "SET %s %s;\n", name, value)
+	}
+	return b.String(), nil
+}
+
+// sessionSQL returns the statements run on a connection after the login
+// and after each session reset: the SessionSettings, the limits of MaxRows
+// and QueryGovernorCostLimit and SessionInitSQL.
+func (c *Connector) sessionSQL() (string, error) {
+	query, err := sessionSettingsSQL(c.SessionSettings)
+	if err != nil {
+		return "", err
+	}
+	if c.MaxRows > 0 {
+		query += fmt.Sprintf("SET ROWCOUNT %d;\n", c.MaxRows)
+	}
+	if c.QueryGovernorCostLimit > 0 {
+		query += fmt.Sprintf("SET QUERY_GOVERNOR_COST_LIMIT %d;\n", c.QueryGovernorCostLimit)
+	}
+	return query + c.SessionInitSQL, nil
+}
+
+// SetLockTimeout runs SET LOCK_TIMEOUT on the connection, so that its
+// statements wait at most d for a lock before failing with the error 1222,
+// returned as an Error. A negative d waits without limit, the default, and
+// zero does not wait at all. The timeout is rounded down to milliseconds.
+//
+// The setting applies to the connection, not to a statement, use it on a
+// *sql.Conn through Raw and run the statements with the same *sql.Conn:
+//
+//	err = conn.Raw(func(driverConn interface{}) error {
+//		return driverConn.(*mssql.Conn).SetLockTimeout(ctx, 5*time.Second)
+//	})
+//
+// The session is reset when the connection is next taken from the pool,
+// which restores the default settings.
+func (c *Conn) SetLockTimeout(ctx context.Context, d time.Duration) error {
+	ms := int64(-1)
+	if d >= 0 {
+		ms = int64(d / time.Millisecond)
+	}
+	return c.execSet(ctx, fmt.Sprintf("SET LOCK_TIMEOUT %d", ms))
+}
+
+// SetDeadlockPriority runs SET DEADLOCK_PRIORITY on the connection with a
+// level from -10 to 10, the session with the lowest priority is chosen as
+// the deadlock victim. LOW, NORMAL and HIGH are the levels -5, 0 and 5.
+// As with SetLockTimeout, the setting applies to the connection.
+func (c *Conn) SetDeadlockPriority(ctx context.Context, level int) error {
+	if level < -10 || level > 10 {
+		return fmt.Errorf("mssql: deadlock priority must be between -10 and 10, got %d", level)
+	}
+	return c.execSet(ctx, fmt.Sprintf("SET DEADLOCK_PRIORITY %d", level))
+}
+
+func (c *Conn) execSet(ctx context.Context, query string) error {
+	if !c.connectionGood {
+		return driver.ErrBadConn
+	}
+	stmt := &Stmt{c, query, 0, nil, true}
+	_, err := stmt.ExecContext(ctx, nil)
+	return err
+}
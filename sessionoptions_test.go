@@ -0,0 +1,231 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// batches returns the text of the SQL batches written to the transport,
+// each of them being a single packet.
+func (t *scriptedTransport) batches() (texts []string) {
+	b := t.requests.Bytes()
+	for len(b) > 0 {
+		size := binary.BigEndian.Uint16(b[2:])
+		data := b[8:size]
+		text, _ := ucs22str(data[binary.LittleEndian.Uint32(data):])
+		texts = append(texts, text)
+		b = b[size:]
+	}
+	return
+}
+
+func TestSetSessionOptions(t *testing.T) {
+	transport := &scriptedTransport{}
+	for i := 0; i < 5; i++ {
+		transport.reply(cursorDoneProc())
+	}
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	ctx := context.Background()
+	for _, d := range []time.Duration{1500*time.Millisecond + time.Microsecond, 0, -1} {
+		if err := c.SetLockTimeout(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, level := range []int{-10, 10} {
+		if err := c.SetDeadlockPriority(ctx, level); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.SetDeadlockPriority(ctx, 11); err == nil {
+		t.Error("expected an error for a priority out of range")
+	}
+	want := []string{"SET LOCK_TIMEOUT 1500", "SET LOCK_TIMEOUT 0", "SET LOCK_TIMEOUT -1", "SET DEADLOCK_PRIORITY -10", "SET DEADLOCK_PRIORITY 10"}
+	if got := transport.batches(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the batches %q, got %q", want, got)
+	}
+}
+
+func TestSessionSettings(t *testing.T) {
+	transport := &scriptedTransport{}
+	transport.reply(cursorDoneProc())
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	c.connector.SessionSettings = map[string]string{"QUOTED_IDENTIFIER": "ON", "ARITHABORT": "OFF", "LOCK_TIMEOUT": "-1"}
+	c.connector.SessionInitSQL = "SET XACT_ABORT ON"
+	if err := c.ResetSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"SET ARITHABORT OFF;\nSET LOCK_TIMEOUT -1;\nSET QUOTED_IDENTIFIER ON;\nSET XACT_ABORT ON"}
+	if got := transport.batches(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the batches %q, got %q", want, got)
+	}
+
+	for _, settings := range []map[string]string{
+		{"ARITHABORT": "ON; DROP TABLE t"},
+		{"ARITHABORT ON;--": "ON"},
+		{"DATEFORMAT": ""},
+	} {
+		c.connector.SessionSettings = settings
+		if err := c.ResetSession(context.Background()); err == nil {
+			t.Errorf("expected an error for the settings %q", settings)
+		}
+	}
+}
+
+func TestSessionLimits(t *testing.T) {
+	transport := &scriptedTransport{}
+	transport.reply(cursorDoneProc())
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	c.connector.SessionSettings = map[string]string{"ARITHABORT": "ON"}
+	c.connector.MaxRows = 100
+	c.connector.QueryGovernorCostLimit = 300
+	c.connector.SessionInitSQL = "SET XACT_ABORT ON"
+	if err := c.ResetSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"SET ARITHABORT ON;\nSET ROWCOUNT 100;\nSET QUERY_GOVERNOR_COST_LIMIT 300;\nSET XACT_ABORT ON"}
+	if got := transport.batches(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the batches %q, got %q", want, got)
+	}
+}
+
+func TestSessionMaxRowsQuery(t *testing.T) {
+	checkConnStr(t)
+	connector, err := NewConnector(makeConnStr(t).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	connector.MaxRows = 5
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	// on the new connection, then on the connection reset when reused
+	for round := 0; round < 2; round++ {
+		rows, err := db.QueryContext(ctx, "select top 20 object_id from sys.all_objects")
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for rows.Next() {
+			count++
+		}
+		if err = rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+		if count != 5 {
+			t.Errorf("round %d: expected at most 5 rows, got %d", round, count)
+		}
+		// lifted on the connection, the limit is restored on reset
+		if _, err = db.ExecContext(ctx, "set rowcount 0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSessionSettingsQuery(t *testing.T) {
+	checkConnStr(t)
+	connector, err := NewConnector(makeConnStr(t).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	connector.SessionSettings = map[string]string{"ARITHABORT": "OFF", "QUOTED_IDENTIFIER": "OFF"}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	// the settings hold on new connections and on the connections reset
+	// when taken from the pool again
+	for round := 0; round < 2; round++ {
+		conns := make([]*sql.Conn, 3)
+		for i := range conns {
+			if conns[i], err = db.Conn(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i, conn := range conns {
+			var arithAbort, quotedIdentifier int
+			err = conn.QueryRowContext(ctx, "select cast(sessionproperty('ARITHABORT') as int), cast(sessionproperty('QUOTED_IDENTIFIER') as int)").Scan(&arithAbort, &quotedIdentifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if arithAbort != 0 || quotedIdentifier != 0 {
+				t.Errorf("round %d, connection %d: expected ARITHABORT and QUOTED_IDENTIFIER off, got %d and %d", round, i, arithAbort, quotedIdentifier)
+			}
+			// changed on the connection, the settings are restored on reset
+			if _, err = conn.ExecContext(ctx, "set arithabort on"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	db.Exec("drop table if exists ##lock_timeout_test")
+	if _, err := db.Exec("create table ##lock_timeout_test (id int primary key); insert into ##lock_timeout_test values (1)"); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("drop table ##lock_timeout_test")
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec("update ##lock_timeout_test set id = 2"); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	err = conn.Raw(func(driverConn interface{}) error {
+		c := driverConn.(*Conn)
+		if err := c.SetDeadlockPriority(ctx, -5); err != nil {
+			return err
+		}
+		return c.SetLockTimeout(ctx, 200*time.Millisecond)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var timeout, priority int
+	if err = conn.QueryRowContext(ctx, "select @@lock_timeout, (select deadlock_priority from sys.dm_exec_sessions where session_id = @@spid)").Scan(&timeout, &priority); err != nil {
+		t.Fatal(err)
+	}
+	if timeout != 200 || priority != -5 {
+		t.Errorf("expected the lock timeout 200 and the priority -5, got %d and %d", timeout, priority)
+	}
+
+	start := time.Now()
+	var id int
+	err = conn.QueryRowContext(ctx, "select id from ##lock_timeout_test").Scan(&id)
+	var sqlErr Error
+	if !errors.As(err, &sqlErr) || sqlErr.Number != 1222 {
+		t.Fatalf("expected the lock timeout error 1222, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the statement to fail after the lock timeout, it took %v", elapsed)
+	}
+	if err = conn.PingContext(ctx); err != nil {
+		t.Errorf("expected the connection to stay usable, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package mssql
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// slowQuery measures a statement for Connector.SlowQueryCallback.
+type slowQuery struct {
+	connector *Connector
+	query     string
+	args      []namedValue
+	start     time.Time
+}
+
+// startSlowQuery starts measuring the statement, it returns nil when the
+// connector has no SlowQueryCallback.
+func (c *Conn) startSlowQuery(query string, args []namedValue) *slowQuery {
+	if c.connector == nil || c.connector.SlowQueryCallback == nil {
+		return nil
+	}
+	return &slowQuery{connector: c.connector, query: query, args: args, start: time.Now()}
+}
+
+// done reports the statement when it took longer than the threshold. It
+// does nothing on a nil slowQuery, or once the statement was reported.
+func (q *slowQuery) done() {
+	if q == nil || q.connector == nil {
+		return
+	}
+	connector := q.connector
+	q.connector = nil
+	dur := time.Since(q.start)
+	if dur <= connector.SlowQueryThreshold {
+		return
+	}
+	args := make([]driver.NamedValue, len(q.args))
+	for i, a := range q.args {
+		args[i] = driver.NamedValue{Name: a.Name, Ordinal: a.Ordinal, Value: a.Value}
+		if connector.RedactSlowQueryArgs {
+			args[i].Value = nil
+		}
+	}
+	connector.SlowQueryCallback(q.query, args, dur)
+}
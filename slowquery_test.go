@@ -0,0 +1,146 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// delayedTransport waits before answering the first read, as a server
+// taking time to run the statement.
+type delayedTransport struct {
+	*scriptedTransport
+	delay time.Duration
+}
+
+func (t *delayedTransport) Read(p []byte) (int, error) {
+	time.Sleep(t.delay)
+	t.delay = 0
+	return t.scriptedTransport.Read(p)
+}
+
+type slowQueryCall struct {
+	sql  string
+	args []driver.NamedValue
+	dur  time.Duration
+}
+
+func slowQueryConn(transport io.ReadWriteCloser, threshold time.Duration, redact bool, calls *[]slowQueryCall) *Conn {
+	connector := newConnector(msdsn.Config{}, nil)
+	connector.SlowQueryThreshold = threshold
+	connector.RedactSlowQueryArgs = redact
+	connector.SlowQueryCallback = func(sql string, args []driver.NamedValue, dur time.Duration) {
+		*calls = append(*calls, slowQueryCall{sql, args, dur})
+	}
+	return testConn(connector, transport)
+}
+
+func TestSlowQueryCallback(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	done := []byte{byte(tokenDone), doneCount, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0}
+	args := []namedValue{{Name: "id", Ordinal: 1, Value: int64(5)}}
+	ctx := context.Background()
+
+	for _, redact := range []bool{false, true} {
+		transport := &delayedTransport{&scriptedTransport{}, delay}
+		transport.reply(done)
+		var calls []slowQueryCall
+		c := slowQueryConn(transport, delay/2, redact, &calls)
+		if _, err := (&Stmt{c: c, query: "update t set n = 1 where id = @id"}).exec(ctx, args); err != nil {
+			t.Fatal(err)
+		}
+		if len(calls) != 1 {
+			t.Fatalf("expected the slow statement to be reported once, got %v", calls)
+		}
+		want := []driver.NamedValue{{Name: "id", Ordinal: 1, Value: int64(5)}}
+		if redact {
+			want[0].Value = nil
+		}
+		if calls[0].sql != "update t set n = 1 where id = @id" || !reflect.DeepEqual(calls[0].args, want) {
+			t.Errorf("expected the statement and the arguments %v, got %+v", want, calls[0])
+		}
+		if calls[0].dur < delay {
+			t.Errorf("expected a duration of at least %v, got %v", delay, calls[0].dur)
+		}
+	}
+
+	// statements faster than the threshold are not reported
+	transport := &scriptedTransport{}
+	transport.reply(done)
+	var calls []slowQueryCall
+	c := slowQueryConn(transport, time.Hour, false, &calls)
+	if _, err := (&Stmt{c: c, query: "update t set n = 1"}).exec(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("expected a fast statement not to be reported, got %v", calls)
+	}
+}
+
+func TestSlowQueryCallbackRows(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	transport := &delayedTransport{&scriptedTransport{}, delay}
+	metadata := append([]byte{byte(tokenColMetadata), 1, 0}, cursorIntColumn("n", colFlagNullable)...)
+	transport.reply(metadata, cursorRow(1), cursorRow(2), []byte{byte(tokenDone), doneCount, 0, 0xc1, 0, 2, 0, 0, 0, 0, 0, 0, 0})
+	var calls []slowQueryCall
+	c := slowQueryConn(transport, delay/2, false, &calls)
+	rows, err := (&Stmt{c: c, query: "select n from numbers"}).queryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("expected the statement to be reported once its rows are read, got %v", calls)
+	}
+	dest := make([]driver.Value, 1)
+	for err == nil {
+		err = rows.Next(dest)
+	}
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	if err = rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0].sql != "select n from numbers" {
+		t.Errorf("expected the query to be reported once, got %v", calls)
+	}
+}
+
+func TestSlowQueryCallbackQuery(t *testing.T) {
+	checkConnStr(t)
+	connector, err := NewConnector(makeConnStr(t).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := make(chan slowQueryCall, 10)
+	connector.SlowQueryThreshold = 100 * time.Millisecond
+	connector.SlowQueryCallback = func(sql string, args []driver.NamedValue, dur time.Duration) {
+		calls <- slowQueryCall{sql, args, dur}
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	if _, err = db.Exec("select @p1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec("waitfor delay '00:00:00.300'"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case call := <-calls:
+		if call.sql != "waitfor delay '00:00:00.300'" || call.dur < 300*time.Millisecond {
+			t.Errorf("expected the waitfor to be reported, got %+v", call)
+		}
+	default:
+		t.Fatal("expected the slow statement to be reported")
+	}
+	if len(calls) != 0 {
+		t.Errorf("expected only the slow statement to be reported, got %+v", <-calls)
+	}
+}
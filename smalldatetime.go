@@ -0,0 +1,39 @@
+package mssql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SmallDateTime encodes parameters to the SmallDateTime SQL type, the
+// clock time in its own location rounded to the nearest minute as SQL
+// Server does: 29.999 seconds are rounded down and 30 seconds up. Times
+// out of the range of the type, 1900-01-01 to 2079-06-06 23:59, are sent
+// as the closest limit. It scans date and time values rounded the same way.
+type SmallDateTime time.Time
+
+// Value implements driver.Valuer and returns the time rounded to the minute.
+func (d SmallDateTime) Value() (driver.Value, error) {
+	return roundSmallDateTime(time.Time(d)), nil
+}
+
+// Scan implements sql.Scanner.
+func (d *SmallDateTime) Scan(v interface{}) error {
+	switch vt := v.(type) {
+	case time.Time:
+		*d = SmallDateTime(roundSmallDateTime(vt))
+		return nil
+	case nil:
+		return errors.New("mssql: cannot scan NULL into SmallDateTime")
+	default:
+		return fmt.Errorf("mssql: cannot convert %T to SmallDateTime", v)
+	}
+}
+
+// roundSmallDateTime returns the smalldatetime value of t in its location.
+func roundSmallDateTime(t time.Time) time.Time {
+	r := decodeDateTim4(encodeDateTim4(t))
+	return time.Date(r.Year(), r.Month(), r.Day(), r.Hour(), r.Minute(), 0, 0, t.Location())
+}
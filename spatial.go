@@ -0,0 +1,345 @@
+package mssql
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SpatialType is the kind of shape held by a SpatialValue.
+type SpatialType uint8
+
+const (
+	SpatialPoint      SpatialType = 1
+	SpatialLineString SpatialType = 2
+	SpatialPolygon    SpatialType = 3
+)
+
+func (t SpatialType) String() string {
+	switch t {
+	case SpatialPoint:
+		return "POINT"
+	case SpatialLineString:
+		return "LINESTRING"
+	case SpatialPolygon:
+		return "POLYGON"
+	}
+	return fmt.Sprintf("SpatialType(%d)", uint8(t))
+}
+
+// SpatialCoord is a point of a SpatialValue. For GEOGRAPHY values X is
+// the longitude and Y the latitude.
+type SpatialCoord struct {
+	X, Y float64
+}
+
+// SpatialValue is a GEOGRAPHY or GEOMETRY value holding a point,
+// a linestring or a polygon. It is scanned from and sent as the binary
+// serialization format of SQL Server, see
+// https://learn.microsoft.com/en-us/openspecs/sql_server_protocols/ms-ssclrt.
+//
+// The format is the same for both types except for the order of the
+// coordinates, set Geography before scanning a GEOGRAPHY column:
+//
+//	v := mssql.SpatialValue{Geography: true}
+//	err := db.QueryRow("select location from places").Scan(&v)
+//
+// Z and M values are ignored when scanning.
+type SpatialValue struct {
+	Geography bool
+	SRID      int32
+	Type      SpatialType
+	// Figures holds a single figure for a point or a linestring and the
+	// exterior ring followed by the interior rings for a polygon.
+	// An empty value has no figures.
+	Figures [][]SpatialCoord
+}
+
+const (
+	spatialVersion = 1
+
+	spatialValid       = 0x04
+	spatialSinglePoint = 0x08
+	spatialSingleLine  = 0x10
+	spatialHasZ        = 0x01
+	spatialHasM        = 0x02
+
+	spatialFigureInteriorRing = 0
+	spatialFigureStroke       = 1
+	spatialFigureExteriorRing = 2
+)
+
+// Scan decodes the binary serialization format of SQL Server.
+func (v *SpatialValue) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("mssql: cannot convert %T to SpatialValue", src)
+	}
+	res, err := decodeSpatial(b, v.Geography)
+	if err != nil {
+		return err
+	}
+	*v = res
+	return nil
+}
+
+// Value encodes the value in the binary serialization format of SQL Server.
+func (v SpatialValue) Value() (driver.Value, error) {
+	return encodeSpatial(v)
+}
+
+// WKT returns the well-known text representation of the value,
+// with the X coordinate first.
+func (v SpatialValue) WKT() string {
+	var s strings.Builder
+	s.WriteString(v.Type.String())
+	if len(v.Figures) == 0 {
+		s.WriteString(" EMPTY")
+		return s.String()
+	}
+	writeFigure := func(fig []SpatialCoord) {
+		s.WriteByte('(')
+		for i, c := range fig {
+			if i > 0 {
+				s.WriteString(", ")
+			}
+			s.WriteString(strconv.FormatFloat(c.X, 'f', -1, 64))
+			s.WriteByte(' ')
+			s.WriteString(strconv.FormatFloat(c.Y, 'f', -1, 64))
+		}
+		s.WriteByte(')')
+	}
+	s.WriteByte(' ')
+	if v.Type == SpatialPolygon {
+		s.WriteByte('(')
+		for i, fig := range v.Figures {
+			if i > 0 {
+				s.WriteString(", ")
+			}
+			writeFigure(fig)
+		}
+		s.WriteByte(')')
+	} else {
+		writeFigure(v.Figures[0])
+	}
+	return s.String()
+}
+
+// WKB returns the little endian well-known binary representation of
+// the value, with the X coordinate first. An empty point has NaN coordinates.
+func (v SpatialValue) WKB() []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(1) // little endian
+	binary.Write(&buf, binary.LittleEndian, uint32(v.Type))
+	writeCoords := func(fig []SpatialCoord) {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(fig)))
+		for _, c := range fig {
+			binary.Write(&buf, binary.LittleEndian, c.X)
+			binary.Write(&buf, binary.LittleEndian, c.Y)
+		}
+	}
+	switch v.Type {
+	case SpatialPoint:
+		c := SpatialCoord{math.NaN(), math.NaN()}
+		if len(v.Figures) > 0 && len(v.Figures[0]) > 0 {
+			c = v.Figures[0][0]
+		}
+		binary.Write(&buf, binary.LittleEndian, c.X)
+		binary.Write(&buf, binary.LittleEndian, c.Y)
+	case SpatialLineString:
+		var fig []SpatialCoord
+		if len(v.Figures) > 0 {
+			fig = v.Figures[0]
+		}
+		writeCoords(fig)
+	case SpatialPolygon:
+		binary.Write(&buf, binary.LittleEndian, uint32(len(v.Figures)))
+		for _, fig := range v.Figures {
+			writeCoords(fig)
+		}
+	}
+	return buf.Bytes()
+}
+
+func decodeSpatial(b []byte, geography bool) (res SpatialValue, err error) {
+	res.Geography = geography
+	r := bytes.NewReader(b)
+	read := func(data interface{}) {
+		if err == nil {
+			err = binary.Read(r, binary.LittleEndian, data)
+		}
+	}
+	readCoords := func(n int) []SpatialCoord {
+		coords := make([]SpatialCoord, 0, n)
+		for i := 0; i < n && err == nil; i++ {
+			var c SpatialCoord
+			if geography {
+				read(&c.Y)
+				read(&c.X)
+			} else {
+				read(&c.X)
+				read(&c.Y)
+			}
+			coords = append(coords, c)
+		}
+		return coords
+	}
+	var version, props uint8
+	read(&res.SRID)
+	read(&version)
+	read(&props)
+	if err != nil {
+		return res, errors.New("mssql: spatial value is too short")
+	}
+	if version != 1 && version != 2 {
+		return res, fmt.Errorf("mssql: unsupported spatial serialization version %d", version)
+	}
+
+	switch {
+	case props&spatialSinglePoint != 0:
+		res.Type = SpatialPoint
+		res.Figures = [][]SpatialCoord{readCoords(1)}
+	case props&spatialSingleLine != 0:
+		res.Type = SpatialLineString
+		res.Figures = [][]SpatialCoord{readCoords(2)}
+	default:
+		var numPoints, numFigures, numShapes int32
+		read(&numPoints)
+		if err != nil || numPoints < 0 || int64(numPoints)*16 > int64(r.Len()) {
+			return res, errors.New("mssql: invalid spatial point count")
+		}
+		points := readCoords(int(numPoints))
+		if props&spatialHasZ != 0 {
+			r.Seek(int64(numPoints)*8, io.SeekCurrent)
+		}
+		if props&spatialHasM != 0 {
+			r.Seek(int64(numPoints)*8, io.SeekCurrent)
+		}
+		read(&numFigures)
+		if err != nil || numFigures < 0 || int64(numFigures)*5 > int64(r.Len()) {
+			return res, errors.New("mssql: invalid spatial figure count")
+		}
+		offsets := make([]int32, numFigures)
+		for i := range offsets {
+			var attr uint8
+			read(&attr)
+			read(&offsets[i])
+		}
+		read(&numShapes)
+		if err != nil || numShapes != 1 {
+			return res, errors.New("mssql: only spatial values with a single shape are supported")
+		}
+		var parent, figure int32
+		var shapeType uint8
+		read(&parent)
+		read(&figure)
+		read(&shapeType)
+		if err != nil {
+			return res, errors.New("mssql: spatial value is too short")
+		}
+		res.Type = SpatialType(shapeType)
+		if res.Type != SpatialPoint && res.Type != SpatialLineString && res.Type != SpatialPolygon {
+			return res, fmt.Errorf("mssql: unsupported spatial shape type %d", shapeType)
+		}
+		if figure < 0 {
+			// empty shape
+			return res, nil
+		}
+		for i := int(figure); i < len(offsets); i++ {
+			end := numPoints
+			if i+1 < len(offsets) {
+				end = offsets[i+1]
+			}
+			if offsets[i] < 0 || offsets[i] > end || end > numPoints {
+				return res, errors.New("mssql: invalid spatial figure offset")
+			}
+			res.Figures = append(res.Figures, points[offsets[i]:end])
+		}
+	}
+	if err != nil {
+		return res, errors.New("mssql: spatial value is too short")
+	}
+	return res, nil
+}
+
+func encodeSpatial(v SpatialValue) ([]byte, error) {
+	switch v.Type {
+	case SpatialPoint, SpatialLineString:
+		if len(v.Figures) > 1 {
+			return nil, fmt.Errorf("mssql: a spatial %s has a single figure, got %d", v.Type, len(v.Figures))
+		}
+	case SpatialPolygon:
+	default:
+		return nil, fmt.Errorf("mssql: unsupported spatial shape type %d", uint8(v.Type))
+	}
+	if v.Type == SpatialPoint && len(v.Figures) == 1 && len(v.Figures[0]) != 1 {
+		return nil, fmt.Errorf("mssql: a spatial point has a single coordinate, got %d", len(v.Figures[0]))
+	}
+
+	var buf bytes.Buffer
+	write := func(data interface{}) {
+		binary.Write(&buf, binary.LittleEndian, data)
+	}
+	writeCoords := func(coords []SpatialCoord) {
+		for _, c := range coords {
+			if v.Geography {
+				write(c.Y)
+				write(c.X)
+			} else {
+				write(c.X)
+				write(c.Y)
+			}
+		}
+	}
+	write(v.SRID)
+	write(uint8(spatialVersion))
+	switch {
+	case v.Type == SpatialPoint && len(v.Figures) == 1:
+		write(uint8(spatialValid | spatialSinglePoint))
+		writeCoords(v.Figures[0])
+		return buf.Bytes(), nil
+	case v.Type == SpatialLineString && len(v.Figures) == 1 && len(v.Figures[0]) == 2:
+		write(uint8(spatialValid | spatialSingleLine))
+		writeCoords(v.Figures[0])
+		return buf.Bytes(), nil
+	}
+
+	write(uint8(spatialValid))
+	numPoints := 0
+	for _, fig := range v.Figures {
+		numPoints += len(fig)
+	}
+	write(int32(numPoints))
+	for _, fig := range v.Figures {
+		writeCoords(fig)
+	}
+	write(int32(len(v.Figures)))
+	offset := 0
+	for i, fig := range v.Figures {
+		attr := uint8(spatialFigureStroke)
+		if v.Type == SpatialPolygon {
+			attr = spatialFigureInteriorRing
+			if i == 0 {
+				attr = spatialFigureExteriorRing
+			}
+		}
+		write(attr)
+		write(int32(offset))
+		offset += len(fig)
+	}
+	write(int32(1))  // shapes
+	write(int32(-1)) // no parent
+	if len(v.Figures) == 0 {
+		write(int32(-1))
+	} else {
+		write(int32(0))
+	}
+	write(uint8(v.Type))
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,112 @@
+package mssql
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestSpatialPoint(t *testing.T) {
+	// select cast(geography::Point(47.651, -122.349, 4326) as varbinary(max))
+	b, _ := hex.DecodeString("E6100000010C17D9CEF753D347407593180456965EC0")
+	v := SpatialValue{Geography: true}
+	if err := v.Scan(b); err != nil {
+		t.Fatal(err)
+	}
+	want := SpatialValue{Geography: true, SRID: 4326, Type: SpatialPoint, Figures: [][]SpatialCoord{{{-122.349, 47.651}}}}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("expected %+v, got %+v", want, v)
+	}
+	if v.WKT() != "POINT (-122.349 47.651)" {
+		t.Errorf("unexpected WKT %s", v.WKT())
+	}
+	wkb, _ := hex.DecodeString("01010000007593180456965EC017D9CEF753D34740")
+	if !bytes.Equal(v.WKB(), wkb) {
+		t.Errorf("unexpected WKB %X", v.WKB())
+	}
+	enc, err := v.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc.([]byte), b) {
+		t.Errorf("expected %X, got %X", b, enc)
+	}
+}
+
+func TestSpatialRoundTrip(t *testing.T) {
+	values := []struct {
+		v   SpatialValue
+		wkt string
+	}{
+		{SpatialValue{Type: SpatialPoint}, "POINT EMPTY"},
+		{SpatialValue{Type: SpatialLineString, Figures: [][]SpatialCoord{{{0, 0}, {1, 2}}}}, "LINESTRING (0 0, 1 2)"},
+		{SpatialValue{SRID: 4326, Geography: true, Type: SpatialLineString, Figures: [][]SpatialCoord{{{-122.36, 47.656}, {-122.343, 47.656}, {-122.3, 47.7}}}},
+			"LINESTRING (-122.36 47.656, -122.343 47.656, -122.3 47.7)"},
+		{SpatialValue{Type: SpatialPolygon, Figures: [][]SpatialCoord{
+			{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+			{{2, 2}, {2, 4}, {4, 4}, {2, 2}},
+		}}, "POLYGON ((0 0, 10 0, 10 10, 0 10, 0 0), (2 2, 2 4, 4 4, 2 2))"},
+	}
+	for _, tt := range values {
+		if got := tt.v.WKT(); got != tt.wkt {
+			t.Errorf("expected WKT %s, got %s", tt.wkt, got)
+		}
+		b, err := tt.v.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := SpatialValue{Geography: tt.v.Geography}
+		if err = got.Scan(b); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.v) {
+			t.Errorf("expected %+v, got %+v", tt.v, got)
+		}
+	}
+
+	if _, err := (SpatialValue{Type: 7}).Value(); err == nil {
+		t.Error("expected an error for a geometry collection")
+	}
+	var v SpatialValue
+	if err := v.Scan([]byte{0, 0, 0, 0, 1}); err == nil {
+		t.Error("expected an error for a truncated value")
+	}
+	if err := v.Scan("POINT (1 2)"); err == nil {
+		t.Error("expected an error scanning a string")
+	}
+}
+
+func TestSpatialQuery(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	v := SpatialValue{Geography: true}
+	err := conn.QueryRow("select geography::Point(47.651, -122.349, 4326)").Scan(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.SRID != 4326 || v.Type != SpatialPoint || v.Figures[0][0] != (SpatialCoord{X: -122.349, Y: 47.651}) {
+		t.Errorf("unexpected point %+v", v)
+	}
+
+	var polygon SpatialValue
+	err = conn.QueryRow("select geometry::STGeomFromText('POLYGON ((0 0, 10 0, 10 10, 0 10, 0 0), (2 2, 2 4, 4 4, 2 2))', 0)").Scan(&polygon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if polygon.WKT() != "POLYGON ((0 0, 10 0, 10 10, 0 10, 0 0), (2 2, 2 4, 4 4, 2 2))" {
+		t.Errorf("unexpected polygon %s", polygon.WKT())
+	}
+
+	var wkt string
+	line := SpatialValue{Geography: true, SRID: 4326, Type: SpatialLineString, Figures: [][]SpatialCoord{{{-122.36, 47.656}, {-122.343, 47.656}, {-122.3, 47.7}}}}
+	err = conn.QueryRow("declare @g geography = @p1; select @g.STAsText()", line).Scan(&wkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wkt != line.WKT() {
+		t.Errorf("expected %s, got %s", line.WKT(), wkt)
+	}
+}
@@ -18,6 +18,7 @@ import (
 
 	"github.com/microsoft/go-mssqldb/aecmk"
 	"github.com/microsoft/go-mssqldb/integratedauth"
+	"github.com/microsoft/go-mssqldb/internal/cp"
 	"github.com/microsoft/go-mssqldb/msdsn"
 )
 
@@ -175,7 +176,22 @@ type tdsSession struct {
 	aeSettings      *alwaysEncryptedSettings
 	connid          UniqueIdentifier
 	activityid      UniqueIdentifier
-	encoding        msdsn.EncodeParameters
+	// activitySeq is the sequence number of the last request sent with
+	// the activity id of the connection
+	activitySeq uint32
+	encoding    msdsn.EncodeParameters
+	// collation is the default collation of the current database
+	collation cp.Collation
+	// utf8Support is set when the server agreed to send the data of
+	// UTF-8 collations as UTF-8 instead of converting it to a code page,
+	// reported by ConnectionState
+	utf8Support bool
+	// tlsState is the state of the TLS connection, if any, and encrypted
+	// is set when all the traffic goes through it instead of only the login
+	tlsState  *tls.ConnectionState
+	encrypted bool
+	// conn is the connection to the server, checked by ResetSession
+	conn net.Conn
 }
 
 type alwaysEncryptedSettings struct {
@@ -197,6 +213,8 @@ type columnStruct struct {
 	ColName    string
 	ti         typeInfo
 	cryptoMeta *cryptoMetadata
+	// source is the base table and column of a browse mode query
+	source *ColumnSource
 }
 
 func (c columnStruct) isEncrypted() bool {
@@ -451,9 +469,16 @@ func (e featureExts) toBytes() []byte {
 	if len(e.features) == 0 {
 		return nil
 	}
+	// send the features in a stable order
+	ids := make([]int, 0, len(e.features))
+	for featureID := range e.features {
+		ids = append(ids, int(featureID))
+	}
+	sort.Ints(ids)
 	var d []byte
-	for featureID, f := range e.features {
-		featureData := f.toBytes()
+	for _, id := range ids {
+		featureID := byte(id)
+		featureData := e.features[featureID].toBytes()
 
 		hdr := make([]byte, 5)
 		hdr[0] = featureID                                               // FedAuth feature extension BYTE
@@ -905,7 +930,13 @@ func (hdr transDescrHdr) pack() (res []byte) {
 	return res
 }
 
+// writeAllHeaders writes the ALL_HEADERS of a request. They are only defined
+// from TDS 7.2 on, nil headers, returned by Conn.requestHeaders for older
+// servers, write nothing.
 func writeAllHeaders(w io.Writer, headers []headerStruct) (err error) {
+	if headers == nil {
+		return nil
+	}
 	// Calculating total length.
 	var totallen uint32 = 4
 	for _, hdr := range headers {
@@ -1063,6 +1094,7 @@ func prepareLogin(ctx context.Context, c *Connector, p msdsn.Config, logger Cont
 	if p.ColumnEncryption {
 		_ = l.FeatureExt.Add(&featureExtColumnEncryption{})
 	}
+	_ = l.FeatureExt.Add(&featureExtUTF8Support{})
 	switch {
 	case fe.FedAuthLibrary == FedAuthLibrarySecurityToken:
 		if uint64(p.LogFlags)&logDebug != 0 {
@@ -1139,7 +1171,10 @@ func connect(ctx context.Context, c *Connector, logger ContextLogger, p msdsn.Co
 		logger.Log(ctx, msdsn.LogDebug, "WARN: You specified both instance name and port in the connection string, port will be used and instance name will be ignored")
 	}
 
-	packetSize := p.PacketSize
+	packetSize, err := c.getPacketSize(&p)
+	if err != nil {
+		return nil, err
+	}
 	if packetSize == 0 {
 		packetSize = defaultPacketSize
 	}
@@ -1153,6 +1188,19 @@ func connect(ctx context.Context, c *Connector, logger ContextLogger, p msdsn.Co
 		packetSize = 32767
 	}
 
+	c.applyTLSConfig(&p)
+
+	// read-only connections go directly to the replica a previous
+	// connection was routed to
+	origParams := p
+	cachedRoute := c.getRoute()
+	if p.ReadOnlyIntent && cachedRoute != nil {
+		p = routeParams(p, cachedRoute)
+	} else {
+		cachedRoute = nil
+	}
+	routed := false
+
 initiate_connection:
 	dialCtx := ctx
 	if p.DialTimeout >= 0 {
@@ -1166,11 +1214,24 @@ initiate_connection:
 	}
 	conn, err := dialConnection(dialCtx, c, &p, logger)
 	if err != nil {
+		if cachedRoute != nil {
+			// the replica may have moved, ask the original server again
+			c.setRoute(nil)
+			cachedRoute = nil
+			p = origParams
+			goto initiate_connection
+		}
+		if routed && p.ReadOnlyIntent {
+			// forget the replica, the caller falls back to the failover partner
+			c.setRoute(nil)
+			return nil, fmt.Errorf("unable to connect to the replica %s the connection was routed to: %w", p.Host, err)
+		}
 		return nil, err
 	}
 
 	toconn := newTimeoutConn(conn, p.ConnTimeout)
 	outbuf := newTdsBuffer(packetSize, toconn)
+	outbuf.tracer = c.getPacketTracer()
 
 	if p.Encryption == msdsn.EncryptionStrict {
 		outbuf.transport, err = getTLSConn(toconn, p, "tds/8.0")
@@ -1180,6 +1241,12 @@ initiate_connection:
 		isTransportEncrypted = true
 	}
 	sess := newSession(outbuf, logger, p)
+	sess.conn = conn
+	if isTransportEncrypted {
+		state := outbuf.transport.(*tls.Conn).ConnectionState()
+		sess.tlsState = &state
+		sess.encrypted = true
+	}
 
 	for i, p := range c.keyProviders {
 		sess.aeSettings.keyProviders[i] = p
@@ -1243,6 +1310,9 @@ initiate_connection:
 			if err != nil {
 				return nil, fmt.Errorf("TLS Handshake failed: %v", err)
 			}
+			state := tlsConn.ConnectionState()
+			sess.tlsState = &state
+			sess.encrypted = encrypt != encryptOff
 			if encrypt == encryptOff {
 				outbuf.afterFirst = func() {
 					outbuf.transport = toconn
@@ -1252,7 +1322,7 @@ initiate_connection:
 
 	}
 
-	auth, err := integratedauth.GetIntegratedAuthenticator(p)
+	auth, err := c.getIntegratedAuthenticator(p)
 	if err != nil {
 		if uint64(p.LogFlags)&logDebug != 0 {
 			logger.Log(ctx, msdsn.LogDebug, fmt.Sprintf("Error while creating integrated authenticator: %v", err))
@@ -1260,6 +1330,9 @@ initiate_connection:
 
 		return nil, err
 	}
+	if binder, ok := auth.(integratedauth.ChannelBinder); ok && sess.tlsState != nil && len(sess.tlsState.PeerCertificates) > 0 {
+		binder.SetChannelBinding(integratedauth.TLSServerEndPoint(sess.tlsState.PeerCertificates[0]))
+	}
 
 	if auth != nil {
 		defer auth.Free()
@@ -1277,11 +1350,13 @@ initiate_connection:
 
 	// Loop until a packet containing a login acknowledgement is received.
 	// SSPI and federated authentication scenarios may require multiple
-	// packet exchanges to complete the login sequence.
+	// packet exchanges to complete the login sequence, each response of the
+	// server but the last one being answered with another token.
 	for loginAck := false; !loginAck; {
 		reader := startReading(sess, ctx, outputs{})
 		// don't send attention or wait for cancel confirmation during login
 		reader.noAttn = true
+		answered := false
 
 		for {
 			tok, err := reader.nextToken()
@@ -1295,6 +1370,9 @@ initiate_connection:
 
 			switch token := tok.(type) {
 			case sspiMsg:
+				if auth == nil {
+					return nil, errors.New("login error: unexpected SSPI token from the server")
+				}
 				sspi_msg, err := auth.NextBytes(token)
 				if err != nil {
 					return nil, err
@@ -1310,6 +1388,7 @@ initiate_connection:
 						return nil, err
 					}
 					sspi_msg = nil
+					answered = true
 				}
 			// TODO: for Live ID authentication it may be necessary to
 			// compare fedAuth.Nonce == token.Nonce and keep track of signature
@@ -1333,8 +1412,9 @@ initiate_connection:
 				if err != nil {
 					return nil, err
 				}
+				answered = true
 			case loginAckStruct:
-				sess.loginAck = token
+				// sess.loginAck is set by the goroutine reading the response
 				loginAck = true
 			case featureExtAck:
 				for _, v := range token {
@@ -1346,6 +1426,9 @@ initiate_connection:
 								sess.aeSettings.enclaveType = string(v.EnclaveType)
 							}
 						}
+					case utf8SupportAck:
+						sess.utf8Support = bool(v)
+						sess.LogF(ctx, msdsn.LogDebug, "Server UTF-8 support: %v", sess.utf8Support)
 					}
 				}
 			case doneStruct:
@@ -1359,26 +1442,43 @@ initiate_connection:
 				return nil, fmt.Errorf("login error: %s", token.Error())
 			}
 		}
+		if !loginAck && !answered {
+			// the server waits for a token which the client has not sent,
+			// reading on would block until the connection times out
+			return nil, errors.New("login error: the server did not acknowledge the login")
+		}
 	}
 
 	if sess.routedServer != "" {
 		toconn.Close()
-		// Need to handle case when routedServer is in "host\instance" format.
-		routedParts := strings.SplitN(sess.routedServer, "\\", 2)
-		p.Host = routedParts[0]
-		if len(routedParts) == 2 {
-			p.Instance = routedParts[1]
-		}
-		p.Port = uint64(sess.routedPort)
-		if !p.HostInCertificateProvided && p.TLSConfig != nil {
-			p.TLSConfig = p.TLSConfig.Clone()
-			p.TLSConfig.ServerName = p.Host
+		route := &connRoute{server: sess.routedServer, port: sess.routedPort}
+		if p.ReadOnlyIntent {
+			c.setRoute(route)
 		}
+		p = routeParams(p, route)
+		routed = true
 		goto initiate_connection
 	}
 	return sess, nil
 }
 
+// routeParams returns the connection parameters of the server a
+// connection is routed to.
+func routeParams(p msdsn.Config, route *connRoute) msdsn.Config {
+	// Need to handle case when the server is in "host\instance" format.
+	routedParts := strings.SplitN(route.server, "\\", 2)
+	p.Host = routedParts[0]
+	if len(routedParts) == 2 {
+		p.Instance = routedParts[1]
+	}
+	p.Port = uint64(route.port)
+	if !p.HostInCertificateProvided && p.TLSConfig != nil {
+		p.TLSConfig = p.TLSConfig.Clone()
+		p.TLSConfig.ServerName = p.Host
+	}
+	return p
+}
+
 type featureExtColumnEncryption struct {
 }
 
@@ -1397,6 +1497,19 @@ func (f *featureExtColumnEncryption) toBytes() []byte {
 	return []byte{0x01}
 }
 
+// featureExtUTF8Support asks the server to send the data of UTF-8 collations
+// as UTF-8 instead of converting it to a code page.
+type featureExtUTF8Support struct {
+}
+
+func (f *featureExtUTF8Support) featureID() byte {
+	return featExtUTF8SUPPORT
+}
+
+func (f *featureExtUTF8Support) toBytes() []byte {
+	return nil
+}
+
 // return the 6 byte hardware identifier for the LOGIN7 packet
 func getClientId(mac *[6]byte) {
 	interfaces, err := net.Interfaces()
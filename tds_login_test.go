@@ -1,17 +1,30 @@
 package mssql
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
 	"os"
+	"reflect"
 	"regexp"
+	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/microsoft/go-mssqldb/integratedauth"
 	"github.com/microsoft/go-mssqldb/msdsn"
 )
 
@@ -147,11 +160,11 @@ func TestLoginWithSQLServerAuth(t *testing.T) {
 			fmt.Sprintf("12 01 00 2f 00 00 01 00  00 00 1a 00 06 01 00 20\n"+
 				"00 01 02 00 21 00 01 03  00 22 00 04 04 00 26 00\n"+
 				"01 ff %s             00 00  00 00 00 00 00 00 00\n", v),
-			fmt.Sprintf("10 01 00 c6 00 00 01 00  be 00 00 00 04 00 00 74\n"+
+			fmt.Sprintf("10 01 00 d0 00 00 01 00  c8 00 00 00 04 00 00 74\n"+
 				"00 10 00 00 %s           %s 00 00 00 00\n"+
-				"A0 02 00 00 00 00 00 00  00 00 00 00 5e 00 09 00\n"+
+				"A0 02 00 10 00 00 00 00  00 00 00 00 5e 00 09 00\n"+
 				"70 00 04 00 78 00 06 00  84 00 0a 00 98 00 09 00\n"+
-				"00 00 00 00 aa 00 0a 00  be 00 00 00 be 00 00 00\n"+
+				"be 00 04 00 aa 00 0a 00  be 00 00 00 be 00 00 00\n"+
 				"%s be 00  00 00 be 00 00 00 be 00\n"+
 				"00 00 00 00 00 00 6c 00  6f 00 63 00 61 00 6c 00\n"+
 				"68 00 6f 00 73 00 74 00  74 00 65 00 73 00 74 00\n"+
@@ -159,7 +172,7 @@ func TestLoginWithSQLServerAuth(t *testing.T) {
 				"2d 00 6d 00 73 00 73 00  71 00 6c 00 64 00 62 00\n"+
 				"6c 00 6f 00 63 00 61 00  6c 00 68 00 6f 00 73 00\n"+
 				"74 00 67 00 6f 00 2d 00  6d 00 73 00 73 00 71 00\n"+
-				"6c 00 64 00 62 00\n", v, pid, clientIdToHexString()),
+				"6c 00 64 00 62 00 c2 00  00 00 0a 00 00 00 00 ff\n", v, pid, clientIdToHexString()),
 		},
 		[]string{
 			"  04 01 00 20  00 00 01 00   00 00 10 00  06 01 00 16\n" +
@@ -210,7 +223,7 @@ func TestLoginWithSecurityTokenAuth(t *testing.T) {
 				"00 01 02 00 26 00 01 03  00 27 00 04 04 00 2B 00\n"+
 				"01 06 00 2c 00 01 ff %s           00 00 00 00 00\n"+
 				"00 00 00 00 01\n", v),
-			fmt.Sprintf("10 01 00 CF 00 00 01 00  C7 00 00 00 04 00 00 74\n"+
+			fmt.Sprintf("10 01 00 D4 00 00 01 00  CC 00 00 00 04 00 00 74\n"+
 				"00 10 00 00 %s           %s 00 00 00 00\n"+
 				"A0 02 00 10 00 00 00 00  00 00 00 00 5E 00 09 00\n"+
 				"70 00 00 00 70 00 00 00  70 00 0A 00 84 00 09 00\n"+
@@ -222,7 +235,8 @@ func TestLoginWithSecurityTokenAuth(t *testing.T) {
 				"63 00 61 00 6C 00 68 00  6F 00 73 00 74 00 67 00\n"+
 				"6F 00 2D 00 6D 00 73 00  73 00 71 00 6C 00 64 00\n"+
 				"62 00 AE 00 00 00 02 13  00 00 00 03 0E 00 00 00\n"+
-				"3C 00 74 00 6F 00 6B 00  65 00 6E 00 3E 00 FF\n", v, pid, clientIdToHexString()),
+				"3C 00 74 00 6F 00 6B 00  65 00 6E 00 3E 00 0A 00\n"+
+				"00 00 00 FF\n", v, pid, clientIdToHexString()),
 		},
 		[]string{
 			"  04 01 00 20  00 00 01 00   00 00 10 00  06 01 00 16\n" +
@@ -275,7 +289,7 @@ func TestLoginWithADALUsernamePasswordAuth(t *testing.T) {
 				"00 01 02 00 26 00 01 03  00 27 00 04 04 00 2B 00\n"+
 				"01 06 00 2C 00 01 ff %s  00 00 00 00 00\n"+
 				"00 00 00 00 01\n", v),
-			fmt.Sprintf("10 01 00 BE 00 00 01 00  b6 00 00 00 04 00 00 74\n"+
+			fmt.Sprintf("10 01 00 C3 00 00 01 00  bb 00 00 00 04 00 00 74\n"+
 				"00 10 00 00 %s           %s 00 00 00 00\n"+
 				"A0 02 00 10 00 00 00 00  00 00 00 00 5e 00 09 00\n"+
 				"70 00 00 00 70 00 00 00  70 00 0a 00 84 00 09 00\n"+
@@ -286,7 +300,8 @@ func TestLoginWithADALUsernamePasswordAuth(t *testing.T) {
 				"73 00 73 00 71 00 6c 00  64 00 62 00 6c 00 6f 00\n"+
 				"63 00 61 00 6c 00 68 00  6f 00 73 00 74 00 67 00\n"+
 				"6f 00 2d 00 6d 00 73 00  73 00 71 00 6c 00 64 00\n"+
-				"62 00 AE 00 00 00 02 02  00 00  00 05 01 ff\n", v, pid, clientIdToHexString()),
+				"62 00 AE 00 00 00 02 02  00 00  00 05 01 0a 00 00\n"+
+				"00 00 ff\n", v, pid, clientIdToHexString()),
 			"  08 01 00 1e 00 00 01 00  12 00 00 00 0e 00 00 00\n" +
 				"3c 00 74 00 6f 00 6b 00  65 00 6e 00 3e 00\n",
 		},
@@ -352,7 +367,7 @@ func TestLoginWithADALManagedIdentityAuth(t *testing.T) {
 				"00 01 02 00 26 00 01 03  00 27 00 04 04 00 2B 00\n"+
 				"01 06 00 2C 00 01 ff %s           00 00 00 00 00\n"+
 				"00 00 00 00 01\n", v),
-			fmt.Sprintf("10 01 00 be 00 00 01 00  b6 00 00 00 04 00 00 74\n"+
+			fmt.Sprintf("10 01 00 c3 00 00 01 00  bb 00 00 00 04 00 00 74\n"+
 				"00 10 00 00 %s           %s 00 00 00 00\n"+
 				"A0 02 00 10 00 00 00 00  00 00 00 00 5e 00 09 00\n"+
 				"70 00 00 00 70 00 00 00  70 00 0a 00 84 00 09 00\n"+
@@ -363,7 +378,8 @@ func TestLoginWithADALManagedIdentityAuth(t *testing.T) {
 				"73 00 73 00 71 00 6c 00  64 00 62 00 6c 00 6f 00\n"+
 				"63 00 61 00 6c 00 68 00  6f 00 73 00 74 00 67 00\n"+
 				"6f 00 2d 00 6d 00 73 00  73 00 71 00 6c 00 64 00\n"+
-				"62 00 AE 00 00 00 02 02  00 00 00 05 03 ff\n", v, pid, clientIdToHexString()),
+				"62 00 AE 00 00 00 02 02  00 00 00 05 03 0a 00 00\n"+
+				"00 00 ff\n", v, pid, clientIdToHexString()),
 			"  08 01 00 1e 00 00 01 00  12 00 00 00 0e 00 00 00\n" +
 				"3c 00 74 00 6f 00 6b 00  65 00 6e 00 3e 00\n",
 		},
@@ -407,3 +423,508 @@ func clientIdToHexString() string {
 	return hex.EncodeToString(clientid[:])
 
 }
+
+type fakeAuthenticator struct {
+	freed bool
+}
+
+func (a *fakeAuthenticator) InitialBytes() ([]byte, error) {
+	return []byte("initial"), nil
+}
+
+func (a *fakeAuthenticator) NextBytes([]byte) ([]byte, error) {
+	return nil, nil
+}
+
+func (a *fakeAuthenticator) Free() {
+	a.freed = true
+}
+
+func TestIntegratedAuthProvider(t *testing.T) {
+	conn, err := NewConnector("sqlserver://localhost:1433?authenticator=krb5&serverspn=MSSQLSvc/db.example.com:1433")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got msdsn.Config
+	calls := 0
+	conn.IntegratedAuthProvider = integratedauth.ProviderFunc(func(config msdsn.Config) (integratedauth.IntegratedAuthenticator, error) {
+		calls++
+		got = config
+		return &fakeAuthenticator{}, nil
+	})
+
+	auth, err := conn.getIntegratedAuthenticator(conn.params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || got.ServerSPN != "MSSQLSvc/db.example.com:1433" {
+		t.Errorf("expected the provider to be called with the connection config, got %d calls with %+v", calls, got)
+	}
+	l, err := prepareLogin(context.Background(), conn, conn.params, driverInstanceNoProcess.logger, auth, &featureExtFedAuth{FedAuthLibrary: FedAuthLibraryReserved}, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(l.SSPI) != "initial" || l.OptionFlags2&fIntSecurity == 0 {
+		t.Errorf("expected the login to use the integrated authenticator, got %q", l.SSPI)
+	}
+
+	conn.IntegratedAuthProvider = nil
+	if _, err = conn.getIntegratedAuthenticator(msdsn.Config{Parameters: map[string]string{"authenticator": "unknown"}}); err == nil {
+		t.Error("expected the authenticator parameter to be used without a provider")
+	}
+}
+
+// pipeDialer returns the client end of a pipe whose server end is handed
+// to serve.
+type pipeDialer struct {
+	serve func(net.Conn)
+}
+
+func (d pipeDialer) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	server, client := net.Pipe()
+	go d.serve(server)
+	return client, nil
+}
+
+func selfSignedCertificate(t *testing.T, host string) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+func TestConnectorTLSConfigStrict(t *testing.T) {
+	serverCert, caCert := selfSignedCertificate(t, "localhost")
+	handshakes := make(chan error, 1)
+	dialer := pipeDialer{serve: func(conn net.Conn) {
+		defer conn.Close()
+		tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{serverCert}, NextProtos: []string{"tds/8.0"}})
+		handshakes <- tlsConn.Handshake()
+	}}
+	config, err := msdsn.Parse("sqlserver://localhost?encrypt=strict&protocol=tcp&dial timeout=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the system roots do not trust the certificate
+	c := NewConnectorConfig(config)
+	c.Dialer = dialer
+	_, err = connect(context.Background(), c, driverInstanceNoProcess.logger, c.params)
+	if err == nil || !strings.Contains(err.Error(), "TLS Handshake failed") {
+		t.Errorf("expected the handshake to fail without the root certificate, got %v", err)
+	}
+	<-handshakes
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	tlsConfig := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	c = NewConnectorConfig(config)
+	c.Dialer = dialer
+	c.TLSConfig = tlsConfig
+	// the server closes the connection after the handshake
+	_, _ = connect(context.Background(), c, driverInstanceNoProcess.logger, c.params)
+	if err = <-handshakes; err != nil {
+		t.Errorf("expected the handshake to succeed with the root certificate, got %v", err)
+	}
+	if tlsConfig.ServerName != "" || tlsConfig.NextProtos != nil {
+		t.Errorf("expected the TLSConfig of the connector to be left unchanged, got %+v", tlsConfig)
+	}
+}
+
+func TestDialerFunc(t *testing.T) {
+	loginAck, err := hex.DecodeString(strings.ReplaceAll(testLoginAck, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCert, caCert := selfSignedCertificate(t, "localhost")
+	var dials []string
+	dialer := DialerFunc(func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		dials = append(dials, network+" "+addr)
+		server, client := net.Pipe()
+		go serveStatements(tls.Server(server, &tls.Config{Certificates: []tls.Certificate{serverCert}, NextProtos: []string{"tds/8.0"}}), loginAck)
+		return client, nil
+	})
+	config, err := msdsn.Parse("sqlserver://127.0.0.1:1500?encrypt=strict&protocol=tcp&dial timeout=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	c := NewConnectorConfig(config)
+	c.Dialer = dialer
+	c.TLSConfig = &tls.Config{RootCAs: roots, ServerName: "localhost"}
+	db := sql.OpenDB(c)
+	defer db.Close()
+	if _, err = db.Exec("select 1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(dials) != 1 || dials[0] != "tcp 127.0.0.1:1500" {
+		t.Errorf("expected the dialer to be called with the server address, got %v", dials)
+	}
+}
+
+// tcpOptionsConn records the TCP options set on it.
+type tcpOptionsConn struct {
+	net.Conn
+	options []string
+}
+
+func (c *tcpOptionsConn) SetKeepAlive(keepalive bool) error {
+	c.options = append(c.options, fmt.Sprint("keepalive ", keepalive))
+	return nil
+}
+
+func (c *tcpOptionsConn) SetKeepAlivePeriod(d time.Duration) error {
+	c.options = append(c.options, fmt.Sprint("period ", d))
+	return nil
+}
+
+func (c *tcpOptionsConn) SetNoDelay(noDelay bool) error {
+	c.options = append(c.options, fmt.Sprint("nodelay ", noDelay))
+	return nil
+}
+
+func TestTCPOptions(t *testing.T) {
+	loginAck, err := hex.DecodeString(strings.ReplaceAll(testLoginAck, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		dsn  string
+		want []string
+	}{
+		{"", []string{"keepalive true", "period 30s", "nodelay true"}},
+		{"&keepalive=10&tcpnodelay=false", []string{"keepalive true", "period 10s", "nodelay false"}},
+		{"&keepalive=0", []string{"keepalive false", "nodelay true"}},
+	}
+	for _, tt := range tests {
+		var conn *tcpOptionsConn
+		config, err := msdsn.Parse("sqlserver://127.0.0.1?encrypt=disable&protocol=tcp&dial timeout=5" + tt.dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := NewConnectorConfig(config)
+		c.Dialer = DialerFunc(func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			server, client := net.Pipe()
+			go serveStatements(server, loginAck)
+			conn = &tcpOptionsConn{Conn: client}
+			return conn, nil
+		})
+		db := sql.OpenDB(c)
+		err = db.Ping()
+		db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(conn.options, tt.want) {
+			t.Errorf("%q: expected the options %q, got %q", tt.dsn, tt.want, conn.options)
+		}
+	}
+}
+
+func TestApplyTLSConfig(t *testing.T) {
+	p := msdsn.Config{Host: "server.example.com"}
+	c := &Connector{TLSConfig: &tls.Config{MinVersion: tls.VersionTLS13}}
+	c.applyTLSConfig(&p)
+	if p.TLSConfig == c.TLSConfig || p.TLSConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("expected a copy of the TLSConfig, got %+v", p.TLSConfig)
+	}
+	if p.TLSConfig.ServerName != "server.example.com" || p.HostInCertificateProvided {
+		t.Errorf("expected the server name to default to the host, got %q", p.TLSConfig.ServerName)
+	}
+
+	p = msdsn.Config{Host: "listener.example.com"}
+	c.TLSConfig.ServerName = "sql.example.com"
+	c.applyTLSConfig(&p)
+	if p.TLSConfig.ServerName != "sql.example.com" || !p.HostInCertificateProvided {
+		t.Errorf("expected the server name of the TLSConfig to be kept, got %q", p.TLSConfig.ServerName)
+	}
+}
+
+// bindingAuth records the channel binding set by the driver.
+type bindingAuth struct {
+	binding []byte
+}
+
+func (a *bindingAuth) InitialBytes() ([]byte, error)    { return []byte("NTLMSSP\x00"), nil }
+func (a *bindingAuth) NextBytes([]byte) ([]byte, error) { return nil, nil }
+func (a *bindingAuth) Free()                            {}
+func (a *bindingAuth) SetChannelBinding(b []byte)       { a.binding = b }
+
+func TestConnectChannelBinding(t *testing.T) {
+	serverCert, caCert := selfSignedCertificate(t, "localhost")
+	done := make(chan error, 1)
+	dialer := pipeDialer{serve: func(conn net.Conn) {
+		defer conn.Close()
+		tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{serverCert}, NextProtos: []string{"tds/8.0"}})
+		buf := newTdsBuffer(4096, tlsConn)
+		if _, err := buf.BeginRead(); err != nil {
+			done <- err
+			return
+		}
+		if _, err := io.ReadAll(buf); err != nil {
+			done <- err
+			return
+		}
+		// the connection is closed once the login is read
+		done <- writePrelogin(packReply, buf, map[uint8][]byte{preloginENCRYPTION: {encryptStrict}})
+		_, _ = buf.BeginRead()
+	}}
+	config, err := msdsn.Parse("sqlserver://localhost?encrypt=strict&protocol=tcp&dial timeout=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	auth := &bindingAuth{}
+	c := NewConnectorConfig(config)
+	c.Dialer = dialer
+	c.TLSConfig = &tls.Config{RootCAs: roots}
+	c.IntegratedAuthProvider = integratedauth.ProviderFunc(func(msdsn.Config) (integratedauth.IntegratedAuthenticator, error) {
+		return auth, nil
+	})
+	_, _ = connect(context.Background(), c, driverInstanceNoProcess.logger, c.params)
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(serverCert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := integratedauth.TLSServerEndPoint(leaf)
+	if !bytes.Equal(auth.binding, want) {
+		t.Errorf("expected the channel binding of the server certificate %x, got %x", want, auth.binding)
+	}
+}
+
+// multiLegAuth answers each challenge of the server with the next token.
+type multiLegAuth struct {
+	tokens     []string
+	challenges []string
+}
+
+func (a *multiLegAuth) InitialBytes() ([]byte, error) {
+	return []byte(a.tokens[0]), nil
+}
+
+func (a *multiLegAuth) NextBytes(challenge []byte) ([]byte, error) {
+	a.challenges = append(a.challenges, string(challenge))
+	if len(a.challenges) >= len(a.tokens) {
+		return nil, nil
+	}
+	return []byte(a.tokens[len(a.challenges)]), nil
+}
+
+func (a *multiLegAuth) Free() {}
+
+func sspiToken(data string) []byte {
+	return append([]byte{byte(tokenSSPI), byte(len(data)), byte(len(data) >> 8)}, data...)
+}
+
+// testLoginAck is the LOGINACK and DONE tokens of a SQL Server login.
+const testLoginAck = "AD 32 00 01 74 00 00 04 14 4d 00 69 00 63 00 72 00 6f 00 73 00 6f 00 66" +
+	"00 74 00 20 00 53 00 51 00 4c 00 20 00 53 00 65 00 72 00 76 00 65 00 72" +
+	"00 0c 00 07 d0 fd 00 00 00 00 00 00 00 00 00 00 00 00"
+
+// recordConn records the bytes written to the connection.
+type recordConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.written.Write(b)
+	return c.Conn.Write(b)
+}
+
+func TestLoginUTF8Support(t *testing.T) {
+	loginAck, err := hex.DecodeString(strings.ReplaceAll(testLoginAck, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := msdsn.Parse("sqlserver://localhost?encrypt=disable&protocol=tcp&dial timeout=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the UTF8_SUPPORT feature then the terminator of the feature extensions
+	feature := []byte{featExtUTF8SUPPORT, 0, 0, 0, 0, featExtTERMINATOR}
+	for _, acked := range []bool{false, true} {
+		reply := loginAck
+		if acked {
+			// the acknowledgement goes before the final DONE token
+			done := len(loginAck) - 13
+			ack := []byte{byte(tokenFeatureExtAck), featExtUTF8SUPPORT, 1, 0, 0, 0, 1, featExtTERMINATOR}
+			reply = append(append(append([]byte{}, loginAck[:done]...), ack...), loginAck[done:]...)
+		}
+		var client *recordConn
+		c := NewConnectorConfig(config)
+		c.Dialer = DialerFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			server, conn := net.Pipe()
+			go serveStatements(server, reply)
+			client = &recordConn{Conn: conn}
+			return client, nil
+		})
+		conn, err := c.Connect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		state, err := conn.(*Conn).ConnectionState()
+		conn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(client.written.Bytes(), feature) {
+			t.Errorf("acked=%v: expected the login to ask for UTF8_SUPPORT", acked)
+		}
+		if state.UTF8 != acked {
+			t.Errorf("acked=%v: expected the UTF-8 support %v, got %v", acked, acked, state.UTF8)
+		}
+	}
+}
+
+func TestLoginSSPIContinuation(t *testing.T) {
+	loginAck, err := hex.DecodeString(strings.ReplaceAll(testLoginAck, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type request struct {
+		packetType packetType
+		data       []byte
+	}
+	requests := make(chan request, 4)
+	done := make(chan error, 1)
+	dialer := pipeDialer{serve: func(conn net.Conn) {
+		defer conn.Close()
+		buf := newTdsBuffer(4096, conn)
+		read := func() ([]byte, error) {
+			packetType, err := buf.BeginRead()
+			if err != nil {
+				return nil, err
+			}
+			data, err := io.ReadAll(buf)
+			requests <- request{packetType, data}
+			return data, err
+		}
+		reply := func(tokens []byte) error {
+			buf.BeginPacket(packReply, false)
+			if _, err := buf.Write(tokens); err != nil {
+				return err
+			}
+			return buf.FinishPacket()
+		}
+		if _, err := read(); err != nil {
+			done <- err
+			return
+		}
+		if err := writePrelogin(packReply, buf, map[uint8][]byte{preloginENCRYPTION: {encryptNotSup}}); err != nil {
+			done <- err
+			return
+		}
+		// the login and the first token get the first challenge, the
+		// second token the second one and the third token the login ack
+		for _, response := range [][]byte{sspiToken("challenge 1"), sspiToken("challenge 2"), loginAck} {
+			if _, err := read(); err != nil {
+				done <- err
+				return
+			}
+			if err := reply(response); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}}
+	config, err := msdsn.Parse("sqlserver://localhost?encrypt=disable&protocol=tcp&dial timeout=5&authenticator=multileg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := &multiLegAuth{tokens: []string{"token 1", "token 2", "token 3"}}
+	c := NewConnectorConfig(config)
+	c.Dialer = dialer
+	c.IntegratedAuthProvider = integratedauth.ProviderFunc(func(msdsn.Config) (integratedauth.IntegratedAuthenticator, error) {
+		return auth, nil
+	})
+	sess, err := connect(context.Background(), c, driverInstanceNoProcess.logger, c.params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.buf.transport.Close()
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	close(requests)
+
+	var got []request
+	for r := range requests {
+		got = append(got, r)
+	}
+	if len(got) != 4 || got[1].packetType != packLogin7 || !bytes.Contains(got[1].data, []byte("token 1")) {
+		t.Fatalf("expected the login to carry the first token, got %q", got)
+	}
+	for i, want := range []string{"token 2", "token 3"} {
+		if r := got[i+2]; r.packetType != packSSPIMessage || string(r.data) != want {
+			t.Errorf("expected the SSPI message %q, got %d %q", want, r.packetType, r.data)
+		}
+	}
+	if !reflect.DeepEqual(auth.challenges, []string{"challenge 1", "challenge 2"}) {
+		t.Errorf("expected both challenges to reach the authenticator, got %q", auth.challenges)
+	}
+}
+
+func TestLoginSSPIUnanswered(t *testing.T) {
+	dialer := pipeDialer{serve: func(conn net.Conn) {
+		defer conn.Close()
+		buf := newTdsBuffer(4096, conn)
+		for i := 0; i < 2; i++ {
+			if _, err := buf.BeginRead(); err != nil {
+				return
+			}
+			if _, err := io.ReadAll(buf); err != nil {
+				return
+			}
+			if i == 0 {
+				_ = writePrelogin(packReply, buf, map[uint8][]byte{preloginENCRYPTION: {encryptNotSup}})
+				continue
+			}
+			// a challenge the authenticator has no answer to
+			buf.BeginPacket(packReply, false)
+			_, _ = buf.Write(sspiToken("challenge"))
+			_ = buf.FinishPacket()
+		}
+		// wait for the client to give up
+		_, _ = buf.BeginRead()
+	}}
+	config, err := msdsn.Parse("sqlserver://localhost?encrypt=disable&protocol=tcp&dial timeout=5&authenticator=multileg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewConnectorConfig(config)
+	c.Dialer = dialer
+	c.IntegratedAuthProvider = integratedauth.ProviderFunc(func(msdsn.Config) (integratedauth.IntegratedAuthenticator, error) {
+		return &multiLegAuth{tokens: []string{"token 1"}}, nil
+	})
+	_, err = connect(context.Background(), c, driverInstanceNoProcess.logger, c.params)
+	if err == nil || !strings.Contains(err.Error(), "did not acknowledge the login") {
+		t.Errorf("expected the login to fail instead of waiting for the server, got %v", err)
+	}
+}
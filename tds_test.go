@@ -31,6 +31,17 @@ func (t *MockTransport) Close() error {
 	return nil
 }
 
+// testConn returns a connection of connector exchanging its packets over
+// transport.
+func testConn(connector *Connector, transport io.ReadWriteCloser) *Conn {
+	return &Conn{
+		connector:      connector,
+		sess:           &tdsSession{buf: newTdsBuffer(1024, transport)},
+		transactionCtx: context.Background(),
+		connectionGood: true,
+	}
+}
+
 func TestConstantsDefined(t *testing.T) {
 	// This test is just here to avoid complaints about unused code.
 	// These constants are part of the spec but not yet used.
@@ -0,0 +1,89 @@
+package mssql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimeOfDay is a value of the TIME(n) SQL type: the time elapsed since
+// midnight, and the scale n, the number of digits of the fractional
+// seconds from 0 to 7. It is sent as a time(Scale) parameter, Duration
+// being rounded to the scale as SQL Server does, and scans TIME values
+// without the date of a time.Time:
+//
+//	var start mssql.TimeOfDay
+//	err = db.QueryRow("select opens from shops where id = @p1", id).Scan(&start)
+//
+// From Go 1.27 scanned values have the scale of the column. With older Go
+// versions they have the scale 7, they hold the value of the column exactly
+// whatever its scale, returned by sql.ColumnType.DecimalSize.
+type TimeOfDay struct {
+	Duration time.Duration
+	Scale    uint8
+}
+
+// TimeFromDuration returns the TimeOfDay of d, the time elapsed since
+// midnight, with the scale 7. A plain time.Duration is sent as a bigint,
+// wrap it to store it in a TIME column:
+//
+//	_, err = db.Exec("insert into shops (id, opens) values (@p1, @p2)", id, mssql.TimeFromDuration(9*time.Hour))
+//
+// The server rounds the time(7) parameter to the scale of the column. A
+// negative duration or one of 24 hours or more is rejected before the
+// statement is sent.
+func TimeFromDuration(d time.Duration) TimeOfDay {
+	return TimeOfDay{Duration: d, Scale: 7}
+}
+
+// Value implements driver.Valuer and returns the time on 0001-01-01 UTC,
+// rounded to the scale.
+func (t TimeOfDay) Value() (driver.Value, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	return decodeTime(t.Scale, t.encode()), nil
+}
+
+// Scan implements sql.Scanner.
+func (t *TimeOfDay) Scan(v interface{}) error {
+	switch vt := v.(type) {
+	case time.Time:
+		midnight := time.Date(vt.Year(), vt.Month(), vt.Day(), 0, 0, 0, 0, vt.Location())
+		*t = TimeOfDay{Duration: vt.Sub(midnight), Scale: 7}
+		return nil
+	case nil:
+		return errors.New("mssql: cannot scan NULL into TimeOfDay")
+	default:
+		return fmt.Errorf("mssql: cannot convert %T to TimeOfDay", v)
+	}
+}
+
+// String returns the time as hh:mm:ss followed by Scale digits of
+// fractional seconds, as "13:45:30.1230000" for a scale of 7.
+func (t TimeOfDay) String() string {
+	d := t.Duration
+	s := fmt.Sprintf("%02d:%02d:%02d", d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second)
+	if t.Scale > 0 && t.Scale <= 7 {
+		s += fmt.Sprintf(".%07d", d%time.Second/100)[:t.Scale+1]
+	}
+	return s
+}
+
+func (t TimeOfDay) check() error {
+	if t.Scale > 7 {
+		return fmt.Errorf("mssql: TimeOfDay scale must be between 0 and 7, got %d", t.Scale)
+	}
+	if t.Duration < 0 || t.Duration >= 24*time.Hour {
+		return fmt.Errorf("mssql: TimeOfDay must be at least 0 and less than 24h, got %v", t.Duration)
+	}
+	return nil
+}
+
+// encode returns the value of the time(Scale) type.
+func (t TimeOfDay) encode() []byte {
+	buf := make([]byte, calcTimeSize(int(t.Scale)))
+	encodeTimeInt(int(t.Duration/time.Second), int(t.Duration%time.Second), int(t.Scale), buf)
+	return buf
+}
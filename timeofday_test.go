@@ -0,0 +1,167 @@
+package mssql
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeOfDayScales(t *testing.T) {
+	d := 13*time.Hour + 45*time.Minute + 30*time.Second + 123456700
+	for scale := uint8(0); scale <= 7; scale++ {
+		tod := TimeOfDay{Duration: d, Scale: scale}
+		buf := tod.encode()
+		if len(buf) != calcTimeSize(int(scale)) {
+			t.Errorf("scale %d: expected %d bytes, got %d", scale, calcTimeSize(int(scale)), len(buf))
+		}
+		sec, ns := decodeTimeInt(scale, buf)
+		got := time.Duration(sec)*time.Second + time.Duration(ns)
+		if want := d.Round(timeScaleUnit(int(scale))); got != want {
+			t.Errorf("scale %d: expected %v, got %v", scale, want, got)
+		}
+	}
+
+	// rounded up to midnight, a time wraps around
+	sec, ns := decodeTimeInt(0, TimeOfDay{Duration: 24*time.Hour - time.Millisecond}.encode())
+	if sec != 0 || ns != 0 {
+		t.Errorf("expected midnight, got %d seconds %d nanoseconds", sec, ns)
+	}
+}
+
+func TestTimeOfDayString(t *testing.T) {
+	d := 9*time.Hour + 5*time.Minute + 7*time.Second + 120000000
+	tests := []struct {
+		tod  TimeOfDay
+		want string
+	}{
+		{TimeOfDay{Duration: d, Scale: 0}, "09:05:07"},
+		{TimeOfDay{Duration: d, Scale: 3}, "09:05:07.120"},
+		{TimeOfDay{Duration: d, Scale: 7}, "09:05:07.1200000"},
+		{TimeOfDay{}, "00:00:00"},
+	}
+	for _, tt := range tests {
+		if got := tt.tod.String(); got != tt.want {
+			t.Errorf("%+v: expected %s, got %s", tt.tod, tt.want, got)
+		}
+	}
+}
+
+func TestTimeOfDayScan(t *testing.T) {
+	var tod TimeOfDay
+	if err := tod.Scan(time.Date(1, 1, 1, 23, 59, 59, 999999900, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if want := 24*time.Hour - 100; tod.Duration != want || tod.Scale != 7 {
+		t.Errorf("expected %v with the scale 7, got %+v", want, tod)
+	}
+	for _, v := range []interface{}{nil, "12:00:00", int64(5)} {
+		if err := tod.Scan(v); err == nil {
+			t.Errorf("expected an error scanning %v", v)
+		}
+	}
+}
+
+func TestTimeOfDayParam(t *testing.T) {
+	tod := TimeOfDay{Duration: 10*time.Hour + 1234567, Scale: 3}
+	p, err := (&Stmt{}).makeParam(tod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ti.TypeId != typeTimeN || p.ti.Scale != 3 || p.ti.Size != 4 {
+		t.Errorf("expected a time(3) of 4 bytes, got %+v", p.ti)
+	}
+	if decl := makeDecl(p.ti); decl != "time(3)" {
+		t.Errorf("expected time(3), got %s", decl)
+	}
+	if got := decodeTime(3, p.buffer); !got.Equal(time.Date(1, 1, 1, 10, 0, 0, 1000000, time.UTC)) {
+		t.Errorf("unexpected value %v", got)
+	}
+	v, err := tod.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(1, 1, 1, 10, 0, 0, 1000000, time.UTC); v != want {
+		t.Errorf("expected the value %v, got %v", want, v)
+	}
+
+	for _, tod := range []TimeOfDay{{Duration: -1}, {Duration: 24 * time.Hour}, {Scale: 8}} {
+		if _, err = (&Stmt{}).makeParam(tod); err == nil {
+			t.Errorf("expected an error for %+v", tod)
+		}
+		if _, err = tod.Value(); err == nil {
+			t.Errorf("expected an error from Value for %+v", tod)
+		}
+	}
+}
+
+func TestTimeOfDayRoundTrip(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	d := 23*time.Hour + 59*time.Minute + 59*time.Second + 999999900
+	for scale := uint8(0); scale <= 7; scale++ {
+		in := TimeOfDay{Duration: d.Truncate(timeScaleUnit(int(scale))), Scale: scale}
+		var out TimeOfDay
+		var str string
+		err := db.QueryRow("select @p1, cast(@p1 as varchar(16))", in).Scan(&out, &str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Duration != in.Duration {
+			t.Errorf("scale %d: expected %v, got %v", scale, in.Duration, out.Duration)
+		}
+		if want := in.String(); str != want {
+			t.Errorf("scale %d: expected the server to read %s, got %s", scale, want, str)
+		}
+	}
+}
+
+func TestTimeFromDuration(t *testing.T) {
+	d := 8*time.Hour + 30*time.Minute + 1234567
+	p, err := (&Stmt{}).makeParam(TimeFromDuration(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decl := makeDecl(p.ti); decl != "time(7)" {
+		t.Errorf("expected time(7), got %s", decl)
+	}
+	sec, ns := decodeTimeInt(7, p.buffer)
+	if got := time.Duration(sec)*time.Second + time.Duration(ns); got != d.Round(100) {
+		t.Errorf("expected %v, got %v", d.Round(100), got)
+	}
+
+	for _, d := range []time.Duration{-time.Second, 24 * time.Hour, 30 * time.Hour} {
+		_, err := (&Stmt{}).makeParam(TimeFromDuration(d))
+		if err == nil || !strings.Contains(err.Error(), "less than 24h") {
+			t.Errorf("expected an error for %v, got %v", d, err)
+		}
+	}
+}
+
+func TestTimeFromDurationInsert(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(context.Background(), "create table #shops (opens time(3))"); err != nil {
+		t.Fatal(err)
+	}
+	d := 9*time.Hour + 15*time.Minute + 1234567890
+	if _, err = conn.ExecContext(context.Background(), "insert into #shops values (@p1)", TimeFromDuration(d)); err != nil {
+		t.Fatal(err)
+	}
+	var tod TimeOfDay
+	if err = conn.QueryRowContext(context.Background(), "select opens from #shops").Scan(&tod); err != nil {
+		t.Fatal(err)
+	}
+	if want := d.Round(time.Millisecond); tod.Duration != want {
+		t.Errorf("expected %v rounded to the column scale, got %v", want, tod.Duration)
+	}
+}
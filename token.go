@@ -8,10 +8,12 @@ import (
 	"io"
 	"io/ioutil"
 	"net"
+	"reflect"
 	"strconv"
 
 	"github.com/golang-sql/sqlexp"
 	"github.com/microsoft/go-mssqldb/aecmk"
+	"github.com/microsoft/go-mssqldb/internal/cp"
 	"github.com/microsoft/go-mssqldb/internal/github.com/swisscom/mssql-always-encrypted/pkg/algorithms"
 	"github.com/microsoft/go-mssqldb/internal/github.com/swisscom/mssql-always-encrypted/pkg/encryption"
 	"github.com/microsoft/go-mssqldb/internal/github.com/swisscom/mssql-always-encrypted/pkg/keys"
@@ -27,6 +29,9 @@ type token byte
 const (
 	tokenReturnStatus  token = 121 // 0x79
 	tokenColMetadata   token = 129 // 0x81
+	tokenAltMetadata   token = 136 // 0x88
+	tokenTabName       token = 164 // 0xA4
+	tokenColInfo       token = 165 // 0xA5
 	tokenOrder         token = 169 // 0xA9
 	tokenError         token = 170 // 0xAA
 	tokenInfo          token = 171 // 0xAB
@@ -35,6 +40,7 @@ const (
 	tokenFeatureExtAck token = 174 // 0xae
 	tokenRow           token = 209 // 0xd1
 	tokenNbcRow        token = 210 // 0xd2
+	tokenAltRow        token = 211 // 0xd3
 	tokenEnvChange     token = 227 // 0xE3
 	tokenSSPI          token = 237 // 0xED
 	tokenFedAuthInfo   token = 238 // 0xEE
@@ -105,8 +111,13 @@ const (
 // COLMETADATA flags
 // https://msdn.microsoft.com/en-us/library/dd357363.aspx
 const (
-	colFlagNullable  = 1
-	colFlagEncrypted = 0x0800
+	colFlagNullable        = 1
+	colFlagUpdateable      = 0x0c
+	colFlagIdentity        = 0x10
+	colFlagComputed        = 0x20
+	colFlagSparseColumnSet = 0x0400
+	colFlagEncrypted       = 0x0800
+	colFlagHidden          = 0x2000
 	// TODO implement more flags
 )
 
@@ -222,7 +233,6 @@ func processEnvChg(ctx context.Context, sess *tdsSession) {
 				badStreamPanic(err)
 			}
 		case envSqlCollation:
-			// currently ignored
 			var collationSize uint8
 			err = binary.Read(r, binary.LittleEndian, &collationSize)
 			if err != nil {
@@ -247,6 +257,7 @@ func processEnvChg(ctx context.Context, sess *tdsSession) {
 			if err != nil {
 				badStreamPanic(err)
 			}
+			sess.collation = cp.Collation{LcidAndFlags: info, SortId: sortID}
 
 			// old value, should be 0
 			if _, err = readBVarChar(r); err != nil {
@@ -412,19 +423,28 @@ func parseOrder(r *tdsBuffer) (res orderStruct) {
 	return res
 }
 
+// parseRowCount reads the row count of a DONE token, a 32-bit value
+// before TDS 7.2.
+func parseRowCount(r *tdsBuffer, s *tdsSession) uint64 {
+	if s.beforeTDS72() {
+		return uint64(r.uint32())
+	}
+	return r.uint64()
+}
+
 // https://msdn.microsoft.com/en-us/library/dd340421.aspx
-func parseDone(r *tdsBuffer) (res doneStruct) {
+func parseDone(r *tdsBuffer, s *tdsSession) (res doneStruct) {
 	res.Status = r.uint16()
 	res.CurCmd = r.uint16()
-	res.RowCount = r.uint64()
+	res.RowCount = parseRowCount(r, s)
 	return res
 }
 
 // https://msdn.microsoft.com/en-us/library/dd340553.aspx
-func parseDoneInProc(r *tdsBuffer) (res doneInProcStruct) {
+func parseDoneInProc(r *tdsBuffer, s *tdsSession) (res doneInProcStruct) {
 	res.Status = r.uint16()
 	res.CurCmd = r.uint16()
-	res.RowCount = r.uint64()
+	res.RowCount = parseRowCount(r, s)
 	return res
 }
 
@@ -543,6 +563,10 @@ type colAckStruct struct {
 	EnclaveType string
 }
 
+// utf8SupportAck tells whether the server sends the data of UTF-8
+// collations as UTF-8.
+type utf8SupportAck bool
+
 type featureExtAck map[byte]interface{}
 
 func parseFeatureExtAck(r *tdsBuffer) featureExtAck {
@@ -582,6 +606,11 @@ func parseFeatureExtAck(r *tdsBuffer) featureExtAck {
 
 			}
 			ack[feature] = colAck
+		case featExtUTF8SUPPORT:
+			if length > 0 {
+				ack[feature] = utf8SupportAck(r.byte() != 0)
+				length--
+			}
 		}
 
 		// Skip unprocessed bytes
@@ -609,7 +638,7 @@ func parseColMetadata72(r *tdsBuffer, s *tdsSession) (columns []columnStruct) {
 
 	for i := range columns {
 		column := &columns[i]
-		baseTi := getBaseTypeInfo(r, true)
+		baseTi := getBaseTypeInfo(r, true, s.beforeTDS72())
 		typeInfo := readTypeInfo(r, baseTi.TypeId, column.cryptoMeta, s.encoding)
 		typeInfo.UserType = baseTi.UserType
 		typeInfo.Flags = baseTi.Flags
@@ -633,8 +662,15 @@ func parseColMetadata72(r *tdsBuffer, s *tdsSession) (columns []columnStruct) {
 	return columns
 }
 
-func getBaseTypeInfo(r *tdsBuffer, parseFlags bool) typeInfo {
-	userType := r.uint32()
+// getBaseTypeInfo reads the user type, a 16-bit value before TDS 7.2 when
+// shortUserType is set, the flags and the type id.
+func getBaseTypeInfo(r *tdsBuffer, parseFlags bool, shortUserType bool) typeInfo {
+	var userType uint32
+	if shortUserType {
+		userType = uint32(r.uint16())
+	} else {
+		userType = r.uint32()
+	}
 	flags := uint16(0)
 	if parseFlags {
 		flags = r.uint16()
@@ -663,7 +699,7 @@ func parseCryptoMetadata(r *tdsBuffer, cekTable *cekTable, encoding msdsn.Encode
 		ordinal = r.uint16()
 	}
 
-	typeInfo := getBaseTypeInfo(r, false)
+	typeInfo := getBaseTypeInfo(r, false, false)
 	ti := readTypeInfo(r, typeInfo.TypeId, nil, encoding)
 	ti.UserType = typeInfo.UserType
 	ti.Flags = typeInfo.Flags
@@ -925,7 +961,7 @@ func parseReturnValue(r *tdsBuffer, s *tdsSession) (nv namedValue) {
 	nv.Name = r.BVarChar() // ParamName
 	_ = r.byte()           // Status
 
-	ti := getBaseTypeInfo(r, true) // UserType + Flags + TypeInfo
+	ti := getBaseTypeInfo(r, true, s.beforeTDS72()) // UserType + Flags + TypeInfo
 
 	var cryptoMetadata *cryptoMetadata = nil
 	if s.alwaysEncrypted && (ti.Flags&fEncrypted) == fEncrypted {
@@ -976,6 +1012,21 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 		badStreamPanic(fmt.Errorf("unexpected packet type in reply: got %v, expected %v", packet_type, packReply))
 	}
 	var columns []columnStruct
+	// the base tables of a browse mode query, named by COLINFO
+	var tables []string
+	// the columns of the COMPUTE clauses by id, and the id of the compute
+	// row last sent, -1 once a row of the query follows
+	var computeCols map[uint16][]columnStruct
+	computeSent := -1
+	// resultSet starts another result set while reading the rows, the
+	// compute rows are their own result sets
+	resultSet := func(cols []columnStruct) {
+		ch <- cols
+		if outs.msgq != nil {
+			_ = sqlexp.ReturnMessageEnqueue(ctx, outs.msgq, sqlexp.MsgNextResultSet{})
+			_ = sqlexp.ReturnMessageEnqueue(ctx, outs.msgq, sqlexp.MsgNext{})
+		}
+	}
 	errs := make([]Error, 0, 5)
 	for tokens := 0; ; tokens += 1 {
 		token := token(sess.buf.byte())
@@ -992,6 +1043,9 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 			ch <- returnStatus
 		case tokenLoginAck:
 			loginAck := parseLoginAck(sess.buf)
+			// the tokens following the login acknowledgement are read
+			// according to the TDS version of the server
+			sess.loginAck = loginAck
 			ch <- loginAck
 		case tokenFeatureExtAck:
 			featureExtAck := parseFeatureExtAck(sess.buf)
@@ -999,8 +1053,12 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 		case tokenOrder:
 			order := parseOrder(sess.buf)
 			ch <- order
+		case tokenTabName:
+			tables = parseTabName(sess.buf, sess)
+		case tokenColInfo:
+			ch <- parseColInfo(sess.buf, columns, tables)
 		case tokenDoneInProc:
-			done := parseDoneInProc(sess.buf)
+			done := parseDoneInProc(sess.buf, sess)
 
 			ch <- done
 			if done.Status&doneCount != 0 {
@@ -1026,7 +1084,7 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 				return
 			}
 		case tokenDone, tokenDoneProc:
-			done := parseDone(sess.buf)
+			done := parseDone(sess.buf, sess)
 			done.errors = errs
 			if outs.msgq != nil {
 				errs = make([]Error, 0, 5)
@@ -1062,13 +1120,44 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 			}
 		case tokenColMetadata:
 			columns = parseColMetadata72(sess.buf, sess)
+			if outs.cursor != nil && outs.cursor.columns != nil {
+				// the rows of a cursor fetch use the metadata of sp_cursoropen
+				if columns == nil {
+					columns = outs.cursor.columns
+				}
+				break
+			}
+			if xmlStreamFromContext(ctx) {
+				streamLastXMLColumn(columns)
+			}
+			tables = nil
+			computeCols = nil
+			computeSent = -1
+			readBrowseTokens(sess, columns, &tables)
 			ch <- columns
 			colsReceived = true
 			if outs.msgq != nil {
 				_ = sqlexp.ReturnMessageEnqueue(ctx, outs.msgq, sqlexp.MsgNext{})
 			}
 
+		case tokenAltMetadata:
+			id, cols := parseAltMetadata(sess.buf, sess)
+			if computeCols == nil {
+				computeCols = make(map[uint16][]columnStruct)
+			}
+			computeCols[id] = cols
+		case tokenAltRow:
+			id, cols, row := parseAltRow(sess.buf, computeCols)
+			if computeSent != int(id) {
+				resultSet(cols)
+				computeSent = int(id)
+			}
+			ch <- row
 		case tokenRow:
+			if computeSent >= 0 {
+				resultSet(columns)
+				computeSent = -1
+			}
 			row := make([]interface{}, len(columns))
 			err = parseRow(ctx, sess.buf, sess, columns, row)
 			if err != nil {
@@ -1076,7 +1165,14 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 				return
 			}
 			ch <- row
+			if xr := streamedXML(row); xr != nil {
+				xr.pump()
+			}
 		case tokenNbcRow:
+			if computeSent >= 0 {
+				resultSet(columns)
+				computeSent = -1
+			}
 			row := make([]interface{}, len(columns))
 			err = parseNbcRow(ctx, sess.buf, sess, columns, row)
 			if err != nil {
@@ -1084,6 +1180,9 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 				return
 			}
 			ch <- row
+			if xr := streamedXML(row); xr != nil {
+				xr.pump()
+			}
 		case tokenEnvChange:
 			processEnvChg(ctx, sess)
 		case tokenError:
@@ -1091,6 +1190,9 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 			sess.LogF(ctx, msdsn.LogDebug, "got ERROR %d %s", err.Number, err.Message)
 			errs = append(errs, err)
 			sess.LogS(ctx, msdsn.LogErrors, err.Message)
+			if outs.messages {
+				ch <- rawMessage{err}
+			}
 			if outs.msgq != nil {
 				_ = sqlexp.ReturnMessageEnqueue(ctx, outs.msgq, sqlexp.MsgError{Error: err})
 			}
@@ -1098,12 +1200,23 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 			info := parseInfo(sess.buf)
 			sess.LogF(ctx, msdsn.LogDebug, "got INFO %d %s", info.Number, info.Message)
 			sess.LogS(ctx, msdsn.LogMessages, info.Message)
+			handleMessage(ctx, info)
+			if outs.messages {
+				ch <- rawMessage{info}
+			}
 			if outs.msgq != nil {
 				_ = sqlexp.ReturnMessageEnqueue(ctx, outs.msgq, sqlexp.MsgNotice{Message: info})
 			}
 		case tokenReturnValue:
 			nv := parseReturnValue(sess.buf, sess)
-			if len(nv.Name) > 0 {
+			if outs.cursor != nil {
+				outs.cursor.returnValue(nv)
+			} else if outs.prepared != nil {
+				// statements run with sp_prepexec have no other output parameters
+				if handle, ok := nv.Value.(int64); ok {
+					outs.prepared.cache.put(outs.prepared.key, int32(handle))
+				}
+			} else if len(nv.Name) > 0 {
 				name := nv.Name[1:] // Remove the leading "@".
 				if ov, has := outs.params[name]; has {
 					err = scanIntoOut(name, nv.Value, ov)
@@ -1127,6 +1240,8 @@ type tokenProcessor struct {
 	lastRow    []interface{}
 	rowCount   int64
 	firstError error
+	// the row count of each statement, in the order of the DONE tokens
+	rowCounts []int64
 	// whether to skip sending attention when ctx is done
 	noAttn bool
 }
@@ -1157,10 +1272,12 @@ func (t *tokenProcessor) iterateResponse() error {
 				case doneInProcStruct:
 					if token.Status&doneCount != 0 {
 						t.rowCount += int64(token.RowCount)
+						t.rowCounts = append(t.rowCounts, int64(token.RowCount))
 					}
 				case doneStruct:
 					if token.Status&doneCount != 0 {
 						t.rowCount += int64(token.RowCount)
+						t.rowCounts = append(t.rowCounts, int64(token.RowCount))
 					}
 					if token.isError() && t.firstError == nil {
 						t.firstError = token.getError()
@@ -1234,10 +1351,14 @@ func (t tokenProcessor) nextToken() (tokenStruct, error) {
 		// first lets finish reading current response and look
 		// for confirmation in it
 		if readCancelConfirmation(t.tokChan) {
-			// we got confirmation in current response
+			// we got confirmation in current response, the statement was
+			// interrupted and the output parameters the server may have
+			// sent are not all there
+			clearOutputs(t.outs.params)
 			return nil, t.ctx.Err()
 		}
-		// we did not get cancellation confirmation in the current response
+		// we did not get cancellation confirmation in the current response,
+		// the statement completed and its output parameters are set
 		// read one more response, it must be there
 		t.tokChan = make(chan tokenStruct, 5)
 		go processSingleResponse(t.ctx, t.sess, t.tokChan, t.outs)
@@ -1250,6 +1371,17 @@ func (t tokenProcessor) nextToken() (tokenStruct, error) {
 	}
 }
 
+// clearOutputs sets the destinations of the output parameters of a
+// cancelled statement to their zero value, so they do not hold a mix of
+// input values and values sent before the cancellation.
+func clearOutputs(params map[string]interface{}) {
+	for _, dest := range params {
+		if v := reflect.ValueOf(dest); v.Kind() == reflect.Ptr && !v.IsNil() {
+			v.Elem().Set(reflect.Zero(v.Elem().Type()))
+		}
+	}
+}
+
 func readCancelConfirmation(tokChan chan tokenStruct) bool {
 	for tok := range tokChan {
 		switch tok := tok.(type) {
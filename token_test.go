@@ -1,9 +1,17 @@
 package mssql
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
 	"encoding/hex"
+	"fmt"
+	"reflect"
 	"regexp"
 	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
 )
 
 func TestParseFeatureExtAck(t *testing.T) {
@@ -40,3 +48,260 @@ func TestParseFeatureExtAck(t *testing.T) {
 		parseFeatureExtAck(r)
 	}
 }
+
+func TestParseFeatureExtAckUTF8Support(t *testing.T) {
+	tests := []struct {
+		data string
+		want interface{}
+	}{
+		{"0A0100000001FF", utf8SupportAck(true)},
+		{"0A0100000000FF", utf8SupportAck(false)},
+		{"0900000000FF", nil},
+	}
+	for _, tst := range tests {
+		b, err := hex.DecodeString(tst.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &tdsBuffer{packetSize: len(b), rbuf: b, rsize: len(b)}
+		ack := parseFeatureExtAck(r)
+		if got := ack[featExtUTF8SUPPORT]; got != tst.want {
+			t.Errorf("%s: expected %v, got %v", tst.data, tst.want, got)
+		}
+		if r.rpos != len(b) {
+			t.Errorf("%s: expected the ack to be read to the end, stopped at %d", tst.data, r.rpos)
+		}
+	}
+}
+
+func TestProcessEnvChgRouting(t *testing.T) {
+	server := str2ucs2(`replica.example.com\inst`)
+	value := []byte{0}                // protocol TCP
+	value = append(value, 0x99, 0x05) // port 1433
+	value = append(value, byte(len(server)/2), 0)
+	value = append(value, server...)
+
+	data := []byte{envRouting, byte(len(value)), 0}
+	data = append(data, value...)
+	data = append(data, 0, 0) // old value
+	b := append([]byte{byte(len(data)), 0}, data...)
+
+	sess := &tdsSession{
+		buf: &tdsBuffer{packetSize: len(b), rbuf: b, rsize: len(b)},
+	}
+	processEnvChg(context.Background(), sess)
+	if sess.routedServer != `replica.example.com\inst` || sess.routedPort != 1433 {
+		t.Fatalf("unexpected route %s:%d", sess.routedServer, sess.routedPort)
+	}
+
+	p := routeParams(msdsn.Config{Host: "primary", Port: 1433, TLSConfig: &tls.Config{ServerName: "primary"}},
+		&connRoute{server: sess.routedServer, port: 11000})
+	if p.Host != "replica.example.com" || p.Instance != "inst" || p.Port != 11000 {
+		t.Errorf("unexpected routed params %s\\%s:%d", p.Host, p.Instance, p.Port)
+	}
+	if p.TLSConfig.ServerName != "replica.example.com" {
+		t.Errorf("expected the TLS server name to follow the route, got %s", p.TLSConfig.ServerName)
+	}
+}
+
+func TestParseError72(t *testing.T) {
+	bvarchar := func(s string) []byte {
+		return append([]byte{byte(len(s))}, str2ucs2(s)...)
+	}
+	message := str2ucs2("Invalid column name 'bad'.")
+	data := []byte{0xcf, 0, 0, 0, 1, 16} // number 207, state 1, class 16
+	data = append(data, byte(len(message)/2), 0)
+	data = append(data, message...)
+	data = append(data, bvarchar("sqlhost")...)
+	data = append(data, bvarchar("usp_report")...)
+	data = append(data, 3, 0, 0, 0) // line 3
+	b := append([]byte{byte(len(data)), 0}, data...)
+
+	err := parseError72(&tdsBuffer{packetSize: len(b), rbuf: b, rsize: len(b)})
+	if err.Number != 207 || err.State != 1 || err.Class != 16 || err.Message != "Invalid column name 'bad'." {
+		t.Errorf("unexpected error %+v", err)
+	}
+	if err.ServerName != "sqlhost" || err.ProcName != "usp_report" || err.LineNo != 3 {
+		t.Errorf("unexpected location %s %s %d", err.ServerName, err.ProcName, err.LineNo)
+	}
+	if err.Error() != "mssql: Invalid column name 'bad'." {
+		t.Errorf("unexpected error string %s", err.Error())
+	}
+}
+
+func TestLoginAckTDS71(t *testing.T) {
+	progName := str2ucs2("Microsoft SQL Server")
+	loginAck := []byte{1, 0x71, 0, 0, 1, byte(len(progName) / 2)} // TDS 7.1 rev 1
+	loginAck = append(loginAck, progName...)
+	loginAck = append(loginAck, 8, 0, 0x07, 0xd0) // program version
+	loginAck = append([]byte{byte(tokenLoginAck), byte(len(loginAck)), 0}, loginAck...)
+	// 32-bit row counts and 16-bit user types
+	done := []byte{byte(tokenDone), 0, 0, 0, 0, 0, 0, 0, 0}
+	metadata := []byte{byte(tokenColMetadata), 1, 0, 0, 0, colFlagNullable, 0, typeIntN, 4, 1}
+	metadata = append(metadata, str2ucs2("n")...)
+	row := []byte{byte(tokenRow), 4, 42, 0, 0, 0}
+	selectDone := []byte{byte(tokenDone), doneCount, 0, cmdSelect, 0, 1, 0, 0, 0}
+
+	transport := &scriptedTransport{}
+	transport.reply(loginAck, done)
+	transport.reply(metadata, row, selectDone)
+	sess := &tdsSession{buf: newTdsBuffer(1024, transport)}
+	read := func() (tokens []tokenStruct) {
+		ch := make(chan tokenStruct, 5)
+		go processSingleResponse(context.Background(), sess, ch, outputs{})
+		for tok := range ch {
+			if err, ok := tok.(error); ok {
+				t.Fatal(err)
+			}
+			tokens = append(tokens, tok)
+		}
+		return
+	}
+
+	tokens := read()
+	if len(tokens) != 2 {
+		t.Fatalf("expected the login acknowledgement and done tokens, got %v", tokens)
+	}
+	if sess.loginAck.TDSVersion != verTDS71rev1 {
+		t.Errorf("expected TDS version %x, got %x", verTDS71rev1, sess.loginAck.TDSVersion)
+	}
+	c := &Conn{sess: sess, connectionGood: true}
+	if state, err := c.ConnectionState(); err != nil || state.TDSVersion != verTDS71rev1 {
+		t.Errorf("expected the TDS version in the connection state, got %x, %v", state.TDSVersion, err)
+	}
+
+	tokens = read()
+	if len(tokens) != 3 {
+		t.Fatalf("expected the metadata, row and done tokens, got %v", tokens)
+	}
+	if cols, ok := tokens[0].([]columnStruct); !ok || len(cols) != 1 || cols[0].ColName != "n" {
+		t.Errorf("unexpected metadata %v", tokens[0])
+	}
+	if values, ok := tokens[1].([]interface{}); !ok || len(values) != 1 || values[0] != int64(42) {
+		t.Errorf("unexpected row %v", tokens[1])
+	}
+	if d, ok := tokens[2].(doneStruct); !ok || d.RowCount != 1 {
+		t.Errorf("expected a row count of 1, got %v", tokens[2])
+	}
+}
+
+func TestRequestHeadersTDS71(t *testing.T) {
+	transport := &scriptedTransport{}
+	// 32-bit row counts
+	transport.reply([]byte{byte(tokenDone), doneCount, 0, 0, 0, 1, 0, 0, 0})
+	transport.reply([]byte{byte(tokenDoneProc), doneCount, 0, 0, 0, 1, 0, 0, 0})
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+	c.sess.loginAck.TDSVersion = verTDS71rev1
+	ctx := context.Background()
+	if _, err := (&Stmt{c: c, query: "update t set n = 1"}).exec(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Stmt{c: c, query: "update t set n = @p1"}).exec(ctx, []namedValue{{Ordinal: 1, Value: int64(2)}}); err != nil {
+		t.Fatal(err)
+	}
+	var requests [][]byte
+	for b := transport.requests.Bytes(); len(b) > 0; b = b[binary.BigEndian.Uint16(b[2:]):] {
+		requests = append(requests, b[8:binary.BigEndian.Uint16(b[2:])])
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	// the batch text and the procedure id come first, without ALL_HEADERS
+	if want := str2ucs2("update t set n = 1"); !bytes.Equal(requests[0], want) {
+		t.Errorf("expected the batch text only, got % x", requests[0])
+	}
+	if id := binary.LittleEndian.Uint16(requests[1][2:]); binary.LittleEndian.Uint16(requests[1]) != 0xffff || id != sp_ExecuteSql.id {
+		t.Errorf("expected the id of sp_executesql first, got % x", requests[1][:4])
+	}
+}
+
+func TestReturnStatusAndOutputParam(t *testing.T) {
+	name := str2ucs2("@out")
+	returnValue := []byte{byte(tokenReturnValue), 1, 0, byte(len(name) / 2)}
+	returnValue = append(returnValue, name...)
+	returnValue = append(returnValue, 1, 0, 0, 0, 0, colFlagNullable, 0, typeIntN, 4, 4, 7, 0, 0, 0)
+	returnStatus := []byte{byte(tokenReturnStatus), 42, 0, 0, 0}
+	doneProc := []byte{byte(tokenDoneProc), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	transport := &scriptedTransport{}
+	transport.reply(returnValue, returnStatus, doneProc)
+	sess := &tdsSession{buf: newTdsBuffer(1024, transport)}
+	var out int64
+	var rs ReturnStatus
+	outs := outputs{params: map[string]interface{}{"out": &out}, returnStatus: &rs}
+	if err := startReading(sess, context.Background(), outs).iterateResponse(); err != nil {
+		t.Fatal(err)
+	}
+	if rs != 42 {
+		t.Errorf("expected the return status 42, got %d", rs)
+	}
+	if out != 7 {
+		t.Errorf("expected the output parameter 7, got %d", out)
+	}
+}
+
+func TestParseNbcRowWide(t *testing.T) {
+	// 20 columns of alternating types, the odd columns are NULL
+	const count = 20
+	metadata := []byte{byte(tokenColMetadata), count, 0}
+	bitmap := make([]byte, (count+7)/8)
+	row := []byte{byte(tokenNbcRow)}
+	var values []byte
+	want := make([]interface{}, count)
+	for i := 0; i < count; i++ {
+		metadata = append(metadata, 0, 0, 0, 0, colFlagNullable, 0)
+		switch i % 3 {
+		case 0:
+			metadata = append(metadata, typeIntN, 4)
+		case 1:
+			metadata = append(metadata, typeNVarChar, 20, 0, 0, 0, 0, 0, 0)
+		case 2:
+			metadata = append(metadata, typeBitN, 1)
+		}
+		name := str2ucs2(fmt.Sprintf("c%d", i))
+		metadata = append(metadata, byte(len(name)/2))
+		metadata = append(metadata, name...)
+		if i%2 == 1 {
+			bitmap[i/8] |= 1 << (uint(i) % 8)
+			continue
+		}
+		switch i % 3 {
+		case 0:
+			values = append(values, 4, byte(i), 0, 0, 0)
+			want[i] = int64(i)
+		case 1:
+			v := str2ucs2(fmt.Sprintf("v%d", i))
+			values = append(values, byte(len(v)), 0)
+			values = append(values, v...)
+			want[i] = fmt.Sprintf("v%d", i)
+		case 2:
+			values = append(values, 1, 1)
+			want[i] = true
+		}
+	}
+	row = append(row, bitmap...)
+	row = append(row, values...)
+	selectDone := []byte{byte(tokenDone), doneCount, 0, cmdSelect, 0, 1, 0, 0, 0, 0, 0, 0, 0}
+
+	transport := &scriptedTransport{}
+	transport.reply(metadata, row, selectDone)
+	sess := &tdsSession{buf: newTdsBuffer(1024, transport)}
+	ch := make(chan tokenStruct, 5)
+	go processSingleResponse(context.Background(), sess, ch, outputs{})
+	var tokens []tokenStruct
+	for tok := range ch {
+		if err, ok := tok.(error); ok {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, tok)
+	}
+	if len(tokens) != 3 {
+		t.Fatalf("expected the metadata, row and done tokens, got %v", tokens)
+	}
+	if got, ok := tokens[1].([]interface{}); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the row %v, got %v", want, tokens[1])
+	}
+	if d, ok := tokens[2].(doneStruct); !ok || d.RowCount != 1 {
+		t.Errorf("expected the done token after the row, got %v", tokens[2])
+	}
+}
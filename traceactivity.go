@@ -0,0 +1,67 @@
+package mssql
+
+import (
+	"context"
+	"encoding/binary"
+	"sync/atomic"
+)
+
+type traceActivityContextKey struct{}
+
+// traceActivity is an activity whose requests are numbered in sequence,
+// possibly over several connections.
+type traceActivity struct {
+	id  UniqueIdentifier
+	seq uint32
+}
+
+// ActivityIDContext returns a copy of ctx that makes the requests run with
+// it send id as their trace activity when Connector.SendTraceActivity is
+// set, each request of the activity with the next sequence number. SQL
+// Server reports them in the attach_activity_id action of extended events,
+// which lets the events of a client operation be found with its id:
+//
+//	ctx = mssql.ActivityIDContext(ctx, operationID)
+//	rows, err := db.QueryContext(ctx, "select ...")
+//
+// Requests run with a context without an activity id send the activity id
+// of the connection, the one of the prelogin trace id.
+func ActivityIDContext(ctx context.Context, id UniqueIdentifier) context.Context {
+	return context.WithValue(ctx, traceActivityContextKey{}, &traceActivity{id: id})
+}
+
+// requestHeaders returns the headers of a request sent with ctx in the
+// transaction tranid, none for servers before TDS 7.2.
+func (c *Conn) requestHeaders(ctx context.Context, tranid uint64) []headerStruct {
+	if c.sess.beforeTDS72() {
+		return nil
+	}
+	headers := []headerStruct{
+		{hdrtype: dataStmHdrTransDescr,
+			data: transDescrHdr{tranid, 1}.pack()},
+	}
+	if c.connector != nil && c.connector.SendTraceActivity {
+		headers = append(headers, c.traceActivityHeader(ctx))
+	}
+	return headers
+}
+
+// traceActivityHeader returns the trace activity header of the next
+// request of the activity of ctx, or of the connection.
+func (c *Conn) traceActivityHeader(ctx context.Context) headerStruct {
+	var id UniqueIdentifier
+	var seq uint32
+	if activity, ok := ctx.Value(traceActivityContextKey{}).(*traceActivity); ok {
+		id = activity.id
+		seq = atomic.AddUint32(&activity.seq, 1)
+	} else {
+		id = c.sess.activityid
+		c.sess.activitySeq++
+		seq = c.sess.activitySeq
+	}
+	data := make([]byte, 16+4)
+	guid, _ := id.Value()
+	copy(data, guid.([]byte))
+	binary.LittleEndian.PutUint32(data[16:], seq)
+	return headerStruct{hdrtype: dataStmHdrTraceActivity, data: data}
+}
@@ -0,0 +1,104 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// requestHeaders returns the ALL_HEADERS of each request written to the
+// transport, each request being a single packet.
+func (t *scriptedTransport) requestHeaders() (headers [][]byte) {
+	b := t.requests.Bytes()
+	for len(b) > 0 {
+		size := binary.BigEndian.Uint16(b[2:])
+		data := b[8:size]
+		headers = append(headers, data[:binary.LittleEndian.Uint32(data)])
+		b = b[size:]
+	}
+	return
+}
+
+func traceActivityBytes(id UniqueIdentifier, seq uint32) []byte {
+	b := []byte{26, 0, 0, 0, dataStmHdrTraceActivity, 0}
+	guid, _ := id.Value()
+	b = append(b, guid.([]byte)...)
+	b = append(b, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b[len(b)-4:], seq)
+	return b
+}
+
+func TestTraceActivityHeader(t *testing.T) {
+	transport := &scriptedTransport{}
+	for i := 0; i < 5; i++ {
+		transport.reply(cursorDoneProc())
+	}
+	connector := newConnector(msdsn.Config{}, nil)
+	connector.SendTraceActivity = true
+	c := testConn(connector, transport)
+	c.sess.activityid = UniqueIdentifier{0x12, 0x34, 0x56, 0x78, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	operation := UniqueIdentifier{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	ctx := ActivityIDContext(context.Background(), operation)
+	for _, ctx := range []context.Context{ctx, ctx, context.Background(), context.Background()} {
+		if _, err := (&Stmt{c: c, query: "select 1"}).exec(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []UniqueIdentifier{operation, operation, c.sess.activityid, c.sess.activityid}
+	seqs := []uint32{1, 2, 1, 2}
+	headers := transport.requestHeaders()
+	if len(headers) != len(want) {
+		t.Fatalf("expected %d requests, got %d", len(want), len(headers))
+	}
+	for i, h := range headers {
+		activity := traceActivityBytes(want[i], seqs[i])
+		if !bytes.HasSuffix(h, activity) {
+			t.Errorf("request %d: expected the trace activity header % x, got the headers % x", i, activity, h)
+		}
+		// the id is sent in the byte order of the uniqueidentifier values
+		if i == 0 && !bytes.Contains(h, []byte{0xdd, 0xcc, 0xbb, 0xaa, 0xff, 0xee, 2, 1}) {
+			t.Errorf("expected the id in the uniqueidentifier byte order, got % x", h)
+		}
+	}
+
+	connector.SendTraceActivity = false
+	transport.requests.Reset()
+	if _, err := (&Stmt{c: c, query: "select 1"}).exec(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if h := transport.requestHeaders()[0]; len(h) != 4+4+2+12 {
+		t.Errorf("expected only the transaction descriptor header, got % x", h)
+	}
+}
+
+func TestTraceActivityQuery(t *testing.T) {
+	checkConnStr(t)
+	connector, err := NewConnector(makeConnStr(t).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	connector.SendTraceActivity = true
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := ActivityIDContext(context.Background(), UniqueIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err = tx.QueryRowContext(ctx, "select @p1", 1).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.QueryRow("select 2").Scan(&n); err != nil || n != 2 {
+		t.Errorf("expected 2 from a query with the connection activity, got %d, %v", n, err)
+	}
+}
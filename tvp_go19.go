@@ -9,6 +9,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strings"
 	"time"
@@ -20,6 +21,7 @@ const (
 	jsonTag      = "json"
 	tvpTag       = "tvp"
 	tvpIdentity  = "@identity"
+	tvpDefault   = "@default"
 	skipTagValue = "-"
 	sqlSeparator = "."
 )
@@ -34,10 +36,21 @@ var (
 )
 
 // TVP is driver type, which allows supporting Table Valued Parameters (TVP) in SQL Server
+//
+// Value is usually a slice of structs. It may also be a channel of structs or
+// an iterator function of the form func(yield func(T) bool). Their rows are
+// encoded while the request is sent, until the channel is closed or the
+// iterator returns, so the rows don't have to be held in memory. Such a TVP
+// can only be used once and the query is not retried on another connection.
+//
+// The fields are the columns of the table type, in order. A field tagged
+// `tvp:"-"` is skipped. A field tagged `tvp:"@identity"` or `tvp:"@default"`
+// leaves its column to the server, which is required for identity, computed
+// and rowversion columns; its value is not sent.
 type TVP struct {
 	//TypeName mustn't be default value
 	TypeName string
-	//Value must be the slice, mustn't be nil
+	//Value must be the slice, channel or iterator function, mustn't be nil
 	Value interface{}
 }
 
@@ -52,22 +65,132 @@ func (tvp TVP) check() error {
 		return ErrorObjectName
 	}
 	valueOf := reflect.ValueOf(tvp.Value)
-	if valueOf.Kind() != reflect.Slice {
+	switch valueOf.Kind() {
+	case reflect.Slice:
+	case reflect.Chan:
+		if valueOf.Type().ChanDir()&reflect.RecvDir == 0 {
+			return ErrorTypeSlice
+		}
+	case reflect.Func:
+		if !isTVPIterator(valueOf.Type()) {
+			return ErrorTypeSlice
+		}
+	default:
 		return ErrorTypeSlice
 	}
 	if valueOf.IsNil() {
 		return ErrorTypeSliceIsEmpty
 	}
-	if reflect.TypeOf(tvp.Value).Elem().Kind() != reflect.Struct {
+	if tvp.rowType().Kind() != reflect.Struct {
 		return ErrorTypeSlice
 	}
 	return nil
 }
 
+// isTVPIterator reports whether t is a func(yield func(T) bool).
+func isTVPIterator(t reflect.Type) bool {
+	if t.NumIn() != 1 || t.NumOut() != 0 {
+		return false
+	}
+	yield := t.In(0)
+	return yield.Kind() == reflect.Func && yield.NumIn() == 1 &&
+		yield.NumOut() == 1 && yield.Out(0).Kind() == reflect.Bool
+}
+
+// isStream reports whether the rows are produced while the TVP is sent.
+func (tvp TVP) isStream() bool {
+	switch reflect.ValueOf(tvp.Value).Kind() {
+	case reflect.Chan, reflect.Func:
+		return true
+	}
+	return false
+}
+
+// rowType returns the struct type of the rows.
+func (tvp TVP) rowType() reflect.Type {
+	t := reflect.TypeOf(tvp.Value)
+	if t.Kind() == reflect.Func {
+		return t.In(0).In(0)
+	}
+	return t.Elem()
+}
+
+// eachRow calls f for every row of a channel or iterator TVP and stops at
+// the first error.
+func (tvp TVP) eachRow(f func(row reflect.Value) error) (err error) {
+	val := reflect.ValueOf(tvp.Value)
+	if val.Kind() == reflect.Chan {
+		for {
+			row, ok := val.Recv()
+			if !ok {
+				return nil
+			}
+			if err = f(row); err != nil {
+				return err
+			}
+		}
+	}
+	yield := reflect.MakeFunc(val.Type().In(0), func(args []reflect.Value) []reflect.Value {
+		if err == nil {
+			err = f(args[0])
+		}
+		return []reflect.Value{reflect.ValueOf(err == nil)}
+	})
+	val.Call([]reflect.Value{yield})
+	return err
+}
+
 func (tvp TVP) encode(schema, name string, columnStr []columnStruct, tvpFieldIndexes []int, encoding msdsn.EncodeParameters) ([]byte, error) {
+	header, err := tvp.encodeHeader(schema, name, columnStr, tvpFieldIndexes, encoding)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(header)
+	stmt := tvpStmt()
+
+	val := reflect.ValueOf(tvp.Value)
+	for i := 0; i < val.Len(); i++ {
+		if err = tvp.encodeRow(buf, stmt, val.Index(i), columnStr, tvpFieldIndexes); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte(_TVP_END_TOKEN)
+	return buf.Bytes(), nil
+}
+
+// writeStream writes the header and the rows of a channel or iterator TVP
+// to w, encoding one row at a time.
+func (tvp TVP) writeStream(w io.Writer, header []byte, columnStr []columnStruct, tvpFieldIndexes []int) error {
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	stmt := tvpStmt()
+	var buf bytes.Buffer
+	err := tvp.eachRow(func(row reflect.Value) error {
+		buf.Reset()
+		if err := tvp.encodeRow(&buf, stmt, row, columnStr, tvpFieldIndexes); err != nil {
+			return err
+		}
+		_, err := w.Write(buf.Bytes())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte{_TVP_END_TOKEN})
+	return err
+}
+
+func (tvp TVP) encodeHeader(schema, name string, columnStr []columnStruct, tvpFieldIndexes []int, encoding msdsn.EncodeParameters) ([]byte, error) {
 	if len(columnStr) != len(tvpFieldIndexes) {
 		return nil, ErrorWrongTyping
 	}
+	return encodeTVPHeader(schema, name, columnStr, encoding)
+}
+
+// encodeTVPHeader returns the type name and the TVP_COLMETADATA of a TVP
+// and sets the writers of the columns.
+func encodeTVPHeader(schema, name string, columnStr []columnStruct, encoding msdsn.EncodeParameters) ([]byte, error) {
 	preparedBuffer := make([]byte, 0, 20+(10*len(columnStr)))
 	buf := bytes.NewBuffer(preparedBuffer)
 	err := writeBVarChar(buf, "")
@@ -87,75 +210,70 @@ func (tvp TVP) encode(schema, name string, columnStr []columnStruct, tvpFieldInd
 	}
 	// The returned error is always nil
 	buf.WriteByte(_TVP_END_TOKEN)
+	return buf.Bytes(), nil
+}
 
+// tvpStmt returns the statement used to encode the TVP column values.
+func tvpStmt() *Stmt {
 	conn := new(Conn)
 	conn.sess = new(tdsSession)
 	conn.sess.loginAck = loginAckStruct{TDSVersion: verTDS73}
-	stmt := &Stmt{
+	return &Stmt{
 		c: conn,
 	}
+}
 
-	val := reflect.ValueOf(tvp.Value)
-	for i := 0; i < val.Len(); i++ {
-		refStr := reflect.ValueOf(val.Index(i).Interface())
-		buf.WriteByte(_TVP_ROW_TOKEN)
-		for columnStrIdx, fieldIdx := range tvpFieldIndexes {
-			if columnStr[columnStrIdx].Flags == fDefault {
-				continue
-			}
-			field := refStr.Field(fieldIdx)
-			tvpVal := field.Interface()
-			if tvp.verifyStandardTypeOnNull(buf, tvpVal) {
+func (tvp TVP) encodeRow(buf *bytes.Buffer, stmt *Stmt, row reflect.Value, columnStr []columnStruct, tvpFieldIndexes []int) error {
+	refStr := reflect.ValueOf(row.Interface())
+	buf.WriteByte(_TVP_ROW_TOKEN)
+	for columnStrIdx, fieldIdx := range tvpFieldIndexes {
+		if columnStr[columnStrIdx].Flags == fDefault {
+			continue
+		}
+		field := refStr.Field(fieldIdx)
+		tvpVal := field.Interface()
+		if tvp.verifyStandardTypeOnNull(buf, tvpVal) {
+			continue
+		}
+		valOf := reflect.ValueOf(tvpVal)
+		elemKind := field.Kind()
+		if elemKind == reflect.Ptr && valOf.IsNil() {
+			switch tvpVal.(type) {
+			case *bool, *time.Time, *int8, *int16, *int32, *int64, *float32, *float64, *int,
+				*uint8, *uint16, *uint32, *uint64, *uint:
+				binary.Write(buf, binary.LittleEndian, uint8(0))
 				continue
-			}
-			valOf := reflect.ValueOf(tvpVal)
-			elemKind := field.Kind()
-			if elemKind == reflect.Ptr && valOf.IsNil() {
-				switch tvpVal.(type) {
-				case *bool, *time.Time, *int8, *int16, *int32, *int64, *float32, *float64, *int,
-					*uint8, *uint16, *uint32, *uint64, *uint:
-					binary.Write(buf, binary.LittleEndian, uint8(0))
-					continue
-				default:
-					binary.Write(buf, binary.LittleEndian, uint64(_PLP_NULL))
-					continue
-				}
-			}
-			if elemKind == reflect.Slice && valOf.IsNil() {
+			default:
 				binary.Write(buf, binary.LittleEndian, uint64(_PLP_NULL))
 				continue
 			}
+		}
+		if elemKind == reflect.Slice && valOf.IsNil() {
+			binary.Write(buf, binary.LittleEndian, uint64(_PLP_NULL))
+			continue
+		}
 
-			cval, err := convertInputParameter(tvpVal)
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert tvp parameter row col: %s", err)
-			}
-			param, err := stmt.makeParam(cval)
-			if err != nil {
-				return nil, fmt.Errorf("failed to make tvp parameter row col: %s", err)
-			}
-			columnStr[columnStrIdx].ti.Writer(buf, param.ti, param.buffer)
+		cval, err := convertInputParameter(tvpVal)
+		if err != nil {
+			return fmt.Errorf("failed to convert tvp parameter row col: %s", err)
 		}
+		param, err := stmt.makeParam(cval)
+		if err != nil {
+			return fmt.Errorf("failed to make tvp parameter row col: %s", err)
+		}
+		columnStr[columnStrIdx].ti.Writer(buf, param.ti, param.buffer)
 	}
-	buf.WriteByte(_TVP_END_TOKEN)
-	return buf.Bytes(), nil
+	return nil
 }
 
 func (tvp TVP) columnTypes() ([]columnStruct, []int, error) {
 	type fieldDetailStore struct {
 		defaultValue interface{}
-		isIdentity   bool
+		// isDefault is set for the columns whose value comes from the server
+		isDefault bool
 	}
 
-	val := reflect.ValueOf(tvp.Value)
-	var firstRow interface{}
-	if val.Len() != 0 {
-		firstRow = val.Index(0).Interface()
-	} else {
-		firstRow = reflect.New(reflect.TypeOf(tvp.Value).Elem()).Elem().Interface()
-	}
-
-	tvpRow := reflect.TypeOf(firstRow)
+	tvpRow := tvp.rowType()
 	columnCount := tvpRow.NumField()
 	defaultValues := make([]fieldDetailStore, 0, columnCount)
 	tvpFieldIndexes := make([]int, 0, columnCount)
@@ -167,18 +285,18 @@ func (tvp TVP) columnTypes() ([]columnStruct, []int, error) {
 			continue
 		}
 		tvpFieldIndexes = append(tvpFieldIndexes, i)
-		isIdentity := tvpTagValue == tvpIdentity
+		isDefault := tvpTagValue == tvpIdentity || tvpTagValue == tvpDefault
 		if field.Type.Kind() == reflect.Ptr {
 			v := reflect.New(field.Type.Elem())
 			defaultValues = append(defaultValues, fieldDetailStore{
 				defaultValue: v.Interface(),
-				isIdentity:   isIdentity,
+				isDefault:    isDefault,
 			})
 			continue
 		}
 		defaultValues = append(defaultValues, fieldDetailStore{
 			defaultValue: tvp.createZeroType(reflect.Zero(field.Type).Interface()),
-			isIdentity:   isIdentity,
+			isDefault:    isDefault,
 		})
 	}
 
@@ -186,12 +304,7 @@ func (tvp TVP) columnTypes() ([]columnStruct, []int, error) {
 		return nil, nil, ErrorSkip
 	}
 
-	conn := new(Conn)
-	conn.sess = new(tdsSession)
-	conn.sess.loginAck = loginAckStruct{TDSVersion: verTDS73}
-	stmt := &Stmt{
-		c: conn,
-	}
+	stmt := tvpStmt()
 
 	columnConfiguration := make([]columnStruct, 0, columnCount)
 	for index, val := range defaultValues {
@@ -206,7 +319,7 @@ func (tvp TVP) columnTypes() ([]columnStruct, []int, error) {
 		column := columnStruct{
 			ti: param.ti,
 		}
-		if val.isIdentity {
+		if val.isDefault {
 			column.Flags = fDefault
 		}
 		switch param.ti.TypeId {
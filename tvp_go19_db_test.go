@@ -1499,3 +1499,163 @@ func TestTVPIdentity(t *testing.T) {
 		t.Fatal("TestTVPIdentity have to be same")
 	}
 }
+
+func TestTVPComputedColumn(t *testing.T) {
+	type item struct {
+		Price    int
+		Quantity int
+		Total    int        `tvp:"@default"`
+		Version  RowVersion `tvp:"@default"`
+	}
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	_, err := conn.Exec(`create type dbo.TestTVPComputedColumn as table (
+		price int, quantity int, total as price * quantity, version rowversion)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Exec("drop type dbo.TestTVPComputedColumn")
+
+	tvp := TVP{
+		TypeName: "dbo.TestTVPComputedColumn",
+		Value:    []item{{Price: 2, Quantity: 3}, {Price: 5, Quantity: 4}},
+	}
+	rows, err := conn.Query("select price, quantity, total, version from @p1 order by price", tvp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []item
+	for rows.Next() {
+		var it item
+		if err = rows.Scan(&it.Price, &it.Quantity, &it.Total, &it.Version); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, it)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Total != 6 || got[1].Total != 20 {
+		t.Errorf("expected the totals computed by the server, got %+v", got)
+	}
+	if got[0].Version == (RowVersion{}) {
+		t.Errorf("expected the server to set the rowversion, got %+v", got[0])
+	}
+}
+
+func TestTVPStream(t *testing.T) {
+	type streamRow struct {
+		ID      int
+		Message string
+	}
+
+	const (
+		createTVP = `CREATE TYPE dbo.TestTVPStream AS TABLE (id int NOT NULL, message NVARCHAR(100))`
+		dropTVP   = `DROP TYPE dbo.TestTVPStream;`
+		rowCount  = 10000
+	)
+
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	conn.Exec(dropTVP)
+	_, err := conn.Exec(createTVP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Exec(dropTVP)
+
+	ch := make(chan streamRow)
+	go func() {
+		defer close(ch)
+		for i := 0; i < rowCount; i++ {
+			ch <- streamRow{ID: i, Message: "row"}
+		}
+	}()
+	iter := func(yield func(streamRow) bool) {
+		for i := 0; i < rowCount; i++ {
+			if !yield(streamRow{ID: i, Message: "row"}) {
+				return
+			}
+		}
+	}
+
+	for _, value := range []interface{}{ch, iter} {
+		var count, sum int64
+		err = conn.QueryRow("select count(*), sum(cast(id as bigint)) from @p1",
+			TVP{TypeName: "dbo.TestTVPStream", Value: value}).Scan(&count, &sum)
+		if err != nil {
+			t.Fatalf("%T: %v", value, err)
+		}
+		if count != rowCount || sum != rowCount*(rowCount-1)/2 {
+			t.Errorf("%T: expected %d rows, got %d rows with sum %d", value, rowCount, count, sum)
+		}
+	}
+}
+
+func TestTVPBool(t *testing.T) {
+	type flagRow struct {
+		ID       int
+		Active   bool
+		Verified sql.NullBool
+		Deleted  *bool
+	}
+
+	const (
+		createTVP  = `CREATE TYPE dbo.TestTVPBool AS TABLE (id int NOT NULL, active bit NOT NULL, verified bit, deleted bit)`
+		dropTVP    = `DROP TYPE dbo.TestTVPBool;`
+		createProc = `CREATE PROCEDURE dbo.spwithtvpBool @flags dbo.TestTVPBool READONLY AS
+	SELECT id, active, verified, deleted FROM @flags ORDER BY id`
+		dropProc = `DROP PROCEDURE dbo.spwithtvpBool`
+	)
+
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	conn.Exec(dropProc)
+	conn.Exec(dropTVP)
+	if _, err := conn.Exec(createTVP); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Exec(dropTVP)
+	if _, err := conn.Exec(createProc); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Exec(dropProc)
+
+	yes, no := true, false
+	in := []flagRow{
+		{ID: 1, Active: true, Verified: sql.NullBool{Bool: true, Valid: true}, Deleted: &no},
+		{ID: 2, Active: false, Verified: sql.NullBool{Bool: false, Valid: true}, Deleted: &yes},
+		{ID: 3, Active: true},
+	}
+	rows, err := conn.Query("exec dbo.spwithtvpBool @flags",
+		sql.Named("flags", TVP{TypeName: "dbo.TestTVPBool", Value: in}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var out []flagRow
+	for rows.Next() {
+		var r flagRow
+		var deleted sql.NullBool
+		if err = rows.Scan(&r.ID, &r.Active, &r.Verified, &deleted); err != nil {
+			t.Fatal(err)
+		}
+		if deleted.Valid {
+			r.Deleted = &deleted.Bool
+		}
+		out = append(out, r)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+}
@@ -3,6 +3,8 @@
 package mssql
 
 import (
+	"bytes"
+	"database/sql"
 	"reflect"
 	"testing"
 	"time"
@@ -586,3 +588,140 @@ func TestTVP_encode_WithGuidConversion(t *testing.T) {
 func TestTVP_encode(t *testing.T) {
 	testTVP_encode(t, false /*guidConversion*/)
 }
+
+func TestTVP_writeStream(t *testing.T) {
+	type row struct {
+		ID   int
+		Name string
+	}
+	rows := []row{{1, "one"}, {2, "two"}, {3, "three"}}
+
+	encoding := msdsn.EncodeParameters{}
+	encodeWith := func(value interface{}) []byte {
+		tvp := TVP{TypeName: "dbo.rows", Value: value}
+		if err := tvp.check(); err != nil {
+			t.Fatalf("%T: check failed: %v", value, err)
+		}
+		columnStr, tvpFieldIndexes, err := tvp.columnTypes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tvp.isStream() {
+			got, err := tvp.encode("dbo", "rows", columnStr, tvpFieldIndexes, encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return got
+		}
+		header, err := tvp.encodeHeader("dbo", "rows", columnStr, tvpFieldIndexes, encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err = tvp.writeStream(&buf, header, columnStr, tvpFieldIndexes); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	want := encodeWith(rows)
+
+	ch := make(chan row)
+	go func() {
+		for _, r := range rows {
+			ch <- r
+		}
+		close(ch)
+	}()
+	if got := encodeWith((<-chan row)(ch)); !bytes.Equal(got, want) {
+		t.Errorf("channel TVP encoded as %v, want %v", got, want)
+	}
+
+	iter := func(yield func(row) bool) {
+		for _, r := range rows {
+			if !yield(r) {
+				return
+			}
+		}
+	}
+	if got := encodeWith(iter); !bytes.Equal(got, want) {
+		t.Errorf("iterator TVP encoded as %v, want %v", got, want)
+	}
+
+	for _, v := range []interface{}{make(chan<- row), func(int) {}, make(chan int), (chan row)(nil)} {
+		if err := (TVP{TypeName: "dbo.rows", Value: v}).check(); err == nil {
+			t.Errorf("%T: expected check to fail", v)
+		}
+	}
+}
+
+func TestTVPBoolColumns(t *testing.T) {
+	type boolRow struct {
+		Bool     bool
+		NullBool sql.NullBool
+		PtrBool  *bool
+	}
+	yes := true
+	tvp := TVP{TypeName: "dbo.BoolRow", Value: []boolRow{
+		{Bool: true, NullBool: sql.NullBool{Bool: true, Valid: true}, PtrBool: &yes},
+		{},
+	}}
+	columns, indexes, err := tvp.columnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, col := range columns {
+		if col.ti.TypeId != typeBitN || col.ti.Size != 1 {
+			t.Errorf("column %d: expected a bit column, got %+v", i, col.ti)
+		}
+	}
+	header, err := tvp.encodeHeader("dbo", "BoolRow", columns, indexes, msdsn.EncodeParameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tvp.encode("dbo", "BoolRow", columns, indexes, msdsn.EncodeParameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := got[len(header):]
+	want := []byte{
+		_TVP_ROW_TOKEN, 1, 1, 1, 1, 1, 1,
+		_TVP_ROW_TOKEN, 1, 0, 0, 0,
+		_TVP_END_TOKEN,
+	}
+	if !bytes.Equal(rows, want) {
+		t.Errorf("expected the rows %v, got %v", want, rows)
+	}
+}
+
+func TestTVPDefaultColumns(t *testing.T) {
+	type row struct {
+		ID      int `tvp:"@identity"`
+		Price   int
+		Total   int        `tvp:"@default"`
+		Version RowVersion `tvp:"@default"`
+	}
+	tvp := TVP{TypeName: "dbo.Items", Value: []row{{ID: 7, Price: 3, Total: 9, Version: RowVersion{1}}}}
+	columns, indexes, err := tvp.columnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []uint16{fDefault, 0, fDefault, fDefault} {
+		if columns[i].Flags != want {
+			t.Errorf("column %d: expected the flags %x, got %x", i, want, columns[i].Flags)
+		}
+	}
+	header, err := tvp.encodeHeader("dbo", "Items", columns, indexes, msdsn.EncodeParameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tvp.encode("dbo", "Items", columns, indexes, msdsn.EncodeParameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the price is sent
+	want := []byte{_TVP_ROW_TOKEN, 8, 3, 0, 0, 0, 0, 0, 0, 0, _TVP_END_TOKEN}
+	if rows := got[len(header):]; !bytes.Equal(rows, want) {
+		t.Errorf("expected the rows %v, got %v", want, rows)
+	}
+}
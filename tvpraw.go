@@ -0,0 +1,163 @@
+package mssql
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// TVPRaw is a Table Valued Parameter whose rows are slices of values
+// rather than structs, for instance when the table type is only known at
+// run time:
+//
+//	tvp := mssql.TVPRaw{
+//		TypeName: "dbo.OrderLine",
+//		Columns: []mssql.TVPColumn{
+//			{Name: "id", Type: int64(0)},
+//			{Name: "product", Type: ""},
+//			{Name: "price", Type: mssql.Decimal{Precision: 10, Scale: 2}},
+//		},
+//		Rows: [][]interface{}{
+//			{int64(1), "widget", mssql.Decimal{Precision: 10, Scale: 2, Value: "9.99"}},
+//			{int64(2), nil, nil},
+//		},
+//	}
+//	_, err = db.Exec("exec dbo.AddOrderLines @lines", sql.Named("lines", tvp))
+//
+// The values of a row are in the order of the columns and a nil value is
+// sent as NULL. A value must have the SQL type of its column, e.g. an int32
+// does not fit a bigint column.
+type TVPRaw struct {
+	// TypeName is the name of the table type, it mustn't be empty.
+	TypeName string
+	Columns  []TVPColumn
+	Rows     [][]interface{}
+}
+
+// TVPColumn is a column of a TVPRaw. Type is a value of the Go type which
+// gives the SQL type of the column, as the fields of the structs of a TVP
+// do: int64 for bigint, string for nvarchar(max), VarChar for varchar(max),
+// []byte for varbinary(max), time.Time for datetimeoffset, a Decimal with
+// the precision and scale of the column and so on. Name is only used in
+// error messages.
+type TVPColumn struct {
+	Name string
+	Type interface{}
+	// Default leaves the column to the server, e.g. for an identity
+	// column. Its values are not sent.
+	Default bool
+}
+
+var errTVPRawNoColumns = errors.New("mssql: TVPRaw must have columns")
+
+func (tvp TVPRaw) check() error {
+	if len(tvp.TypeName) == 0 || !isProc(tvp.TypeName) {
+		return ErrorEmptyTVPTypeName
+	}
+	if sepCount := getCountSQLSeparators(tvp.TypeName); sepCount > 1 {
+		return ErrorObjectName
+	}
+	if len(tvp.Columns) == 0 {
+		return errTVPRawNoColumns
+	}
+	return nil
+}
+
+func (tvp TVPRaw) columnName(i int) string {
+	if name := tvp.Columns[i].Name; name != "" {
+		return name
+	}
+	return strconv.Itoa(i)
+}
+
+func (tvp TVPRaw) columnTypes() ([]columnStruct, error) {
+	stmt := tvpStmt()
+	columnStr := make([]columnStruct, len(tvp.Columns))
+	for i, col := range tvp.Columns {
+		typ := col.Type
+		if d, ok := typ.(Decimal); ok && d.Value == nil {
+			d.Value = 0
+			typ = d
+		}
+		if typ == nil {
+			return nil, fmt.Errorf("mssql: TVPRaw column %s has no type", tvp.columnName(i))
+		}
+		cval, err := convertInputParameter(typ)
+		if err != nil {
+			return nil, fmt.Errorf("mssql: TVPRaw column %s: %v", tvp.columnName(i), err)
+		}
+		param, err := stmt.makeParam(cval)
+		if err != nil {
+			return nil, fmt.Errorf("mssql: TVPRaw column %s: %v", tvp.columnName(i), err)
+		}
+		if param.ti.TypeId == typeNull {
+			return nil, fmt.Errorf("mssql: TVPRaw column %s has no type", tvp.columnName(i))
+		}
+		columnStr[i].ti = param.ti
+		switch param.ti.TypeId {
+		case typeNVarChar, typeBigVarChar, typeBigVarBin:
+			columnStr[i].ti.Size = 0
+		}
+		if col.Default {
+			columnStr[i].Flags = fDefault
+		}
+	}
+	return columnStr, nil
+}
+
+func (tvp TVPRaw) encode(schema, name string, columnStr []columnStruct, encoding msdsn.EncodeParameters) ([]byte, error) {
+	header, err := encodeTVPHeader(schema, name, columnStr, encoding)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(header)
+	stmt := tvpStmt()
+	for r, row := range tvp.Rows {
+		if len(row) != len(columnStr) {
+			return nil, fmt.Errorf("mssql: TVPRaw row %d has %d values, expected %d", r, len(row), len(columnStr))
+		}
+		buf.WriteByte(_TVP_ROW_TOKEN)
+		for i, v := range row {
+			col := columnStr[i]
+			if col.Flags == fDefault {
+				continue
+			}
+			var data []byte
+			if v != nil {
+				cval, err := convertInputParameter(v)
+				if err != nil {
+					return nil, fmt.Errorf("mssql: TVPRaw row %d column %s: %v", r, tvp.columnName(i), err)
+				}
+				param, err := stmt.makeParam(cval)
+				if err != nil {
+					return nil, fmt.Errorf("mssql: TVPRaw row %d column %s: %v", r, tvp.columnName(i), err)
+				}
+				if param.ti.TypeId != typeNull && !tvpValueFits(col.ti, param.ti) {
+					return nil, fmt.Errorf("mssql: TVPRaw row %d column %s: %T does not match the %s column", r, tvp.columnName(i), v, makeDecl(col.ti))
+				}
+				data = param.buffer
+			}
+			if err = col.ti.Writer(buf, col.ti, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	buf.WriteByte(_TVP_END_TOKEN)
+	return buf.Bytes(), nil
+}
+
+// tvpValueFits tells whether a value of type ti can be written to a column
+// of type col, the length of the variable length types being free.
+func tvpValueFits(col, ti typeInfo) bool {
+	if ti.TypeId != col.TypeId {
+		return false
+	}
+	switch ti.TypeId {
+	case typeNVarChar, typeBigVarChar, typeBigVarBin, typeNChar:
+		return true
+	}
+	return ti.Size == col.Size && ti.Prec == col.Prec && ti.Scale == col.Scale
+}
@@ -0,0 +1,120 @@
+package mssql
+
+import (
+	"bytes"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTVPRawEncode(t *testing.T) {
+	type row struct {
+		ID   int64
+		Name string
+		Data []byte
+	}
+	tvp := TVP{TypeName: "dbo.T", Value: []row{{1, "a", []byte{1}}, {2, "", nil}}}
+	raw := TVPRaw{
+		TypeName: "dbo.T",
+		Columns:  []TVPColumn{{Name: "id", Type: int64(0)}, {Name: "name", Type: ""}, {Name: "data", Type: []byte{}}},
+		Rows:     [][]interface{}{{int64(1), "a", []byte{1}}, {int64(2), "", nil}},
+	}
+	want, err := tvpStmt().makeParam(tvp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tvpStmt().makeParam(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.buffer, want.buffer) {
+		t.Errorf("expected the encoding of the struct TVP\n%x, got\n%x", want.buffer, got.buffer)
+	}
+	if decl := makeDecl(got.ti); decl != "dbo.T READONLY" {
+		t.Errorf("unexpected declaration %s", decl)
+	}
+}
+
+func TestTVPRawErrors(t *testing.T) {
+	columns := []TVPColumn{{Name: "id", Type: int64(0)}, {Name: "name", Type: ""}}
+	tests := []struct {
+		tvp  TVPRaw
+		want string
+	}{
+		{TVPRaw{Columns: columns}, ErrorEmptyTVPTypeName.Error()},
+		{TVPRaw{TypeName: "a.b.c", Columns: columns}, ErrorObjectName.Error()},
+		{TVPRaw{TypeName: "dbo.T"}, errTVPRawNoColumns.Error()},
+		{TVPRaw{TypeName: "dbo.T", Columns: []TVPColumn{{Name: "id"}}}, "column id has no type"},
+		{TVPRaw{TypeName: "dbo.T", Columns: columns, Rows: [][]interface{}{{int64(1)}}}, "row 0 has 1 values, expected 2"},
+		{TVPRaw{TypeName: "dbo.T", Columns: columns, Rows: [][]interface{}{{int32(1), "a"}}}, "row 0 column id: int32 does not match the bigint column"},
+		{TVPRaw{TypeName: "dbo.T", Columns: columns, Rows: [][]interface{}{{nil, 1.5}}}, "row 0 column name: float64 does not match"},
+	}
+	for _, test := range tests {
+		_, err := tvpStmt().makeParam(test.tvp)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%+v: expected an error with %q, got %v", test.tvp, test.want, err)
+		}
+	}
+}
+
+func TestTVPRaw(t *testing.T) {
+	const (
+		createTVP  = `CREATE TYPE dbo.TestTVPRaw AS TABLE (id bigint NOT NULL, name nvarchar(50), price decimal(10, 2))`
+		dropTVP    = `DROP TYPE dbo.TestTVPRaw;`
+		createProc = `CREATE PROCEDURE dbo.spwithtvpRaw @rows dbo.TestTVPRaw READONLY AS
+	SELECT id, name, cast(price as varchar(20)) FROM @rows ORDER BY id`
+		dropProc = `DROP PROCEDURE dbo.spwithtvpRaw`
+	)
+
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	conn.Exec(dropProc)
+	conn.Exec(dropTVP)
+	if _, err := conn.Exec(createTVP); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Exec(dropTVP)
+	if _, err := conn.Exec(createProc); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Exec(dropProc)
+
+	price := Decimal{Precision: 10, Scale: 2}
+	tvp := TVPRaw{
+		TypeName: "dbo.TestTVPRaw",
+		Columns:  []TVPColumn{{Name: "id", Type: int64(0)}, {Name: "name", Type: ""}, {Name: "price", Type: price}},
+		Rows: [][]interface{}{
+			{int64(1), "widget", Decimal{Precision: 10, Scale: 2, Value: "9.99"}},
+			{int64(2), nil, nil},
+			{int64(3), "gadget", Decimal{Precision: 10, Scale: 2, Value: 120}},
+		},
+	}
+	rows, err := conn.Query("exec dbo.spwithtvpRaw @rows", sql.Named("rows", tvp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got [][]interface{}
+	for rows.Next() {
+		var id int64
+		var name, price sql.NullString
+		if err = rows.Scan(&id, &name, &price); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, []interface{}{id, name, price})
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{
+		{int64(1), sql.NullString{String: "widget", Valid: true}, sql.NullString{String: "9.99", Valid: true}},
+		{int64(2), sql.NullString{}, sql.NullString{}},
+		{int64(3), sql.NullString{String: "gadget", Valid: true}, sql.NullString{String: "120.00", Valid: true}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -102,6 +102,9 @@ type typeInfo struct {
 	XmlInfo   xmlInfo
 	Reader    func(ti *typeInfo, r *tdsBuffer, cryptoMeta *cryptoMetadata) (res interface{})
 	Writer    func(w io.Writer, ti typeInfo, buf []byte) (err error)
+	// utf8 decodes the values of a single byte character type as UTF-8
+	// whatever the collation, for encoding=utf8
+	utf8 bool
 }
 
 // Common Language Runtime (CLR) Instances
@@ -249,7 +252,13 @@ func writeVarLen(w io.Writer, ti *typeInfo, out bool, encoding msdsn.EncodeParam
 				return
 			}
 		}
-	case typeText, typeImage, typeNText, typeVariant:
+	case typeVariant:
+		// LONGLEN_TYPE without collation
+		if err = binary.Write(w, binary.LittleEndian, uint32(ti.Size)); err != nil {
+			return
+		}
+		ti.Writer = writeVariantType
+	case typeText, typeImage, typeNText:
 		// LONGLEN_TYPE
 		if err = binary.Write(w, binary.LittleEndian, uint32(ti.Size)); err != nil {
 			return
@@ -272,23 +281,45 @@ func decodeDateTim4(buf []byte) time.Time {
 		0, int(mins), 0, 0, time.UTC)
 }
 
+// encodes smalldatetime value, the clock time of val in its own location
+// rounded to the nearest minute, see smallDateTimeMinutes
 func encodeDateTim4(val time.Time) (buf []byte) {
 	buf = make([]byte, 4)
 
-	ref := time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
-	dur := val.Sub(ref)
-	days := dur / (24 * time.Hour)
-	mins := val.Hour()*60 + val.Minute()
+	// days since Jan 1st 1900 (same TZ as val)
+	days := gregorianDays(val.Year(), val.YearDay()) - gregorianDays(1900, 1)
+	mins := smallDateTimeMinutes(val.Hour(), val.Minute(), val.Second())
+	if mins == 24*60 {
+		days++
+		mins = 0
+	}
+	// minimum and maximum possible, 2079-06-06 23:59 being the last minute
+	maxdays := gregorianDays(2079, 157) - gregorianDays(1900, 1)
 	if days < 0 {
 		days = 0
 		mins = 0
 	}
+	if days > maxdays {
+		days = maxdays
+		mins = 24*60 - 1
+	}
 
 	binary.LittleEndian.PutUint16(buf[:2], uint16(days))
 	binary.LittleEndian.PutUint16(buf[2:], uint16(mins))
 	return
 }
 
+// smallDateTimeMinutes returns the minutes since midnight of hour:min:sec
+// rounded as SQL Server rounds smalldatetime values: 29.999 seconds are
+// rounded down and 30 seconds up, which may give the next midnight.
+func smallDateTimeMinutes(hour, min, sec int) int {
+	mins := hour*60 + min
+	if sec >= 30 {
+		mins++
+	}
+	return mins
+}
+
 // encodes datetime value
 // type identifier is typeDateTimeN
 func encodeDateTime(t time.Time) (res []byte) {
@@ -443,7 +474,7 @@ func readByteLenTypeWithEncoding(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata,
 			badStreamPanicf("Invalid size for DATETIMENTYPE")
 		}
 	case typeChar, typeVarChar:
-		return decodeChar(ti.Collation, buf)
+		return decodeTypeChar(ti, buf)
 	case typeBinary, typeVarBinary:
 		// a copy, because the backing array for ti.Buffer is reused
 		// and can be overwritten by the next row while this row waits
@@ -465,6 +496,23 @@ func readByteLenType(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata) interface{}
 	return readByteLenTypeWithEncoding(ti, r, c, msdsn.EncodeParameters{GuidConversion: false})
 }
 
+// reads a DECIMAL or NUMERIC value as its decimal string,
+// the string has as many fractional digits as the scale of the column
+func readDecimalAsString(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata) interface{} {
+	if v, ok := readByteLenType(ti, r, c).([]byte); ok {
+		return string(v)
+	}
+	return nil
+}
+
+func isDecimalType(typeId uint8) bool {
+	switch typeId {
+	case typeDecimal, typeNumeric, typeDecimalN, typeNumericN:
+		return true
+	}
+	return false
+}
+
 func writeByteLenType(w io.Writer, ti typeInfo, buf []byte) (err error) {
 	if ti.Size > 0xff {
 		panic("Invalid size for BYTELEN_TYPE")
@@ -520,7 +568,7 @@ func readShortLenType(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata) interface{}
 	buf := ti.Buffer[:size]
 	switch ti.TypeId {
 	case typeBigVarChar, typeBigChar:
-		return decodeChar(ti.Collation, buf)
+		return decodeTypeChar(ti, buf)
 	case typeBigVarBin, typeBigBinary:
 		// a copy, because the backing array for ti.Buffer is reused
 		// and can be overwritten by the next row while this row waits
@@ -546,7 +594,8 @@ func writeShortLenType(w io.Writer, ti typeInfo, buf []byte) (err error) {
 	if ti.Size > 0xfffe {
 		panic("Invalid size for USHORTLEN_TYPE")
 	}
-	err = binary.Write(w, binary.LittleEndian, uint16(ti.Size))
+	// the value may be shorter than the size declared by the type info
+	err = binary.Write(w, binary.LittleEndian, uint16(len(buf)))
 	if err != nil {
 		return
 	}
@@ -575,7 +624,7 @@ func readLongLenType(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata) interface{}
 	r.ReadFull(buf)
 	switch ti.TypeId {
 	case typeText:
-		return decodeChar(ti.Collation, buf)
+		return decodeTypeChar(ti, buf)
 	case typeImage:
 		return buf
 	case typeNText:
@@ -627,6 +676,78 @@ func writeCollation(w io.Writer, col cp.Collation) (err error) {
 	return
 }
 
+// writes a variant value encoded by encodeVariant
+func writeVariantType(w io.Writer, ti typeInfo, buf []byte) (err error) {
+	if err = binary.Write(w, binary.LittleEndian, uint32(len(buf))); err != nil {
+		return
+	}
+	_, err = w.Write(buf)
+	return
+}
+
+// encodeVariant encodes val as the base type, properties and data of a
+// variant value. Strings use the given collation.
+// http://msdn.microsoft.com/en-us/library/dd303302.aspx
+func encodeVariant(val interface{}, col cp.Collation) ([]byte, error) {
+	var buf bytes.Buffer
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		buf.Write([]byte{typeBit, 0})
+		if v {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case uint8:
+		buf.Write([]byte{typeInt1, 0, v})
+	case int8:
+		buf.Write([]byte{typeInt2, 0})
+		binary.Write(&buf, binary.LittleEndian, int16(v))
+	case int16:
+		buf.Write([]byte{typeInt2, 0})
+		binary.Write(&buf, binary.LittleEndian, v)
+	case int32:
+		buf.Write([]byte{typeInt4, 0})
+		binary.Write(&buf, binary.LittleEndian, v)
+	case int:
+		buf.Write([]byte{typeInt8, 0})
+		binary.Write(&buf, binary.LittleEndian, int64(v))
+	case int64:
+		buf.Write([]byte{typeInt8, 0})
+		binary.Write(&buf, binary.LittleEndian, v)
+	case float32:
+		buf.Write([]byte{typeFlt4, 0})
+		binary.Write(&buf, binary.LittleEndian, math.Float32bits(v))
+	case float64:
+		buf.Write([]byte{typeFlt8, 0})
+		binary.Write(&buf, binary.LittleEndian, math.Float64bits(v))
+	case string:
+		data := str2ucs2(v)
+		if len(data) > 8000 {
+			return nil, fmt.Errorf("mssql: string of %d bytes is too long for sql_variant", len(data))
+		}
+		buf.Write([]byte{typeNVarChar, 7})
+		writeCollation(&buf, col)
+		binary.Write(&buf, binary.LittleEndian, uint16(len(data)))
+		buf.Write(data)
+	case []byte:
+		if len(v) > 8000 {
+			return nil, fmt.Errorf("mssql: binary of %d bytes is too long for sql_variant", len(v))
+		}
+		buf.Write([]byte{typeBigVarBin, 2})
+		binary.Write(&buf, binary.LittleEndian, uint16(len(v)))
+		buf.Write(v)
+	case time.Time:
+		buf.Write([]byte{typeDateTimeOffsetN, 1, 7})
+		buf.Write(encodeDateTimeOffset(v, 7))
+	default:
+		return nil, fmt.Errorf("mssql: unsupported type for sql_variant: %T", val)
+	}
+	return buf.Bytes(), nil
+}
+
 // reads variant value
 // http://msdn.microsoft.com/en-us/library/dd303302.aspx
 func readVariantTypeWithEncoding(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata, encoding msdsn.EncodeParameters) interface{} {
@@ -700,12 +821,18 @@ func readVariantTypeWithEncoding(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata,
 		scale := r.byte()
 		buf := make([]byte, size-2-propbytes)
 		r.ReadFull(buf)
+		if encoding.DecimalAsString {
+			return string(decodeDecimal(prec, scale, buf))
+		}
 		return decodeDecimal(prec, scale, buf)
 	case typeBigVarChar, typeBigChar:
 		col := readCollation(r)
 		r.uint16() // max length, ignoring
 		buf := make([]byte, size-2-propbytes)
 		r.ReadFull(buf)
+		if encoding.UTF8 {
+			return string(buf)
+		}
 		return decodeChar(col, buf)
 	case typeNVarChar, typeNChar:
 		_ = readCollation(r)
@@ -714,7 +841,7 @@ func readVariantTypeWithEncoding(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata,
 		r.ReadFull(buf)
 		return decodeNChar(buf)
 	default:
-		badStreamPanicf("Invalid variant typeid")
+		badStreamPanicf("Invalid variant typeid %#x", vartype)
 	}
 	panic("shoulnd't get here")
 }
@@ -761,7 +888,7 @@ func readPLPType(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata) interface{} {
 	case typeXml:
 		return decodeXml(*ti, bytesToDecode)
 	case typeBigVarChar, typeBigChar, typeText:
-		return decodeChar(ti.Collation, bytesToDecode)
+		return decodeTypeChar(ti, bytesToDecode)
 	case typeBigVarBin, typeBigBinary, typeImage:
 		return bytesToDecode
 	case typeNVarChar, typeNChar, typeNText:
@@ -796,6 +923,43 @@ func writePLPType(w io.Writer, ti typeInfo, buf []byte) (err error) {
 	}
 }
 
+// writePLPReader streams r as PLP chunks. If length is negative the total
+// length is sent as unknown, otherwise r must yield exactly length bytes.
+func writePLPReader(w io.Writer, r io.Reader, length int64) (err error) {
+	if length < 0 {
+		err = binary.Write(w, binary.LittleEndian, uint64(_UNKNOWN_PLP_LEN))
+	} else {
+		err = binary.Write(w, binary.LittleEndian, uint64(length))
+	}
+	if err != nil {
+		return
+	}
+	chunk := make([]byte, 8000)
+	var written int64
+	for {
+		n, rerr := r.Read(chunk)
+		if n > 0 {
+			if err = binary.Write(w, binary.LittleEndian, uint32(n)); err != nil {
+				return
+			}
+			if _, err = w.Write(chunk[:n]); err != nil {
+				return
+			}
+			written += int64(n)
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+	if length >= 0 && written != length {
+		return fmt.Errorf("mssql: reader returned %d bytes, expected %d", written, length)
+	}
+	return binary.Write(w, binary.LittleEndian, uint32(_PLP_TERMINATOR))
+}
+
 func readVarLen(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata, encoding msdsn.EncodeParameters) {
 	switch ti.TypeId {
 	case typeDateN:
@@ -834,9 +998,12 @@ func readVarLen(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata, encoding msdsn.En
 			ti.Prec = r.byte()
 			ti.Scale = r.byte()
 		}
-		if encoding.GuidConversion {
+		switch {
+		case encoding.DecimalAsString && isDecimalType(ti.TypeId):
+			ti.Reader = readDecimalAsString
+		case encoding.GuidConversion:
 			ti.Reader = readByteLenTypeWithGuidConversion
-		} else {
+		default:
 			ti.Reader = readByteLenType
 		}
 	case typeXml:
@@ -864,7 +1031,10 @@ func readVarLen(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata, encoding msdsn.En
 		// short len types
 		ti.Size = int(r.uint16())
 		switch ti.TypeId {
-		case typeBigVarChar, typeBigChar, typeNVarChar, typeNChar:
+		case typeBigVarChar, typeBigChar:
+			ti.Collation = readCollation(r)
+			ti.utf8 = encoding.UTF8
+		case typeNVarChar, typeNChar:
 			ti.Collation = readCollation(r)
 		}
 		if ti.Size == 0xffff {
@@ -879,6 +1049,7 @@ func readVarLen(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata, encoding msdsn.En
 		switch ti.TypeId {
 		case typeText, typeNText:
 			ti.Collation = readCollation(r)
+			ti.utf8 = ti.TypeId == typeText && encoding.UTF8
 			// ignore tablenames
 			numparts := int(r.byte())
 			for i := 0; i < numparts; i++ {
@@ -913,12 +1084,17 @@ func decodeMoney(buf []byte) []byte {
 		uint64(buf[1])<<40 |
 		uint64(buf[2])<<48 |
 		uint64(buf[3])<<56)
-	return decimal.ScaleBytes(strconv.FormatInt(money, 10), 4)
+	return decodeMoneyValue(money)
 }
 
 func decodeMoney4(buf []byte) []byte {
 	money := int32(binary.LittleEndian.Uint32(buf[0:4]))
-	return decimal.ScaleBytes(strconv.FormatInt(int64(money), 10), 4)
+	return decodeMoneyValue(int64(money))
+}
+
+// decodeMoneyValue formats a money value scaled by 10^4 as exact decimal text.
+func decodeMoneyValue(money int64) []byte {
+	return decimal.ScaleBytes(strconv.FormatInt(money, 10), moneyScale)
 }
 
 func decodeGuid(buf []byte, encoding msdsn.EncodeParameters) []byte {
@@ -932,6 +1108,43 @@ func decodeGuid(buf []byte, encoding msdsn.EncodeParameters) []byte {
 	return res
 }
 
+// encodeDecimal encodes dec with the given precision as a sign byte
+// followed by the little endian unscaled integer.
+func encodeDecimal(dec decimal.Decimal, prec uint8) ([]byte, error) {
+	dec.SetPrec(prec)
+
+	var length byte
+	switch {
+	case prec <= 9:
+		length = 4
+	case prec <= 19:
+		length = 8
+	case prec <= 28:
+		length = 12
+	default:
+		length = 16
+	}
+
+	buf := make([]byte, length+1)
+	// first byte sign
+	if !dec.IsPositive() {
+		buf[0] = 0
+	} else {
+		buf[0] = 1
+	}
+
+	ub := dec.UnscaledBytes()
+	l := len(ub)
+	if l > int(length) {
+		return nil, fmt.Errorf("decimal out of range: %s", dec)
+	}
+	// reverse the bytes
+	for i, j := 1, l-1; j >= 0; i, j = i+1, j-1 {
+		buf[i] = ub[j]
+	}
+	return buf, nil
+}
+
 func decodeDecimal(prec uint8, scale uint8, buf []byte) []byte {
 	sign := buf[0]
 	var dec decimal.Decimal
@@ -992,16 +1205,38 @@ func calcTimeSize(scale int) int {
 	}
 }
 
+// returns the duration of one unit of a time field with the given scale
+func timeScaleUnit(scale int) time.Duration {
+	unit := time.Second
+	for i := 0; i < scale; i++ {
+		unit /= 10
+	}
+	return unit
+}
+
+// rounds the fractional seconds of a datetime2 or datetimeoffset value
+// to the given scale like the server does, a value which would round
+// past the maximum date is truncated instead.
+// Rounding also strips the monotonic clock reading.
+func roundDateTime2(val time.Time, scale int) time.Time {
+	unit := timeScaleUnit(scale)
+	rounded := val.Round(unit)
+	if rounded.Year() > 9999 {
+		return val.Truncate(unit)
+	}
+	return rounded
+}
+
 // writes time value into a field buffer
 // buffer should be at least calcTimeSize long
 func encodeTimeInt(seconds, ns, scale int, buf []byte) {
 	ns_total := int64(seconds)*1000*1000*1000 + int64(ns)
-	t := ns_total / int64(math.Pow10(int(scale)*-1)*1e9)
-	buf[0] = byte(t)
-	buf[1] = byte(t >> 8)
-	buf[2] = byte(t >> 16)
-	buf[3] = byte(t >> 24)
-	buf[4] = byte(t >> 32)
+	unit := int64(timeScaleUnit(scale))
+	// round to the scale, a time rounded up to midnight wraps around
+	t := (ns_total + unit/2) / unit % (24 * 60 * 60 * int64(time.Second) / unit)
+	for i := 0; i < calcTimeSize(scale); i++ {
+		buf[i] = byte(t >> (8 * i))
+	}
 }
 
 func decodeTime(scale uint8, buf []byte) time.Time {
@@ -1024,7 +1259,7 @@ func decodeDateTime2(scale uint8, buf []byte) time.Time {
 }
 
 func encodeDateTime2(val time.Time, scale int) (buf []byte) {
-	days, seconds, ns := dateTime2(val)
+	days, seconds, ns := dateTime2(roundDateTime2(val, scale))
 	timesize := calcTimeSize(scale)
 	buf = make([]byte, 3+timesize)
 	encodeTimeInt(seconds, ns, scale, buf)
@@ -1048,6 +1283,7 @@ func decodeDateTimeOffset(scale uint8, buf []byte) time.Time {
 func encodeDateTimeOffset(val time.Time, scale int) (buf []byte) {
 	timesize := calcTimeSize(scale)
 	buf = make([]byte, timesize+2+3)
+	val = roundDateTime2(val, scale)
 	days, seconds, ns := dateTime2(val.In(time.UTC))
 	encodeTimeInt(seconds, ns, scale, buf)
 	buf[timesize] = byte(days)
@@ -1089,6 +1325,15 @@ func decodeChar(col cp.Collation, buf []byte) string {
 	return cp.CharsetToUTF8(col, buf)
 }
 
+// decodeTypeChar decodes a value of the single byte character type ti,
+// in the code page of its collation unless it is read as UTF-8.
+func decodeTypeChar(ti *typeInfo, buf []byte) string {
+	if ti.utf8 {
+		return string(buf)
+	}
+	return decodeChar(ti.Collation, buf)
+}
+
 func decodeUcs2(buf []byte) string {
 	res, err := ucs22str(buf)
 	if err != nil {
@@ -1109,10 +1354,29 @@ func decodeUdt(ti typeInfo, buf []byte) []byte {
 	return buf
 }
 
-// makes go/sql type instance as described below
-// It should return
-// the value type that can be used to scan types into. For example, the database
-// column type "bigint" this should return "reflect.TypeOf(int64(0))".
+// makeGoLangScanTypeWithEncoding returns the scan type of a column read with
+// the encoding parameters of the connection, which return DECIMAL values as
+// strings with decimal=string and GUIDs as bytes in the string order with
+// guid conversion, see makeGoLangScanType for the other columns.
+func makeGoLangScanTypeWithEncoding(ti typeInfo, encoding msdsn.EncodeParameters) reflect.Type {
+	if encoding.DecimalAsString && isDecimalType(ti.TypeId) {
+		return reflect.TypeOf("")
+	}
+	if encoding.GuidConversion && ti.TypeId == typeGuid {
+		// the bytes are already in the string order, which UniqueIdentifier
+		// does not expect
+		return reflect.TypeOf([]byte{})
+	}
+	return makeGoLangScanType(ti)
+}
+
+// anyType is the scan type of SQL_VARIANT columns, whose values may be of
+// any of the types returned for the other columns.
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// makeGoLangScanType returns the type a non-NULL value of the column can be
+// scanned into: the type of the values returned by the driver, or the
+// driver type scanning them.
 func makeGoLangScanType(ti typeInfo) reflect.Type {
 	switch ti.TypeId {
 	case typeInt1:
@@ -1149,15 +1413,15 @@ func makeGoLangScanType(ti typeInfo) reflect.Type {
 		default:
 			panic("invalid size of FLNNTYPE")
 		}
-	case typeBigVarBin:
+	case typeBigVarBin, typeVarBinary, typeBinary:
 		return reflect.TypeOf([]byte{})
-	case typeVarChar:
+	case typeVarChar, typeChar:
 		return reflect.TypeOf("")
 	case typeNVarChar:
 		return reflect.TypeOf("")
 	case typeBit, typeBitN:
 		return reflect.TypeOf(true)
-	case typeDecimalN, typeNumericN:
+	case typeDecimalN, typeNumericN, typeDecimal, typeNumeric:
 		return reflect.TypeOf([]byte{})
 	case typeMoney, typeMoney4, typeMoneyN:
 		switch ti.Size {
@@ -1196,7 +1460,7 @@ func makeGoLangScanType(ti typeInfo) reflect.Type {
 	case typeNChar:
 		return reflect.TypeOf("")
 	case typeGuid:
-		return reflect.TypeOf([]byte{})
+		return reflect.TypeOf(UniqueIdentifier{})
 	case typeXml:
 		return reflect.TypeOf("")
 	case typeText:
@@ -1207,8 +1471,8 @@ func makeGoLangScanType(ti typeInfo) reflect.Type {
 		return reflect.TypeOf([]byte{})
 	case typeBigBinary:
 		return reflect.TypeOf([]byte{})
-	case typeVariant:
-		return reflect.TypeOf(nil)
+	case typeVariant, typeNull:
+		return anyType
 	case typeUdt:
 		return reflect.TypeOf([]byte{})
 	default:
@@ -1315,7 +1579,7 @@ func makeDecl(ti typeInfo) string {
 			panic("invalid size of DATETIMNTYPE")
 		}
 	case typeTimeN:
-		return "time"
+		return fmt.Sprintf("time(%d)", ti.Scale)
 	case typeDateTime2N:
 		return fmt.Sprintf("datetime2(%d)", ti.Scale)
 	case typeDateTimeOffsetN:
@@ -1328,6 +1592,8 @@ func makeDecl(ti typeInfo) string {
 		return ti.UdtInfo.TypeName
 	case typeGuid:
 		return "uniqueidentifier"
+	case typeVariant:
+		return "sql_variant"
 	case typeTvp:
 		if ti.UdtInfo.SchemaName != "" {
 			return fmt.Sprintf("%s.%s READONLY", ti.UdtInfo.SchemaName, ti.UdtInfo.TypeName)
@@ -1441,6 +1707,10 @@ func makeGoLangTypeName(ti typeInfo) string {
 	case typeBigBinary:
 		return "BINARY"
 	case typeUdt:
+		// CLR types other than the system ones are qualified by their schema
+		if ti.UdtInfo.SchemaName != "" && !strings.EqualFold(ti.UdtInfo.SchemaName, "sys") {
+			return strings.ToUpper(ti.UdtInfo.SchemaName + "." + ti.UdtInfo.TypeName)
+		}
 		return strings.ToUpper(ti.UdtInfo.TypeName)
 	default:
 		panic(fmt.Sprintf("not implemented makeGoLangTypeName for type %d", ti.TypeId))
@@ -1635,7 +1905,7 @@ func makeGoLangTypePrecisionScale(ti typeInfo) (int64, int64, bool) {
 		}
 	case typeBit, typeBitN:
 		return 0, 0, false
-	case typeDecimalN, typeNumericN:
+	case typeDecimalN, typeNumericN, typeDecimal, typeNumeric:
 		return int64(ti.Prec), int64(ti.Scale), true
 	case typeMoney, typeMoney4, typeMoneyN:
 		switch ti.Size {
@@ -1665,9 +1935,9 @@ func makeGoLangTypePrecisionScale(ti typeInfo) (int64, int64, bool) {
 		return int64(ti.Prec), int64(ti.Scale), true
 	case typeDateTimeOffsetN:
 		return int64(ti.Prec), int64(ti.Scale), true
-	case typeBigVarBin:
+	case typeBigVarBin, typeVarBinary, typeBinary:
 		return 0, 0, false
-	case typeVarChar:
+	case typeVarChar, typeChar:
 		return 0, 0, false
 	case typeBigVarChar:
 		return 0, 0, false
@@ -1697,3 +1967,31 @@ func makeGoLangTypePrecisionScale(ti typeInfo) (int64, int64, bool) {
 		panic(fmt.Sprintf("not implemented makeGoLangTypePrecisionScale for type %d", ti.TypeId))
 	}
 }
+
+// ColumnCollation is the collation of a character column as sent by the
+// server in the column metadata.
+type ColumnCollation struct {
+	// LCID is the Windows locale identifier of the collation.
+	LCID uint32
+	// Flags holds the comparison flags, such as case and accent sensitivity.
+	Flags uint8
+	// Version is the collation version.
+	Version uint8
+	// SortID is the SQL collation sort order, zero for Windows collations.
+	SortID uint8
+}
+
+// makeColumnCollation returns the collation of character types.
+func makeColumnCollation(ti typeInfo) (ColumnCollation, bool) {
+	switch ti.TypeId {
+	case typeChar, typeVarChar, typeBigChar, typeBigVarChar, typeText,
+		typeNChar, typeNVarChar, typeNText:
+		return ColumnCollation{
+			LCID:    ti.Collation.LcidAndFlags & 0x000fffff,
+			Flags:   uint8((ti.Collation.LcidAndFlags & 0x0ff00000) >> 20),
+			Version: uint8((ti.Collation.LcidAndFlags & 0xf0000000) >> 28),
+			SortID:  ti.Collation.SortId,
+		}, true
+	}
+	return ColumnCollation{}, false
+}
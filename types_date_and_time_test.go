@@ -148,3 +148,174 @@ func TestThreeHundredthsToNanosConverter(t *testing.T) {
 		}
 	}
 }
+
+func TestSmallDateTimeMinutesConverter(t *testing.T) {
+	for want := 0; want < 24*60; want++ {
+		got := smallDateTimeMinutes(want/60, want%60, 0)
+		if want != got {
+			t.Fatalf("want = %d, got = %d", want, got)
+		}
+		tm := time.Date(2000, 1, 1, want/60, want%60, 0, 0, time.UTC)
+		if out := decodeDateTim4(encodeDateTim4(tm)); !out.Equal(tm) {
+			t.Fatalf("want = %v, got = %v", tm, out)
+		}
+	}
+}
+
+func TestSmallDateTimeRounding(t *testing.T) {
+	tests := []struct {
+		in, want time.Time
+	}{
+		{time.Date(2020, 5, 1, 10, 15, 29, 999000000, time.UTC), time.Date(2020, 5, 1, 10, 15, 0, 0, time.UTC)},
+		{time.Date(2020, 5, 1, 10, 15, 30, 0, time.UTC), time.Date(2020, 5, 1, 10, 16, 0, 0, time.UTC)},
+		{time.Date(2020, 12, 31, 23, 59, 45, 0, time.UTC), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2079, 6, 6, 23, 59, 0, 0, time.UTC), time.Date(2079, 6, 6, 23, 59, 0, 0, time.UTC)},
+		// out of range values are sent as the closest limit
+		{time.Date(2079, 6, 6, 23, 59, 30, 0, time.UTC), time.Date(2079, 6, 6, 23, 59, 0, 0, time.UTC)},
+		{time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2079, 6, 6, 23, 59, 0, 0, time.UTC)},
+		{time.Date(1899, 12, 31, 23, 59, 0, 0, time.UTC), time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if got := decodeDateTim4(encodeDateTim4(test.in)); !got.Equal(test.want) {
+			t.Errorf("%v: expected %v, got %v", test.in, test.want, got)
+		}
+	}
+
+	// the clock time of the location is kept
+	loc := time.FixedZone("UTC+5", 5*60*60)
+	in := time.Date(2020, 5, 1, 1, 2, 40, 0, loc)
+	v, err := SmallDateTime(in).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2020, 5, 1, 1, 3, 0, 0, loc); !v.(time.Time).Equal(want) {
+		t.Errorf("expected %v, got %v", want, v)
+	}
+	p, err := (&Stmt{}).makeParam(SmallDateTime(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decl := makeDecl(p.ti); decl != "smalldatetime" {
+		t.Errorf("expected a smalldatetime parameter, got %s", decl)
+	}
+	if got := decodeDateTim4(p.buffer); got != time.Date(2020, 5, 1, 1, 3, 0, 0, time.UTC) {
+		t.Errorf("expected the clock time 01:03, got %v", got)
+	}
+
+	var d SmallDateTime
+	if err = d.Scan(time.Date(2020, 5, 1, 10, 15, 30, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Time(d); got != time.Date(2020, 5, 1, 10, 16, 0, 0, time.UTC) {
+		t.Errorf("expected the scanned time to be rounded, got %v", got)
+	}
+	if err = d.Scan(nil); err == nil {
+		t.Error("expected an error for NULL")
+	}
+}
+
+func TestSmallDateTimeRoundTrip(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	for _, in := range []time.Time{
+		time.Date(2020, 5, 1, 10, 15, 29, 999000000, time.UTC),
+		time.Date(2020, 5, 1, 10, 15, 30, 0, time.UTC),
+		time.Date(2079, 6, 6, 23, 59, 0, 0, time.UTC),
+	} {
+		var got time.Time
+		var s string
+		err := conn.QueryRow("select @p1, convert(varchar(16), @p1, 120), sql_variant_property(@p1, 'BaseType')", SmallDateTime(in)).Scan(&got, &s, new(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := decodeDateTim4(encodeDateTim4(in))
+		if !got.Equal(want) || s != want.Format("2006-01-02 15:04") {
+			t.Errorf("%v: expected %v, got %v (%s)", in, want, got, s)
+		}
+	}
+}
+
+func TestDateTimeOffsetKeepsOffset(t *testing.T) {
+	zones := []*time.Location{
+		time.FixedZone("", -(3*60+30)*60),
+		time.FixedZone("", (5*60+45)*60),
+		time.FixedZone("", -14*60*60),
+		time.UTC,
+	}
+	for _, loc := range zones {
+		in := time.Date(2023, 1, 2, 23, 45, 6, 1234500, loc)
+		out := decodeDateTimeOffset(7, encodeDateTimeOffset(in, 7))
+		_, wantOffset := in.Zone()
+		_, gotOffset := out.Zone()
+		if !in.Equal(out) || wantOffset != gotOffset {
+			t.Errorf("expected %v, got %v", in, out)
+		}
+	}
+}
+
+func TestDateTimeOffsetRoundTrip(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	in := time.Date(2023, 1, 2, 23, 45, 6, 1234500, time.FixedZone("", -(3*60+30)*60))
+	var out time.Time
+	var text string
+	err := conn.QueryRow("select @p1, convert(nvarchar(40), @p1, 127)", in).Scan(&out, &text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, offset := out.Zone()
+	if !in.Equal(out) || offset != -(3*60+30)*60 {
+		t.Errorf("expected %v, got %v", in, out)
+	}
+	if want := "2023-01-02T23:45:06.0012345-03:30"; text != want {
+		t.Errorf("expected server value %s, got %s", want, text)
+	}
+}
+
+func TestDateTime2Rounding(t *testing.T) {
+	base := time.Date(2023, 5, 17, 10, 20, 30, 0, time.UTC)
+	tests := []struct {
+		ns    int
+		scale int
+		want  time.Time
+	}{
+		{123456749, 7, base.Add(123456700)},
+		{123456750, 7, base.Add(123456800)},
+		{123456789, 3, base.Add(123000000)},
+		{123500000, 3, base.Add(124000000)},
+		{999999999, 7, base.Add(time.Second)},
+		{500000000, 0, base.Add(time.Second)},
+		{499999999, 0, base},
+	}
+	for _, tt := range tests {
+		val := base.Add(time.Duration(tt.ns))
+		got := decodeDateTime2(uint8(tt.scale), encodeDateTime2(val, tt.scale))
+		if !got.Equal(tt.want) {
+			t.Errorf("scale %d: %v encoded as %v, want %v", tt.scale, val, got, tt.want)
+		}
+	}
+
+	// a value with a monotonic clock reading is rounded like the wall time
+	now := time.Now()
+	got := decodeDateTime2(7, encodeDateTime2(now, 7))
+	if want := now.Round(0).Round(100); !got.Equal(want) {
+		t.Errorf("%v encoded as %v, want %v", now, got, want)
+	}
+
+	// rounding up past the maximum date truncates instead
+	max := time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.UTC)
+	got = decodeDateTime2(7, encodeDateTime2(max, 7))
+	if want := max.Truncate(100); !got.Equal(want) {
+		t.Errorf("%v encoded as %v, want %v", max, got, want)
+	}
+
+	// a time rounded up to midnight wraps around
+	sec, ns := decodeTimeInt(7, encodeTime(23, 59, 59, 999999999, 7))
+	if sec != 0 || ns != 0 {
+		t.Errorf("expected midnight, got %d seconds %d nanoseconds", sec, ns)
+	}
+}
@@ -1,9 +1,15 @@
 package mssql
 
 import (
+	"bytes"
+	"encoding/binary"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/microsoft/go-mssqldb/internal/cp"
+	"github.com/microsoft/go-mssqldb/msdsn"
 )
 
 func TestMakeGoLangScanType(t *testing.T) {
@@ -42,6 +48,70 @@ func TestMakeGoLangScanType(t *testing.T) {
 	}
 }
 
+func TestMakeGoLangScanTypeCoreTypes(t *testing.T) {
+	var (
+		intType   = reflect.TypeOf(int64(0))
+		floatType = reflect.TypeOf(float64(0))
+		boolType  = reflect.TypeOf(true)
+		strType   = reflect.TypeOf("")
+		bytesType = reflect.TypeOf([]byte{})
+		timeType  = reflect.TypeOf(time.Time{})
+		guidType  = reflect.TypeOf(UniqueIdentifier{})
+		anyType   = reflect.TypeOf((*interface{})(nil)).Elem()
+	)
+	tests := []struct {
+		name string
+		ti   typeInfo
+		want reflect.Type
+	}{
+		{"tinyint", typeInfo{TypeId: typeIntN, Size: 1}, intType},
+		{"bigint", typeInfo{TypeId: typeIntN, Size: 8}, intType},
+		{"real", typeInfo{TypeId: typeFltN, Size: 4}, floatType},
+		{"float", typeInfo{TypeId: typeFltN, Size: 8}, floatType},
+		{"bit", typeInfo{TypeId: typeBitN, Size: 1}, boolType},
+		{"decimal", typeInfo{TypeId: typeDecimalN, Size: 17}, bytesType},
+		{"numeric", typeInfo{TypeId: typeNumericN, Size: 17}, bytesType},
+		{"legacy decimal", typeInfo{TypeId: typeDecimal, Size: 17}, bytesType},
+		{"money", typeInfo{TypeId: typeMoneyN, Size: 8}, bytesType},
+		{"smallmoney", typeInfo{TypeId: typeMoneyN, Size: 4}, bytesType},
+		{"smalldatetime", typeInfo{TypeId: typeDateTimeN, Size: 4}, timeType},
+		{"datetime", typeInfo{TypeId: typeDateTimeN, Size: 8}, timeType},
+		{"datetime2", typeInfo{TypeId: typeDateTime2N}, timeType},
+		{"datetimeoffset", typeInfo{TypeId: typeDateTimeOffsetN}, timeType},
+		{"date", typeInfo{TypeId: typeDateN}, timeType},
+		{"time", typeInfo{TypeId: typeTimeN}, timeType},
+		{"uniqueidentifier", typeInfo{TypeId: typeGuid, Size: 16}, guidType},
+		{"char", typeInfo{TypeId: typeBigChar}, strType},
+		{"varchar", typeInfo{TypeId: typeBigVarChar}, strType},
+		{"legacy char", typeInfo{TypeId: typeChar}, strType},
+		{"nchar", typeInfo{TypeId: typeNChar}, strType},
+		{"nvarchar", typeInfo{TypeId: typeNVarChar}, strType},
+		{"text", typeInfo{TypeId: typeText}, strType},
+		{"ntext", typeInfo{TypeId: typeNText}, strType},
+		{"xml", typeInfo{TypeId: typeXml}, strType},
+		{"binary", typeInfo{TypeId: typeBigBinary}, bytesType},
+		{"varbinary", typeInfo{TypeId: typeBigVarBin}, bytesType},
+		{"legacy varbinary", typeInfo{TypeId: typeVarBinary}, bytesType},
+		{"image", typeInfo{TypeId: typeImage}, bytesType},
+		{"udt", typeInfo{TypeId: typeUdt}, bytesType},
+		{"sql_variant", typeInfo{TypeId: typeVariant}, anyType},
+	}
+	for _, tt := range tests {
+		if got := makeGoLangScanType(tt.ti); got != tt.want {
+			t.Errorf("%s: expected scan type %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	// with guid conversion the driver returns the bytes in the string order
+	guid := typeInfo{TypeId: typeGuid, Size: 16}
+	if got := makeGoLangScanTypeWithEncoding(guid, msdsn.EncodeParameters{GuidConversion: true}); got != bytesType {
+		t.Errorf("expected []byte scan type with guid conversion, got %v", got)
+	}
+	if got := makeGoLangScanTypeWithEncoding(guid, msdsn.EncodeParameters{}); got != guidType {
+		t.Errorf("expected UniqueIdentifier scan type, got %v", got)
+	}
+}
+
 func TestMakeGoLangTypeName(t *testing.T) {
 	defer handlePanic(t)
 
@@ -63,6 +133,22 @@ func TestMakeGoLangTypeName(t *testing.T) {
 	}
 }
 
+func TestMakeGoLangTypeNameUdt(t *testing.T) {
+	tests := []struct {
+		udt  udtInfo
+		want string
+	}{
+		{udtInfo{DBName: "master", SchemaName: "sys", TypeName: "hierarchyid"}, "HIERARCHYID"},
+		{udtInfo{DBName: "shop", SchemaName: "dbo", TypeName: "Point"}, "DBO.POINT"},
+		{udtInfo{TypeName: "geography"}, "GEOGRAPHY"},
+	}
+	for _, tt := range tests {
+		if got := makeGoLangTypeName(typeInfo{TypeId: typeUdt, UdtInfo: tt.udt}); got != tt.want {
+			t.Errorf("expected %s for %+v, got %s", tt.want, tt.udt, got)
+		}
+	}
+}
+
 func TestMakeGoLangTypeLength(t *testing.T) {
 	defer handlePanic(t)
 
@@ -105,11 +191,17 @@ func TestMakeGoLangTypePrecisionScale(t *testing.T) {
 		{"typeDateTime", typeDateTime, false, 0, 0},
 		{"typeDateTim4", typeDateTim4, false, 0, 0},
 		{"typeBigBinary", typeBigBinary, false, 0, 0},
+		{"typeDecimalN", typeDecimalN, true, 18, 4},
+		{"typeNumericN", typeNumericN, true, 18, 4},
+		{"typeDecimal", typeDecimal, true, 18, 4},
+		{"typeDateTime2N", typeDateTime2N, true, 18, 4},
+		{"typeChar", typeChar, false, 0, 0},
+		{"typeVarBinary", typeVarBinary, false, 0, 0},
 		//TODO: Add other supported types
 	}
 
 	for _, tt := range tests {
-		prec, scale, varLen := makeGoLangTypePrecisionScale(typeInfo{TypeId: tt.typeID})
+		prec, scale, varLen := makeGoLangTypePrecisionScale(typeInfo{TypeId: tt.typeID, Prec: 18, Scale: 4})
 		if varLen != tt.typeVarLen {
 			t.Errorf("invalid type length variability returned for %s", tt.typeName)
 		}
@@ -151,3 +243,238 @@ func handlePanic(t *testing.T) {
 		t.Errorf("recovered panic")
 	}
 }
+
+func TestWritePLPReader(t *testing.T) {
+	data := bytes.Repeat([]byte{0xAB}, 20000)
+	for _, length := range []int64{-1, int64(len(data))} {
+		var buf bytes.Buffer
+		if err := writePLPReader(&buf, bytes.NewReader(data), length); err != nil {
+			t.Fatalf("length %d: %v", length, err)
+		}
+		var total uint64
+		binary.Read(&buf, binary.LittleEndian, &total)
+		if length < 0 && total != _UNKNOWN_PLP_LEN {
+			t.Errorf("expected unknown PLP length, got %d", total)
+		}
+		if length >= 0 && total != uint64(length) {
+			t.Errorf("expected PLP length %d, got %d", length, total)
+		}
+		var got []byte
+		for {
+			var chunk uint32
+			if err := binary.Read(&buf, binary.LittleEndian, &chunk); err != nil {
+				t.Fatal(err)
+			}
+			if chunk == _PLP_TERMINATOR {
+				break
+			}
+			got = append(got, buf.Next(int(chunk))...)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("length %d: streamed data does not match", length)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writePLPReader(&buf, bytes.NewReader(data), int64(len(data)+1)); err == nil {
+		t.Error("expected an error when the reader is shorter than the declared length")
+	}
+}
+
+func TestReadPLPTypeAcrossPackets(t *testing.T) {
+	value := strings.Repeat("\u00e9", 25600) + "end"
+	data := str2ucs2(value)
+	for _, length := range []uint64{uint64(len(data)), _UNKNOWN_PLP_LEN} {
+		var plp bytes.Buffer
+		binary.Write(&plp, binary.LittleEndian, length)
+		for rest := data; len(rest) > 0; {
+			n := 8000
+			if n > len(rest) {
+				n = len(rest)
+			}
+			binary.Write(&plp, binary.LittleEndian, uint32(n))
+			plp.Write(rest[:n])
+			rest = rest[n:]
+		}
+		binary.Write(&plp, binary.LittleEndian, uint32(_PLP_TERMINATOR))
+
+		// split the stream in packets smaller than the chunks
+		transport := &scriptedTransport{}
+		for stream := plp.Bytes(); len(stream) > 0; {
+			n := 4096 - 8
+			status := byte(0)
+			if n >= len(stream) {
+				n = len(stream)
+				status = 1
+			}
+			header := []byte{byte(packReply), status, 0, 0, 0, 0, 1, 0}
+			binary.BigEndian.PutUint16(header[2:], uint16(n+len(header)))
+			transport.responses.Write(header)
+			transport.responses.Write(stream[:n])
+			stream = stream[n:]
+		}
+		r := newTdsBuffer(4096, transport)
+		if _, err := r.BeginRead(); err != nil {
+			t.Fatal(err)
+		}
+		ti := typeInfo{TypeId: typeNVarChar, Size: 0xffff}
+		got, ok := readPLPType(&ti, r, nil).(string)
+		if !ok || got != value {
+			t.Errorf("length %x: expected %d characters, got %d", length, len([]rune(value)), len([]rune(got)))
+		}
+	}
+}
+
+func TestMakeColumnCollation(t *testing.T) {
+	// SQL_Latin1_General_CP1_CI_AS
+	ti := typeInfo{TypeId: typeBigVarChar, Collation: cp.Collation{LcidAndFlags: 0x00d00409, SortId: 52}}
+	got, ok := makeColumnCollation(ti)
+	want := ColumnCollation{LCID: 0x0409, Flags: 0x0d, Version: 0, SortID: 52}
+	if !ok || got != want {
+		t.Errorf("expected %+v, got %+v (ok %v)", want, got, ok)
+	}
+	if _, ok := makeColumnCollation(typeInfo{TypeId: typeInt4}); ok {
+		t.Error("expected no collation for int columns")
+	}
+}
+
+func TestReadCharUTF8(t *testing.T) {
+	// varchar(10) SQL_Latin1_General_CP1_CI_AS, sent as 0x0409 | flags,
+	// sort id 52, then the value "aé" in the code page or in UTF-8
+	typeData := []byte{10, 0, 0x09, 0x04, 0xd0, 0x00, 52}
+	newBuf := func(value []byte) *tdsBuffer {
+		data := append(append([]byte{}, typeData...), byte(len(value)), 0)
+		data = append(data, value...)
+		return &tdsBuffer{packetSize: len(data), rbuf: data, rsize: len(data)}
+	}
+	want := cp.Collation{LcidAndFlags: 0x00d00409, SortId: 52}
+
+	r := newBuf([]byte{'a', 0xe9})
+	ti := readTypeInfo(r, typeBigVarChar, nil, msdsn.EncodeParameters{})
+	if ti.Collation != want {
+		t.Errorf("expected the collation %+v, got %+v", want, ti.Collation)
+	}
+	if got := ti.Reader(&ti, r, nil); got != "aé" {
+		t.Errorf("expected the code page to be used, got %q", got)
+	}
+
+	r = newBuf([]byte("aé"))
+	ti = readTypeInfo(r, typeBigVarChar, nil, msdsn.EncodeParameters{UTF8: true})
+	if ti.Collation != want {
+		t.Errorf("expected the collation sent by the server to be kept, got %+v", ti.Collation)
+	}
+	if got := ti.Reader(&ti, r, nil); got != "aé" {
+		t.Errorf("expected UTF-8 to be decoded as is, got %q", got)
+	}
+}
+
+func TestReadDecimalAsString(t *testing.T) {
+	values := []struct {
+		scale uint8
+		data  []byte
+		want  interface{}
+	}{
+		{2, []byte{5, 0, 0x39, 0x30, 0, 0}, "-123.45"},
+		{3, []byte{5, 1, 0xb0, 0x04, 0, 0}, "1.200"},
+		{0, []byte{5, 0, 100, 0, 0, 0}, "-100"},
+		{0, []byte{5, 1, 0, 0, 0, 0}, "0"},
+		{2, []byte{0}, nil},
+	}
+	for _, v := range values {
+		ti := typeInfo{TypeId: typeDecimalN, Prec: 10, Scale: v.scale, Buffer: make([]byte, 5)}
+		r := &tdsBuffer{packetSize: len(v.data), rbuf: v.data, rsize: len(v.data)}
+		if got := readDecimalAsString(&ti, r, nil); got != v.want {
+			t.Errorf("scale %d: expected %#v, got %#v", v.scale, v.want, got)
+		}
+	}
+	ti := typeInfo{TypeId: typeNumericN}
+	if got := makeGoLangScanTypeWithEncoding(ti, msdsn.EncodeParameters{DecimalAsString: true}); got != reflect.TypeOf("") {
+		t.Errorf("expected string scan type, got %v", got)
+	}
+	if got := makeGoLangScanTypeWithEncoding(ti, msdsn.EncodeParameters{}); got != reflect.TypeOf([]byte{}) {
+		t.Errorf("expected []byte scan type, got %v", got)
+	}
+}
+
+func TestEncodeVariant(t *testing.T) {
+	col := cp.Collation{LcidAndFlags: 0x00d00409, SortId: 52}
+	values := []struct {
+		in   interface{}
+		want []byte
+	}{
+		{nil, nil},
+		{true, []byte{typeBit, 0, 1}},
+		{uint8(7), []byte{typeInt1, 0, 7}},
+		{int16(-2), []byte{typeInt2, 0, 0xfe, 0xff}},
+		{int32(258), []byte{typeInt4, 0, 2, 1, 0, 0}},
+		{int64(1), []byte{typeInt8, 0, 1, 0, 0, 0, 0, 0, 0, 0}},
+		{float32(1), []byte{typeFlt4, 0, 0, 0, 0x80, 0x3f}},
+		{"ab", []byte{typeNVarChar, 7, 0x09, 0x04, 0xd0, 0x00, 52, 4, 0, 'a', 0, 'b', 0}},
+		{[]byte{1, 2}, []byte{typeBigVarBin, 2, 2, 0, 1, 2}},
+	}
+	for _, v := range values {
+		got, err := encodeVariant(v.in, col)
+		if err != nil {
+			t.Errorf("encodeVariant(%v) failed: %v", v.in, err)
+			continue
+		}
+		if !bytes.Equal(got, v.want) {
+			t.Errorf("encodeVariant(%v): expected %v, got %v", v.in, v.want, got)
+		}
+	}
+
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	got, err := encodeVariant(tm, col)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{typeDateTimeOffsetN, 1, 7}, encodeDateTimeOffset(tm, 7)...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeVariant(time): expected %v, got %v", want, got)
+	}
+
+	for _, in := range []interface{}{struct{}{}, make([]byte, 8001), strings.Repeat("x", 4001)} {
+		if _, err := encodeVariant(in, col); err == nil {
+			t.Errorf("expected an error for %T", in)
+		}
+	}
+}
+
+func TestReadVariantTemporal(t *testing.T) {
+	east := time.FixedZone("", 5*3600+30*60)
+	west := time.FixedZone("", -8*3600)
+	variant := func(typeId byte, props []byte, data []byte) []byte {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, uint32(2+len(props)+len(data)))
+		b = append(b, typeId, byte(len(props)))
+		b = append(b, props...)
+		return append(b, data...)
+	}
+	d := time.Date(2021, 3, 4, 5, 6, 7, 123456700, time.UTC)
+	values := []struct {
+		data []byte
+		want time.Time
+	}{
+		{variant(typeDateN, nil, encodeDate(d)), time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{variant(typeTimeN, []byte{3}, encodeTime(5, 6, 7, 123456700, 3)), time.Date(1, 1, 1, 5, 6, 7, 123000000, time.UTC)},
+		{variant(typeDateTime2N, []byte{7}, encodeDateTime2(d, 7)), d},
+		{variant(typeDateTime2N, []byte{0}, encodeDateTime2(d, 0)), d.Round(time.Second)},
+		{variant(typeDateTimeOffsetN, []byte{7}, encodeDateTimeOffset(d.In(east), 7)), d.In(east)},
+		{variant(typeDateTimeOffsetN, []byte{2}, encodeDateTimeOffset(d.In(west), 2)), d.In(west).Round(10 * time.Millisecond)},
+	}
+	for _, v := range values {
+		r := &tdsBuffer{packetSize: len(v.data), rbuf: v.data, rsize: len(v.data)}
+		got, ok := readVariantType(nil, r, nil).(time.Time)
+		if !ok || !got.Equal(v.want) {
+			t.Errorf("% x: expected %v, got %v", v.data, v.want, got)
+			continue
+		}
+		_, wantOffset := v.want.Zone()
+		if _, offset := got.Zone(); offset != wantOffset {
+			t.Errorf("% x: expected the offset %d, got %d", v.data, wantOffset, offset)
+		}
+		if r.rpos != len(v.data) {
+			t.Errorf("% x: expected the value to be read to the end, stopped at %d", v.data, r.rpos)
+		}
+	}
+}
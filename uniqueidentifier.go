@@ -1,15 +1,33 @@
 package mssql
 
 import (
+	"database/sql"
 	"database/sql/driver"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 )
 
+// UniqueIdentifier is a uniqueidentifier value, its bytes are in the order
+// of its string form, as SQL Server and SSMS display it. The first three
+// groups are little-endian in the values sent and received by the server,
+// Scan and Value swap their bytes.
+//
+// Other 16 byte array types that keep the bytes in the order of the string
+// form, such as uuid.UUID of github.com/google/uuid, convert to and from
+// UniqueIdentifier directly, and ScanUniqueIdentifier scans a column into
+// them:
+//
+//	var u mssql.UniqueIdentifier
+//	err = db.QueryRow("select id from users").Scan(&u)
+//	id := uuid.UUID(u)
+//	_, err = db.Exec("delete from users where id = @p1", mssql.UniqueIdentifier(id))
 type UniqueIdentifier [16]byte
 
+// Scan implements sql.Scanner. It also accepts a 16 byte array type, whose
+// bytes are taken in the order of the string form.
 func (u *UniqueIdentifier) Scan(v interface{}) error {
 	reverse := func(b []byte) {
 		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
@@ -49,10 +67,49 @@ func (u *UniqueIdentifier) Scan(v interface{}) error {
 		_, err := hex.Decode(u[:], []byte(b))
 		return err
 	default:
+		if isUniqueIdentifierArray(reflect.TypeOf(v)) {
+			reflect.Copy(reflect.ValueOf(u[:]), reflect.ValueOf(v))
+			return nil
+		}
 		return fmt.Errorf("mssql: cannot convert %T to UniqueIdentifier", v)
 	}
 }
 
+// isUniqueIdentifierArray reports whether t is a 16 byte array type.
+func isUniqueIdentifierArray(t reflect.Type) bool {
+	return t != nil && t.Kind() == reflect.Array && t.Len() == 16 && t.Elem().Kind() == reflect.Uint8
+}
+
+// ScanUniqueIdentifier returns a sql.Scanner that stores a uniqueidentifier
+// column into dest, a pointer to a 16 byte array type such as *uuid.UUID,
+// in the byte order of the string form:
+//
+//	var id uuid.UUID
+//	err = db.QueryRow("select id from users").Scan(mssql.ScanUniqueIdentifier(&id))
+//
+// As with UniqueIdentifier, scanning NULL is an error, and the guid
+// conversion connection string parameter must not be set.
+func ScanUniqueIdentifier(dest interface{}) sql.Scanner {
+	return uniqueIdentifierScanner{dest: dest}
+}
+
+type uniqueIdentifierScanner struct {
+	dest interface{}
+}
+
+func (s uniqueIdentifierScanner) Scan(v interface{}) error {
+	rv := reflect.ValueOf(s.dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || !isUniqueIdentifierArray(rv.Type().Elem()) {
+		return fmt.Errorf("mssql: cannot scan a UniqueIdentifier into %T", s.dest)
+	}
+	var u UniqueIdentifier
+	if err := u.Scan(v); err != nil {
+		return err
+	}
+	reflect.Copy(rv.Elem(), reflect.ValueOf(u[:]))
+	return nil
+}
+
 func (u UniqueIdentifier) Value() (driver.Value, error) {
 	reverse := func(b []byte) {
 		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
@@ -6,7 +6,10 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/google/uuid"
 )
 
 func TestUniqueIdentifierScanNull(t *testing.T) {
@@ -124,3 +127,97 @@ func TestUniqueIdentifierUnmarshalJSON(t *testing.T) {
 var _ fmt.Stringer = UniqueIdentifier{}
 var _ sql.Scanner = &UniqueIdentifier{}
 var _ driver.Valuer = UniqueIdentifier{}
+
+func TestUniqueIdentifierArrayTypes(t *testing.T) {
+	t.Parallel()
+	id := uuid.MustParse("01234567-89ab-cdef-0123-456789abcdef")
+	u := UniqueIdentifier(id)
+	if !strings.EqualFold(u.String(), id.String()) {
+		t.Errorf("expected %s once converted, got %s", id, u)
+	}
+
+	var scanned UniqueIdentifier
+	if err := scanned.Scan(id); err != nil {
+		t.Fatal(err)
+	}
+	if scanned != u {
+		t.Errorf("expected %s scanned from a uuid.UUID, got %s", u, scanned)
+	}
+
+	wire, _ := u.Value()
+	var got uuid.UUID
+	if err := ScanUniqueIdentifier(&got).Scan(wire); err != nil {
+		t.Fatal(err)
+	}
+	if got != id {
+		t.Errorf("expected %s, got %s", id, got)
+	}
+
+	var short [8]byte
+	for _, dest := range []interface{}{&short, got, (*uuid.UUID)(nil)} {
+		if err := ScanUniqueIdentifier(dest).Scan(wire); err == nil {
+			t.Errorf("expected an error scanning into %T", dest)
+		}
+	}
+	if err := ScanUniqueIdentifier(&got).Scan(nil); err == nil {
+		t.Error("expected an error for NULL")
+	}
+}
+
+func TestUniqueIdentifierCastString(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	rows, err := conn.Query(`select id, cast(id as varchar(36)) from (values
+		(cast('01234567-89AB-CDEF-0123-456789ABCDEF' as uniqueidentifier)), (newid()), (newid())) t(id)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u UniqueIdentifier
+		var id uuid.UUID
+		var s string
+		if err = rows.Scan(&u, &s); err != nil {
+			t.Fatal(err)
+		}
+		if u.String() != s {
+			t.Errorf("expected %s like the server, got %s", s, u)
+		}
+		if err = ScanUniqueIdentifier(&id).Scan(mustValue(t, u)); err != nil {
+			t.Fatal(err)
+		}
+		if strings.ToUpper(id.String()) != s {
+			t.Errorf("expected the uuid.UUID %s like the server, got %s", s, id)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var id uuid.UUID
+	err = conn.QueryRow("select cast('01234567-89AB-CDEF-0123-456789ABCDEF' as uniqueidentifier)").Scan(ScanUniqueIdentifier(&id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.String() != "01234567-89ab-cdef-0123-456789abcdef" {
+		t.Errorf("unexpected uuid.UUID %s", id)
+	}
+	var s string
+	if err = conn.QueryRow("select cast(@p1 as varchar(36))", UniqueIdentifier(id)).Scan(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s != "01234567-89AB-CDEF-0123-456789ABCDEF" {
+		t.Errorf("expected the parameter to be sent in the order of the string form, got %s", s)
+	}
+}
+
+func mustValue(t *testing.T, v driver.Valuer) driver.Value {
+	t.Helper()
+	dv, err := v.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dv
+}
@@ -0,0 +1,109 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxValuesBatchParams is the number of parameters a query sent with
+	// sp_executesql may have: the server accepts 2100 parameters per
+	// request, two of them are the statement and its declarations.
+	maxValuesBatchParams = 2100 - 2
+	// maxValuesBatchRows is the number of rows a VALUES clause may have.
+	maxValuesBatchRows = 1000
+)
+
+// ValuesBatchExecer executes the statements of ValuesBatch,
+// it is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type ValuesBatchExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// ValuesBatch inserts rows into table with parameterized
+// INSERT ... VALUES (...), (...) statements, which is cheaper than a
+// statement per row for a moderate number of rows without the setup of a
+// bulk copy. The rows are split into as many statements as the parameter
+// and row limits of the server require, these are not run in a
+// transaction unless db is one. The returned count is the sum of the rows
+// affected by the statements. table is not quoted, its parts, such as
+// "dbo.users", are quoted separately in the statements.
+func ValuesBatch(ctx context.Context, db ValuesBatchExecer, table string, columns []string, rows [][]interface{}) (int64, error) {
+	if len(columns) == 0 {
+		return 0, errors.New("mssql: ValuesBatch needs at least one column")
+	}
+	if len(columns) > maxValuesBatchParams {
+		return 0, fmt.Errorf("mssql: ValuesBatch supports at most %d columns, got %d", maxValuesBatchParams, len(columns))
+	}
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return 0, fmt.Errorf("mssql: row %d has %d values, expected %d", i, len(row), len(columns))
+		}
+	}
+
+	chunkRows := maxValuesBatchParams / len(columns)
+	if chunkRows > maxValuesBatchRows {
+		chunkRows = maxValuesBatchRows
+	}
+	var total int64
+	for len(rows) > 0 {
+		n := chunkRows
+		if n > len(rows) {
+			n = len(rows)
+		}
+		query, args := valuesBatchStatement(table, columns, rows[:n])
+		res, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return total, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += affected
+		rows = rows[n:]
+	}
+	return total, nil
+}
+
+// valuesBatchStatement returns the INSERT statement for rows and its arguments.
+func valuesBatchStatement(table string, columns []string, rows [][]interface{}) (string, []interface{}) {
+	var q strings.Builder
+	q.WriteString("INSERT INTO ")
+	for i, part := range strings.Split(table, ".") {
+		if i > 0 {
+			q.WriteByte('.')
+		}
+		q.WriteString(TSQLQuoter{}.ID(part))
+	}
+	q.WriteString(" (")
+	for i, col := range columns {
+		if i > 0 {
+			q.WriteString(", ")
+		}
+		q.WriteString(TSQLQuoter{}.ID(col))
+	}
+	q.WriteString(") VALUES ")
+
+	args := make([]interface{}, 0, len(rows)*len(columns))
+	for i, row := range rows {
+		if i > 0 {
+			q.WriteString(", ")
+		}
+		q.WriteByte('(')
+		for j, v := range row {
+			if j > 0 {
+				q.WriteString(", ")
+			}
+			args = append(args, v)
+			q.WriteString("@p")
+			q.WriteString(strconv.Itoa(len(args)))
+		}
+		q.WriteByte(')')
+	}
+	return q.String(), args
+}
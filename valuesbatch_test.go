@@ -0,0 +1,115 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+)
+
+type valuesBatchRecorder struct {
+	queries []string
+	args    [][]interface{}
+}
+
+func (r *valuesBatchRecorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.queries = append(r.queries, query)
+	r.args = append(r.args, args)
+	return driver.RowsAffected(len(args) / 2), nil
+}
+
+func TestValuesBatchStatement(t *testing.T) {
+	query, args := valuesBatchStatement("dbo.t", []string{"id", "na]me"}, [][]interface{}{{1, "a"}, {2, "b"}})
+	want := "INSERT INTO [dbo].[t] ([id], [na]]me]) VALUES (@p1, @p2), (@p3, @p4)"
+	if query != want {
+		t.Errorf("expected %s, got %s", want, query)
+	}
+	if fmt.Sprint(args) != "[1 a 2 b]" {
+		t.Errorf("unexpected arguments %v", args)
+	}
+	query, _ = valuesBatchStatement("t]; drop table t; --", []string{"id"}, [][]interface{}{{1}})
+	if want = "INSERT INTO [t]]; drop table t; --] ([id]) VALUES (@p1)"; query != want {
+		t.Errorf("expected %s, got %s", want, query)
+	}
+}
+
+func TestValuesBatchChunks(t *testing.T) {
+	rows := make([][]interface{}, 5000)
+	for i := range rows {
+		rows[i] = []interface{}{i, "x"}
+	}
+	r := &valuesBatchRecorder{}
+	n, err := ValuesBatch(context.Background(), r, "t", []string{"id", "name"}, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5000 {
+		t.Errorf("expected 5000 rows affected, got %d", n)
+	}
+	// two columns fit 1049 rows into the parameters, but VALUES allows 1000
+	if len(r.queries) != 5 {
+		t.Errorf("expected 5 statements, got %d", len(r.queries))
+	}
+
+	rows = make([][]interface{}, 300)
+	for i := range rows {
+		rows[i] = make([]interface{}, 10)
+	}
+	columns := make([]string, 10)
+	for i := range columns {
+		columns[i] = fmt.Sprintf("c%d", i)
+	}
+	r = &valuesBatchRecorder{}
+	if _, err = ValuesBatch(context.Background(), r, "t", columns, rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.queries) != 2 || len(r.args[0]) != 2090 || len(r.args[1]) != 910 {
+		t.Errorf("expected chunks of 209 and 91 rows, got %d statements", len(r.queries))
+	}
+
+	if _, err = ValuesBatch(context.Background(), r, "t", []string{"id"}, [][]interface{}{{1, 2}}); err == nil {
+		t.Error("expected an error for a row with too many values")
+	}
+	if _, err = ValuesBatch(context.Background(), r, "t", nil, nil); err == nil {
+		t.Error("expected an error without columns")
+	}
+}
+
+func TestValuesBatch(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "create table #values_batch (id int not null, name nvarchar(20) null)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const count = 5000
+	rows := make([][]interface{}, count)
+	for i := range rows {
+		rows[i] = []interface{}{i, fmt.Sprintf("row %d", i)}
+	}
+	n, err := ValuesBatch(ctx, conn, "#values_batch", []string{"id", "name"}, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != count {
+		t.Errorf("expected %d rows affected, got %d", count, n)
+	}
+	var got, sum int
+	err = conn.QueryRowContext(ctx, "select count(*), sum(id) from #values_batch").Scan(&got, &sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != count || sum != count*(count-1)/2 {
+		t.Errorf("expected %d rows with id sum %d, got %d rows with sum %d", count, count*(count-1)/2, got, sum)
+	}
+}
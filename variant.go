@@ -0,0 +1,45 @@
+package mssql
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/microsoft/go-mssqldb/internal/cp"
+)
+
+// VariantValue is a parameter sent as sql_variant, see Variant.
+type VariantValue struct {
+	value interface{}
+}
+
+// Variant sends value as a sql_variant parameter holding its base type,
+// instead of the SQL type the driver maps it to, so the server converts it
+// as it does for a sql_variant column:
+//
+//	_, err = db.Exec("insert into settings (name, value) values (@p1, @p2)", name, mssql.Variant(value))
+//
+// The value may be nil, a bool, an integer, a float, a string or a []byte
+// of up to 8000 bytes, a time.Time, sent as datetimeoffset, or a
+// driver.Valuer returning one of these.
+func Variant(value interface{}) VariantValue {
+	return VariantValue{value}
+}
+
+func (s *Stmt) makeVariantParam(v VariantValue) (res param, err error) {
+	val := v.value
+	if valuer, ok := val.(driver.Valuer); ok {
+		if val, err = valuer.Value(); err != nil {
+			return
+		}
+	}
+	var col cp.Collation
+	if s.c != nil && s.c.sess != nil {
+		col = s.c.sess.collation
+	}
+	if res.buffer, err = encodeVariant(val, col); err != nil {
+		return res, fmt.Errorf("mssql: invalid Variant parameter: %v", err)
+	}
+	res.ti.TypeId = typeVariant
+	res.ti.Size = 8016 // the largest sql_variant
+	return
+}
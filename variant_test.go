@@ -0,0 +1,153 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func TestVariantParam(t *testing.T) {
+	t.Parallel()
+	p, err := (&Stmt{}).makeParam(Variant(int32(7)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = writeTypeInfo(&buf, &p.ti, false, msdsn.EncodeParameters{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{typeVariant, 0x50, 0x1f, 0, 0}; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("expected type info % x, got % x", want, buf.Bytes())
+	}
+	if decl := makeDecl(p.ti); decl != "sql_variant" {
+		t.Errorf("expected sql_variant, got %s", decl)
+	}
+	buf.Reset()
+	if err = p.ti.Writer(&buf, p.ti, p.buffer); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{6, 0, 0, 0, typeInt4, 0, 7, 0, 0, 0}; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("expected the value % x, got % x", want, buf.Bytes())
+	}
+
+	// a driver.Valuer is sent as its value, the string of a Money is an nvarchar
+	if p, err = (&Stmt{}).makeParam(Variant(Money(5))); err != nil {
+		t.Fatal(err)
+	}
+	if p.ti.TypeId != typeVariant || len(p.buffer) == 0 || p.buffer[0] != typeNVarChar {
+		t.Errorf("expected a sql_variant holding an nvarchar, got %+v", p)
+	}
+	if _, err = (&Stmt{}).makeParam(Variant(struct{}{})); err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+}
+
+func TestVariantInsert(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err = c.ExecContext(ctx, "create table #variants (id int, v sql_variant)"); err != nil {
+		t.Fatal(err)
+	}
+	values := []struct {
+		value    interface{}
+		baseType string
+	}{
+		{true, "bit"},
+		{int32(-20), "int"},
+		{int64(1) << 40, "bigint"},
+		{2.5, "float"},
+		{"héllo", "nvarchar"},
+		{[]byte{1, 2, 3}, "varbinary"},
+		{time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), "datetimeoffset"},
+		{nil, ""},
+	}
+	for i, v := range values {
+		if _, err = c.ExecContext(ctx, "insert into #variants values (@p1, @p2)", i, Variant(v.value)); err != nil {
+			t.Fatalf("%v: %v", v.value, err)
+		}
+	}
+	rows, err := c.QueryContext(ctx, "select id, v, cast(isnull(sql_variant_property(v, 'BaseType'), '') as nvarchar(20)) from #variants order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var got interface{}
+		var baseType string
+		if err = rows.Scan(&id, &got, &baseType); err != nil {
+			t.Fatal(err)
+		}
+		want := values[id]
+		if baseType != want.baseType {
+			t.Errorf("%v: expected the base type %s, got %s", want.value, want.baseType, baseType)
+		}
+		if fmt.Sprint(got) == fmt.Sprint(want.value) {
+			continue
+		}
+		if tm, ok := got.(time.Time); ok && tm.Equal(want.value.(time.Time)) {
+			continue
+		}
+		t.Errorf("expected %v, got %v", want.value, got)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVariantTemporalQuery(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	var v interface{}
+	var now time.Time
+	var offset int
+	err := db.QueryRow("select cast(sysdatetimeoffset() as sql_variant), sysdatetimeoffset(), datepart(tzoffset, sysdatetimeoffset())").Scan(&v, &now, &offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := v.(time.Time)
+	if !ok || !got.Equal(now) {
+		t.Fatalf("expected %v, got %v (%T)", now, v, v)
+	}
+	if _, gotOffset := got.Zone(); gotOffset != offset*60 {
+		t.Errorf("expected the offset of %d minutes, got %d seconds", offset, gotOffset)
+	}
+
+	values := []struct {
+		sql  string
+		want time.Time
+	}{
+		{"cast(cast('2021-03-04T05:06:07.1234567' as datetime2(7)) as sql_variant)", time.Date(2021, 3, 4, 5, 6, 7, 123456700, time.UTC)},
+		{"cast(cast('2021-03-04T05:06:07.1234567' as datetime2(2)) as sql_variant)", time.Date(2021, 3, 4, 5, 6, 7, 120000000, time.UTC)},
+		{"cast(cast('2021-03-04T05:06:07.1234567+05:30' as datetimeoffset(4)) as sql_variant)", time.Date(2021, 3, 4, 5, 6, 7, 123500000, time.FixedZone("", 5*3600+30*60))},
+		{"cast(cast('05:06:07.1234567' as time(1)) as sql_variant)", time.Date(1, 1, 1, 5, 6, 7, 100000000, time.UTC)},
+	}
+	for _, tt := range values {
+		if err = db.QueryRow("select " + tt.sql).Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := v.(time.Time)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.sql, tt.want, v)
+			continue
+		}
+		_, wantOffset := tt.want.Zone()
+		if _, gotOffset := got.Zone(); gotOffset != wantOffset {
+			t.Errorf("%s: expected the offset %d, got %d", tt.sql, wantOffset, gotOffset)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package mssql
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+type xmlStreamContextKey struct{}
+
+// XMLStreamContext returns a copy of ctx that makes queries run with it
+// stream the value of an XML column when it is the last column of the
+// result set, instead of reading the whole value before returning the row.
+// The column is then scanned into an io.Reader or a *XMLReader:
+//
+//	var r io.Reader
+//	err = rows.Scan(&id, &r)
+//
+// NULL values scan as a nil reader. The reader must be read or closed
+// before the next row, the next row is not read while the value is streamed.
+// Rows.Next closes the reader of the previous row, as does sending another
+// request on the connection while the rows are open.
+func XMLStreamContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, xmlStreamContextKey{}, true)
+}
+
+func xmlStreamFromContext(ctx context.Context) bool {
+	stream, _ := ctx.Value(xmlStreamContextKey{}).(bool)
+	return stream
+}
+
+// streamLastXMLColumn makes the last column of the result set stream its
+// values if it is an XML column.
+func streamLastXMLColumn(columns []columnStruct) {
+	if len(columns) == 0 {
+		return
+	}
+	col := &columns[len(columns)-1]
+	if col.ti.TypeId != typeXml || col.isEncrypted() {
+		return
+	}
+	col.ti.Reader = readXMLStream
+}
+
+func readXMLStream(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata) interface{} {
+	if r.uint64() == _PLP_NULL {
+		return nil
+	}
+	pr, pw := io.Pipe()
+	decoder := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder()
+	return &XMLReader{
+		r:   transform.NewReader(pr, decoder),
+		pr:  pr,
+		pw:  pw,
+		buf: r,
+	}
+}
+
+// streamedXML returns the reader of the last value of row, if it is streamed.
+func streamedXML(row []interface{}) *XMLReader {
+	if len(row) == 0 {
+		return nil
+	}
+	xr, _ := row[len(row)-1].(*XMLReader)
+	return xr
+}
+
+// closeXML closes the reader of the previous row so that the response can
+// be read further.
+func closeXML(x **XMLReader) {
+	if *x != nil {
+		(*x).Close()
+		*x = nil
+	}
+}
+
+// XMLReader streams the value of an XML column as UTF-8 text,
+// see XMLStreamContext.
+type XMLReader struct {
+	r   io.Reader
+	pr  *io.PipeReader
+	pw  *io.PipeWriter
+	buf *tdsBuffer
+}
+
+func (x *XMLReader) Read(p []byte) (int, error) {
+	return x.r.Read(p)
+}
+
+// Close discards the rest of the value.
+func (x *XMLReader) Close() error {
+	return x.pr.Close()
+}
+
+// pump copies the chunks of the value from the response to the reader until
+// the value ends, discarding them once the reader is closed. It is called by
+// the goroutine reading the response after sending the row.
+func (x *XMLReader) pump() {
+	defer func() {
+		if e := recover(); e != nil {
+			x.pw.CloseWithError(errors.New("mssql: reading the XML value failed"))
+			panic(e)
+		}
+	}()
+	var werr error
+	chunk := make([]byte, 4096)
+	for {
+		chunksize := int(x.buf.uint32())
+		if chunksize == 0 {
+			break
+		}
+		for chunksize > 0 {
+			n := len(chunk)
+			if n > chunksize {
+				n = chunksize
+			}
+			x.buf.ReadFull(chunk[:n])
+			if werr == nil {
+				_, werr = x.pw.Write(chunk[:n])
+			}
+			chunksize -= n
+		}
+	}
+	x.pw.Close()
+}
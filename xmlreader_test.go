@@ -0,0 +1,180 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// plpXML encodes the value as a PLP stream of UTF-16 chunks of the given size
+// followed by a byte that is not part of the value.
+func plpXML(value string, chunkSize int) []byte {
+	data := str2ucs2(value)
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint64(_UNKNOWN_PLP_LEN))
+	for len(data) > 0 {
+		n := chunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+		binary.Write(&b, binary.LittleEndian, uint32(n))
+		b.Write(data[:n])
+		data = data[n:]
+	}
+	binary.Write(&b, binary.LittleEndian, uint32(0))
+	b.WriteByte(0xfe)
+	return b.Bytes()
+}
+
+func TestXMLReader(t *testing.T) {
+	value := "<root>" + strings.Repeat("<a>é</a>", 2000) + "</root>"
+	for _, read := range []bool{true, false} {
+		b := plpXML(value, 1001)
+		buf := &tdsBuffer{packetSize: len(b), rbuf: b, rsize: len(b)}
+		xr, ok := readXMLStream(&typeInfo{TypeId: typeXml}, buf, nil).(*XMLReader)
+		if !ok {
+			t.Fatal("expected an XMLReader")
+		}
+		done := make(chan struct{})
+		go func() {
+			xr.pump()
+			close(done)
+		}()
+		if read {
+			got, err := io.ReadAll(xr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != value {
+				t.Errorf("expected a value of %d bytes, got %d bytes", len(value), len(got))
+			}
+		} else {
+			// the rest of the value is discarded
+			xr.Close()
+		}
+		<-done
+		if next := buf.byte(); next != 0xfe {
+			t.Errorf("expected the value to be consumed, next byte is %x", next)
+		}
+	}
+
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, _PLP_NULL)
+	buf := &tdsBuffer{packetSize: len(b), rbuf: b, rsize: len(b)}
+	if v := readXMLStream(&typeInfo{TypeId: typeXml}, buf, nil); v != nil {
+		t.Errorf("expected nil for NULL, got %v", v)
+	}
+}
+
+func TestXMLStreamQuery(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	ctx := XMLStreamContext(context.Background())
+	rows, err := conn.QueryContext(ctx, `select id, x from (values
+		(1, cast(replicate(cast(N'<a>0123456789</a>' as nvarchar(max)), 200000) as xml)),
+		(2, cast(N'<b/>' as xml)),
+		(3, cast(null as xml)),
+		(4, cast(replicate(cast(N'<c/>' as nvarchar(max)), 100000) as xml))) t(id, x) order by id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		var r io.Reader
+		if err = rows.Scan(&id, &r); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+		switch id {
+		case 1:
+			n, err := io.Copy(io.Discard, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 200000*17 {
+				t.Errorf("expected %d bytes, got %d", 200000*17, n)
+			}
+		case 2:
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "<b/>" {
+				t.Errorf("unexpected value %s", b)
+			}
+		case 3:
+			if r != nil {
+				t.Error("expected a nil reader for NULL")
+			}
+		case 4:
+			// left unread, closed by Rows.Close
+		}
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 4 {
+		t.Errorf("expected 4 rows, got %v", ids)
+	}
+}
+
+func TestXMLStreamBufferOpenRows(t *testing.T) {
+	transport := &scriptedTransport{}
+	column := append([]byte{0, 0, 0, 0, colFlagNullable, 0, typeXml, 0, 1}, str2ucs2("x")...)
+	value := plpXML("<root>"+strings.Repeat("<a/>", 20)+"</root>", 50)
+	row := append([]byte{byte(tokenRow)}, value[:len(value)-1]...)
+	transport.reply(append([]byte{byte(tokenColMetadata), 1, 0}, column...), row,
+		[]byte{byte(tokenDone), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	transport.reply([]byte{byte(tokenDone), doneCount, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0})
+	c := testConn(newConnector(msdsn.Config{}, nil), transport)
+
+	ctx := context.Background()
+	rows, err := (&Stmt{c: c, query: "select x from docs"}).queryContext(XMLStreamContext(ctx), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := make([]driver.Value, 1)
+	if err = rows.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	xr, ok := dest[0].(*XMLReader)
+	if !ok {
+		t.Fatalf("expected an XMLReader, got %T", dest[0])
+	}
+
+	// the unread value does not block the next request
+	done := make(chan error, 1)
+	go func() {
+		_, err := (&Stmt{c: c, query: "update docs set n = 1"}).exec(ctx, nil)
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request is blocked by the streamed XML value")
+	}
+	if _, err = io.ReadAll(xr); err == nil {
+		t.Error("expected the streamed value to be discarded")
+	}
+	if err = rows.Next(dest); err != io.EOF {
+		t.Errorf("expected the end of the rows, got %v", err)
+	}
+	if err = rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+}